@@ -0,0 +1,42 @@
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/ingest"
+
+	"github.com/labstack/echo/v4"
+)
+
+// BackupGet will return a backup archive of the store. The archive is written to a
+// temporary file first, so the interactions are only paused while it is written
+// (not while it is downloaded) and a failed backup is an error response.
+func BackupGet(c echo.Context) error {
+	if _, ok := client.(db.Snapshotter); !ok {
+		return c.NoContent(http.StatusNotImplemented)
+	}
+
+	f, err := ioutil.TempFile("", "engauge-backup-*.tar.gz")
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = ingest.Backup(client, f, nil)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	filename := fmt.Sprintf("engauge-%s.tar.gz", time.Now().UTC().Format("20060102T150405Z"))
+	return c.Attachment(f.Name(), filename)
+}
@@ -0,0 +1,14 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/EngaugeAI/engauge/ingest"
+
+	"github.com/labstack/echo/v4"
+)
+
+// BotsGet will return the number of detected bot interactions per reason
+func BotsGet(c echo.Context) error {
+	return c.JSON(http.StatusOK, ingest.BotStats())
+}
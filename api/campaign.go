@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/labstack/echo/v4"
+)
+
+// CampaignList will return the sessions, conversions and revenue per
+// source/medium/campaign of the current summary of an interval
+func CampaignList(c echo.Context) error {
+	interval := c.Param("id")
+	if !summaryToggle(interval) {
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	var limit, offset int
+	l := c.QueryParam("limit")
+	if l != "" {
+		i, err := strconv.Atoi(l)
+		if err != nil || i < 0 {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		limit = i
+	}
+	o := c.QueryParam("offset")
+	if o != "" {
+		i, err := strconv.Atoi(o)
+		if err != nil || i < 0 {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		offset = i
+	}
+
+	item, ok := db.SummaryCache.Load(interval)
+	if !ok {
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	summary := item.(*types.Summary)
+
+	campaigns := make([]*types.CampaignStats, 0)
+	if time.Now().Before(summary.End) {
+		channel := c.QueryParam("channel")
+		for _, stats := range summary.Campaigns() {
+			if channel != "" && stats.Channel != channel {
+				continue
+			}
+			campaigns = append(campaigns, stats)
+		}
+	}
+
+	total := len(campaigns)
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+
+	c.Response().Header().Add("x-total-count", strconv.Itoa(total))
+	return c.JSON(http.StatusOK, campaigns[offset:end])
+}
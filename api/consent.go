@@ -0,0 +1,14 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/EngaugeAI/engauge/db"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ConsentGet will return the number of interactions received per consent mode
+func ConsentGet(c echo.Context) error {
+	return c.JSON(http.StatusOK, db.ConsentCache.Stats())
+}
@@ -0,0 +1,17 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/EngaugeAI/engauge/db"
+
+	"github.com/labstack/echo/v4"
+)
+
+// FilterList will return the filter rules along with their hit counts
+func FilterList(c echo.Context) error {
+	views := db.FilterHitsCache.Views(db.GlobalSettings.Filters)
+	c.Response().Header().Add("x-total-count", strconv.Itoa(len(views)))
+	return c.JSON(http.StatusOK, views)
+}
@@ -25,6 +25,7 @@ func AttachHandlers(server *echo.Echo, dev bool) {
 	}
 
 	api.POST("/interaction", interactionPost, middleware.BodyLimit("2K"))
+	api.POST("/identify", identifyPost, middleware.BodyLimit("2K"))
 
 	dashboard := server.Group("/dashboard")
 	dashboard.Use(middleware.JWTWithConfig(middleware.JWTConfig{
@@ -38,26 +39,55 @@ func AttachHandlers(server *echo.Echo, dev bool) {
 	// summary
 	dashboard.GET("/summaries", SummaryList)
 	dashboard.GET("/summaries/:id", SummaryGet)
+	dashboard.GET("/summaries/:id/campaigns", CampaignList)
+	dashboard.GET("/summaries/:id/history", SummaryHistory)
 
 	// properties
 	dashboard.GET("/properties/:id", PropertiesGet)
 	dashboard.GET("/properties", PropertiesList)
+	dashboard.GET("/properties/:id/history", PropertiesHistory)
 
 	// endpoints
 	dashboard.GET("/endpoint", EndpointList)
 	dashboard.POST("/endpoint", EndpointPost)
 	dashboard.GET("/endpoint/:id", EndpointGet)
+	dashboard.GET("/endpoint/:id/history", EndpointHistory)
 
 	// origins
 	dashboard.GET("/origin", OriginsList)
 	dashboard.GET("/origin/:id", OriginGet)
+	dashboard.GET("/origin/:id/history", OriginHistory)
 
 	// entities
 	dashboard.GET("/entity", EntityList)
 	dashboard.GET("/entity/:id", EntityGet)
+	dashboard.GET("/entity/:id/history", EntityHistory)
+
+	// users
+	dashboard.GET("/user", UserList)
+	dashboard.GET("/user/:id", UserGet)
+	dashboard.GET("/user/:id/timeline", UserTimeline)
+
+	// query
+	dashboard.POST("/query", QueryPost)
+
+	// traffic
+	dashboard.GET("/bots", BotsGet)
+	dashboard.GET("/filters", FilterList)
+	dashboard.GET("/pipeline", PipelineGet)
+
+	// privacy
+	dashboard.GET("/consent", ConsentGet)
+	dashboard.GET("/privacy/export", PrivacyExport)
+	dashboard.POST("/privacy/erase", PrivacyErase)
+	dashboard.GET("/privacy/reports", DeletionReportList)
+	dashboard.GET("/privacy/reports/:id", DeletionReportGet)
 
 	// settings
 	dashboard.GET("/settings", SettingsList)
 	dashboard.GET("/settings/:id", SettingsGet)
 	dashboard.PUT("/settings", SettingsPut)
+
+	// backup
+	dashboard.GET("/backup", BackupGet)
 }
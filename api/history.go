@@ -0,0 +1,150 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/labstack/echo/v4"
+)
+
+// EndpointHistory --
+func EndpointHistory(c echo.Context) error {
+	return intervalStatsHistory(c, db.EndpointStats)
+}
+
+// OriginHistory --
+func OriginHistory(c echo.Context) error {
+	return intervalStatsHistory(c, db.OriginStats)
+}
+
+// EntityHistory --
+func EntityHistory(c echo.Context) error {
+	return intervalStatsHistory(c, db.EntityStats)
+}
+
+// intervalStatsHistory will return the closed periods of the `interval` stats
+// of the endpoint, origin or entity that start within `from` and `to`
+func intervalStatsHistory(c echo.Context, resource string) error {
+	where, err := historyWhere(c, resource)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	where["item.id"] = types.UUIDFromString(c.Param("id"))
+	where["item.interval"] = c.QueryParam("interval")
+
+	historyResult := client.Do(&db.Op{
+		Resource: db.History,
+		Type:     db.List,
+		Where:    where,
+	})
+	if historyResult.Error != nil {
+		c.Logger().Error(historyResult.Error)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	list := historyResult.Item.([]*types.IntervalStats)
+
+	c.Response().Header().Add("x-total-count", strconv.Itoa(len(list)))
+	return c.JSON(http.StatusOK, list)
+}
+
+// PropertiesHistory will return the closed periods of the `interval` stats
+// of the property that start within `from` and `to`
+func PropertiesHistory(c echo.Context) error {
+	where, err := historyWhere(c, db.PropertyStats)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	where["item.name"] = c.Param("id")
+	where["item.spanType"] = c.QueryParam("interval")
+
+	historyResult := client.Do(&db.Op{
+		Resource: db.History,
+		Type:     db.List,
+		Where:    where,
+	})
+	if historyResult.Error != nil {
+		c.Logger().Error(historyResult.Error)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	list := historyResult.Item.([]*types.PropertyStats)
+
+	c.Response().Header().Add("x-total-count", strconv.Itoa(len(list)))
+	return c.JSON(http.StatusOK, list)
+}
+
+// SummaryHistory will return the closed periods of the summary of the interval
+// that start within `from` and `to`
+func SummaryHistory(c echo.Context) error {
+	where, err := historyWhere(c, db.Summaries)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	where["item.spanType"] = c.Param("id")
+
+	historyResult := client.Do(&db.Op{
+		Resource: db.History,
+		Type:     db.List,
+		Where:    where,
+	})
+	if historyResult.Error != nil {
+		c.Logger().Error(historyResult.Error)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	response := make([]*types.SummaryResponse, 0)
+	for _, summary := range historyResult.Item.([]*types.Summary) {
+		response = append(response, summary.Response())
+	}
+
+	c.Response().Header().Add("x-total-count", strconv.Itoa(len(response)))
+	return c.JSON(http.StatusOK, response)
+}
+
+// historyWhere will return the where clause of the history of the resource
+// with the `from` and `to` query parameters (both optional)
+func historyWhere(c echo.Context, resource string) (db.WhereMap, error) {
+	where := db.WhereMap{
+		"item.resource": resource,
+	}
+
+	interval := c.QueryParam("interval")
+	if resource == db.Summaries {
+		interval = c.Param("id")
+	}
+	if !historyInterval(interval) {
+		return nil, types.ErrInterval
+	}
+
+	from := c.QueryParam("from")
+	if from != "" {
+		t, err := types.ParseTimestamp(from, nil, timezone)
+		if err != nil {
+			return nil, err
+		}
+		where["from"] = t
+	}
+	to := c.QueryParam("to")
+	if to != "" {
+		t, err := types.ParseTimestamp(to, nil, timezone)
+		if err != nil {
+			return nil, err
+		}
+		where["to"] = t
+	}
+
+	return where, nil
+}
+
+// historyInterval will return whether or not the periods of the interval are archived
+// (the all-time stats never end)
+func historyInterval(interval string) bool {
+	switch interval {
+	case types.Hourly, types.Daily, types.Weekly, types.Monthly, types.Quarterly, types.Yearly:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/ingest"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/labstack/echo/v4"
+)
+
+func identifyPost(c echo.Context) error {
+	var request *types.Identify
+	err := json.NewDecoder(c.Request().Body).Decode(&request)
+	if err != nil || request == nil {
+		return echo.ErrBadRequest
+	}
+
+	err = request.Validate()
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	// identities are only linked with full consent
+	dnt, gpc := privacyHeaders(c)
+	if db.GlobalSettings.Consent.Mode(request.Traits, dnt, gpc) != types.ConsentFull {
+		return c.NoContent(http.StatusOK)
+	}
+
+	kept, err := ingest.PseudonymizeIdentify(client, request)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	// requests that involve an erased user are ignored
+	if !kept {
+		return c.NoContent(http.StatusOK)
+	}
+
+	err = ingest.Identify(client, request)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -21,7 +21,11 @@ var (
 
 // Init intialized the global db client
 func Init(c db.Client, tz string) {
-	location, err := time.LoadLocation(types.DefaultTimeZone)
+	if tz == "" {
+		tz = types.DefaultTimeZone
+	}
+
+	location, err := time.LoadLocation(tz)
 	if err != nil {
 		panic(err)
 	}
@@ -2,7 +2,6 @@ package api
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 	"time"
 
@@ -13,54 +12,6 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
-var (
-	formats = []string{
-		"2006",
-		"2006-1",
-		"2006-1-2",
-		"2006-1-2 15",
-		"2006-1-2 15:4",
-		"2006-1-2 15:4:5",
-		"1-2",
-		"15:4:5",
-		"15:4",
-		"15",
-		"2006-01-02'T'15:04:05",
-		"2006-01-02T15:04:05-0700",
-		"2006 Jan 02 15:04:05",
-		"2006-01-02T15:04:05-07:00",
-		"15:4:5 Jan 2, 2006 MST",
-		"2006-01-02 15:04:05.999999999 -0700 MST",
-		"Jan 02 15:04:05 -0700 2006",
-		"02/Jan/2006:15:04:05 -0700",
-		"Jan 02 2006 15:04:05",
-		"Jan 02 15:04:05 2006",
-		"2006.1.2",
-		"2006.1.2 15:04:05",
-		"2006.01.02",
-		"2006.01.02 15:04:05",
-		"1/2/2006",
-		"1/2/2006 15:4:5",
-		"2006/01/02",
-		"2006/01/02 15:04:05",
-		time.ANSIC,
-		time.UnixDate,
-		time.RubyDate,
-		time.RFC822,
-		time.RFC822Z,
-		time.RFC850,
-		time.RFC1123,
-		time.RFC1123Z,
-		time.RFC3339,
-		time.RFC3339Nano,
-		time.Kitchen,
-		time.Stamp,
-		time.StampMilli,
-		time.StampMicro,
-		time.StampNano,
-	}
-)
-
 func interactionPost(c echo.Context) error {
 	var interaction *types.Interaction
 	err := json.NewDecoder(c.Request().Body).Decode(&interaction)
@@ -71,6 +22,8 @@ func interactionPost(c echo.Context) error {
 	// stamp
 	t := time.Now().In(timezone)
 	interaction.ReceivedAt = &t
+	interaction.UserAgent = c.Request().UserAgent()
+	interaction.IP = types.ClientIP(c.Request().RemoteAddr, c.Request().Header.Get("X-Forwarded-For"), db.GlobalSettings.TrustedProxies)
 
 	// validate
 	err = interaction.Validate()
@@ -78,41 +31,83 @@ func interactionPost(c echo.Context) error {
 		return c.String(http.StatusBadRequest, err.Error())
 	}
 
-	go func(i *types.Interaction) {
-		if i.Timestamp != nil {
-			timestamp, err := parseTimestamp(*i.Timestamp)
-			if err != nil {
-				log.Println(err)
-			}
-			i.CreatedAt = &timestamp
-		} else {
-			i.CreatedAt = i.ReceivedAt
+	// timestamp
+	apiKey := c.Request().Header.Get("api-key")
+	if interaction.Timestamp != nil {
+		format := db.GlobalSettings.Timestamps.Format(apiKey, interaction.TimestampFormat())
+		timestamp, err := types.ParseTimestamp(*interaction.Timestamp, format, timezone)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+
+		err = db.GlobalSettings.Timestamps.Check(timestamp, t)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
 		}
+		interaction.CreatedAt = &timestamp
+	} else {
+		interaction.CreatedAt = interaction.ReceivedAt
+	}
 
-		expiresAt := i.CreatedAt.Add(3 * time.Second)
-		tte := expiresAt.UTC().Sub(time.Now().UTC())
-		ingest.InteractionsCache.Add(i.String(), i, tte)
-	}(interaction)
+	// consent
+	dnt, gpc := privacyHeaders(c)
+	mode := db.GlobalSettings.Consent.Mode(interaction.Properties, dnt, gpc)
+	db.ConsentCache.Add(mode, dnt, gpc, t)
+	switch mode {
+	case types.ConsentDrop:
+		return c.NoContent(http.StatusOK)
+	case types.ConsentAnonymous:
+		interaction.Anonymize()
+	}
 
-	return c.NoContent(http.StatusOK)
-}
+	// internal and test traffic
+	rule := db.GlobalSettings.Filters.Match(interaction, apiKey)
+	if rule != nil {
+		db.FilterHitsCache.Hit(rule.ID, t)
+		if rule.Action == types.FilterExclude {
+			return c.NoContent(http.StatusOK)
+		}
+	}
 
-func parseTimestamp(ts string) (timestamp time.Time, err error) {
-	for _, frmt := range db.TimestampFormats {
-		timestamp, err = time.ParseInLocation(frmt, ts, timezone)
-		if err == nil {
-			return
+	// sampling (on the original user id, sandboxed interactions are never sampled)
+	if rule == nil || rule.Action != types.FilterSandbox {
+		sample := db.GlobalSettings.Sampling.Match(interaction)
+		if sample != nil && !sample.Sample(interaction) {
+			return c.NoContent(http.StatusOK)
 		}
 	}
 
-	for _, frmt := range formats {
-		timestamp, err = time.ParseInLocation(frmt, ts, timezone)
-		if err == nil {
-			db.TimestampFormats = append(db.TimestampFormats, frmt)
-			return
+	// pseudonymize
+	kept, err := ingest.Pseudonymize(client, interaction)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	// interactions of erased users are dropped
+	if !kept {
+		return c.NoContent(http.StatusOK)
+	}
+
+	if rule != nil && rule.Action == types.FilterSandbox {
+		err := ingest.Sandbox(client, interaction, rule.Sandbox)
+		if err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
 		}
+		return c.NoContent(http.StatusOK)
 	}
 
-	err = types.ErrTimestamp
-	return
+	expiresAt := interaction.CreatedAt.Add(ingest.InteractionDelay)
+	tte := expiresAt.UTC().Sub(time.Now().UTC())
+	ingest.InteractionsCache.Add(interaction.String(), interaction, tte)
+
+	return c.NoContent(http.StatusOK)
+}
+
+// privacyHeaders will return whether or not the request has
+// the do-not-track and the global-privacy-control headers set.
+func privacyHeaders(c echo.Context) (bool, bool) {
+	header := c.Request().Header
+	return header.Get("DNT") == "1", header.Get("Sec-GPC") == "1"
 }
@@ -0,0 +1,14 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/EngaugeAI/engauge/ingest"
+
+	"github.com/labstack/echo/v4"
+)
+
+// PipelineGet will return the counters of every stage of the ingest pipeline (in order)
+func PipelineGet(c echo.Context) error {
+	return c.JSON(http.StatusOK, ingest.PipelineStats())
+}
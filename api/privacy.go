@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/ingest"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo/v4"
+)
+
+// PrivacyExport will return all of the stored data about a user
+func PrivacyExport(c echo.Context) error {
+	subject := &types.DataSubject{
+		UserType: c.QueryParam("userType"),
+		UserID:   c.QueryParam("userID"),
+	}
+
+	err := subject.Validate()
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	export, err := ingest.Export(client, ingest.Subjects(subject.User()))
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, export)
+}
+
+// PrivacyErase will erase all of the stored data about a user
+func PrivacyErase(c echo.Context) error {
+	var subject *types.DataSubject
+	err := json.NewDecoder(c.Request().Body).Decode(&subject)
+	if err != nil || subject == nil {
+		return echo.ErrBadRequest
+	}
+
+	err = subject.Validate()
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	report, err := ingest.Erase(client, ingest.Subjects(subject.User()), "dashboard:"+username(c))
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// DeletionReportList --
+func DeletionReportList(c echo.Context) error {
+	var limit, offset *int64
+	l := c.QueryParam("limit")
+	if l != "" {
+		i, err := strconv.Atoi(l)
+		if err != nil || i < 0 {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		li := int64(i)
+		limit = &li
+	}
+	o := c.QueryParam("offset")
+	if o != "" {
+		i, err := strconv.Atoi(o)
+		if err != nil || i < 0 {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		oi := int64(i)
+		offset = &oi
+	}
+
+	countResult := client.Do(&db.Op{
+		Resource: db.DeletionReports,
+		Type:     db.Count,
+	})
+	if countResult.Error != nil {
+		c.Logger().Error(countResult.Error)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	reportsResult := client.Do(&db.Op{
+		Resource: db.DeletionReports,
+		Type:     db.List,
+		Limit:    limit,
+		Offset:   offset,
+	})
+	if reportsResult.Error != nil {
+		c.Logger().Error(reportsResult.Error)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	c.Response().Header().Add("x-total-count", strconv.FormatInt(countResult.Item.(int64), 10))
+	return c.JSON(http.StatusOK, reportsResult.Item.([]*types.DeletionReport))
+}
+
+// DeletionReportGet --
+func DeletionReportGet(c echo.Context) error {
+	id := types.UUIDFromString(c.Param("id"))
+	if id == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+
+	reportResult := client.Do(&db.Op{
+		Resource: db.DeletionReports,
+		Type:     db.Read,
+		Where: db.WhereMap{
+			"item.id": id,
+		},
+	})
+	if reportResult.Error == types.ErrDNE {
+		return c.NoContent(http.StatusNotFound)
+	} else if reportResult.Error != nil {
+		c.Logger().Error(reportResult.Error)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, reportResult.Item.(*types.DeletionReport))
+}
+
+// username will return the name of the logged in dashboard user
+func username(c echo.Context) string {
+	token, ok := c.Get("user").(*jwt.Token)
+	if !ok {
+		return ""
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+
+	name, _ := claims["username"].(string)
+	return name
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/EngaugeAI/engauge/ingest"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/labstack/echo/v4"
+)
+
+// QueryPost will run an ad-hoc query over the stored interactions
+func QueryPost(c echo.Context) error {
+	var query *types.Query
+	err := json.NewDecoder(c.Request().Body).Decode(&query)
+	if err != nil || query == nil {
+		return echo.ErrBadRequest
+	}
+
+	if query.Timezone == "" {
+		query.Timezone = timezone.String()
+	}
+
+	err = query.Validate()
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	result, err := ingest.Query(client, query)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 
 	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/ingest"
 	"github.com/EngaugeAI/engauge/types"
 
 	"github.com/labstack/echo/v4"
@@ -33,8 +34,126 @@ func SettingsPut(c echo.Context) error {
 		return echo.ErrBadRequest
 	}
 
+	// validate
+	if request.Consent != nil {
+		err := request.Consent.Validate()
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+	}
+	if request.Bots != nil {
+		err := request.Bots.Validate()
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+	}
+	if request.Filters != nil {
+		err := request.Filters.Validate()
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+	}
+	if request.Sampling != nil {
+		err := request.Sampling.Validate()
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+	}
+	if request.Goals != nil {
+		err := request.Goals.Validate()
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+	}
+	if request.Geo != nil {
+		err := request.Geo.Validate()
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+
+		err = ingest.CheckGeo(request.Geo)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+	}
+	if request.TrustedProxies != nil {
+		err := types.ValidateProxies(request.TrustedProxies)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+	}
+	if request.Pipeline != nil {
+		err := ingest.CheckPipeline(request.Pipeline)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+	}
+	if request.Retention != nil {
+		err := request.Retention.Validate()
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+	}
+	if request.Timestamps != nil {
+		err := request.Timestamps.Validate()
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+	}
+
 	db.GlobalSettings.StatsToggles = request.StatsToggles
 	db.GlobalSettings.InteractionsStorage = request.InteractionsStorage
+	if request.Timestamps != nil {
+		db.GlobalSettings.Timestamps = request.Timestamps
+	}
+	if request.Consent != nil {
+		db.GlobalSettings.Consent = request.Consent
+	}
+	if request.Bots != nil {
+		db.GlobalSettings.Bots = request.Bots
+	}
+	if request.Filters != nil {
+		db.GlobalSettings.Filters = request.Filters
+	}
+	if request.Sampling != nil {
+		db.GlobalSettings.Sampling = request.Sampling
+	}
+	if request.Goals != nil {
+		db.GlobalSettings.Goals = request.Goals
+		types.SetGoals(request.Goals)
+	}
+	if request.UserAgents != nil {
+		db.GlobalSettings.UserAgents = request.UserAgents
+	}
+	if request.Geo != nil {
+		db.GlobalSettings.Geo = request.Geo
+	}
+	if request.Campaigns != nil {
+		db.GlobalSettings.Campaigns = request.Campaigns
+	}
+	if request.TrustedProxies != nil {
+		db.GlobalSettings.TrustedProxies = request.TrustedProxies
+	}
+	if request.Retention != nil {
+		db.GlobalSettings.Retention = request.Retention
+	}
+	if request.Pseudonyms != nil {
+		ingest.SetPseudonyms(request.Pseudonyms)
+	}
+
+	if request.Geo != nil {
+		err := ingest.ReloadGeo()
+		if err != nil {
+			c.Logger().Error(err)
+		}
+	}
+	if request.Pipeline != nil {
+		err := ingest.SetPipeline(request.Pipeline)
+		if err != nil {
+			c.Logger().Error(err)
+		}
+		db.GlobalSettings.Pipeline = request.Pipeline
+	}
 
 	// update in db
 	campaignUpdate := client.Do(&db.Op{
@@ -30,32 +30,7 @@ func SummaryGet(c echo.Context) error {
 	interval := c.Param("id")
 
 	// check toggle
-	switch interval {
-	case types.Hourly:
-		if !db.GlobalSettings.StatsToggles.Hourly {
-			return c.NoContent(http.StatusBadRequest)
-		}
-	case types.Daily:
-		if !db.GlobalSettings.StatsToggles.Daily {
-			return c.NoContent(http.StatusBadRequest)
-		}
-	case types.Weekly:
-		if !db.GlobalSettings.StatsToggles.Weekly {
-			return c.NoContent(http.StatusBadRequest)
-		}
-	case types.Monthly:
-		if !db.GlobalSettings.StatsToggles.Monthly {
-			return c.NoContent(http.StatusBadRequest)
-		}
-	case types.Quarterly:
-		if !db.GlobalSettings.StatsToggles.Quarterly {
-			return c.NoContent(http.StatusBadRequest)
-		}
-	case types.Yearly:
-		if !db.GlobalSettings.StatsToggles.Yearly {
-			return c.NoContent(http.StatusBadRequest)
-		}
-	default:
+	if !summaryToggle(interval) {
 		return c.NoContent(http.StatusBadRequest)
 	}
 
@@ -65,12 +40,83 @@ func SummaryGet(c echo.Context) error {
 	}
 	summary := item.(*types.Summary)
 
-	if time.Now().After(summary.End) {
-		response := &types.SummaryResponse{
-			ID: summary.Interval,
-		}
-		return c.JSON(http.StatusOK, response)
+	// without interactions since the summary ended, the current period is empty
+	current := summary
+	now := time.Now().In(summary.Start.Location())
+	if now.After(summary.End) {
+		start, end := types.PeriodSpan(interval, now)
+		current = types.NewEmptySummary(interval, start, end)
+	}
+	response := current.Response()
+
+	previousStart, _ := types.PeriodSpan(interval, current.Start.Add(-time.Nanosecond))
+	previous, err := periodSummary(interval, previousStart, summary)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	if previous != nil {
+		response.Previous = current.Compare(previous)
+	}
+
+	lastYearStart, _ := types.PeriodSpan(interval, current.Start.AddDate(-1, 0, 0))
+	lastYear, err := periodSummary(interval, lastYearStart, summary)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	if lastYear != nil {
+		response.LastYear = current.Compare(lastYear)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// periodSummary will return the summary of the interval that starts at the time: the
+// (expired) summary of the cache or an archived summary (nil if there is none)
+func periodSummary(interval string, start time.Time, cached *types.Summary) (*types.Summary, error) {
+	if cached.Start.Equal(start) {
+		return cached, nil
+	}
+
+	historyResult := client.Do(&db.Op{
+		Resource: db.History,
+		Type:     db.List,
+		Where: db.WhereMap{
+			"item.resource": db.Summaries,
+			"item.spanType": interval,
+			"from":          start,
+			"to":            start.Add(time.Nanosecond),
+		},
+	})
+	if historyResult.Error != nil {
+		return nil, historyResult.Error
+	}
+
+	list := historyResult.Item.([]*types.Summary)
+	if len(list) == 0 {
+		return nil, nil
+	}
+
+	return list[len(list)-1], nil
+}
+
+// summaryToggle will return whether or not the summary of the interval is toggled on
+func summaryToggle(interval string) bool {
+	switch interval {
+	case types.Hourly:
+		return db.GlobalSettings.StatsToggles.Hourly
+	case types.Daily:
+		return db.GlobalSettings.StatsToggles.Daily
+	case types.Weekly:
+		return db.GlobalSettings.StatsToggles.Weekly
+	case types.Monthly:
+		return db.GlobalSettings.StatsToggles.Monthly
+	case types.Quarterly:
+		return db.GlobalSettings.StatsToggles.Quarterly
+	case types.Yearly:
+		return db.GlobalSettings.StatsToggles.Yearly
 	}
 
-	return c.JSON(http.StatusOK, summary.Response())
+	return false
 }
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/labstack/echo/v4"
+)
+
+// UserList --
+func UserList(c echo.Context) error {
+	var limit, offset int
+	l := c.QueryParam("limit")
+	if l != "" {
+		i, err := strconv.Atoi(l)
+		if err != nil || i < 0 {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		limit = i
+	}
+	o := c.QueryParam("offset")
+	if o != "" {
+		i, err := strconv.Atoi(o)
+		if err != nil || i < 0 {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		offset = i
+	}
+
+	descending := c.QueryParam("order") != "asc"
+	profiles, err := db.UsersCache.Sorted(c.QueryParam("sort"), descending)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	// bots are only listed when asked for (userType=bot)
+	userType := c.QueryParam("userType")
+	filtered := make([]*types.UserProfile, 0, len(profiles))
+	for _, profile := range profiles {
+		if userType != "" && profile.UserType != userType {
+			continue
+		}
+		if userType == "" && profile.UserType == types.UserTypeBot {
+			continue
+		}
+		filtered = append(filtered, profile)
+	}
+	profiles = filtered
+
+	total := len(profiles)
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+
+	listView := make([]*types.UserProfileListView, 0)
+	for _, profile := range profiles[offset:end] {
+		listView = append(listView, profile.ListView())
+	}
+
+	c.Response().Header().Add("x-total-count", strconv.Itoa(total))
+	return c.JSON(http.StatusOK, listView)
+}
+
+// UserGet --
+func UserGet(c echo.Context) error {
+	id := c.Param("id")
+
+	profile, err := db.UsersCache.Get(id)
+	if err == types.ErrDNE {
+		return c.NoContent(http.StatusNotFound)
+	} else if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, profile)
+}
+
+// UserTimeline --
+func UserTimeline(c echo.Context) error {
+	id := c.Param("id")
+
+	profile, err := db.UsersCache.Get(id)
+	if err == types.ErrDNE {
+		return c.NoContent(http.StatusNotFound)
+	} else if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	var limit, offset *int64
+	l := c.QueryParam("limit")
+	if l != "" {
+		i, err := strconv.Atoi(l)
+		if err != nil || i < 0 {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		li := int64(i)
+		limit = &li
+	}
+	o := c.QueryParam("offset")
+	if o != "" {
+		i, err := strconv.Atoi(o)
+		if err != nil || i < 0 {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		oi := int64(i)
+		offset = &oi
+	}
+
+	// include the interactions of every user linked to the profile user
+	where := db.WhereMap{
+		"item.user": db.IdentitiesCache.Users(profile.User()),
+	}
+
+	from := c.QueryParam("from")
+	if from != "" {
+		t, err := types.ParseTimestamp(from, nil, timezone)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+		where["from"] = t
+	}
+	to := c.QueryParam("to")
+	if to != "" {
+		t, err := types.ParseTimestamp(to, nil, timezone)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+		where["to"] = t
+	}
+
+	countResult := client.Do(&db.Op{
+		Resource: db.Interactions,
+		Type:     db.Count,
+		Where:    where,
+	})
+	if countResult.Error != nil {
+		c.Logger().Error(countResult.Error)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	interactionsResult := client.Do(&db.Op{
+		Resource: db.Interactions,
+		Type:     db.List,
+		Where:    where,
+		Limit:    limit,
+		Offset:   offset,
+	})
+	if interactionsResult.Error != nil {
+		c.Logger().Error(interactionsResult.Error)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	timeline := types.NewTimeline(profile.User(), interactionsResult.Item.([]*types.Interaction))
+
+	c.Response().Header().Add("x-total-count", strconv.FormatInt(countResult.Item.(int64), 10))
+	return c.JSON(http.StatusOK, timeline)
+}
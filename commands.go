@@ -0,0 +1,456 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/db/kv"
+	"github.com/EngaugeAI/engauge/db/local"
+	"github.com/EngaugeAI/engauge/ingest"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+// command will run a maintenance subcommand. Subcommands operate directly
+// on the data in the basepath, so the server should not be running.
+func command(client db.Client, name string, args []string) error {
+	switch name {
+	case "export":
+		return exportCommand(client, args)
+	case "erase":
+		return eraseCommand(client, args)
+	case "csv":
+		return csvCommand(client, args)
+	case "backup":
+		return backupCommand(client, args)
+	}
+
+	return fmt.Errorf("unknown command %q (expected export, erase, csv, backup, restore or migrate)", name)
+}
+
+// exportCommand will write all of the stored data about a user as JSON.
+//
+//	engauge export -type customer -id c9449105 [-out export.json]
+func exportCommand(client db.Client, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	subject := subjectFlags(flags)
+	out := flags.String("out", "", "output file (default stdout)")
+	flags.Parse(args)
+
+	err := subject.Validate()
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	export, err := ingest.Export(client, ingest.Subjects(subject.User()))
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return errors.New(err, map[string]interface{}{
+				"file": *out,
+			})
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return writeJSON(w, export)
+}
+
+// eraseCommand will erase all of the stored data about a user
+// and write the deletion report as JSON.
+//
+//	engauge erase -type customer -id c9449105
+func eraseCommand(client db.Client, args []string) error {
+	flags := flag.NewFlagSet("erase", flag.ExitOnError)
+	subject := subjectFlags(flags)
+	flags.Parse(args)
+
+	err := subject.Validate()
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	report, err := ingest.Erase(client, ingest.Subjects(subject.User()), "cli")
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	return writeJSON(os.Stdout, report)
+}
+
+// interactionScanner is implemented by the stores that can scan all of their interactions
+type interactionScanner interface {
+	ScanInteractions(fn func(sandbox string, interaction *types.Interaction) error) error
+}
+
+// csvCommand will write the stored interactions as CSV records (the columns of the
+// CSV partitions that were used before the columnar storage).
+//
+//	engauge csv [-from 2021-01-01T00:00:00Z] [-to 2021-02-01T00:00:00Z] [-sandbox name] [-out interactions.csv]
+func csvCommand(client db.Client, args []string) error {
+	flags := flag.NewFlagSet("csv", flag.ExitOnError)
+	from := flags.String("from", "", "only the interactions created at or after this time (RFC 3339)")
+	to := flags.String("to", "", "only the interactions created before this time (RFC 3339)")
+	sandbox := flags.String("sandbox", "", "export the interactions of this sandbox instead")
+	out := flags.String("out", "", "output file (default stdout)")
+	flags.Parse(args)
+
+	scanner, ok := client.(interactionScanner)
+	if !ok {
+		return fmt.Errorf("the store can not export its interactions")
+	}
+
+	var start, end time.Time
+	var err error
+	if *from != "" {
+		start, err = time.Parse(time.RFC3339, *from)
+		if err != nil {
+			return errors.New(err, map[string]interface{}{
+				"from": *from,
+			})
+		}
+	}
+	if *to != "" {
+		end, err = time.Parse(time.RFC3339, *to)
+		if err != nil {
+			return errors.New(err, map[string]interface{}{
+				"to": *to,
+			})
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return errors.New(err, map[string]interface{}{
+				"file": *out,
+			})
+		}
+		defer f.Close()
+		w = f
+	}
+
+	records := csv.NewWriter(w)
+	err = scanner.ScanInteractions(func(name string, interaction *types.Interaction) error {
+		if name != *sandbox || interaction.CreatedAt == nil {
+			return nil
+		}
+		if !start.IsZero() && interaction.CreatedAt.Before(start) {
+			return nil
+		}
+		if !end.IsZero() && !interaction.CreatedAt.Before(end) {
+			return nil
+		}
+
+		return records.Write(interaction.CSV())
+	})
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	records.Flush()
+	return records.Error()
+}
+
+// backupCommand will write a backup archive of the store and its manifest as JSON.
+// With a base (full) backup, the partitions that are unchanged since the base are
+// left out of the archive.
+//
+//	engauge backup -out backup.tar.gz [-base previous.tar.gz]
+func backupCommand(client db.Client, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	out := flags.String("out", "", "output file")
+	base := flags.String("base", "", "a full backup that the backup is incremental to")
+	flags.Parse(args)
+
+	if *out == "" {
+		return fmt.Errorf("missing -out file")
+	}
+
+	var baseManifest *db.Manifest
+	if *base != "" {
+		var err error
+		baseManifest, err = db.VerifyBackup(*base)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+	}
+
+	// the archive is written next to the output file first (it could be inside the basepath)
+	temp := *out + ".tmp"
+	f, err := os.Create(temp)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"file": temp,
+		})
+	}
+
+	m, err := ingest.Backup(client, f, baseManifest)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp, *out)
+	}
+	if err != nil {
+		os.Remove(temp)
+		return errors.New(err, map[string]interface{}{
+			"file": *out,
+		})
+	}
+
+	return writeJSON(os.Stdout, m)
+}
+
+// restoreCommand will verify a backup archive (and its base archive) and restore
+// it into the basepath, which must be missing or empty, and write its manifest as JSON.
+//
+//	engauge restore -in backup.tar.gz [-base previous.tar.gz] [-to <basepath>] [-verify]
+func restoreCommand(store, basepath string, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	in := flags.String("in", "", "backup file")
+	base := flags.String("base", "", "the base backup of an incremental backup")
+	to := flags.String("to", basepath, "data directory to restore into")
+	verify := flags.Bool("verify", false, "only verify the backup")
+	flags.Parse(args)
+
+	if *in == "" {
+		return fmt.Errorf("missing -in file")
+	}
+	if store == "" {
+		store = "local"
+	}
+
+	var m *db.Manifest
+	var err error
+	if *verify {
+		m, err = db.VerifyBackup(*in)
+	} else {
+		m, err = db.RestoreBackup(*in, *base, store, *to)
+	}
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	return writeJSON(os.Stdout, m)
+}
+
+// migrateCommand will copy the data of a local (directory) store into a new kv store
+// and write the number of migrated items per resource as JSON.
+//
+//	engauge migrate [-from <basepath>] [-to <basepath>/engauge.db]
+func migrateCommand(basepath string, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := flags.String("from", basepath, "data directory of the local store")
+	to := flags.String("to", "", "file of the kv store (default <from>/engauge.db)")
+	flags.Parse(args)
+
+	if *to == "" {
+		*to = filepath.Join(*from, kv.Filename)
+	}
+
+	// migrating twice would duplicate the interactions
+	if _, err := os.Stat(*to); err == nil {
+		return fmt.Errorf("%s already exists", *to)
+	}
+
+	src, err := local.NewClient(*from)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	dest, err := kv.Open(*to)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+	defer dest.Close()
+
+	m := &migration{
+		dest:   dest,
+		counts: make(map[string]int64),
+	}
+
+	for _, resource := range migratedLists {
+		result := src.Do(&db.Op{
+			Resource: resource,
+			Type:     db.List,
+		})
+		if result.Error != nil {
+			return errors.New(result.Error, map[string]interface{}{
+				"resource": resource,
+			})
+		}
+
+		list := reflect.ValueOf(result.Item)
+		for i := 0; i < list.Len(); i++ {
+			err := m.add(resource, list.Index(i).Interface(), nil)
+			if err != nil {
+				return errors.New(err, nil)
+			}
+		}
+	}
+
+	for _, resource := range []string{db.Settings, db.Consent, db.FilterHits} {
+		result := src.Do(&db.Op{
+			Resource: resource,
+			Type:     db.Read,
+		})
+		if result.Error == types.ErrDNE {
+			continue
+		} else if result.Error != nil {
+			return errors.New(result.Error, map[string]interface{}{
+				"resource": resource,
+			})
+		}
+
+		err := m.add(resource, result.Item, nil)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+	}
+
+	err = src.ScanInteractions(func(sandbox string, interaction *types.Interaction) error {
+		if sandbox != "" {
+			return m.add(db.Sandbox, interaction, db.WhereMap{
+				"item.sandbox": sandbox,
+			})
+		}
+
+		return m.add(db.Interactions, interaction, nil)
+	})
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	// the archived partitions keep their path in the archive bucket
+	err = src.ScanArchive(func(path string, interaction *types.Interaction) error {
+		return m.add(db.Archive, interaction, db.WhereMap{
+			"item.path": path,
+		})
+	})
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	err = src.ScanHistory(func(resource string, period types.Period) error {
+		return m.add(db.History, period, db.WhereMap{
+			"item.resource": resource,
+		})
+	})
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	err = m.flush()
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	return writeJSON(os.Stdout, m.counts)
+}
+
+// the resources that are migrated with a list of all of their items
+var migratedLists = []string{
+	db.Endpoints,
+	db.EndpointStats,
+	db.Origins,
+	db.OriginStats,
+	db.Entities,
+	db.EntityStats,
+	db.Properties,
+	db.PropertyStats,
+	db.Summaries,
+	db.Identities,
+	db.Users,
+	db.Tombstones,
+	db.DeletionReports,
+}
+
+// migrationBatch is the number of items that are committed in a single transaction
+const migrationBatch = 1000
+
+type migration struct {
+	dest   db.Client
+	ops    []*db.Op
+	counts map[string]int64
+}
+
+func (m *migration) add(resource string, item interface{}, where db.Where) error {
+	m.ops = append(m.ops, &db.Op{
+		Resource: resource,
+		Type:     db.Create,
+		Where:    where,
+		Item:     item,
+	})
+	m.counts[resource]++
+
+	if len(m.ops) < migrationBatch {
+		return nil
+	}
+
+	return m.flush()
+}
+
+func (m *migration) flush() error {
+	ops := m.ops
+	m.ops = nil
+
+	tx, err := m.dest.Begin()
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	for _, op := range ops {
+		result := tx.Do(op)
+		if result.Error != nil {
+			tx.Rollback()
+			return errors.New(result.Error, map[string]interface{}{
+				"resource": op.Resource,
+			})
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	return nil
+}
+
+func subjectFlags(flags *flag.FlagSet) *types.DataSubject {
+	subject := &types.DataSubject{}
+	flags.StringVar(&subject.UserType, "type", "", "user type")
+	flags.StringVar(&subject.UserID, "id", "", "user id")
+	return subject
+}
+
+func writeJSON(w io.Writer, item interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(item)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	return nil
+}
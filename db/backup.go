@@ -0,0 +1,481 @@
+package db
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+// BackupFormat is the version of the layout of the backup archives
+const BackupFormat = 1
+
+const (
+	manifestName = "manifest.json"
+	dataDir      = "data/"
+)
+
+// Snapshotter is implemented by the stores that can take a consistent snapshot of their files
+type Snapshotter interface {
+	Store() string // the name of the store (local or kv)
+	Snapshot(fn func(file *SnapshotFile) error) error
+}
+
+// SnapshotFile is a file of a snapshot. No file of the store is written
+// until the snapshot function returns, so it can be opened more than once.
+type SnapshotFile struct {
+	Name      string // relative to the basepath (with forward slashes)
+	Size      int64
+	Partition bool // a partition of raw interactions (it is only appended to or removed)
+	Open      func() (io.ReadCloser, error)
+}
+
+// Manifest describes the files of a backup archive (the last entry of the archive)
+type Manifest struct {
+	ID        string          `json:"id"`
+	Format    int             `json:"format"`
+	Store     string          `json:"store"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Base      string          `json:"base,omitempty"` // the backup that holds the unchanged partitions
+	Files     []*ManifestFile `json:"files"`
+}
+
+// ManifestFile --
+type ManifestFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	Base   bool   `json:"base,omitempty"` // unchanged since the base backup (not in the archive)
+}
+
+// WriteBackup will write a snapshot of the store as a compressed archive. The
+// partitions that are unchanged since the (optional) base backup are only listed
+// in the manifest, so restoring the archive requires the base archive as well.
+func WriteBackup(w io.Writer, s Snapshotter, base *Manifest) (*Manifest, error) {
+	m := &Manifest{
+		ID:        types.NewUUID().String(),
+		Format:    BackupFormat,
+		Store:     s.Store(),
+		CreatedAt: time.Now().UTC(),
+		Files:     make([]*ManifestFile, 0),
+	}
+
+	baseFiles := make(map[string]*ManifestFile)
+	if base != nil {
+		// the base must hold all of its files (incremental backups are not chained)
+		if base.Store != m.Store || base.Base != "" {
+			return nil, errors.New(types.ErrBackup, map[string]interface{}{
+				"base":  base.ID,
+				"store": base.Store,
+			})
+		}
+
+		m.Base = base.ID
+		for _, file := range base.Files {
+			baseFiles[file.Name] = file
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	err := s.Snapshot(func(file *SnapshotFile) error {
+		if previous, ok := baseFiles[file.Name]; ok && file.Partition && previous.Size == file.Size {
+			sum, err := fileChecksum(file)
+			if err != nil {
+				return errors.New(err, nil)
+			}
+
+			if sum == previous.SHA256 {
+				m.Files = append(m.Files, &ManifestFile{
+					Name:   file.Name,
+					Size:   file.Size,
+					SHA256: sum,
+					Base:   true,
+				})
+				return nil
+			}
+		}
+
+		err := tw.WriteHeader(&tar.Header{
+			Name:    dataDir + file.Name,
+			Mode:    0644,
+			Size:    file.Size,
+			ModTime: m.CreatedAt,
+		})
+		if err != nil {
+			return errors.New(err, map[string]interface{}{
+				"file": file.Name,
+			})
+		}
+
+		r, err := file.Open()
+		if err != nil {
+			return errors.New(err, map[string]interface{}{
+				"file": file.Name,
+			})
+		}
+		defer r.Close()
+
+		h := sha256.New()
+		n, err := io.Copy(io.MultiWriter(tw, h), r)
+		if err != nil {
+			return errors.New(err, map[string]interface{}{
+				"file": file.Name,
+			})
+		}
+		if n != file.Size {
+			return errors.New(types.ErrLength, map[string]interface{}{
+				"file": file.Name,
+			})
+		}
+
+		m.Files = append(m.Files, &ManifestFile{
+			Name:   file.Name,
+			Size:   file.Size,
+			SHA256: hex.EncodeToString(h.Sum(nil)),
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:    manifestName,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: m.CreatedAt,
+	})
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	_, err = tw.Write(data)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	err = gz.Close()
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	return m, nil
+}
+
+func fileChecksum(file *SnapshotFile) (string, error) {
+	r, err := file.Open()
+	if err != nil {
+		return "", errors.New(err, map[string]interface{}{
+			"file": file.Name,
+		})
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, r)
+	if err != nil {
+		return "", errors.New(err, map[string]interface{}{
+			"file": file.Name,
+		})
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ReadBackup will read the archive and return its manifest once the checksum of every
+// file of the archive was verified. The (optional) function is called with every file
+// as it is read, so the files it was called with are only valid if there is no error.
+func ReadBackup(r io.Reader, fn func(name string, r io.Reader) error) (*Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.New(types.ErrBackup, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	defer gz.Close()
+
+	var m *Manifest
+	sums := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.New(types.ErrBackup, map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+
+		if header.Name == manifestName {
+			err = json.NewDecoder(tr).Decode(&m)
+			if err != nil {
+				return nil, errors.New(types.ErrBackup, map[string]interface{}{
+					"error": err.Error(),
+				})
+			}
+			continue
+		}
+
+		name := strings.TrimPrefix(header.Name, dataDir)
+		if name == header.Name || !validBackupName(name) {
+			return nil, errors.New(types.ErrBackup, map[string]interface{}{
+				"file": header.Name,
+			})
+		}
+
+		h := sha256.New()
+		var data io.Reader = io.TeeReader(tr, h)
+		if fn != nil {
+			err = fn(name, data)
+			if err != nil {
+				return nil, errors.New(err, map[string]interface{}{
+					"file": name,
+				})
+			}
+		}
+
+		// the rest of the file that the function did not read
+		_, err = io.Copy(ioutil.Discard, data)
+		if err != nil {
+			return nil, errors.New(types.ErrBackup, map[string]interface{}{
+				"file":  name,
+				"error": err.Error(),
+			})
+		}
+
+		sums[name] = hex.EncodeToString(h.Sum(nil))
+	}
+
+	if m == nil {
+		return nil, errors.New(types.ErrBackup, map[string]interface{}{
+			"error": "missing manifest",
+		})
+	}
+	if m.Format < 1 || m.Format > BackupFormat {
+		return nil, errors.New(types.ErrBackup, map[string]interface{}{
+			"format": m.Format,
+		})
+	}
+
+	for _, file := range m.Files {
+		if !validBackupName(file.Name) {
+			return nil, errors.New(types.ErrBackup, map[string]interface{}{
+				"file": file.Name,
+			})
+		}
+		if file.Base {
+			continue
+		}
+
+		sum, ok := sums[file.Name]
+		if !ok {
+			return nil, errors.New(types.ErrBackup, map[string]interface{}{
+				"file":  file.Name,
+				"error": "missing file",
+			})
+		}
+		if sum != file.SHA256 {
+			return nil, errors.New(types.ErrChecksum, map[string]interface{}{
+				"file": file.Name,
+			})
+		}
+		delete(sums, file.Name)
+	}
+
+	for name := range sums {
+		return nil, errors.New(types.ErrBackup, map[string]interface{}{
+			"file":  name,
+			"error": "not in the manifest",
+		})
+	}
+
+	return m, nil
+}
+
+// validBackupName will return whether or not the name is a path inside the basepath
+func validBackupName(name string) bool {
+	return name != "" && !path.IsAbs(name) && path.Clean(name) == name && name != ".." && !strings.HasPrefix(name, "../")
+}
+
+// VerifyBackup will verify the checksums of the archive file and return its manifest
+func VerifyBackup(filename string) (*Manifest, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.New(err, map[string]interface{}{
+			"file": filename,
+		})
+	}
+	defer f.Close()
+
+	m, err := ReadBackup(f, nil)
+	if err != nil {
+		return nil, errors.New(err, map[string]interface{}{
+			"file": filename,
+		})
+	}
+
+	return m, nil
+}
+
+// RestoreBackup will restore the archive file (and the partitions of its base archive
+// file) of the store into the basepath, which must be missing or empty. Both archives
+// are verified before any file is written, and the files are extracted next to the
+// basepath first, so a failed restore leaves nothing behind.
+func RestoreBackup(filename, base, store, basepath string) (*Manifest, error) {
+	m, err := VerifyBackup(filename)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	if m.Store != store {
+		return nil, errors.New(types.ErrBackup, map[string]interface{}{
+			"store":  store,
+			"backup": m.Store,
+		})
+	}
+
+	baseFiles := make(map[string]bool)
+	if m.Base != "" {
+		if base == "" {
+			return nil, errors.New(types.ErrBackup, map[string]interface{}{
+				"error": "incremental backup without its base",
+				"base":  m.Base,
+			})
+		}
+
+		bm, err := VerifyBackup(base)
+		if err != nil {
+			return nil, errors.New(err, nil)
+		}
+		if bm.ID != m.Base {
+			return nil, errors.New(types.ErrBackup, map[string]interface{}{
+				"base":     m.Base,
+				"baseFile": bm.ID,
+			})
+		}
+
+		sums := make(map[string]string)
+		for _, file := range bm.Files {
+			sums[file.Name] = file.SHA256
+		}
+		for _, file := range m.Files {
+			if !file.Base {
+				continue
+			}
+			if sums[file.Name] != file.SHA256 {
+				return nil, errors.New(types.ErrChecksum, map[string]interface{}{
+					"file": file.Name,
+					"base": base,
+				})
+			}
+			baseFiles[file.Name] = true
+		}
+	}
+
+	names, err := ioutil.ReadDir(basepath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.New(err, map[string]interface{}{
+			"basepath": basepath,
+		})
+	}
+	if len(names) > 0 {
+		return nil, errors.New(types.ErrExists, map[string]interface{}{
+			"basepath": basepath,
+		})
+	}
+
+	dir := filepath.Clean(basepath) + ".restore"
+	if _, err := os.Stat(dir); err == nil {
+		return nil, errors.New(types.ErrExists, map[string]interface{}{
+			"dir": dir,
+		})
+	}
+
+	err = restoreFiles(filename, dir, nil)
+	if err == nil && len(baseFiles) > 0 {
+		err = restoreFiles(base, dir, baseFiles)
+	}
+	if err == nil {
+		// the basepath is empty
+		os.Remove(basepath)
+		err = os.Rename(dir, basepath)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, errors.New(err, map[string]interface{}{
+			"basepath": basepath,
+		})
+	}
+
+	return m, nil
+}
+
+// restoreFiles will extract the files of the archive file (only the
+// files of the set, if there is one) into the directory
+func restoreFiles(filename, dir string, set map[string]bool) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"file": filename,
+		})
+	}
+	defer f.Close()
+
+	_, err = ReadBackup(f, func(name string, r io.Reader) error {
+		if set != nil && !set[name] {
+			return nil
+		}
+
+		dest := filepath.Join(dir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(dest), 0755)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+
+		out, err := os.Create(dest)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+
+		_, err = io.Copy(out, r)
+		if err != nil {
+			out.Close()
+			return errors.New(err, nil)
+		}
+
+		return out.Close()
+	})
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"file": filename,
+		})
+	}
+
+	return nil
+}
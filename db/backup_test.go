@@ -0,0 +1,429 @@
+package db
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/EngaugeAI/engauge/types"
+)
+
+// memorySnapshotter is a store of files held in memory
+type memorySnapshotter struct {
+	store string
+	files map[string]string
+}
+
+func (s *memorySnapshotter) Store() string {
+	return s.store
+}
+
+func (s *memorySnapshotter) Snapshot(fn func(file *SnapshotFile) error) error {
+	names := make([]string, 0, len(s.files))
+	for name := range s.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		data := s.files[name]
+		err := fn(&SnapshotFile{
+			Name:      name,
+			Size:      int64(len(data)),
+			Partition: strings.HasPrefix(name, Interactions+"/"),
+			Open: func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(strings.NewReader(data)), nil
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeTestBackup(t *testing.T, filename string, s Snapshotter, base *Manifest) *Manifest {
+	t.Helper()
+
+	var buf bytes.Buffer
+	m, err := WriteBackup(&buf, s, base)
+	if err != nil {
+		t.Fatalf("WriteBackup() error = %v", err)
+	}
+
+	err = ioutil.WriteFile(filename, buf.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+// readTree will return the contents of every file below the directory
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	files := make(map[string]string)
+	err := filepath.Walk(dir, func(filename string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+
+		name, _ := filepath.Rel(dir, filename)
+		files[filepath.ToSlash(name)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return files
+}
+
+func TestBackupRestore(t *testing.T) {
+	baseFiles := map[string]string{
+		"settings":                    "settings v1",
+		"endpoints/e-1":               "endpoint",
+		"interactions/2026-10-17.csv": "a,b\n",
+		"interactions/2026-10-18.csv": "c,d\n",
+	}
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		base     bool
+		wantBase []string // the files that are only listed in the manifest
+	}{
+		{
+			name:  "full",
+			files: baseFiles,
+		},
+		{
+			name: "incremental",
+			files: map[string]string{
+				"settings":                    "settings v2",
+				"endpoints/e-1":               "endpoint",
+				"interactions/2026-10-17.csv": "a,b\n",
+				"interactions/2026-10-18.csv": "c,d\ne,f\n",
+				"interactions/2026-10-19.csv": "g,h\n",
+			},
+			base:     true,
+			wantBase: []string{"interactions/2026-10-17.csv"},
+		},
+		{
+			name: "incremental without a removed partition",
+			files: map[string]string{
+				"settings":                    "settings v1",
+				"interactions/2026-10-18.csv": "c,d\n",
+			},
+			base:     true,
+			wantBase: []string{"interactions/2026-10-18.csv"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			baseFile := filepath.Join(dir, "base.tar.gz")
+			bm := writeTestBackup(t, baseFile, &memorySnapshotter{store: "local", files: baseFiles}, nil)
+
+			filename := filepath.Join(dir, "backup.tar.gz")
+			var base *Manifest
+			if tt.base {
+				base = bm
+			}
+			m := writeTestBackup(t, filename, &memorySnapshotter{store: "local", files: tt.files}, base)
+
+			var gotBase []string
+			for _, file := range m.Files {
+				if file.Base {
+					gotBase = append(gotBase, file.Name)
+				}
+			}
+			if strings.Join(gotBase, ",") != strings.Join(tt.wantBase, ",") {
+				t.Errorf("base files = %v, want %v", gotBase, tt.wantBase)
+			}
+			if len(m.Files) != len(tt.files) {
+				t.Errorf("manifest files = %d, want %d", len(m.Files), len(tt.files))
+			}
+
+			basepath := filepath.Join(dir, "restored")
+			restored, err := RestoreBackup(filename, baseFile, "local", basepath)
+			if err != nil {
+				t.Fatalf("RestoreBackup() error = %v", err)
+			}
+			if restored.ID != m.ID {
+				t.Errorf("RestoreBackup() manifest = %s, want %s", restored.ID, m.ID)
+			}
+
+			got := readTree(t, basepath)
+			if len(got) != len(tt.files) {
+				t.Errorf("restored %d files, want %d", len(got), len(tt.files))
+			}
+			for name, want := range tt.files {
+				if got[name] != want {
+					t.Errorf("restored %s = %q, want %q", name, got[name], want)
+				}
+			}
+
+			if _, err := os.Stat(basepath + ".restore"); !os.IsNotExist(err) {
+				t.Errorf("the restore directory was not removed: %v", err)
+			}
+		})
+	}
+}
+
+// rewriteArchive will rewrite the entries of the archive with the function (nil drops the entry)
+func rewriteArchive(t *testing.T, data []byte, fn func(name string, content []byte) (string, []byte)) []byte {
+	t.Helper()
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		name, content := fn(header.Name, content)
+		if content == nil {
+			continue
+		}
+
+		err = tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))})
+		if err == nil {
+			_, err = tw.Write(content)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestReadBackupTampered(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := WriteBackup(&buf, &memorySnapshotter{
+		store: "local",
+		files: map[string]string{
+			"settings":                    "settings",
+			"interactions/2026-10-18.csv": "a,b\n",
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := buf.Bytes()
+
+	tests := []struct {
+		name    string
+		data    func(t *testing.T) []byte
+		wantErr error
+	}{
+		{
+			name: "changed file",
+			data: func(t *testing.T) []byte {
+				return rewriteArchive(t, archive, func(name string, content []byte) (string, []byte) {
+					if name == dataDir+"settings" {
+						return name, []byte("tampered")
+					}
+					return name, content
+				})
+			},
+			wantErr: types.ErrChecksum,
+		},
+		{
+			name: "missing file",
+			data: func(t *testing.T) []byte {
+				return rewriteArchive(t, archive, func(name string, content []byte) (string, []byte) {
+					if name == dataDir+"settings" {
+						return name, nil
+					}
+					return name, content
+				})
+			},
+			wantErr: types.ErrBackup,
+		},
+		{
+			name: "file not in the manifest",
+			data: func(t *testing.T) []byte {
+				return rewriteArchive(t, archive, func(name string, content []byte) (string, []byte) {
+					if name == dataDir+"settings" {
+						return dataDir + "consent", content
+					}
+					return name, content
+				})
+			},
+			wantErr: types.ErrBackup,
+		},
+		{
+			name: "path outside of the basepath",
+			data: func(t *testing.T) []byte {
+				return rewriteArchive(t, archive, func(name string, content []byte) (string, []byte) {
+					if name == dataDir+"settings" {
+						return dataDir + "../settings", content
+					}
+					return name, content
+				})
+			},
+			wantErr: types.ErrBackup,
+		},
+		{
+			name: "missing manifest",
+			data: func(t *testing.T) []byte {
+				return rewriteArchive(t, archive, func(name string, content []byte) (string, []byte) {
+					if name == manifestName {
+						return name, nil
+					}
+					return name, content
+				})
+			},
+			wantErr: types.ErrBackup,
+		},
+		{
+			name: "truncated archive",
+			data: func(t *testing.T) []byte {
+				return archive[:len(archive)/2]
+			},
+			wantErr: types.ErrBackup,
+		},
+		{
+			name: "not an archive",
+			data: func(t *testing.T) []byte {
+				return []byte("not an archive")
+			},
+			wantErr: types.ErrBackup,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadBackup(bytes.NewReader(tt.data(t)), nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ReadBackup() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRestoreBackupRejected(t *testing.T) {
+	files := map[string]string{
+		"settings":                    "settings",
+		"interactions/2026-10-18.csv": "a,b\n",
+	}
+
+	tests := []struct {
+		name     string
+		store    string
+		base     string // "", "base" or "other"
+		existing bool   // a file in the basepath
+		wantErr  error
+	}{
+		{
+			name:    "another store",
+			store:   "kv",
+			base:    "base",
+			wantErr: types.ErrBackup,
+		},
+		{
+			name:    "incremental without its base",
+			store:   "local",
+			wantErr: types.ErrBackup,
+		},
+		{
+			name:    "another base",
+			store:   "local",
+			base:    "other",
+			wantErr: types.ErrBackup,
+		},
+		{
+			name:     "basepath is not empty",
+			store:    "local",
+			base:     "base",
+			existing: true,
+			wantErr:  types.ErrExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			baseFile := filepath.Join(dir, "base")
+			bm := writeTestBackup(t, baseFile, &memorySnapshotter{store: "local", files: files}, nil)
+			otherFile := filepath.Join(dir, "other")
+			writeTestBackup(t, otherFile, &memorySnapshotter{store: "local", files: files}, nil)
+
+			filename := filepath.Join(dir, "backup")
+			writeTestBackup(t, filename, &memorySnapshotter{store: "local", files: files}, bm)
+
+			var base string
+			if tt.base != "" {
+				base = filepath.Join(dir, tt.base)
+			}
+
+			basepath := filepath.Join(dir, "restored")
+			if tt.existing {
+				err := os.MkdirAll(basepath, 0755)
+				if err == nil {
+					err = ioutil.WriteFile(filepath.Join(basepath, "settings"), []byte("current"), 0644)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err := RestoreBackup(filename, base, tt.store, basepath)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RestoreBackup() error = %v, want %v", err, tt.wantErr)
+			}
+
+			// nothing is written
+			got := readTree(t, dir)
+			for name := range got {
+				if strings.HasPrefix(name, "restored") && !tt.existing {
+					t.Errorf("RestoreBackup() wrote %s", name)
+				}
+			}
+			if tt.existing && got["restored/settings"] != "current" {
+				t.Errorf("RestoreBackup() replaced the existing settings")
+			}
+		})
+	}
+}
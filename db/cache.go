@@ -11,6 +11,9 @@ var (
 	GlobalSettings *types.Settings
 	// SummaryCache --
 	SummaryCache *sync.Map
+	// ClosedSummaryCache holds the summary of the last closed period of every interval,
+	// in which the sessions that ended within the period are counted once they expire
+	ClosedSummaryCache *sync.Map
 	// SessionsCache holds the current active sessions
 	SessionsCache *types.UserSessions
 	// EndpointsCache --
@@ -29,6 +32,14 @@ var (
 	PropertiesCache *types.Properties
 	// PropertyStatsCache --
 	PropertyStatsCache *types.PropertyStatsList
-	// TimestampFormats holds the list of timestamp formats that have been seen across the system
-	TimestampFormats []string
+	// IdentitiesCache holds the identity graph used to resolve users to their canonical user
+	IdentitiesCache *types.Identities
+	// UsersCache holds the profiles of all users
+	UsersCache *types.UserProfiles
+	// ConsentCache counts the consent mode of every received interaction
+	ConsentCache *types.ConsentCounter
+	// FilterHitsCache counts the hits of the filter rules
+	FilterHitsCache *types.FilterHits
+	// TombstonesCache holds the tombstones of all erased identifiers
+	TombstonesCache *types.Tombstones
 )
@@ -25,6 +25,25 @@ const (
 	Summaries = "summaries"
 	// Settings is a resource type
 	Settings = "settings"
+	// Identities is a resource type (identity graph links)
+	Identities = "identities"
+	// Users is a resource type (user profiles)
+	Users = "users"
+	// Consent is a resource type (consent mode counts)
+	Consent = "consent"
+	// FilterHits is a resource type (filter rule hit counts)
+	FilterHits = "filterHits"
+	// Sandbox is a resource type (interactions routed to a sandbox by a filter rule)
+	Sandbox = "sandbox"
+	// Archive is a resource type (the archived partitions of interactions and of the sandboxes)
+	Archive = "archive"
+	// Tombstones is a resource type (erased identifiers)
+	Tombstones = "tombstones"
+	// DeletionReports is a resource type (erasure audit records)
+	DeletionReports = "deletionReports"
+	// History is a resource type (the closed periods of the stats and summaries of
+	// the resource found in the where clause as `item.resource`)
+	History = "history"
 
 	/*  operation types */
 
@@ -40,11 +59,23 @@ const (
 	List = "list"
 	// Count will return the count of documents in the resource
 	Count = "count"
+	// Sync will flush the appended documents of the resource to disk
+	Sync = "sync"
 )
 
 // Client --
 type Client interface {
 	Do(operation *Op) Result
+	Begin() (Tx, error)
+}
+
+// Tx is a transaction of a client. The documents that are written (or deleted)
+// with the transaction are all applied by Commit, or none of them are (Rollback,
+// or a crash before the commit completes). Reads return the committed documents.
+type Tx interface {
+	Do(operation *Op) Result
+	Commit() error
+	Rollback() error
 }
 
 // Where --
@@ -0,0 +1,313 @@
+package db
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/cache"
+	"github.com/JKhawaja/errors"
+)
+
+// InitCache will load the caches from the stored data of the client
+func InitCache(c Client) {
+	log.Println("creating sessions cache")
+	initSessionsCache(c)
+
+	log.Println("setting global settings")
+	GlobalSettings = types.NewSettings()
+	settingsResult := c.Do(&Op{
+		Resource: Settings,
+		Type:     Read,
+	})
+	if settingsResult.Error == types.ErrDNE {
+		createResults := c.Do(&Op{
+			Resource: Settings,
+			Type:     Create,
+			Item:     GlobalSettings,
+		})
+		if createResults.Error != nil {
+			panic(createResults.Error)
+		}
+	} else if settingsResult.Error != nil {
+		panic(settingsResult.Error)
+	} else {
+		GlobalSettings = settingsResult.Item.(*types.Settings)
+	}
+
+	log.Println("loading consent counts")
+	consentResult := c.Do(&Op{
+		Resource: Consent,
+		Type:     Read,
+	})
+	if consentResult.Error == types.ErrDNE {
+		ConsentCache = types.NewConsentCounter(nil)
+	} else if consentResult.Error != nil {
+		panic(consentResult.Error)
+	} else {
+		ConsentCache = types.NewConsentCounter(consentResult.Item.(*types.ConsentStats))
+	}
+
+	log.Println("loading filter hits")
+	filterHitsResult := c.Do(&Op{
+		Resource: FilterHits,
+		Type:     Read,
+	})
+	if filterHitsResult.Error == types.ErrDNE {
+		FilterHitsCache = types.NewFilterHits(nil)
+	} else if filterHitsResult.Error != nil {
+		panic(filterHitsResult.Error)
+	} else {
+		FilterHitsCache = types.NewFilterHits(*filterHitsResult.Item.(*map[string]*types.FilterHit))
+	}
+
+	log.Println("loading summaries")
+	SummaryCache = &sync.Map{}
+	ClosedSummaryCache = &sync.Map{}
+	summariesResult := c.Do(&Op{
+		Resource: Summaries,
+		Type:     List,
+	})
+	if summariesResult.Error != nil {
+		panic(summariesResult.Error)
+	}
+	for _, summary := range summariesResult.Item.([]*types.Summary) {
+		SummaryCache.Store(summary.Interval, summary)
+	}
+
+	log.Println("loading properties")
+	PropertiesCache = types.NewProperties()
+	propertiesResult := c.Do(&Op{
+		Resource: Properties,
+		Type:     List,
+	})
+	if propertiesResult.Error != nil {
+		panic(propertiesResult.Error)
+	}
+	for _, property := range propertiesResult.Item.([]*types.Property) {
+		PropertiesCache.Set(property.Name, property)
+	}
+
+	log.Println("loading property stats")
+	PropertyStatsCache = types.NewPropertyStatsList()
+	propertyStatsResult := c.Do(&Op{
+		Resource: PropertyStats,
+		Type:     List,
+	})
+	if propertyStatsResult.Error != nil {
+		panic(propertyStatsResult.Error)
+	}
+	err := PropertyStatsCache.Load(propertyStatsResult.Item.([]*types.PropertyStats))
+	if err != nil {
+		panic(err)
+	}
+
+	log.Println("loading origins")
+	OriginsCache = types.NewOrigins()
+	originsResult := c.Do(&Op{
+		Resource: Origins,
+		Type:     List,
+	})
+	if originsResult.Error != nil {
+		panic(originsResult.Error)
+	}
+	for _, origin := range originsResult.Item.([]*types.Origin) {
+		err := OriginsCache.Set(origin.ID.UUID, origin)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	log.Println("loading origin stats")
+	OriginsStatsCache = types.NewIntervalStatsList(types.OriginObjectType)
+	originStatsResult := c.Do(&Op{
+		Resource: OriginStats,
+		Type:     List,
+	})
+	if originStatsResult.Error != nil {
+		panic(originStatsResult.Error)
+	}
+	err = OriginsStatsCache.Load(originStatsResult.Item.([]*types.IntervalStats))
+	if err != nil {
+		panic(err)
+	}
+
+	log.Println("loading entities")
+	EntitiesCache = types.NewEntities()
+	entitiesResult := c.Do(&Op{
+		Resource: Entities,
+		Type:     List,
+	})
+	if entitiesResult.Error != nil {
+		panic(entitiesResult.Error)
+	}
+	for _, entity := range entitiesResult.Item.([]*types.Entity) {
+		EntitiesCache.Set(entity)
+	}
+
+	log.Println("loading entity stats")
+	EntityStatsCache = types.NewIntervalStatsList(types.EntityObjectType)
+	entityStatsResult := c.Do(&Op{
+		Resource: EntityStats,
+		Type:     List,
+	})
+	if entityStatsResult.Error != nil {
+		panic(entityStatsResult.Error)
+	}
+	err = EntityStatsCache.Load(entityStatsResult.Item.([]*types.IntervalStats))
+	if err != nil {
+		panic(err)
+	}
+
+	log.Println("loading endpoints")
+	EndpointsCache = types.NewEndpoints()
+	endpointsResult := c.Do(&Op{
+		Resource: Endpoints,
+		Type:     List,
+	})
+	if endpointsResult.Error != nil {
+		panic(endpointsResult.Error)
+	}
+
+	for _, endpoint := range endpointsResult.Item.([]*types.Endpoint) {
+		err := EndpointsCache.Set(endpoint.ID.UUID, endpoint)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	log.Println("loading endpoint stats")
+	EndpointsStatsCache = types.NewIntervalStatsList(types.EndpointObjectType)
+	endpointStatsResult := c.Do(&Op{
+		Resource: EndpointStats,
+		Type:     List,
+	})
+	if endpointStatsResult.Error != nil {
+		panic(endpointStatsResult.Error)
+	}
+	err = EndpointsStatsCache.Load(endpointStatsResult.Item.([]*types.IntervalStats))
+	if err != nil {
+		panic(err)
+	}
+
+	log.Println("loading identities")
+	IdentitiesCache = types.NewIdentities()
+	identitiesResult := c.Do(&Op{
+		Resource: Identities,
+		Type:     List,
+	})
+	if identitiesResult.Error != nil {
+		panic(identitiesResult.Error)
+	}
+	for _, identity := range identitiesResult.Item.([]*types.Identity) {
+		IdentitiesCache.Set(identity)
+	}
+
+	log.Println("loading users")
+	UsersCache = types.NewUserProfiles()
+	usersResult := c.Do(&Op{
+		Resource: Users,
+		Type:     List,
+	})
+	if usersResult.Error != nil {
+		panic(usersResult.Error)
+	}
+	for _, profile := range usersResult.Item.([]*types.UserProfile) {
+		UsersCache.Set(profile)
+	}
+
+	log.Println("loading tombstones")
+	TombstonesCache = types.NewTombstones()
+	tombstonesResult := c.Do(&Op{
+		Resource: Tombstones,
+		Type:     List,
+	})
+	if tombstonesResult.Error != nil {
+		panic(tombstonesResult.Error)
+	}
+	for _, tombstone := range tombstonesResult.Item.([]*types.Tombstone) {
+		TombstonesCache.Set(tombstone)
+	}
+}
+
+func initSessionsCache(c Client) {
+	config := &cache.CacheConfig{
+		OnExpires: func(item interface{}) {
+			sess := item.(*types.UserSession)
+
+			// update summaries
+			SummaryCache.Range(func(key, value interface{}) bool {
+				summary := value.(*types.Summary)
+				interval := summary.Interval
+
+				// a session that ended before the period of the summary is counted in the
+				// closed summary of its period (which is archived again) instead
+				var closed bool
+				if sess.UpdatedAt.Before(summary.Start) {
+					if s, ok := ClosedSummaryCache.Load(interval); ok && s.(*types.Summary).Contains(sess.UpdatedAt) {
+						summary = s.(*types.Summary)
+						closed = true
+					}
+				}
+
+				var toggle bool
+				switch interval {
+				case types.Hourly:
+					toggle = GlobalSettings.StatsToggles.Hourly
+				case types.Daily:
+					toggle = GlobalSettings.StatsToggles.Daily
+				case types.Weekly:
+					toggle = GlobalSettings.StatsToggles.Weekly
+				case types.Monthly:
+					toggle = GlobalSettings.StatsToggles.Monthly
+				}
+
+				if !toggle {
+					return true
+				}
+
+				err := summary.SessionExpirationUpdate(sess)
+				if err != nil {
+					log.Println(errors.NewTrace(err).Error())
+					return true
+				}
+
+				if closed {
+					historyResult := c.Do(&Op{
+						Resource: History,
+						Type:     Create,
+						Where: WhereMap{
+							"item.resource": Summaries,
+						},
+						Item: summary,
+					})
+					if historyResult.Error != nil {
+						log.Println(errors.NewTrace(historyResult.Error).Error())
+					}
+					return true
+				}
+
+				summaryResult := c.Do(&Op{
+					Resource: Summaries,
+					Type:     Update,
+					Where: WhereMap{
+						"interval": interval,
+					},
+					Item: summary,
+				})
+				if summaryResult.Error != nil {
+					log.Println(errors.NewTrace(summaryResult.Error).Error())
+				}
+
+				return true
+			})
+		},
+		Refresh:         true,
+		RefreshDuration: types.SessionExpiryDuration,
+		CleanDuration:   1 * time.Minute,
+	}
+
+	SessionsCache = types.NewUserSessions(cache.NewCache(config))
+}
@@ -0,0 +1,40 @@
+package kv
+
+import (
+	"io"
+
+	"github.com/EngaugeAI/engauge/db"
+
+	"github.com/JKhawaja/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Store --
+func (c *Client) Store() string {
+	return "kv"
+}
+
+// Snapshot will call the function with the store file, as it is seen by a read-only
+// transaction (the writes of the other transactions are not part of the snapshot).
+func (c *Client) Snapshot(fn func(file *db.SnapshotFile) error) error {
+	return c.bolt.View(func(tx *bolt.Tx) error {
+		err := fn(&db.SnapshotFile{
+			Name: Filename,
+			Size: tx.Size(),
+			Open: func() (io.ReadCloser, error) {
+				r, w := io.Pipe()
+				go func() {
+					_, err := tx.WriteTo(w)
+					w.CloseWithError(err)
+				}()
+
+				return r, nil
+			},
+		})
+		if err != nil {
+			return errors.New(err, nil)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,472 @@
+package kv
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Filename is the name of the store file inside the basepath
+const Filename = "engauge.db"
+
+// Client is a db.Client that stores every resource in a single, transactional
+// key-value file (one bucket per resource).
+type Client struct {
+	bolt *bolt.DB
+}
+
+// Tx is a (read-write) transaction of the store
+type Tx struct {
+	tx *bolt.Tx
+}
+
+// buckets that are created when the store is opened
+var buckets = []string{
+	db.Interactions,
+	db.Endpoints,
+	db.EndpointStats,
+	db.Origins,
+	db.OriginStats,
+	db.Entities,
+	db.EntityStats,
+	db.Properties,
+	db.PropertyStats,
+	db.Summaries,
+	db.Settings,
+	db.Identities,
+	db.Users,
+	db.Consent,
+	db.FilterHits,
+	db.Sandbox,
+	db.Tombstones,
+	db.DeletionReports,
+	db.History,
+	indexBucket,
+}
+
+// NewClient will open (or create) the store file inside the basepath
+func NewClient(basepath string) (*Client, error) {
+	c, err := Open(filepath.Join(basepath, Filename))
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	c.InitCache()
+
+	return c, nil
+}
+
+// Open will open (or create) the store file without loading the caches
+func Open(filename string) (*Client, error) {
+	err := os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return nil, errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+
+	store, err := bolt.Open(filename, 0644, &bolt.Options{
+		Timeout: 5 * time.Second,
+	})
+	if err != nil {
+		return nil, errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+
+	err = store.Update(func(tx *bolt.Tx) error {
+		for _, name := range buckets {
+			_, err := tx.CreateBucketIfNotExists([]byte(name))
+			if err != nil {
+				return errors.New(err, map[string]interface{}{
+					"bucket": name,
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		store.Close()
+		return nil, errors.New(err, nil)
+	}
+
+	return &Client{
+		bolt: store,
+	}, nil
+}
+
+// InitCache --
+func (c *Client) InitCache() {
+	db.InitCache(c)
+}
+
+// Close will close the store file
+func (c *Client) Close() error {
+	return c.bolt.Close()
+}
+
+// Do will do every operation in its own transaction
+func (c *Client) Do(op *db.Op) db.Result {
+	var result db.Result
+
+	switch op.Type {
+	case db.Read, db.List, db.Count:
+		err := c.bolt.View(func(tx *bolt.Tx) error {
+			result = do(tx, op)
+			return nil
+		})
+		if err != nil {
+			result.Error = errors.New(err, nil)
+		}
+	case db.Sync:
+		// every transaction is synced when it is committed
+	default:
+		err := c.bolt.Update(func(tx *bolt.Tx) error {
+			result = do(tx, op)
+			return result.Error
+		})
+		if err != nil && result.Error == nil {
+			result.Error = errors.New(err, nil)
+		}
+	}
+
+	return result
+}
+
+// Begin will start a read-write transaction. Write transactions are serialized,
+// so the other writes of the store wait until it is committed or rolled back.
+func (c *Client) Begin() (db.Tx, error) {
+	tx, err := c.bolt.Begin(true)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	return &Tx{
+		tx: tx,
+	}, nil
+}
+
+// Do --
+func (t *Tx) Do(op *db.Op) db.Result {
+	if t.tx.DB() == nil {
+		return db.Result{
+			Error: types.ErrTxDone,
+		}
+	}
+
+	if op.Type == db.Sync {
+		return db.Result{}
+	}
+
+	return do(t.tx, op)
+}
+
+// Commit --
+func (t *Tx) Commit() error {
+	err := t.tx.Commit()
+	if err == bolt.ErrTxClosed {
+		return types.ErrTxDone
+	} else if err != nil {
+		return errors.New(err, nil)
+	}
+
+	return nil
+}
+
+// Rollback will discard the transaction (a no-op after a commit)
+func (t *Tx) Rollback() error {
+	err := t.tx.Rollback()
+	if err != nil && err != bolt.ErrTxClosed {
+		return errors.New(err, nil)
+	}
+
+	return nil
+}
+
+func do(tx *bolt.Tx, op *db.Op) db.Result {
+	var result db.Result
+
+	switch op.Type {
+	case db.Create, db.Update:
+		if op.Resource == db.Interactions {
+			err := putInteraction(tx, op.Item.(*types.Interaction))
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+			}
+			return result
+		}
+
+		if op.Resource == db.Sandbox {
+			err := putSandbox(tx, op.Item.(*types.Interaction), op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+			}
+			return result
+		}
+
+		if op.Resource == db.Archive {
+			err := putArchived(tx, op.Item.(*types.Interaction), op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+			}
+			return result
+		}
+
+		if op.Resource == db.History {
+			err := putHistory(tx, op.Item, op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+			}
+			return result
+		}
+
+		key := itemKey(op.Resource, op.Item)
+		data, err := encode(op.Item)
+		if err != nil {
+			result.Error = errors.New(err, map[string]interface{}{
+				"op":       op.Type,
+				"resource": op.Resource,
+				"key":      key,
+			})
+			return result
+		}
+
+		err = tx.Bucket([]byte(op.Resource)).Put([]byte(key), data)
+		if err != nil {
+			result.Error = errors.New(err, map[string]interface{}{
+				"op":       op.Type,
+				"resource": op.Resource,
+				"key":      key,
+			})
+		}
+	case db.Read:
+		key := whereKey(op.Resource, op.Where)
+		if key == "" {
+			result.Error = types.ErrDNE
+			return result
+		}
+
+		data := tx.Bucket([]byte(op.Resource)).Get([]byte(key))
+		if data == nil {
+			result.Error = types.ErrDNE
+			return result
+		}
+
+		item, err := decode(op.Resource, data)
+		if err != nil {
+			result.Error = errors.New(err, map[string]interface{}{
+				"op":       op.Type,
+				"resource": op.Resource,
+				"key":      key,
+			})
+			return result
+		}
+
+		result.Item = item
+	case db.List:
+		if op.Resource == db.Interactions {
+			entries, err := userInteractions(tx, op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+				return result
+			}
+
+			start, end := page(int64(len(entries)), op.Offset, op.Limit)
+			list, err := readInteractions(tx, entries[start:end])
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+				return result
+			}
+
+			result.Item = list
+			return result
+		}
+
+		if op.Resource == db.Sandbox {
+			list, err := sandboxInteractions(tx, op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+				return result
+			}
+
+			result.Item = list
+			return result
+		}
+
+		if op.Resource == db.Archive {
+			list, err := archivedInteractions(tx, op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+				return result
+			}
+
+			result.Item = list
+			return result
+		}
+
+		if op.Resource == db.History {
+			list, err := listHistory(tx, op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+				return result
+			}
+
+			result.Item = list
+			return result
+		}
+
+		bucket := tx.Bucket([]byte(op.Resource))
+		start, end := page(int64(bucket.Stats().KeyN), op.Offset, op.Limit)
+
+		items := make([]interface{}, 0, end-start)
+		var n int64
+		err := bucket.ForEach(func(key, data []byte) error {
+			defer func() { n++ }()
+			if n < start || n >= end {
+				return nil
+			}
+
+			item, err := decode(op.Resource, data)
+			if err != nil {
+				return errors.New(err, map[string]interface{}{
+					"key": string(key),
+				})
+			}
+			items = append(items, item)
+
+			return nil
+		})
+		if err != nil {
+			result.Error = errors.New(err, map[string]interface{}{
+				"op":       op.Type,
+				"resource": op.Resource,
+			})
+			return result
+		}
+
+		result.Item = list(op.Resource, items)
+	case db.Count:
+		if op.Resource == db.Interactions {
+			entries, err := userInteractions(tx, op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+				return result
+			}
+
+			result.Item = int64(len(entries))
+			return result
+		}
+
+		result.Item = int64(tx.Bucket([]byte(op.Resource)).Stats().KeyN)
+	case db.Delete:
+		if op.Resource == db.Sandbox {
+			removed, err := eraseSandbox(tx, op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+				return result
+			}
+
+			result.Item = removed
+			return result
+		}
+
+		if op.Resource == db.Archive {
+			removed, err := eraseArchive(tx, op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+				return result
+			}
+
+			result.Item = removed
+			return result
+		}
+
+		if op.Resource == db.Interactions {
+			removed, err := eraseInteractions(tx, op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+				return result
+			}
+
+			result.Item = removed
+			return result
+		}
+
+		key := whereKey(op.Resource, op.Where)
+		bucket := tx.Bucket([]byte(op.Resource))
+		if key == "" || bucket.Get([]byte(key)) == nil {
+			result.Error = types.ErrDNE
+			return result
+		}
+
+		err := bucket.Delete([]byte(key))
+		if err != nil {
+			result.Error = errors.New(err, nil)
+		}
+	}
+
+	return result
+}
+
+// page will return the start and end of a page of n items
+func page(n int64, offset, limit *int64) (int64, int64) {
+	var start int64
+	if offset != nil && *offset > 0 {
+		start = *offset
+	}
+	if start > n {
+		start = n
+	}
+
+	end := n
+	if limit != nil && *limit > 0 && start+*limit < end {
+		end = start + *limit
+	}
+
+	return start, end
+}
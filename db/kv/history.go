@@ -0,0 +1,167 @@
+package kv
+
+import (
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// the closed periods are stored in one bucket per document, keyed by their start
+// (a period that is archived again replaces the previous one):
+//
+//	history/<resource>/<document>/<start> -> period
+
+// historyResource will return the resource of the history found in the where clause
+func historyResource(where db.Where) (string, error) {
+	wm, ok := where.(db.WhereMap)
+	if !ok {
+		return "", errors.New(types.ErrAssertion, nil)
+	}
+
+	resource, _ := wm["item.resource"].(string)
+	switch resource {
+	case db.EndpointStats, db.OriginStats, db.EntityStats, db.PropertyStats, db.Summaries:
+		return resource, nil
+	}
+
+	return "", errors.New(types.ErrResourceType, map[string]interface{}{
+		"resource": resource,
+	})
+}
+
+// putHistory will put the closed period in the history of its document
+func putHistory(tx *bolt.Tx, item interface{}, where db.Where) error {
+	resource, err := historyResource(where)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	period, ok := item.(types.Period)
+	if !ok {
+		return errors.New(types.ErrAssertion, nil)
+	}
+	_, start, _ := period.Period()
+
+	data, err := encode(item)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	key := itemKey(resource, item)
+	bucket, err := tx.Bucket([]byte(db.History)).CreateBucketIfNotExists([]byte(resource))
+	if err == nil {
+		bucket, err = bucket.CreateBucketIfNotExists([]byte(key))
+	}
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"resource": resource,
+			"key":      key,
+		})
+	}
+
+	return bucket.Put(timeKey(start), data)
+}
+
+// listHistory will return the periods of the document found in the where clause
+// that start within its (optional) `from` and `to` times, sorted by their start.
+func listHistory(tx *bolt.Tx, where db.Where) (interface{}, error) {
+	resource, err := historyResource(where)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	key := whereKey(resource, where)
+	if key == "" {
+		return nil, errors.New(types.ErrDNE, map[string]interface{}{
+			"resource": resource,
+		})
+	}
+
+	from, _ := where.(db.WhereMap)["from"].(time.Time)
+	to, _ := where.(db.WhereMap)["to"].(time.Time)
+
+	items := make([]interface{}, 0)
+	bucket := tx.Bucket([]byte(db.History)).Bucket([]byte(resource))
+	if bucket != nil {
+		bucket = bucket.Bucket([]byte(key))
+	}
+	if bucket == nil {
+		return list(resource, items), nil
+	}
+
+	cursor := bucket.Cursor()
+	k, data := cursor.First()
+	if !from.IsZero() {
+		k, data = cursor.Seek(timeKey(from))
+	}
+	for ; k != nil; k, data = cursor.Next() {
+		if !to.IsZero() && !keyTime(k).Before(to) {
+			break
+		}
+
+		item, err := decode(resource, data)
+		if err != nil {
+			return nil, errors.New(err, map[string]interface{}{
+				"resource": resource,
+				"key":      key,
+			})
+		}
+		items = append(items, item)
+	}
+
+	return list(resource, items), nil
+}
+
+// pruneHistory will remove the periods that ended more than the number of periods ago
+// from the history of every document. It returns the number of removed periods.
+func pruneHistory(tx *bolt.Tx, periods int, now time.Time) (int, error) {
+	var removed int
+
+	root := tx.Bucket([]byte(db.History))
+	for _, resource := range partitions(root) {
+		documents := root.Bucket([]byte(resource))
+		for _, document := range partitions(documents) {
+			bucket := documents.Bucket([]byte(document))
+
+			expired := make([][]byte, 0)
+			err := bucket.ForEach(func(key, data []byte) error {
+				item, err := decode(resource, data)
+				if err != nil {
+					return errors.New(err, map[string]interface{}{
+						"resource": resource,
+						"key":      document,
+					})
+				}
+
+				if types.PeriodExpired(item.(types.Period), periods, now) {
+					expired = append(expired, append([]byte{}, key...))
+				}
+				return nil
+			})
+			if err != nil {
+				return 0, errors.New(err, nil)
+			}
+
+			for _, key := range expired {
+				err := bucket.Delete(key)
+				if err != nil {
+					return 0, errors.New(err, nil)
+				}
+			}
+			removed += len(expired)
+
+			if k, _ := bucket.Cursor().First(); k == nil {
+				err := documents.DeleteBucket([]byte(document))
+				if err != nil {
+					return 0, errors.New(err, nil)
+				}
+			}
+		}
+	}
+
+	return removed, nil
+}
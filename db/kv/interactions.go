@@ -0,0 +1,594 @@
+package kv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// indexBucket holds one bucket per user which points to the location of each
+// of the user's interactions inside the daily partitions of the interactions bucket.
+const indexBucket = "interactionIndex"
+
+// interactions are stored as CSV records in one bucket per (daily) partition,
+// keyed by the sequence of the interactions bucket:
+//
+//	interactions/<partition>/<sequence>           -> record
+//	interactionIndex/<user>/<created at><sequence> -> partition
+//	sandbox/<name>/<partition>/<sequence>          -> record
+type indexEntry struct {
+	Partition string
+	Sequence  []byte
+	CreatedAt time.Time
+}
+
+func putInteraction(tx *bolt.Tx, interaction *types.Interaction) error {
+	interactions := tx.Bucket([]byte(db.Interactions))
+	partition := interaction.Date()
+	seq, err := putRecord(interactions, interactions, partition, interaction)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	index, err := tx.Bucket([]byte(indexBucket)).CreateBucketIfNotExists([]byte(types.UserProfileID(interaction.User())))
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	key := append(timeKey(*interaction.CreatedAt), seq...)
+	err = index.Put(key, []byte(partition))
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	return nil
+}
+
+// putSandbox will put the interaction in the (daily) partition of the sandbox
+// found in the where clause. Sandboxed interactions are not indexed.
+func putSandbox(tx *bolt.Tx, interaction *types.Interaction, where db.Where) error {
+	wm, ok := where.(db.WhereMap)
+	if !ok {
+		return errors.New(types.ErrAssertion, nil)
+	}
+
+	name, ok := wm["item.sandbox"].(string)
+	if !ok || name == "" || strings.ContainsAny(name, `/\.`) {
+		return errors.New(types.ErrFilterRule, map[string]interface{}{
+			"sandbox": name,
+		})
+	}
+
+	sandbox, err := tx.Bucket([]byte(db.Sandbox)).CreateBucketIfNotExists([]byte(name))
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"sandbox": name,
+		})
+	}
+
+	_, err = putRecord(sandbox, sandbox, interaction.Date(), interaction)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	return nil
+}
+
+// putArchived will put the interaction in the (daily) partition of the archive at the path
+// found in the where clause (`interactions` or `sandbox/<name>`). It is used to migrate the archive.
+func putArchived(tx *bolt.Tx, interaction *types.Interaction, where db.Where) error {
+	wm, ok := where.(db.WhereMap)
+	if !ok {
+		return errors.New(types.ErrAssertion, nil)
+	}
+
+	path, ok := wm["item.path"].(string)
+	if !ok || path == "" {
+		return errors.New(types.ErrAssertion, map[string]interface{}{
+			"path": path,
+		})
+	}
+
+	// the keys are taken from the sequence of the bucket at the same path in the store (as the
+	// keys of the interactions that are archived by Prune), so that they never collide
+	names := strings.Split(path, "/")
+	sequence := tx.Bucket([]byte(names[0]))
+	if sequence == nil {
+		return errors.New(types.ErrAssertion, map[string]interface{}{
+			"path": path,
+		})
+	}
+
+	bucket, err := tx.CreateBucketIfNotExists([]byte(archiveBucket))
+	if err == nil {
+		bucket, err = bucket.CreateBucketIfNotExists([]byte(names[0]))
+	}
+	for _, name := range names[1:] {
+		if err != nil {
+			break
+		}
+		bucket, err = bucket.CreateBucketIfNotExists([]byte(name))
+		if err == nil {
+			sequence, err = sequence.CreateBucketIfNotExists([]byte(name))
+		}
+	}
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"path": path,
+		})
+	}
+
+	_, err = putRecord(bucket, sequence, interaction.Date(), interaction)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	return nil
+}
+
+// putRecord will put the CSV record of the interaction in the partition of the
+// bucket, and return the key (the next sequence of the sequence bucket) of the record.
+func putRecord(bucket, sequence *bolt.Bucket, partition string, interaction *types.Interaction) ([]byte, error) {
+	b, err := bucket.CreateBucketIfNotExists([]byte(partition))
+	if err != nil {
+		return nil, errors.New(err, map[string]interface{}{
+			"partition": partition,
+		})
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	err = w.Write(interaction.CSV())
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+	w.Flush()
+
+	// the sequence is shared by every partition
+	n, err := sequence.NextSequence()
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+	seq := make([]byte, 8)
+	binary.BigEndian.PutUint64(seq, n)
+
+	err = b.Put(seq, buf.Bytes())
+	if err != nil {
+		return nil, errors.New(err, map[string]interface{}{
+			"partition": partition,
+		})
+	}
+
+	return seq, nil
+}
+
+// timeKey will return a key that sorts in the order of the times
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano())^(1<<63))
+	return key
+}
+
+func keyTime(key []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key[:8])^(1<<63))).UTC()
+}
+
+// userInteractions will return the index entries of the users (found in the where clause)
+// that fall within the optional "from" and "to" times, ordered by creation time.
+func userInteractions(tx *bolt.Tx, where db.Where) ([]*indexEntry, error) {
+	wm, ok := where.(db.WhereMap)
+	if !ok {
+		return nil, errors.New(types.ErrAssertion, nil)
+	}
+
+	users, err := whereUsers(wm)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	from, hasFrom := wm["from"].(time.Time)
+	to, hasTo := wm["to"].(time.Time)
+
+	entries := make([]*indexEntry, 0)
+	for _, user := range users {
+		index := tx.Bucket([]byte(indexBucket)).Bucket([]byte(types.UserProfileID(user)))
+		if index == nil {
+			continue
+		}
+
+		cursor := index.Cursor()
+		key, value := cursor.First()
+		if hasFrom {
+			key, value = cursor.Seek(timeKey(from))
+		}
+		for ; key != nil; key, value = cursor.Next() {
+			createdAt := keyTime(key)
+			if hasTo && createdAt.After(to) {
+				break
+			}
+
+			entries = append(entries, &indexEntry{
+				Partition: string(value),
+				Sequence:  append([]byte{}, key[8:]...),
+				CreatedAt: createdAt,
+			})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+
+	return entries, nil
+}
+
+func whereUsers(wm db.WhereMap) ([]types.User, error) {
+	switch u := wm["item.user"].(type) {
+	case types.User:
+		return []types.User{u}, nil
+	case []types.User:
+		return u, nil
+	}
+
+	return nil, types.ErrUser
+}
+
+// readInteractions will read the interactions pointed to by the index entries
+func readInteractions(tx *bolt.Tx, entries []*indexEntry) ([]*types.Interaction, error) {
+	interactions := tx.Bucket([]byte(db.Interactions))
+
+	list := make([]*types.Interaction, 0, len(entries))
+	for _, entry := range entries {
+		partition := interactions.Bucket([]byte(entry.Partition))
+		if partition == nil {
+			continue
+		}
+
+		data := partition.Get(entry.Sequence)
+		if data == nil {
+			continue
+		}
+
+		interaction, err := decodeRecord(data)
+		if err != nil {
+			return nil, errors.New(err, map[string]interface{}{
+				"partition": entry.Partition,
+			})
+		}
+
+		list = append(list, interaction)
+	}
+
+	return list, nil
+}
+
+func decodeRecord(data []byte) (*types.Interaction, error) {
+	record, err := csv.NewReader(bytes.NewReader(data)).Read()
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	interaction, err := types.InteractionFromCSV(record)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	return interaction, nil
+}
+
+// eraseInteractions will delete the interactions and the index of the users
+// (found in the where clause). It returns the number of removed interactions per partition.
+func eraseInteractions(tx *bolt.Tx, where db.Where) (map[string]int64, error) {
+	wm, ok := where.(db.WhereMap)
+	if !ok {
+		return nil, errors.New(types.ErrAssertion, nil)
+	}
+
+	users, err := whereUsers(wm)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	interactions := tx.Bucket([]byte(db.Interactions))
+	indexes := tx.Bucket([]byte(indexBucket))
+
+	removed := make(map[string]int64)
+	for _, user := range users {
+		id := []byte(types.UserProfileID(user))
+		index := indexes.Bucket(id)
+		if index == nil {
+			continue
+		}
+
+		err := index.ForEach(func(key, value []byte) error {
+			partition := interactions.Bucket(value)
+			if partition == nil || partition.Get(key[8:]) == nil {
+				return nil
+			}
+
+			removed[string(value)]++
+			return partition.Delete(key[8:])
+		})
+		if err != nil {
+			return nil, errors.New(err, nil)
+		}
+
+		err = indexes.DeleteBucket(id)
+		if err != nil {
+			return nil, errors.New(err, nil)
+		}
+	}
+
+	return removed, nil
+}
+
+// sandboxInteractions will return the sandboxed interactions of the users (found in the
+// where clause) by sandbox. The sandboxes are not indexed, so every partition is scanned.
+func sandboxInteractions(tx *bolt.Tx, where db.Where) (map[string][]*types.Interaction, error) {
+	users, err := sandboxUsers(where)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	list := make(map[string][]*types.Interaction)
+	sandboxes := tx.Bucket([]byte(db.Sandbox))
+	err = sandboxes.ForEach(func(name, value []byte) error {
+		if value != nil {
+			return nil
+		}
+
+		return scanPartitions(sandboxes.Bucket(name), string(name), func(sandbox string, interaction *types.Interaction) error {
+			if _, ok := users[interaction.User().String()]; ok {
+				list[sandbox] = append(list[sandbox], interaction)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	return list, nil
+}
+
+// eraseSandbox will delete the sandboxed interactions of the users (found in the where clause).
+// It returns the number of removed interactions per partition (`sandbox/<name>/<partition>`).
+func eraseSandbox(tx *bolt.Tx, where db.Where) (map[string]int64, error) {
+	users, err := sandboxUsers(where)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	remove := func(interaction *types.Interaction) bool {
+		_, ok := users[interaction.User().String()]
+		return ok
+	}
+
+	removed := make(map[string]int64)
+	sandboxes := tx.Bucket([]byte(db.Sandbox))
+	for _, name := range partitions(sandboxes) {
+		sandbox := sandboxes.Bucket([]byte(name))
+		for _, partition := range partitions(sandbox) {
+			count, err := prunePartition(tx, sandbox, partition, remove, false, db.Sandbox, name)
+			if err != nil {
+				return nil, errors.New(err, nil)
+			}
+			if count > 0 {
+				removed[fmt.Sprintf("%s/%s/%s", db.Sandbox, name, partition)] = count
+			}
+		}
+	}
+
+	return removed, nil
+}
+
+// archivedInteractions will return the archived interactions of the users (found in the where clause)
+func archivedInteractions(tx *bolt.Tx, where db.Where) ([]*types.Interaction, error) {
+	users, err := sandboxUsers(where)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	list := make([]*types.Interaction, 0)
+	for _, p := range archivedPartitions(tx) {
+		err := p.parent.Bucket([]byte(p.name)).ForEach(func(key, data []byte) error {
+			interaction, err := decodeRecord(data)
+			if err != nil {
+				return errors.New(err, map[string]interface{}{
+					"partition": p.name,
+				})
+			}
+
+			if _, ok := users[interaction.User().String()]; ok {
+				list = append(list, interaction)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.New(err, nil)
+		}
+	}
+
+	return list, nil
+}
+
+// eraseArchive will delete the archived interactions of the users (found in the where clause). It returns
+// the number of removed interactions per archived partition (`archive/<path of the partition>`).
+func eraseArchive(tx *bolt.Tx, where db.Where) (map[string]int64, error) {
+	users, err := sandboxUsers(where)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	remove := func(interaction *types.Interaction) bool {
+		_, ok := users[interaction.User().String()]
+		return ok
+	}
+
+	removed := make(map[string]int64)
+	for _, p := range archivedPartitions(tx) {
+		count, err := prunePartition(tx, p.parent, p.name, remove, false, archiveBucket)
+		if err != nil {
+			return nil, errors.New(err, nil)
+		}
+		if count > 0 {
+			removed[strings.Join(append(p.path, p.name), "/")] = count
+		}
+	}
+
+	return removed, nil
+}
+
+// archivedPartition is a partition of the archive bucket
+type archivedPartition struct {
+	parent *bolt.Bucket
+	path   []string // the path of the parent bucket (from the archive bucket)
+	name   string
+}
+
+// archivedPartitions will return the partitions of the archive (of interactions and of the sandboxes).
+// The partitions are the nested buckets that hold records.
+func archivedPartitions(tx *bolt.Tx) []*archivedPartition {
+	list := make([]*archivedPartition, 0)
+
+	var walk func(bucket *bolt.Bucket, path []string)
+	walk = func(bucket *bolt.Bucket, path []string) {
+		for _, name := range partitions(bucket) {
+			nested := bucket.Bucket([]byte(name))
+			if key, value := nested.Cursor().First(); key != nil && value != nil {
+				list = append(list, &archivedPartition{
+					parent: bucket,
+					path:   path,
+					name:   name,
+				})
+				continue
+			}
+
+			walk(nested, append(append([]string{}, path...), name))
+		}
+	}
+
+	archive := tx.Bucket([]byte(archiveBucket))
+	if archive != nil {
+		walk(archive, []string{archiveBucket})
+	}
+
+	return list
+}
+
+// sandboxUsers will return the set of the users found in the where clause
+func sandboxUsers(where db.Where) (map[string]struct{}, error) {
+	wm, ok := where.(db.WhereMap)
+	if !ok {
+		return nil, errors.New(types.ErrAssertion, nil)
+	}
+
+	users, err := whereUsers(wm)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	set := make(map[string]struct{})
+	for _, user := range users {
+		set[user.String()] = struct{}{}
+	}
+
+	return set, nil
+}
+
+// ScanInteractions will call the function for every stored interaction, partition by
+// partition, and then for every sandboxed interaction (with the name of its sandbox).
+func (c *Client) ScanInteractions(fn func(sandbox string, interaction *types.Interaction) error) error {
+	return c.bolt.View(func(tx *bolt.Tx) error {
+		err := scanPartitions(tx.Bucket([]byte(db.Interactions)), "", fn)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+
+		sandboxes := tx.Bucket([]byte(db.Sandbox))
+		return sandboxes.ForEach(func(name, value []byte) error {
+			if value != nil {
+				return nil
+			}
+
+			return scanPartitions(sandboxes.Bucket(name), string(name), fn)
+		})
+	})
+}
+
+func scanPartitions(bucket *bolt.Bucket, sandbox string, fn func(sandbox string, interaction *types.Interaction) error) error {
+	return bucket.ForEach(func(name, value []byte) error {
+		// the partitions are the nested buckets
+		if value != nil {
+			return nil
+		}
+
+		return bucket.Bucket(name).ForEach(func(key, data []byte) error {
+			interaction, err := decodeRecord(data)
+			if err != nil {
+				return errors.New(err, map[string]interface{}{
+					"partition": string(name),
+				})
+			}
+
+			return fn(sandbox, interaction)
+		})
+	})
+}
+
+// Scan will stream the stored interactions that match the scan (the daily partitions are pruned with their date)
+func (c *Client) Scan(scan *db.Scan, fn func(interaction *types.Interaction) error) error {
+	return c.bolt.View(func(tx *bolt.Tx) error {
+		interactions := tx.Bucket([]byte(db.Interactions))
+		return interactions.ForEach(func(name, value []byte) error {
+			if value != nil {
+				return nil
+			}
+
+			err := scan.Err()
+			if err != nil {
+				return errors.New(err, nil)
+			}
+
+			if !scan.MatchPartition(string(name)) {
+				scan.Pruned++
+				return nil
+			}
+			scan.Partitions++
+
+			return interactions.Bucket(name).ForEach(func(key, data []byte) error {
+				err := scan.Err()
+				if err != nil {
+					return errors.New(err, nil)
+				}
+
+				interaction, err := decodeRecord(data)
+				if err != nil {
+					return errors.New(err, map[string]interface{}{
+						"partition": string(name),
+					})
+				}
+
+				var action string
+				if interaction.Action != nil {
+					action = *interaction.Action
+				}
+				if interaction.CreatedAt == nil || !scan.Match(*interaction.CreatedAt, action) {
+					return nil
+				}
+
+				return fn(interaction)
+			})
+		})
+	})
+}
@@ -0,0 +1,187 @@
+package kv
+
+import (
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// archiveBucket holds the expired partitions (at the same path as in the
+// store) when they are archived instead of deleted
+const archiveBucket = "archive"
+
+// Prune will remove (or archive) the expired partitions of interactions and of the sandboxes,
+// remove the interactions that are not kept from the other expired partitions, and remove
+// the expired periods of the history. There are no quarantined files in the store.
+func (c *Client) Prune(prune *db.Prune) (*db.PruneResult, error) {
+	result := &db.PruneResult{}
+	err := c.bolt.Update(func(tx *bolt.Tx) error {
+		interactions := tx.Bucket([]byte(db.Interactions))
+		for _, name := range partitions(interactions) {
+			end, ok := db.PartitionEnd(name)
+			if !ok {
+				continue
+			}
+
+			expired := prune.Expired(end)
+			if !expired && (prune.Before.IsZero() || !end.Before(prune.Before)) {
+				continue
+			}
+
+			count, err := prunePartition(tx, interactions, name, func(interaction *types.Interaction) bool {
+				var action string
+				if interaction.Action != nil {
+					action = *interaction.Action
+				}
+				return expired || !prune.Keeps(action, *interaction.CreatedAt)
+			}, prune.Archive, db.Interactions)
+			if err != nil {
+				return errors.New(err, nil)
+			}
+
+			if expired {
+				result.Partitions++
+			} else if count > 0 {
+				result.Rewritten++
+				result.Interactions += count
+			}
+		}
+
+		// the sandboxed interactions are not kept
+		sandboxes := &db.Prune{
+			Before: prune.Before,
+		}
+		root := tx.Bucket([]byte(db.Sandbox))
+		for _, sandbox := range partitions(root) {
+			bucket := root.Bucket([]byte(sandbox))
+			for _, name := range partitions(bucket) {
+				end, ok := db.PartitionEnd(name)
+				if !ok || !sandboxes.Expired(end) {
+					continue
+				}
+
+				_, err := prunePartition(tx, bucket, name, func(*types.Interaction) bool {
+					return true
+				}, prune.Archive, db.Sandbox, sandbox)
+				if err != nil {
+					return errors.New(err, nil)
+				}
+				result.Partitions++
+			}
+		}
+
+		if prune.HistoryPeriods == 0 {
+			return nil
+		}
+
+		removed, err := pruneHistory(tx, prune.HistoryPeriods, prune.Now)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+		result.History = removed
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	return result, nil
+}
+
+// partitions will return the names of the nested buckets of the bucket
+func partitions(bucket *bolt.Bucket) []string {
+	names := make([]string, 0)
+	bucket.ForEach(func(name, value []byte) error {
+		if value == nil {
+			names = append(names, string(name))
+		}
+		return nil
+	})
+
+	return names
+}
+
+// prunePartition will remove the interactions of the partition (and their index entries) that
+// are removed, and the partition once it is empty. The removed interactions are moved to the
+// archive (at the path of the partition) if archive is set. It returns the number of removed interactions.
+func prunePartition(tx *bolt.Tx, bucket *bolt.Bucket, name string, remove func(interaction *types.Interaction) bool, archive bool, path ...string) (int64, error) {
+	partition := bucket.Bucket([]byte(name))
+
+	var archived *bolt.Bucket
+	if archive {
+		var err error
+		archived, err = tx.CreateBucketIfNotExists([]byte(archiveBucket))
+		for _, p := range append(path, name) {
+			if err != nil {
+				break
+			}
+			archived, err = archived.CreateBucketIfNotExists([]byte(p))
+		}
+		if err != nil {
+			return 0, errors.New(err, map[string]interface{}{
+				"partition": name,
+			})
+		}
+	}
+
+	removed := make([][]byte, 0)
+	var kept int64
+	err := partition.ForEach(func(key, data []byte) error {
+		interaction, err := decodeRecord(data)
+		if err != nil {
+			return errors.New(err, map[string]interface{}{
+				"partition": name,
+			})
+		}
+
+		if interaction.CreatedAt == nil || !remove(interaction) {
+			kept++
+			return nil
+		}
+		removed = append(removed, append([]byte{}, key...))
+
+		if archived != nil {
+			err := archived.Put(key, data)
+			if err != nil {
+				return errors.New(err, nil)
+			}
+		}
+
+		// sandboxed interactions are not indexed
+		if path[0] != db.Interactions {
+			return nil
+		}
+		index := tx.Bucket([]byte(indexBucket)).Bucket([]byte(types.UserProfileID(interaction.User())))
+		if index == nil {
+			return nil
+		}
+
+		return index.Delete(append(timeKey(*interaction.CreatedAt), key...))
+	})
+	if err != nil {
+		return 0, errors.New(err, nil)
+	}
+
+	for _, key := range removed {
+		err := partition.Delete(key)
+		if err != nil {
+			return 0, errors.New(err, map[string]interface{}{
+				"partition": name,
+			})
+		}
+	}
+
+	if kept == 0 {
+		err := bucket.DeleteBucket([]byte(name))
+		if err != nil {
+			return 0, errors.New(err, map[string]interface{}{
+				"partition": name,
+			})
+		}
+	}
+
+	return int64(len(removed)), nil
+}
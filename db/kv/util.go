@@ -0,0 +1,237 @@
+package kv
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+// itemKey will return the key of an item (the same as its filename in the local store)
+func itemKey(resource string, item interface{}) string {
+	var key string
+	switch resource {
+	case db.Endpoints:
+		key = item.(*types.Endpoint).ID.String()
+	case db.Origins:
+		key = item.(*types.Origin).ID.String()
+	case db.Entities:
+		key = item.(*types.Entity).ID.String()
+	case db.OriginStats, db.EntityStats, db.EndpointStats:
+		i := item.(*types.IntervalStats)
+		key = fmt.Sprintf("%s-%s", i.ID.String(), i.Interval)
+	case db.Properties:
+		key = item.(*types.Property).Name
+	case db.PropertyStats:
+		i := item.(*types.PropertyStats)
+		key = fmt.Sprintf("%s-%s", i.Name, i.SpanType)
+	case db.Summaries:
+		key = item.(*types.Summary).Interval
+	case db.Settings, db.Consent, db.FilterHits:
+		key = resource
+	case db.Identities:
+		key = item.(*types.Identity).ID
+	case db.Users:
+		key = item.(*types.UserProfile).ID
+	case db.Tombstones:
+		key = item.(*types.Tombstone).ID
+	case db.DeletionReports:
+		key = item.(*types.DeletionReport).ID.String()
+	}
+
+	return key
+}
+
+// whereKey will return the key of the item found in the where clause ("" if there is none)
+func whereKey(resource string, where db.Where) string {
+	// single document resources
+	switch resource {
+	case db.Settings, db.Consent, db.FilterHits:
+		return resource
+	}
+
+	wm, ok := where.(db.WhereMap)
+	if !ok {
+		return ""
+	}
+
+	i, ok := wm["item.id"]
+	if !ok {
+		name, _ := wm["item.name"].(string)
+		spanType, _ := wm["item.spanType"].(string)
+
+		switch resource {
+		case db.Summaries:
+			return spanType
+		case db.Properties:
+			return name
+		case db.PropertyStats:
+			if name == "" || spanType == "" {
+				return ""
+			}
+			return fmt.Sprintf("%s-%s", name, spanType)
+		}
+
+		return ""
+	}
+
+	switch resource {
+	case db.Identities, db.Users, db.Tombstones:
+		id, _ := i.(string)
+		return id
+	}
+
+	id, ok := i.(*types.UUID)
+	if !ok {
+		return ""
+	}
+
+	switch resource {
+	case db.EndpointStats, db.OriginStats, db.EntityStats:
+		interval, ok := wm["item.interval"].(string)
+		if !ok {
+			return ""
+		}
+
+		return fmt.Sprintf("%s-%s", id, interval)
+	}
+
+	return id.String()
+}
+
+func decode(resource string, b []byte) (interface{}, error) {
+	var item interface{}
+	switch resource {
+	case db.Endpoints:
+		item = &types.Endpoint{}
+	case db.Origins:
+		item = &types.Origin{}
+	case db.Entities:
+		item = &types.Entity{}
+	case db.EndpointStats, db.OriginStats, db.EntityStats:
+		item = &types.IntervalStats{}
+	case db.Properties:
+		item = &types.Property{}
+	case db.PropertyStats:
+		item = &types.PropertyStats{}
+	case db.Summaries:
+		item = &types.Summary{}
+	case db.Settings:
+		item = &types.Settings{}
+	case db.Consent:
+		item = &types.ConsentStats{}
+	case db.FilterHits:
+		item = &map[string]*types.FilterHit{}
+	case db.Identities:
+		item = &types.Identity{}
+	case db.Users:
+		item = &types.UserProfile{}
+	case db.Tombstones:
+		item = &types.Tombstone{}
+	case db.DeletionReports:
+		item = &types.DeletionReport{}
+	default:
+		return nil, errors.New(types.ErrAssertion, map[string]interface{}{
+			"resource": resource,
+		})
+	}
+
+	dec := gob.NewDecoder(bytes.NewReader(b))
+	err := dec.Decode(item)
+	if err != nil {
+		return nil, errors.New(err, map[string]interface{}{
+			"resource": resource,
+		})
+	}
+
+	return item, nil
+}
+
+func encode(item interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	err := enc.Encode(item)
+	if err != nil {
+		return []byte{}, errors.New(err, nil)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// list will return the decoded items as a typed list (the same as the local store)
+func list(resource string, items []interface{}) interface{} {
+	switch resource {
+	case db.Endpoints:
+		list := make([]*types.Endpoint, 0, len(items))
+		for _, item := range items {
+			list = append(list, item.(*types.Endpoint))
+		}
+		return list
+	case db.Origins:
+		list := make([]*types.Origin, 0, len(items))
+		for _, item := range items {
+			list = append(list, item.(*types.Origin))
+		}
+		return list
+	case db.OriginStats, db.EndpointStats, db.EntityStats:
+		list := make([]*types.IntervalStats, 0, len(items))
+		for _, item := range items {
+			list = append(list, item.(*types.IntervalStats))
+		}
+		return list
+	case db.Entities:
+		list := make([]*types.Entity, 0, len(items))
+		for _, item := range items {
+			list = append(list, item.(*types.Entity))
+		}
+		return list
+	case db.Properties:
+		list := make([]*types.Property, 0, len(items))
+		for _, item := range items {
+			list = append(list, item.(*types.Property))
+		}
+		return list
+	case db.PropertyStats:
+		list := make([]*types.PropertyStats, 0, len(items))
+		for _, item := range items {
+			list = append(list, item.(*types.PropertyStats))
+		}
+		return list
+	case db.Summaries:
+		list := make([]*types.Summary, 0, len(items))
+		for _, item := range items {
+			list = append(list, item.(*types.Summary))
+		}
+		return list
+	case db.Identities:
+		list := make([]*types.Identity, 0, len(items))
+		for _, item := range items {
+			list = append(list, item.(*types.Identity))
+		}
+		return list
+	case db.Users:
+		list := make([]*types.UserProfile, 0, len(items))
+		for _, item := range items {
+			list = append(list, item.(*types.UserProfile))
+		}
+		return list
+	case db.Tombstones:
+		list := make([]*types.Tombstone, 0, len(items))
+		for _, item := range items {
+			list = append(list, item.(*types.Tombstone))
+		}
+		return list
+	case db.DeletionReports:
+		list := make([]*types.DeletionReport, 0, len(items))
+		for _, item := range items {
+			list = append(list, item.(*types.DeletionReport))
+		}
+		return list
+	}
+
+	return nil
+}
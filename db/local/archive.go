@@ -0,0 +1,159 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+// archivedInteractions will return the archived interactions of the users (found in the
+// where clause). The archive is not indexed, so every archived partition is scanned.
+func (c *Client) archivedInteractions(where db.Where) ([]*types.Interaction, error) {
+	users, err := sandboxUsers(where)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	list := make([]*types.Interaction, 0)
+	err = c.ScanArchive(func(path string, interaction *types.Interaction) error {
+		if _, ok := users[interaction.User().String()]; ok {
+			list = append(list, interaction)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	return list, nil
+}
+
+// ScanArchive will call the function for every archived interaction with the path of its
+// partition's directory relative to the archive (`interactions` or `sandbox/<name>`).
+// It is used to migrate the archive.
+func (c *Client) ScanArchive(fn func(path string, interaction *types.Interaction) error) error {
+	// no partition is archived while it is scanned
+	c.blocksMutex.Lock()
+	defer c.blocksMutex.Unlock()
+
+	root := filepath.Join(c.basepath, archiveDir)
+	return c.walkArchive(func(filename, suffix string) error {
+		path, err := filepath.Rel(root, filepath.Dir(filename))
+		if err != nil {
+			return errors.New(err, map[string]interface{}{
+				"filename": filename,
+			})
+		}
+		path = filepath.ToSlash(path)
+
+		if suffix == ".csv" {
+			return scanPartition(filename, path, fn)
+		}
+
+		return scanBlocks(filename, func(offset, length int64, b *block) error {
+			for row := 0; row < b.Len(); row++ {
+				interaction, err := b.interaction(row)
+				if err != nil {
+					return errors.New(err, nil)
+				}
+
+				err = fn(path, interaction)
+				if err != nil {
+					return errors.New(err, nil)
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// eraseArchive will rewrite every archived partition that holds interactions of the users
+// (found in the where clause) without those interactions. It returns the number of removed
+// interactions per archived partition (`archive/<path of the partition>`).
+func (c *Client) eraseArchive(where db.Where) (map[string]int64, error) {
+	users, err := sandboxUsers(where)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+	remove := func(user types.User, action string, createdAt time.Time) bool {
+		_, ok := users[user.String()]
+		return ok
+	}
+
+	c.blocksMutex.Lock()
+	defer c.blocksMutex.Unlock()
+
+	removed := make(map[string]int64)
+	err = c.walkArchive(func(filename, suffix string) error {
+		var count int64
+		var err error
+		if suffix == ".csv" {
+			count, _, err = c.rewriteCSV(filename, "", remove, false)
+		} else {
+			count, _, err = c.rewriteBlocksFile(filename, "", remove, false)
+		}
+		if err != nil {
+			return errors.New(err, nil)
+		}
+
+		if count > 0 {
+			removed[strings.TrimSuffix(c.relative(filename), suffix)] = count
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	return removed, nil
+}
+
+// walkArchive will call the function with every archived partition file (of interactions and
+// of the sandboxes) and its suffix. The archived copies of a partition are suffixed with
+// their time when the partition was already archived (see archiveFile).
+func (c *Client) walkArchive(fn func(filename, suffix string) error) error {
+	root := filepath.Join(c.basepath, archiveDir)
+	err := filepath.Walk(root, func(filename string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		suffix := archivedSuffix(info.Name())
+		if suffix == "" {
+			return nil
+		}
+
+		return fn(filename, suffix)
+	})
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"dir": root,
+		})
+	}
+
+	return nil
+}
+
+// archivedSuffix will return the suffix (".csv" or blockSuffix) of an archived
+// partition file, or "" if the file is not a partition
+func archivedSuffix(name string) string {
+	for _, suffix := range []string{".csv", blockSuffix} {
+		if strings.HasSuffix(name, suffix) || strings.Contains(name, suffix+".") {
+			return suffix
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,70 @@
+package local
+
+import (
+	"testing"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+)
+
+func TestEraseArchive(t *testing.T) {
+	interactions := testInteractions()
+	c := newTestClient(t, t.TempDir())
+
+	for _, i := range interactions {
+		if result := c.Do(&db.Op{Resource: db.Interactions, Type: db.Create, Item: i}); result.Error != nil {
+			t.Fatal(result.Error)
+		}
+	}
+
+	// every partition expires and is archived
+	result, err := c.Prune(&db.Prune{
+		Before:  time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC),
+		Archive: true,
+	})
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if result.Partitions == 0 {
+		t.Fatalf("Prune() archived no partitions")
+	}
+
+	archived := func(u types.User) int {
+		t.Helper()
+
+		result := c.Do(&db.Op{Resource: db.Archive, Type: db.List, Where: db.WhereMap{"item.user": []types.User{u}}})
+		if result.Error != nil {
+			t.Fatalf("list the archived interactions of %v: error = %v", u, result.Error)
+		}
+		return len(result.Item.([]*types.Interaction))
+	}
+
+	erased, kept := interactions[0].User(), interactions[1].User()
+	if n := archived(erased); n != 1 {
+		t.Fatalf("archived interactions of %v = %d, want 1", erased, n)
+	}
+
+	deleted := c.Do(&db.Op{Resource: db.Archive, Type: db.Delete, Where: db.WhereMap{"item.user": []types.User{erased}}})
+	if deleted.Error != nil {
+		t.Fatalf("erase the archive: error = %v", deleted.Error)
+	}
+
+	var total int64
+	for partition, count := range deleted.Item.(map[string]int64) {
+		if count != 1 {
+			t.Errorf("removed from %s = %d, want 1", partition, count)
+		}
+		total += count
+	}
+	if total != 1 {
+		t.Errorf("removed = %v, want 1 interaction", deleted.Item)
+	}
+
+	if n := archived(erased); n != 0 {
+		t.Errorf("archived interactions of %v after the erasure = %d, want 0", erased, n)
+	}
+	if n := archived(kept); n != 1 {
+		t.Errorf("archived interactions of %v after the erasure = %d, want 1", kept, n)
+	}
+}
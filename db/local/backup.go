@@ -0,0 +1,103 @@
+package local
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/EngaugeAI/engauge/db"
+
+	"github.com/JKhawaja/errors"
+)
+
+// Store --
+func (c *Client) Store() string {
+	return "local"
+}
+
+// Snapshot will call the function with every file of the basepath (the pending interactions
+// are written first). No operation is done, no transaction is committed and no partition is
+// pruned until the function returns for the last file (the scans only wait for the blocks).
+// The quarantined files, the backups and the temporary files are not part of the snapshot.
+func (c *Client) Snapshot(fn func(file *db.SnapshotFile) error) error {
+	c.snapshotMutex.Lock()
+	defer c.snapshotMutex.Unlock()
+	c.journalMutex.Lock()
+	defer c.journalMutex.Unlock()
+	c.blocksMutex.Lock()
+	defer c.blocksMutex.Unlock()
+	c.historyMutex.Lock()
+	defer c.historyMutex.Unlock()
+
+	err := c.writeBlocks()
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	// a previous commit that could not be applied
+	err = c.replayJournal()
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	files := make([]*db.SnapshotFile, 0)
+	err = filepath.Walk(c.basepath, func(filename string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name := filepath.ToSlash(c.relative(filename))
+		if info.IsDir() {
+			if name == quarantineDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || internalFile(name) {
+			return nil
+		}
+
+		files = append(files, &db.SnapshotFile{
+			Name:      name,
+			Size:      info.Size(),
+			Partition: partitionFile(name),
+			Open: func() (io.ReadCloser, error) {
+				return os.Open(filename)
+			},
+		})
+
+		return nil
+	})
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"basepath": c.basepath,
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+
+	for _, file := range files {
+		err := fn(file)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+	}
+
+	return nil
+}
+
+// partitionFile will return whether or not the file (relative to the basepath)
+// is a partition of interactions, of a sandbox or of the archive
+func partitionFile(name string) bool {
+	for _, dir := range []string{db.Interactions, db.Sandbox, archiveDir} {
+		if strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,126 @@
+package local
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+)
+
+func TestBackupRestore(t *testing.T) {
+	interactions := testInteractions()
+
+	tests := []struct {
+		name  string
+		write func(t *testing.T, c *Client)
+		base  bool
+	}{
+		{
+			name: "full",
+			write: func(t *testing.T, c *Client) {
+				for _, i := range interactions[:2] {
+					if result := c.Do(&db.Op{Resource: db.Interactions, Type: db.Create, Item: i}); result.Error != nil {
+						t.Fatal(result.Error)
+					}
+				}
+			},
+		},
+		{
+			name: "incremental",
+			write: func(t *testing.T, c *Client) {
+				for _, i := range interactions[2:] {
+					if result := c.Do(&db.Op{Resource: db.Interactions, Type: db.Create, Item: i}); result.Error != nil {
+						t.Fatal(result.Error)
+					}
+				}
+				if result := c.Do(&db.Op{Resource: db.Properties, Type: db.Update, Item: &types.Property{Name: "plan", Type: "number"}}); result.Error != nil {
+					t.Fatal(result.Error)
+				}
+			},
+			base: true,
+		},
+	}
+
+	dir := t.TempDir()
+	c := newTestClient(t, filepath.Join(dir, "store"))
+
+	// the documents and the interactions of the base backup
+	result := c.Do(&db.Op{Resource: db.Properties, Type: db.Create, Item: &types.Property{Name: "plan", Type: "string"}})
+	if result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	if result := c.Do(&db.Op{Resource: db.Interactions, Type: db.Create, Item: interactions[0]}); result.Error != nil {
+		t.Fatal(result.Error)
+	}
+
+	var buf bytes.Buffer
+	bm, err := db.WriteBackup(&buf, c, nil)
+	if err != nil {
+		t.Fatalf("WriteBackup() error = %v", err)
+	}
+	baseFile := filepath.Join(dir, "base.tar.gz")
+	err = ioutil.WriteFile(baseFile, buf.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.write(t, c)
+
+			var base *db.Manifest
+			if tt.base {
+				base = bm
+			}
+
+			var buf bytes.Buffer
+			m, err := db.WriteBackup(&buf, c, base)
+			if err != nil {
+				t.Fatalf("WriteBackup() error = %v", err)
+			}
+			filename := filepath.Join(t.TempDir(), "backup.tar.gz")
+			err = ioutil.WriteFile(filename, buf.Bytes(), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			basepath := filepath.Join(t.TempDir(), "restored")
+			_, err = db.RestoreBackup(filename, baseFile, m.Store, basepath)
+			if err != nil {
+				t.Fatalf("RestoreBackup() error = %v", err)
+			}
+			restored := newTestClient(t, basepath)
+
+			// the restored store holds the same documents and interactions
+			want, err := readProperty(c, "plan")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := readProperty(restored, "plan")
+			if err != nil {
+				t.Fatalf("read the restored property: error = %v", err)
+			}
+			if got.Type != want.Type {
+				t.Errorf("restored property type = %q, want %q", got.Type, want.Type)
+			}
+
+			for _, i := range interactions {
+				users := []types.User{i.User()}
+				wantList := c.Do(&db.Op{Resource: db.Interactions, Type: db.List, Where: db.WhereMap{"item.user": users}})
+				gotList := restored.Do(&db.Op{Resource: db.Interactions, Type: db.List, Where: db.WhereMap{"item.user": users}})
+				if wantList.Error != nil || gotList.Error != nil {
+					t.Fatalf("list the interactions of %v: error = %v, %v", users, wantList.Error, gotList.Error)
+				}
+
+				wantCount := len(wantList.Item.([]*types.Interaction))
+				gotCount := len(gotList.Item.([]*types.Interaction))
+				if gotCount != wantCount {
+					t.Errorf("restored interactions of %v = %d, want %d", users, gotCount, wantCount)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package local
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"time"
+
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+// blockHeader is the length and the CRC32 of the (compressed) block that follows it
+const blockHeader = 8
+
+// block is a columnar block of interactions. The low-cardinality dimensions are
+// dictionary-encoded (0 is a missing value), and the block is gob-encoded and
+// compressed with gzip. A block holds the interactions of a single flush.
+type block struct {
+	Dictionary []string
+
+	// dictionary-encoded columns
+	Actions      []uint32
+	EntityTypes  []uint32
+	OriginTypes  []uint32
+	UserTypes    []uint32
+	DeviceTypes  []uint32
+	SessionTypes []uint32
+
+	// plain columns ("" is a missing value)
+	EntityIDs  []string
+	OriginIDs  []string
+	UserIDs    []string
+	DeviceIDs  []string
+	SessionIDs []string
+	Timestamps []string
+	Properties []string // JSON
+
+	// times (unix nanoseconds, and the offset of their zone in seconds)
+	CreatedAt     []int64
+	CreatedZone   []int32
+	ReceivedAt    []int64
+	ReceivedZone  []int32
+	HasReceivedAt []bool
+
+	dictionary map[string]uint32
+}
+
+func newBlock(interactions []*types.Interaction) *block {
+	b := &block{
+		Dictionary: []string{""},
+		dictionary: map[string]uint32{"": 0},
+	}
+
+	for _, i := range interactions {
+		b.add(i)
+	}
+
+	return b
+}
+
+func (b *block) add(i *types.Interaction) {
+	b.Actions = append(b.Actions, b.encode(i.Action))
+	b.EntityTypes = append(b.EntityTypes, b.encode(i.EntityType))
+	b.OriginTypes = append(b.OriginTypes, b.encode(i.OriginType))
+	b.UserTypes = append(b.UserTypes, b.encode(i.UserType))
+	b.DeviceTypes = append(b.DeviceTypes, b.encode(i.DeviceType))
+	b.SessionTypes = append(b.SessionTypes, b.encode(i.SessionType))
+
+	b.EntityIDs = append(b.EntityIDs, pstr(i.EntityID))
+	b.OriginIDs = append(b.OriginIDs, pstr(i.OriginID))
+	b.UserIDs = append(b.UserIDs, pstr(i.UserID))
+	b.DeviceIDs = append(b.DeviceIDs, pstr(i.DeviceID))
+	b.SessionIDs = append(b.SessionIDs, pstr(i.SessionID))
+	b.Timestamps = append(b.Timestamps, pstr(i.Timestamp))
+
+	var properties string
+	if i.Properties != nil {
+		data, _ := json.Marshal(i.Properties)
+		properties = string(data)
+	}
+	b.Properties = append(b.Properties, properties)
+
+	createdAt, createdZone := timeColumn(i.CreatedAt)
+	b.CreatedAt = append(b.CreatedAt, createdAt)
+	b.CreatedZone = append(b.CreatedZone, createdZone)
+
+	receivedAt, receivedZone := timeColumn(i.ReceivedAt)
+	b.ReceivedAt = append(b.ReceivedAt, receivedAt)
+	b.ReceivedZone = append(b.ReceivedZone, receivedZone)
+	b.HasReceivedAt = append(b.HasReceivedAt, i.ReceivedAt != nil)
+}
+
+func (b *block) encode(value *string) uint32 {
+	v := pstr(value)
+	code, ok := b.dictionary[v]
+	if !ok {
+		code = uint32(len(b.Dictionary))
+		b.Dictionary = append(b.Dictionary, v)
+		b.dictionary[v] = code
+	}
+
+	return code
+}
+
+func (b *block) decode(code uint32) *string {
+	if int(code) >= len(b.Dictionary) {
+		return nil
+	}
+
+	return strp(b.Dictionary[code])
+}
+
+// Len --
+func (b *block) Len() int {
+	return len(b.Actions)
+}
+
+// interaction will return the interaction of a row of the block
+func (b *block) interaction(row int) (*types.Interaction, error) {
+	if row < 0 || row >= b.Len() {
+		return nil, errors.New(types.ErrLength, map[string]interface{}{
+			"row": row,
+		})
+	}
+
+	i := &types.Interaction{
+		Action:      b.decode(b.Actions[row]),
+		EntityType:  b.decode(b.EntityTypes[row]),
+		EntityID:    strp(b.EntityIDs[row]),
+		OriginType:  b.decode(b.OriginTypes[row]),
+		OriginID:    strp(b.OriginIDs[row]),
+		UserType:    b.decode(b.UserTypes[row]),
+		UserID:      strp(b.UserIDs[row]),
+		DeviceType:  b.decode(b.DeviceTypes[row]),
+		DeviceID:    strp(b.DeviceIDs[row]),
+		SessionType: b.decode(b.SessionTypes[row]),
+		SessionID:   strp(b.SessionIDs[row]),
+		Timestamp:   strp(b.Timestamps[row]),
+	}
+
+	createdAt := b.createdAt(row)
+	i.CreatedAt = &createdAt
+
+	if b.HasReceivedAt[row] {
+		receivedAt := columnTime(b.ReceivedAt[row], b.ReceivedZone[row])
+		i.ReceivedAt = &receivedAt
+	}
+
+	if b.Properties[row] != "" {
+		err := json.Unmarshal([]byte(b.Properties[row]), &i.Properties)
+		if err != nil {
+			return nil, errors.New(err, nil)
+		}
+	}
+
+	return i, nil
+}
+
+// action will return the action of a row without decoding the whole interaction
+func (b *block) action(row int) string {
+	return b.Dictionary[b.Actions[row]]
+}
+
+// createdAt will return the creation time of a row
+func (b *block) createdAt(row int) time.Time {
+	return columnTime(b.CreatedAt[row], b.CreatedZone[row])
+}
+
+// user will return the user of a row without decoding the whole interaction
+func (b *block) user(row int) types.User {
+	return types.User{
+		Type: b.Dictionary[b.UserTypes[row]],
+		ID:   b.UserIDs[row],
+	}
+}
+
+func timeColumn(t *time.Time) (int64, int32) {
+	if t == nil {
+		return 0, 0
+	}
+
+	_, offset := t.Zone()
+	return t.UnixNano(), int32(offset)
+}
+
+func columnTime(nanos int64, offset int32) time.Time {
+	t := time.Unix(0, nanos)
+	if offset == 0 {
+		return t.UTC()
+	}
+
+	return t.In(time.FixedZone("", int(offset)))
+}
+
+// frame will compress the block and prefix it with its header
+func (b *block) frame() ([]byte, error) {
+	return frame(b)
+}
+
+// unframe will check and decode a framed block
+func unframe(data []byte) (*block, error) {
+	b := &block{}
+	err := unframeItem(data, b)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	return b, nil
+}
+
+// frame will gob-encode and compress the item, and prefix it with its header
+func frame(item interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, blockHeader))
+
+	zw := gzip.NewWriter(&buf)
+	err := gob.NewEncoder(zw).Encode(item)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	err = zw.Close()
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	data := buf.Bytes()
+	payload := data[blockHeader:]
+	binary.BigEndian.PutUint32(data[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(data[4:8], crc32.ChecksumIEEE(payload))
+
+	return data, nil
+}
+
+// unframeItem will check and decode a framed item
+func unframeItem(data []byte, item interface{}) error {
+	if len(data) < blockHeader {
+		return errors.New(io.ErrUnexpectedEOF, nil)
+	}
+
+	length := binary.BigEndian.Uint32(data[0:4])
+	payload := data[blockHeader:]
+	if int(length) != len(payload) {
+		return errors.New(io.ErrUnexpectedEOF, map[string]interface{}{
+			"length": length,
+		})
+	}
+
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(data[4:8]) {
+		return errors.New(types.ErrChecksum, nil)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return errors.New(err, nil)
+	}
+	defer zr.Close()
+
+	err = gob.NewDecoder(zr).Decode(item)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	return nil
+}
+
+func pstr(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func strp(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
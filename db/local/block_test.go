@@ -0,0 +1,179 @@
+package local
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/EngaugeAI/engauge/types"
+)
+
+func testInteractions() []*types.Interaction {
+	createdAt := time.Date(2026, 10, 18, 15, 30, 0, 123456789, time.UTC)
+	zoned := time.Date(2026, 10, 18, 17, 30, 0, 0, time.FixedZone("", 2*60*60))
+	receivedAt := createdAt.Add(time.Second)
+
+	return []*types.Interaction{
+		{
+			Action:      strp("view"),
+			EntityType:  strp("article"),
+			EntityID:    strp("a-1"),
+			OriginType:  strp("page"),
+			OriginID:    strp("/home"),
+			UserType:    strp("visitor"),
+			UserID:      strp("u-1"),
+			DeviceType:  strp("browser"),
+			DeviceID:    strp("d-1"),
+			SessionType: strp("web"),
+			SessionID:   strp("s-1"),
+			Timestamp:   strp("2026-10-18T15:30:00.123456789Z"),
+			CreatedAt:   &createdAt,
+			ReceivedAt:  &receivedAt,
+			Properties: map[string]interface{}{
+				"plan":  "pro",
+				"seats": float64(3),
+			},
+		},
+		{
+			// the same dimensions as the first row, in another zone
+			Action:     strp("view"),
+			EntityType: strp("article"),
+			EntityID:   strp("a-2"),
+			UserType:   strp("visitor"),
+			UserID:     strp("u-2"),
+			CreatedAt:  &zoned,
+		},
+		{
+			// only the required fields
+			Action:    strp("signup"),
+			CreatedAt: &createdAt,
+		},
+	}
+}
+
+func TestBlockFrame(t *testing.T) {
+	tests := []struct {
+		name         string
+		interactions []*types.Interaction
+		wantDict     int
+	}{
+		{
+			name:         "empty",
+			interactions: []*types.Interaction{},
+			wantDict:     1,
+		},
+		{
+			name:         "interactions",
+			interactions: testInteractions(),
+			// "", view, article, page, visitor, browser, web, signup
+			wantDict: 8,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBlock(tt.interactions)
+			if len(b.Dictionary) != tt.wantDict {
+				t.Errorf("dictionary = %q, want %d values", b.Dictionary, tt.wantDict)
+			}
+
+			data, err := b.frame()
+			if err != nil {
+				t.Fatalf("frame() error = %v", err)
+			}
+
+			decoded, err := unframe(data)
+			if err != nil {
+				t.Fatalf("unframe() error = %v", err)
+			}
+			if decoded.Len() != len(tt.interactions) {
+				t.Fatalf("Len() = %d, want %d", decoded.Len(), len(tt.interactions))
+			}
+
+			for row, want := range tt.interactions {
+				got, err := decoded.interaction(row)
+				if err != nil {
+					t.Fatalf("interaction(%d) error = %v", row, err)
+				}
+
+				gotJSON, _ := json.Marshal(got)
+				wantJSON, _ := json.Marshal(want)
+				if string(gotJSON) != string(wantJSON) {
+					t.Errorf("interaction(%d) = %s, want %s", row, gotJSON, wantJSON)
+				}
+
+				if decoded.action(row) != *want.Action {
+					t.Errorf("action(%d) = %q, want %q", row, decoded.action(row), *want.Action)
+				}
+				if !decoded.createdAt(row).Equal(*want.CreatedAt) {
+					t.Errorf("createdAt(%d) = %v, want %v", row, decoded.createdAt(row), *want.CreatedAt)
+				}
+				if decoded.user(row) != want.User() {
+					t.Errorf("user(%d) = %v, want %v", row, decoded.user(row), want.User())
+				}
+			}
+
+			_, err = decoded.interaction(len(tt.interactions))
+			if !errors.Is(err, types.ErrLength) {
+				t.Errorf("interaction(%d) error = %v, want %v", len(tt.interactions), err, types.ErrLength)
+			}
+		})
+	}
+}
+
+func TestUnframeCorrupt(t *testing.T) {
+	data, err := newBlock(testInteractions()).frame()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    func() []byte
+		wantErr error
+	}{
+		{
+			name: "short header",
+			data: func() []byte {
+				return data[:blockHeader-1]
+			},
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name: "truncated payload",
+			data: func() []byte {
+				return data[:len(data)-1]
+			},
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name: "flipped payload byte",
+			data: func() []byte {
+				corrupt := append([]byte{}, data...)
+				corrupt[len(corrupt)/2] ^= 0xff
+				return corrupt
+			},
+			wantErr: types.ErrChecksum,
+		},
+		{
+			name: "flipped checksum byte",
+			data: func() []byte {
+				corrupt := append([]byte{}, data...)
+				corrupt[4] ^= 0xff
+				return corrupt
+			},
+			wantErr: types.ErrChecksum,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := unframe(tt.data())
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("unframe() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
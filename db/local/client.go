@@ -2,8 +2,8 @@ package local
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
+	"sync"
 
 	"github.com/EngaugeAI/engauge/db"
 	"github.com/EngaugeAI/engauge/types"
@@ -13,13 +13,30 @@ import (
 
 // Client --
 type Client struct {
-	basepath string
+	basepath      string
+	appended      map[string]struct{} // files appended to since the last sync
+	appendedMutex *sync.Mutex
+	journalMutex  *sync.Mutex       // one transaction is committed at a time
+	pending       map[string]*block // interactions that have not been written yet (by partition)
+	pendingCount  int
+	blocksMutex   *sync.Mutex
+	historyMutex  *sync.Mutex   // the history files are appended to and pruned one at a time
+	snapshotMutex *sync.RWMutex // no file is written while a snapshot is taken
+	sandboxMutex  *sync.Mutex   // the sandbox partitions are not appended to while they are erased
 }
 
 // NewClient --
 func NewClient(basepath string) (*Client, error) {
 	c := &Client{
-		basepath: basepath,
+		basepath:      basepath,
+		appended:      make(map[string]struct{}),
+		appendedMutex: &sync.Mutex{},
+		journalMutex:  &sync.Mutex{},
+		pending:       make(map[string]*block),
+		blocksMutex:   &sync.Mutex{},
+		historyMutex:  &sync.Mutex{},
+		snapshotMutex: &sync.RWMutex{},
+		sandboxMutex:  &sync.Mutex{},
 	}
 	err := c.init()
 	if err != nil {
@@ -31,6 +48,11 @@ func NewClient(basepath string) (*Client, error) {
 	return c, nil
 }
 
+// InitCache --
+func (c *Client) InitCache() {
+	db.InitCache(c)
+}
+
 func (c *Client) init() error {
 	err := os.MkdirAll(c.basepath, 0644)
 	if err != nil {
@@ -87,19 +109,92 @@ func (c *Client) init() error {
 		return errors.New(err, nil)
 	}
 
+	err = os.MkdirAll(fmt.Sprintf("%s/%s/", c.basepath, db.Identities), 0644)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	err = os.MkdirAll(fmt.Sprintf("%s/%s/", c.basepath, db.Users), 0644)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	err = os.MkdirAll(fmt.Sprintf("%s/%s/", c.basepath, db.Tombstones), 0644)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	err = os.MkdirAll(fmt.Sprintf("%s/%s/", c.basepath, db.DeletionReports), 0644)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	err = os.MkdirAll(fmt.Sprintf("%s/%s/", c.basepath, indexDir), 0644)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	err = os.MkdirAll(fmt.Sprintf("%s/%s/", c.basepath, quarantineDir), 0644)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	// interrupted writes
+	err = c.recover()
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	// interrupted commits
+	err = c.replayJournal()
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
 	return nil
 }
 
 // Do --
 func (c *Client) Do(op *db.Op) db.Result {
+	// reads can repair (or quarantine) the files as well
+	c.snapshotMutex.RLock()
+	defer c.snapshotMutex.RUnlock()
+
 	var result db.Result
 
 	switch op.Type {
 	case db.Create, db.Update:
 		if op.Resource == db.Interactions {
-			interaction := op.Item.(*types.Interaction)
-			filename := fmt.Sprintf("%s/%s/%s.csv", c.basepath, db.Interactions, interaction.Date())
-			appendCSV(filename, interaction.CSV())
+			// written as a block of its partition by the next sync
+			err := c.bufferInteraction(op.Item.(*types.Interaction))
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+			}
+			return result
+		}
+
+		if op.Resource == db.Sandbox {
+			err := c.appendSandbox(op.Item.(*types.Interaction), op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+			}
+			return result
+		}
+
+		if op.Resource == db.History {
+			err := c.appendHistory(op.Item, op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+			}
 			return result
 		}
 
@@ -115,7 +210,7 @@ func (c *Client) Do(op *db.Op) db.Result {
 			return result
 		}
 
-		err = ioutil.WriteFile(filename, data, 0644)
+		err = writeFile(filename, data)
 		if err != nil {
 			result.Error = errors.New(err, map[string]interface{}{
 				"op":       op.Type,
@@ -126,8 +221,8 @@ func (c *Client) Do(op *db.Op) db.Result {
 		}
 	case db.Read:
 		filename := c.filenameFromWhere(op.Resource, op.Where)
-		data, err := ioutil.ReadFile(filename)
-		if os.IsNotExist(err) {
+		item, err := c.readFile(op.Resource, filename)
+		if err == types.ErrDNE {
 			result.Error = types.ErrDNE
 			return result
 		} else if err != nil {
@@ -139,19 +234,81 @@ func (c *Client) Do(op *db.Op) db.Result {
 			return result
 		}
 
-		item, err := decodeFile(op.Resource, data)
-		if err != nil {
-			result.Error = errors.New(err, map[string]interface{}{
-				"op":       op.Type,
-				"resource": op.Resource,
-				"file":     filename,
-			})
-			return result
-		}
-
 		result.Item = item
 		return result
 	case db.List:
+		if op.Resource == db.Interactions {
+			entries, err := c.userInteractions(op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+				return result
+			}
+
+			offset := pint64(op.Offset)
+			if offset < 0 {
+				offset = 0
+			} else if offset > int64(len(entries)) {
+				offset = int64(len(entries))
+			}
+			end := int64(len(entries))
+			if limit := pint64(op.Limit); limit > 0 && offset+limit < end {
+				end = offset + limit
+			}
+
+			list, err := c.readInteractions(entries[offset:end])
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+				return result
+			}
+			result.Item = list
+			return result
+		}
+
+		if op.Resource == db.Sandbox {
+			list, err := c.sandboxInteractions(op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+				return result
+			}
+			result.Item = list
+			return result
+		}
+
+		if op.Resource == db.Archive {
+			list, err := c.archivedInteractions(op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+				return result
+			}
+			result.Item = list
+			return result
+		}
+
+		if op.Resource == db.History {
+			list, err := c.listHistory(op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+				return result
+			}
+			result.Item = list
+			return result
+		}
+
 		dir := fmt.Sprintf("%s/%s/", c.basepath, op.Resource)
 		filenames, err := c.readDir(dir)
 		if err != nil {
@@ -194,6 +351,20 @@ func (c *Client) Do(op *db.Op) db.Result {
 		result.Item = list
 		return result
 	case db.Count:
+		if op.Resource == db.Interactions {
+			entries, err := c.userInteractions(op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+				return result
+			}
+
+			result.Item = int64(len(entries))
+			return result
+		}
+
 		dir := fmt.Sprintf("%s/%s/", c.basepath, op.Resource)
 
 		filenames, err := c.readDir(dir)
@@ -209,17 +380,78 @@ func (c *Client) Do(op *db.Op) db.Result {
 		result.Item = int64(len(filenames))
 		return result
 	case db.Delete:
+		if op.Resource == db.Sandbox {
+			removed, err := c.eraseSandbox(op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+				return result
+			}
+
+			result.Item = removed
+			return result
+		}
+
+		if op.Resource == db.Archive {
+			removed, err := c.eraseArchive(op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+				return result
+			}
+
+			result.Item = removed
+			return result
+		}
+
+		if op.Resource == db.Interactions {
+			removed, err := c.eraseInteractions(op.Where)
+			if err != nil {
+				result.Error = errors.New(err, map[string]interface{}{
+					"op":       op.Type,
+					"resource": op.Resource,
+				})
+				return result
+			}
+
+			result.Item = removed
+			return result
+		}
+
 		filename := c.filenameFromWhere(op.Resource, op.Where)
-		err := os.Remove(filename)
-		if err != nil {
+		err := removeFile(filename)
+		if err == types.ErrDNE {
+			result.Error = types.ErrDNE
+		} else if err != nil {
 			result.Error = errors.New(err, nil)
 		}
+	case db.Sync:
+		err := c.flushBlocks()
+		if err == nil {
+			err = c.sync()
+		}
+		if err != nil {
+			result.Error = errors.New(err, map[string]interface{}{
+				"op":       op.Type,
+				"resource": op.Resource,
+			})
+		}
 	}
 
 	return result
 }
 
 func (c *Client) filenameFromWhere(resource string, where db.Where) string {
+	// single document resources
+	switch resource {
+	case db.Settings, db.Consent, db.FilterHits:
+		return fmt.Sprintf("%s/%s", c.basepath, resource)
+	}
+
 	if where == nil {
 		return ""
 	}
@@ -273,6 +505,16 @@ func (c *Client) filenameFromWhere(resource string, where db.Where) string {
 		}
 	}
 
+	switch resource {
+	case db.Identities, db.Users, db.Tombstones:
+		id, ok := i.(string)
+		if !ok {
+			return ""
+		}
+
+		return fmt.Sprintf("%s/%s/%s", c.basepath, resource, id)
+	}
+
 	id, ok := i.(*types.UUID)
 	if !ok {
 		return ""
@@ -309,7 +551,15 @@ func (c *Client) readDir(dir string) ([]string, error) {
 		return nil, err
 	}
 
-	return names, nil
+	// backups and temporary files are not documents
+	filtered := names[:0]
+	for _, name := range names {
+		if !internalFile(name) {
+			filtered = append(filtered, name)
+		}
+	}
+
+	return filtered, nil
 }
 
 func (c *Client) filename(resource string, item interface{}) string {
@@ -336,8 +586,20 @@ func (c *Client) filename(resource string, item interface{}) string {
 	case db.Summaries:
 		i := item.(*types.Summary)
 		filename = fmt.Sprintf("%s/%s/%s", c.basepath, resource, i.Interval)
-	case db.Settings:
+	case db.Settings, db.Consent, db.FilterHits:
 		filename = fmt.Sprintf("%s/%s", c.basepath, resource)
+	case db.Identities:
+		i := item.(*types.Identity)
+		filename = fmt.Sprintf("%s/%s/%s", c.basepath, resource, i.ID)
+	case db.Users:
+		i := item.(*types.UserProfile)
+		filename = fmt.Sprintf("%s/%s/%s", c.basepath, resource, i.ID)
+	case db.Tombstones:
+		i := item.(*types.Tombstone)
+		filename = fmt.Sprintf("%s/%s/%s", c.basepath, resource, i.ID)
+	case db.DeletionReports:
+		i := item.(*types.DeletionReport)
+		filename = fmt.Sprintf("%s/%s/%s", c.basepath, resource, i.ID.String())
 	}
 
 	return filename
@@ -0,0 +1,356 @@
+package local
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+// eraseInteractions will rewrite every partition (of blocks or CSV records) that holds
+// interactions of the users (found in the where clause) without those interactions.
+// Every partition is scanned, so interactions stored before the user index existed are
+// erased as well. The user index entries of the rewritten partitions are rebuilt and
+// the index files of the erased users are removed.
+// It returns the number of removed interactions per partition.
+func (c *Client) eraseInteractions(where db.Where) (map[string]int64, error) {
+	wm, ok := where.(db.WhereMap)
+	if !ok {
+		return nil, errors.New(types.ErrAssertion, nil)
+	}
+
+	users, err := whereUsers(wm)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	erased := make(map[string]struct{})
+	for _, user := range users {
+		erased[user.String()] = struct{}{}
+	}
+	remove := func(user types.User, action string, createdAt time.Time) bool {
+		_, ok := erased[user.String()]
+		return ok
+	}
+
+	// the pending interactions of the users are erased as well,
+	// and no blocks are appended while the partitions are rewritten
+	c.blocksMutex.Lock()
+	defer c.blocksMutex.Unlock()
+
+	err = c.writeBlocks()
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	dir := fmt.Sprintf("%s/%s", c.basepath, db.Interactions)
+	filenames, err := c.readDir(dir)
+	if err != nil {
+		return nil, errors.New(err, map[string]interface{}{
+			"dir": dir,
+		})
+	}
+
+	removed := make(map[string]int64)
+	rewritten := make(map[string]struct{})    // partition files
+	rebuilt := make(map[string][]*indexEntry) // index filename -> entries of rewritten partitions
+	for _, name := range filenames {
+		var partition string
+		var count int64
+		var entries map[string][]*indexEntry
+		switch {
+		case strings.HasSuffix(name, ".csv"):
+			partition = strings.TrimSuffix(name, ".csv")
+			count, entries, err = c.rewritePartition(partition, remove, false)
+		case strings.HasSuffix(name, blockSuffix):
+			partition = strings.TrimSuffix(name, blockSuffix)
+			count, entries, err = c.rewriteBlocks(partition, remove, false)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, errors.New(err, nil)
+		}
+
+		if count == 0 {
+			continue
+		}
+
+		removed[partition] += count
+		rewritten[name] = struct{}{}
+		for filename, e := range entries {
+			rebuilt[filename] = append(rebuilt[filename], e...)
+		}
+	}
+
+	for _, user := range users {
+		filename := c.indexFilename(user)
+		err := os.Remove(filename)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, errors.New(err, map[string]interface{}{
+				"filename": filename,
+			})
+		}
+	}
+
+	if len(removed) == 0 {
+		return removed, nil
+	}
+
+	err = c.rebuildIndex(rewritten, rebuilt)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	return removed, nil
+}
+
+// rowFilter reports whether or not the interaction of a row is removed
+type rowFilter func(user types.User, action string, createdAt time.Time) bool
+
+// rewritePartition will rewrite the partition without the interactions that are removed
+// (the partition is archived first if archive is set). It returns the number of removed
+// interactions and the new index entries (by index filename) of the interactions that
+// were kept. The partition is left untouched if no interactions are removed.
+func (c *Client) rewritePartition(partition string, remove rowFilter, archive bool) (int64, map[string][]*indexEntry, error) {
+	filename := fmt.Sprintf("%s/%s/%s.csv", c.basepath, db.Interactions, partition)
+	return c.rewriteCSV(filename, partition, remove, archive)
+}
+
+// rewriteCSV will rewrite the CSV file of the partition without the interactions that are removed
+func (c *Client) rewriteCSV(filename, partition string, remove rowFilter, archive bool) (int64, map[string][]*indexEntry, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return 0, nil, errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+
+	var count int64
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	entries := make(map[string][]*indexEntry)
+
+	r := csv.NewReader(bytes.NewReader(data))
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, nil, errors.New(err, map[string]interface{}{
+				"filename": filename,
+			})
+		}
+
+		interaction, err := types.InteractionFromCSV(record)
+		if err != nil {
+			return 0, nil, errors.New(err, map[string]interface{}{
+				"filename": filename,
+			})
+		}
+
+		var action string
+		if interaction.Action != nil {
+			action = *interaction.Action
+		}
+		var createdAt time.Time
+		if interaction.CreatedAt != nil {
+			createdAt = *interaction.CreatedAt
+		}
+
+		user := interaction.User()
+		if remove(user, action, createdAt) {
+			count++
+			continue
+		}
+
+		offset := int64(buf.Len())
+		err = w.Write(record)
+		if err != nil {
+			return 0, nil, errors.New(err, map[string]interface{}{
+				"filename": filename,
+			})
+		}
+		w.Flush()
+
+		if interaction.CreatedAt != nil {
+			indexFilename := c.indexFilename(user)
+			entries[indexFilename] = append(entries[indexFilename], &indexEntry{
+				Partition: partition,
+				Offset:    offset,
+				Length:    int64(buf.Len()) - offset,
+				Row:       -1,
+				CreatedAt: *interaction.CreatedAt,
+			})
+		}
+	}
+
+	if count == 0 {
+		return 0, nil, nil
+	}
+
+	if archive {
+		err = c.archiveFile(filename, false)
+		if err != nil {
+			return 0, nil, errors.New(err, nil)
+		}
+	}
+
+	err = replaceFile(filename, buf.Bytes())
+	if err != nil {
+		return 0, nil, errors.New(err, nil)
+	}
+
+	return count, entries, nil
+}
+
+// rewriteBlocks will rewrite the partition of blocks without the interactions that
+// are removed (see rewritePartition), and then rebuild the index of the partition.
+func (c *Client) rewriteBlocks(partition string, remove rowFilter, archive bool) (int64, map[string][]*indexEntry, error) {
+	filename := c.partitionFilename(partition, blockSuffix)
+	count, entries, err := c.rewriteBlocksFile(filename, partition, remove, archive)
+	if err != nil {
+		return 0, nil, errors.New(err, nil)
+	}
+	if count == 0 {
+		return 0, nil, nil
+	}
+
+	err = c.rebuildPartitionIndex(partition)
+	if err != nil {
+		return 0, nil, errors.New(err, nil)
+	}
+
+	return count, entries, nil
+}
+
+// rewriteBlocksFile will rewrite the file of blocks of the partition without the interactions that are removed
+func (c *Client) rewriteBlocksFile(filename, partition string, remove rowFilter, archive bool) (int64, map[string][]*indexEntry, error) {
+	var count int64
+	var buf bytes.Buffer
+	entries := make(map[string][]*indexEntry)
+
+	err := scanBlocks(filename, func(offset, length int64, b *block) error {
+		kept := newBlock(nil)
+		for row := 0; row < b.Len(); row++ {
+			if remove(b.user(row), b.action(row), b.createdAt(row)) {
+				count++
+				continue
+			}
+
+			interaction, err := b.interaction(row)
+			if err != nil {
+				return errors.New(err, nil)
+			}
+			kept.add(interaction)
+		}
+
+		if kept.Len() == 0 {
+			return nil
+		}
+
+		data, err := kept.frame()
+		if err != nil {
+			return errors.New(err, nil)
+		}
+
+		offset = int64(buf.Len())
+		buf.Write(data)
+		for row := 0; row < kept.Len(); row++ {
+			indexFilename := c.indexFilename(kept.user(row))
+			entries[indexFilename] = append(entries[indexFilename], &indexEntry{
+				Partition: partition,
+				Offset:    offset,
+				Length:    int64(len(data)),
+				Row:       int64(row),
+				CreatedAt: kept.createdAt(row),
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, nil, errors.New(err, nil)
+	}
+
+	if count == 0 {
+		return 0, nil, nil
+	}
+
+	if archive {
+		err = c.archiveFile(filename, false)
+		if err != nil {
+			return 0, nil, errors.New(err, nil)
+		}
+	}
+
+	err = replaceFile(filename, buf.Bytes())
+	if err != nil {
+		return 0, nil, errors.New(err, nil)
+	}
+
+	return count, entries, nil
+}
+
+// rebuildIndex will replace the index entries of the rewritten partition files
+// in every index file with the rebuilt entries.
+func (c *Client) rebuildIndex(rewritten map[string]struct{}, rebuilt map[string][]*indexEntry) error {
+	dir := fmt.Sprintf("%s/%s", c.basepath, indexDir)
+	names, err := c.readDir(dir)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"dir": dir,
+		})
+	}
+
+	for _, name := range names {
+		filename := fmt.Sprintf("%s/%s", dir, name)
+		entries, err := readIndexFile(filename)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+
+		kept := make([]*indexEntry, 0, len(entries))
+		for _, entry := range entries {
+			if _, ok := rewritten[entry.file()]; !ok {
+				kept = append(kept, entry)
+			}
+		}
+		kept = append(kept, rebuilt[filename]...)
+		delete(rebuilt, filename)
+
+		err = writeIndexFile(filename, kept)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+	}
+
+	// users that did not have an index file yet
+	for filename, entries := range rebuilt {
+		err := writeIndexFile(filename, entries)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+	}
+
+	return nil
+}
+
+func writeIndexFile(filename string, entries []*indexEntry) error {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		buf.WriteString(entry.String())
+		buf.WriteString("\n")
+	}
+
+	return replaceFile(filename, buf.Bytes())
+}
@@ -0,0 +1,380 @@
+package local
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+const (
+	// quarantineDir holds the corrupt files (and the partial records of
+	// the CSV partitions and index files) that were found by the client
+	quarantineDir = "quarantine"
+
+	backupSuffix = ".bak"
+	tempSuffix   = ".tmp"
+)
+
+// writeFile will atomically replace the file with the data (see replaceFile)
+// and keep the previous version of the file as its backup.
+func writeFile(filename string, data []byte) error {
+	_, err := os.Stat(filename)
+	if err == nil {
+		backup := filename + backupSuffix
+		err := os.Remove(backup)
+		if err != nil && !os.IsNotExist(err) {
+			return errors.New(err, map[string]interface{}{
+				"filename": backup,
+			})
+		}
+
+		err = os.Link(filename, backup)
+		if err != nil {
+			return errors.New(err, map[string]interface{}{
+				"filename": backup,
+			})
+		}
+	}
+
+	return replaceFile(filename, data)
+}
+
+// replaceFile will write the data to a temporary file, fsync it, rename the
+// temporary file to the filename and then fsync the directory. A crash leaves
+// either the previous or the new version of the file, never a partial one.
+func replaceFile(filename string, data []byte) error {
+	dir, base := filepath.Split(filename)
+	f, err := ioutil.TempFile(dir, base+".*"+tempSuffix)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return errors.New(err, map[string]interface{}{
+			"filename": tmp,
+		})
+	}
+
+	err = os.Rename(tmp, filename)
+	if err != nil {
+		os.Remove(tmp)
+		return errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+
+	return syncDir(dir)
+}
+
+// syncFile will fsync the file (if it exists)
+func syncFile(filename string) error {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+	defer f.Close()
+
+	err = f.Sync()
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+
+	return nil
+}
+
+// syncDir will fsync the directory, so that created and renamed files are durable
+func syncDir(dir string) error {
+	if dir == "" {
+		dir = "."
+	}
+
+	return syncFile(dir)
+}
+
+// markAppended will record a file that has been appended to since the last sync
+func (c *Client) markAppended(filename string) {
+	c.appendedMutex.Lock()
+	c.appended[filename] = struct{}{}
+	c.appendedMutex.Unlock()
+}
+
+// sync will fsync every file that has been appended to since the last sync,
+// and their directories (for the files that have been created).
+func (c *Client) sync() error {
+	c.appendedMutex.Lock()
+	appended := c.appended
+	c.appended = make(map[string]struct{})
+	c.appendedMutex.Unlock()
+
+	dirs := make(map[string]struct{})
+	for filename := range appended {
+		err := syncFile(filename)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+		dirs[filepath.Dir(filename)] = struct{}{}
+	}
+
+	for dir := range dirs {
+		err := syncDir(dir)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+	}
+
+	return nil
+}
+
+// readFile will read and decode a file of the resource. A file that can not be
+// decoded is moved to the quarantine directory and restored from its backup.
+// If there is no (valid) backup, then the file does not exist anymore (ErrDNE).
+func (c *Client) readFile(resource, filename string) (interface{}, error) {
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, types.ErrDNE
+	} else if err != nil {
+		return nil, errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+
+	item, err := decodeFile(resource, data)
+	if err == nil {
+		return item, nil
+	}
+	log.Printf("corrupt file %s: %v", filename, err)
+
+	err = c.quarantine(filename)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	backup := filename + backupSuffix
+	data, err = ioutil.ReadFile(backup)
+	if os.IsNotExist(err) {
+		return nil, types.ErrDNE
+	} else if err != nil {
+		return nil, errors.New(err, map[string]interface{}{
+			"filename": backup,
+		})
+	}
+
+	item, err = decodeFile(resource, data)
+	if err != nil {
+		log.Printf("corrupt backup %s: %v", backup, err)
+		err := c.quarantine(backup)
+		if err != nil {
+			return nil, errors.New(err, nil)
+		}
+		return nil, types.ErrDNE
+	}
+
+	err = replaceFile(filename, data)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+	log.Printf("restored %s from its backup", filename)
+
+	return item, nil
+}
+
+// removeFile will remove the file and its backup
+func removeFile(filename string) error {
+	err := os.Remove(filename)
+	if os.IsNotExist(err) {
+		return types.ErrDNE
+	} else if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+
+	err = os.Remove(filename + backupSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.New(err, map[string]interface{}{
+			"filename": filename + backupSuffix,
+		})
+	}
+
+	return nil
+}
+
+// quarantineName will return a (unique) quarantine filename for the file
+func (c *Client) quarantineName(filename string) string {
+	name, err := filepath.Rel(c.basepath, filename)
+	if err != nil {
+		name = filepath.Base(filename)
+	}
+	name = strings.ReplaceAll(filepath.ToSlash(name), "/", "-")
+
+	return fmt.Sprintf("%s/%s/%s.%d", c.basepath, quarantineDir, name, time.Now().UnixNano())
+}
+
+// quarantine will move the file to the quarantine directory
+func (c *Client) quarantine(filename string) error {
+	dest := c.quarantineName(filename)
+	err := os.Rename(filename, dest)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+	log.Printf("quarantined %s as %s", filename, dest)
+
+	return syncDir(filepath.Dir(filename))
+}
+
+// recover will remove the temporary files of interrupted writes, and truncate
+// the partial record at the end of the partitions, index and history files.
+func (c *Client) recover() error {
+	quarantine := filepath.Join(c.basepath, quarantineDir)
+	archive := filepath.Join(c.basepath, archiveDir)
+	history := filepath.Join(c.basepath, db.History)
+	return filepath.Walk(c.basepath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if path == quarantine || path == archive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if strings.HasSuffix(path, tempSuffix) {
+			log.Printf("removing the temporary file %s", path)
+			return os.Remove(path)
+		}
+
+		if strings.HasSuffix(path, blockSuffix) {
+			return c.repairBlockFile(path, info.Size())
+		}
+
+		if strings.HasPrefix(path, history+string(filepath.Separator)) && !internalFile(path) {
+			_, err := c.truncateFrames(path, info.Size())
+			return err
+		}
+
+		dir := filepath.Base(filepath.Dir(path))
+		if strings.HasSuffix(path, ".csv") || dir == indexDir {
+			return c.repairAppendFile(path, info.Size())
+		}
+
+		return nil
+	})
+}
+
+// repairAppendFile will truncate the file after its last complete line
+// (records always end with a newline). The partial record is quarantined.
+func (c *Client) repairAppendFile(filename string, size int64) error {
+	if size == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(filename, os.O_RDWR, 0644)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+	defer f.Close()
+
+	last := make([]byte, 1)
+	_, err = f.ReadAt(last, size-1)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+
+	// find the end of the last complete line
+	var end int64
+	chunk := make([]byte, 64*1024)
+	for pos := size; pos > 0 && end == 0; {
+		n := int64(len(chunk))
+		if pos < n {
+			n = pos
+		}
+		pos -= n
+
+		_, err := f.ReadAt(chunk[:n], pos)
+		if err != nil && err != io.EOF {
+			return errors.New(err, map[string]interface{}{
+				"filename": filename,
+			})
+		}
+
+		for i := n - 1; i >= 0; i-- {
+			if chunk[i] == '\n' {
+				end = pos + i + 1
+				break
+			}
+		}
+	}
+
+	partial := make([]byte, size-end)
+	_, err = f.ReadAt(partial, end)
+	if err != nil && err != io.EOF {
+		return errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+
+	dest := c.quarantineName(filename)
+	err = replaceFile(dest, partial)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	err = f.Truncate(end)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+	log.Printf("truncated the partial record of %s (quarantined as %s)", filename, dest)
+
+	return nil
+}
+
+// internalFile will return whether or not the file is a backup or a temporary file
+func internalFile(name string) bool {
+	return strings.HasSuffix(name, backupSuffix) || strings.HasSuffix(name, tempSuffix)
+}
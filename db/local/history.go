@@ -0,0 +1,297 @@
+package local
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+// historyResource will return the resource of the history found in the where clause
+func historyResource(where db.Where) (string, error) {
+	wm, ok := where.(db.WhereMap)
+	if !ok {
+		return "", errors.New(types.ErrAssertion, nil)
+	}
+
+	resource, _ := wm["item.resource"].(string)
+	switch resource {
+	case db.EndpointStats, db.OriginStats, db.EntityStats, db.PropertyStats, db.Summaries:
+		return resource, nil
+	}
+
+	return "", errors.New(types.ErrResourceType, map[string]interface{}{
+		"resource": resource,
+	})
+}
+
+// historyFilename will return the history file of a document of the resource
+// (`<basepath>/history/<resource>/<document>`)
+func (c *Client) historyFilename(resource, filename string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", c.basepath, db.History, resource, filepath.Base(filename))
+}
+
+// appendHistory will append the closed period to the history file of its document.
+// The periods are framed like the blocks of interactions.
+func (c *Client) appendHistory(item interface{}, where db.Where) error {
+	resource, err := historyResource(where)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	data, err := frame(item)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	filename := c.historyFilename(resource, c.filename(resource, item))
+	err = os.MkdirAll(filepath.Dir(filename), 0644)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+
+	c.historyMutex.Lock()
+	defer c.historyMutex.Unlock()
+
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+	defer f.Close()
+
+	_, err = f.Write(data)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+	c.markAppended(filename)
+
+	return nil
+}
+
+// listHistory will return the periods of the document found in the where clause
+// that start within its (optional) `from` and `to` times, sorted by their start.
+func (c *Client) listHistory(where db.Where) (interface{}, error) {
+	resource, err := historyResource(where)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	filename := c.filenameFromWhere(resource, where)
+	if filename == "" {
+		return nil, errors.New(types.ErrDNE, map[string]interface{}{
+			"resource": resource,
+		})
+	}
+
+	from, _ := where.(db.WhereMap)["from"].(time.Time)
+	to, _ := where.(db.WhereMap)["to"].(time.Time)
+
+	periods := make([]types.Period, 0)
+	c.historyMutex.Lock()
+	err = readHistory(resource, c.historyFilename(resource, filename), func(period types.Period) error {
+		if types.InPeriods(period, from, to) {
+			periods = append(periods, period)
+		}
+		return nil
+	})
+	c.historyMutex.Unlock()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.New(err, nil)
+	}
+
+	return historyList(resource, periods), nil
+}
+
+// readHistory will call the function for every period of the history file
+func readHistory(resource, filename string, fn func(period types.Period) error) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var offset int64
+	for {
+		data, err := readFrame(f, offset)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.New(err, map[string]interface{}{
+				"filename": filename,
+				"offset":   offset,
+			})
+		}
+
+		period := newPeriod(resource)
+		err = unframeItem(data, period)
+		if err != nil {
+			return errors.New(err, map[string]interface{}{
+				"filename": filename,
+				"offset":   offset,
+			})
+		}
+
+		err = fn(period)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+
+		offset += int64(len(data))
+	}
+}
+
+func newPeriod(resource string) types.Period {
+	switch resource {
+	case db.PropertyStats:
+		return &types.PropertyStats{}
+	case db.Summaries:
+		return &types.Summary{}
+	}
+
+	return &types.IntervalStats{}
+}
+
+// historyList will return the periods as a typed list sorted by their start. A period
+// that was archived more than once (e.g., by a flush that was retried) is only listed once.
+func historyList(resource string, periods []types.Period) interface{} {
+	sort.SliceStable(periods, func(i, j int) bool {
+		_, a, _ := periods[i].Period()
+		_, b, _ := periods[j].Period()
+		return a.Before(b)
+	})
+
+	unique := periods[:0]
+	for _, period := range periods {
+		if n := len(unique); n > 0 {
+			_, last, _ := unique[n-1].Period()
+			if _, start, _ := period.Period(); start.Equal(last) {
+				unique[n-1] = period
+				continue
+			}
+		}
+		unique = append(unique, period)
+	}
+
+	switch resource {
+	case db.PropertyStats:
+		list := make([]*types.PropertyStats, 0, len(unique))
+		for _, period := range unique {
+			list = append(list, period.(*types.PropertyStats))
+		}
+		return list
+	case db.Summaries:
+		list := make([]*types.Summary, 0, len(unique))
+		for _, period := range unique {
+			list = append(list, period.(*types.Summary))
+		}
+		return list
+	}
+
+	list := make([]*types.IntervalStats, 0, len(unique))
+	for _, period := range unique {
+		list = append(list, period.(*types.IntervalStats))
+	}
+	return list
+}
+
+// pruneHistory will remove the periods that ended more than the number of periods ago
+// from every history file. It returns the number of removed periods.
+func (c *Client) pruneHistory(periods int, now time.Time) (int, error) {
+	c.historyMutex.Lock()
+	defer c.historyMutex.Unlock()
+
+	var removed int
+	err := c.walkHistory(func(resource, filename string) error {
+		kept := make([]byte, 0)
+		var count int
+		err := readHistory(resource, filename, func(period types.Period) error {
+			if types.PeriodExpired(period, periods, now) {
+				count++
+				return nil
+			}
+
+			data, err := frame(period)
+			if err != nil {
+				return errors.New(err, nil)
+			}
+			kept = append(kept, data...)
+
+			return nil
+		})
+		if err != nil {
+			return errors.New(err, nil)
+		}
+
+		if count == 0 {
+			return nil
+		}
+		removed += count
+
+		if len(kept) == 0 {
+			return os.Remove(filename)
+		}
+
+		return replaceFile(filename, kept)
+	})
+	if err != nil {
+		return 0, errors.New(err, nil)
+	}
+
+	return removed, nil
+}
+
+// ScanHistory will call the function for every archived period of every document
+func (c *Client) ScanHistory(fn func(resource string, period types.Period) error) error {
+	c.historyMutex.Lock()
+	defer c.historyMutex.Unlock()
+
+	return c.walkHistory(func(resource, filename string) error {
+		return readHistory(resource, filename, func(period types.Period) error {
+			return fn(resource, period)
+		})
+	})
+}
+
+// walkHistory will call the function for every history file
+func (c *Client) walkHistory(fn func(resource, filename string) error) error {
+	dir := fmt.Sprintf("%s/%s", c.basepath, db.History)
+	resources, err := c.readDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"dir": dir,
+		})
+	}
+
+	for _, resource := range resources {
+		names, err := c.readDir(fmt.Sprintf("%s/%s", dir, resource))
+		if err != nil {
+			return errors.New(err, map[string]interface{}{
+				"dir": dir,
+			})
+		}
+
+		for _, name := range names {
+			err := fn(resource, fmt.Sprintf("%s/%s/%s", dir, resource, name))
+			if err != nil {
+				return errors.New(err, nil)
+			}
+		}
+	}
+
+	return nil
+}
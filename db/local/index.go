@@ -0,0 +1,305 @@
+package local
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+// indexDir holds one append-only index file per user which points to
+// the location of each of the user's interactions inside the partitions.
+const indexDir = "interactionIndex"
+
+// indexEntry is the location of an interaction: the offset and length of its
+// block and its row inside the block, or of its record in a (legacy) CSV partition.
+type indexEntry struct {
+	Partition string
+	Offset    int64
+	Length    int64
+	Row       int64 // -1 for the records of the CSV partitions
+	CreatedAt time.Time
+}
+
+// String will return the index line of the entry
+func (e *indexEntry) String() string {
+	if e.Row < 0 {
+		return fmt.Sprintf("%s,%d,%d,%d", e.Partition, e.Offset, e.Length, e.CreatedAt.UnixNano())
+	}
+
+	return fmt.Sprintf("%s,%d,%d,%d,%d", e.Partition, e.Offset, e.Length, e.CreatedAt.UnixNano(), e.Row)
+}
+
+// file will return the name of the partition file of the entry
+func (e *indexEntry) file() string {
+	if e.Row < 0 {
+		return e.Partition + ".csv"
+	}
+
+	return e.Partition + blockSuffix
+}
+
+func (c *Client) indexFilename(user types.User) string {
+	return fmt.Sprintf("%s/%s/%s", c.basepath, indexDir, types.UserProfileID(user))
+}
+
+func (c *Client) appendIndex(user types.User, entry *indexEntry) error {
+	filename := c.indexFilename(user)
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+
+	_, err = fmt.Fprintln(f, entry.String())
+	if err != nil {
+		f.Close()
+		return errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+	c.markAppended(filename)
+
+	return f.Close()
+}
+
+func (c *Client) readIndex(user types.User) ([]*indexEntry, error) {
+	return readIndexFile(c.indexFilename(user))
+}
+
+func readIndexFile(filename string) ([]*indexEntry, error) {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return []*indexEntry{}, nil
+	} else if err != nil {
+		return nil, errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+	defer f.Close()
+
+	entries := make([]*indexEntry, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ",")
+		if len(fields) != 4 && len(fields) != 5 {
+			continue
+		}
+
+		offset, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		length, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		createdAt, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		row := int64(-1)
+		if len(fields) == 5 {
+			row, err = strconv.ParseInt(fields[4], 10, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		entries = append(entries, &indexEntry{
+			Partition: fields[0],
+			Offset:    offset,
+			Length:    length,
+			Row:       row,
+			CreatedAt: time.Unix(0, createdAt).UTC(),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+
+	return entries, nil
+}
+
+// userInteractions will return the index entries of the users (found in the where clause)
+// that fall within the optional "from" and "to" times, ordered by creation time.
+func (c *Client) userInteractions(where db.Where) ([]*indexEntry, error) {
+	wm, ok := where.(db.WhereMap)
+	if !ok {
+		return nil, errors.New(types.ErrAssertion, nil)
+	}
+
+	users, err := whereUsers(wm)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	entries := make([]*indexEntry, 0)
+	for _, user := range users {
+		userEntries, err := c.readIndex(user)
+		if err != nil {
+			return nil, errors.New(err, nil)
+		}
+		entries = append(entries, userEntries...)
+	}
+
+	from, hasFrom := wm["from"].(time.Time)
+	to, hasTo := wm["to"].(time.Time)
+
+	filtered := make([]*indexEntry, 0, len(entries))
+	for _, entry := range entries {
+		if hasFrom && entry.CreatedAt.Before(from) {
+			continue
+		}
+		if hasTo && entry.CreatedAt.After(to) {
+			continue
+		}
+		filtered = append(filtered, entry)
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return filtered[i].CreatedAt.Before(filtered[j].CreatedAt)
+	})
+
+	return filtered, nil
+}
+
+func whereUsers(wm db.WhereMap) ([]types.User, error) {
+	switch u := wm["item.user"].(type) {
+	case types.User:
+		return []types.User{u}, nil
+	case []types.User:
+		return u, nil
+	}
+
+	return nil, types.ErrUser
+}
+
+// readInteractions will read the interactions pointed to by the index entries
+func (c *Client) readInteractions(entries []*indexEntry) ([]*types.Interaction, error) {
+	files := make(map[string]*os.File)
+	defer func() {
+		for _, f := range files {
+			if f != nil {
+				f.Close()
+			}
+		}
+	}()
+	blocks := make(map[string]*block)
+
+	list := make([]*types.Interaction, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.file()
+		f, ok := files[name]
+		if !ok {
+			filename := fmt.Sprintf("%s/%s/%s", c.basepath, db.Interactions, name)
+			file, err := os.Open(filename)
+			if err != nil && !os.IsNotExist(err) {
+				return nil, errors.New(err, map[string]interface{}{
+					"filename": filename,
+				})
+			}
+			files[name] = file
+			f = file
+		}
+		if f == nil {
+			continue
+		}
+
+		var interaction *types.Interaction
+		var err error
+		if entry.Row < 0 {
+			interaction, err = readRecord(f, entry)
+		} else {
+			interaction, err = readRow(f, entry, blocks)
+		}
+		if err != nil {
+			return nil, errors.New(err, map[string]interface{}{
+				"partition": entry.Partition,
+				"offset":    entry.Offset,
+			})
+		}
+
+		// the record of the entry was lost in a crash (the partial record was truncated
+		// at startup), or its offset has been reused by another record after a crash
+		if interaction == nil || interaction.CreatedAt == nil || !interaction.CreatedAt.Equal(entry.CreatedAt) {
+			continue
+		}
+
+		list = append(list, interaction)
+	}
+
+	return list, nil
+}
+
+// readRecord will read the interaction of an entry of a CSV partition
+func readRecord(f *os.File, entry *indexEntry) (*types.Interaction, error) {
+	data := make([]byte, entry.Length)
+	_, err := f.ReadAt(data, entry.Offset)
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	record, err := csv.NewReader(bytes.NewReader(data)).Read()
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	interaction, err := types.InteractionFromCSV(record)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	return interaction, nil
+}
+
+// readRow will read the interaction of an entry of a partition of blocks
+// (the decoded blocks are kept in the blocks map)
+func readRow(f *os.File, entry *indexEntry, blocks map[string]*block) (*types.Interaction, error) {
+	key := fmt.Sprintf("%s-%d", entry.Partition, entry.Offset)
+	b, ok := blocks[key]
+	if !ok {
+		data, err := readFrame(f, entry.Offset)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, nil
+		} else if err != nil {
+			return nil, errors.New(err, nil)
+		}
+
+		// the block has been replaced by another block after a crash
+		if int64(len(data)) != entry.Length {
+			return nil, nil
+		}
+
+		b, err = unframe(data)
+		if err != nil {
+			return nil, errors.New(err, nil)
+		}
+		blocks[key] = b
+	}
+
+	if entry.Row >= int64(b.Len()) {
+		return nil, nil
+	}
+
+	return b.interaction(int(entry.Row))
+}
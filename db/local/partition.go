@@ -0,0 +1,435 @@
+package local
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+const (
+	// blockSuffix is the suffix of the partitions of columnar blocks
+	blockSuffix = ".blk"
+	// partitionIndexSuffix is the suffix of the index of a partition
+	partitionIndexSuffix = ".idx"
+	// partitionIndexes is the (internal) resource of the partition indexes
+	partitionIndexes = "partitionIndexes"
+
+	// maxPending is the number of interactions that are buffered before a block
+	// is written (without waiting for the sync at the end of the ingest batch)
+	maxPending = 10000
+)
+
+// HourlyPartitions will store the interactions in hourly partitions instead of daily ones
+var HourlyPartitions bool
+
+// PartitionIndex is the summary of a partition that is used to skip
+// the partitions that can not match a query without reading them.
+type PartitionIndex struct {
+	Partition string    `json:"partition"`
+	Size      int64     `json:"size"` // size of the indexed partition file
+	Blocks    int64     `json:"blocks"`
+	Rows      int64     `json:"rows"`
+	MinTime   time.Time `json:"minTime"`
+	MaxTime   time.Time `json:"maxTime"`
+	Actions   []string  `json:"actions"` // distinct actions
+}
+
+// partitionOf will return the partition of an interaction (`Y-M-D`, or `Y-M-D-H`)
+func partitionOf(interaction *types.Interaction) string {
+	if HourlyPartitions {
+		return fmt.Sprintf("%s-%d", interaction.Date(), interaction.CreatedAt.Hour())
+	}
+
+	return interaction.Date()
+}
+
+func (c *Client) partitionFilename(partition, suffix string) string {
+	return fmt.Sprintf("%s/%s/%s%s", c.basepath, db.Interactions, partition, suffix)
+}
+
+// bufferInteraction will add the interaction to the pending block of its partition
+func (c *Client) bufferInteraction(interaction *types.Interaction) error {
+	c.blocksMutex.Lock()
+	defer c.blocksMutex.Unlock()
+
+	partition := partitionOf(interaction)
+	b, ok := c.pending[partition]
+	if !ok {
+		b = newBlock(nil)
+		c.pending[partition] = b
+	}
+	b.add(interaction)
+	c.pendingCount++
+
+	if c.pendingCount < maxPending {
+		return nil
+	}
+
+	return c.writeBlocks()
+}
+
+// flushBlocks will write the pending interactions as one block per partition
+func (c *Client) flushBlocks() error {
+	c.blocksMutex.Lock()
+	defer c.blocksMutex.Unlock()
+
+	return c.writeBlocks()
+}
+
+func (c *Client) writeBlocks() error {
+	partitions := make([]string, 0, len(c.pending))
+	for partition := range c.pending {
+		partitions = append(partitions, partition)
+	}
+	sort.Strings(partitions)
+
+	for _, partition := range partitions {
+		err := c.appendBlock(partition, c.pending[partition])
+		if err != nil {
+			return errors.New(err, nil)
+		}
+
+		c.pendingCount -= c.pending[partition].Len()
+		delete(c.pending, partition)
+	}
+
+	return nil
+}
+
+// appendBlock will append the block to the partition,
+// and then update the user index and the partition index.
+func (c *Client) appendBlock(partition string, b *block) error {
+	data, err := b.frame()
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	filename := c.partitionFilename(partition, blockSuffix)
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+	defer f.Close()
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+
+	_, err = f.Write(data)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+	c.markAppended(filename)
+
+	for row := 0; row < b.Len(); row++ {
+		err := c.appendIndex(b.user(row), &indexEntry{
+			Partition: partition,
+			Offset:    offset,
+			Length:    int64(len(data)),
+			Row:       int64(row),
+			CreatedAt: b.createdAt(row),
+		})
+		if err != nil {
+			return errors.New(err, nil)
+		}
+	}
+
+	index, err := c.readPartitionIndex(partition)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+	index.add(b)
+	index.Size = offset + int64(len(data))
+
+	return c.writePartitionIndex(index)
+}
+
+// add will add the rows of a block to the index
+func (p *PartitionIndex) add(b *block) {
+	p.Blocks++
+
+	actions := make(map[string]struct{}, len(p.Actions))
+	for _, action := range p.Actions {
+		actions[action] = struct{}{}
+	}
+
+	for row := 0; row < b.Len(); row++ {
+		createdAt := b.createdAt(row)
+		if p.Rows == 0 || createdAt.Before(p.MinTime) {
+			p.MinTime = createdAt
+		}
+		if p.Rows == 0 || createdAt.After(p.MaxTime) {
+			p.MaxTime = createdAt
+		}
+		p.Rows++
+
+		action := b.action(row)
+		if _, ok := actions[action]; !ok {
+			actions[action] = struct{}{}
+			p.Actions = append(p.Actions, action)
+		}
+	}
+	sort.Strings(p.Actions)
+}
+
+// HasAction will return whether or not the partition holds interactions of the action
+func (p *PartitionIndex) HasAction(action string) bool {
+	i := sort.SearchStrings(p.Actions, action)
+	return i < len(p.Actions) && p.Actions[i] == action
+}
+
+// Overlaps will return whether or not the partition holds interactions
+// within the (optional, zero) from and (exclusive) to times.
+func (p *PartitionIndex) Overlaps(from, to time.Time) bool {
+	if p.Rows == 0 {
+		return false
+	}
+	if !from.IsZero() && p.MaxTime.Before(from) {
+		return false
+	}
+	if !to.IsZero() && !p.MinTime.Before(to) {
+		return false
+	}
+
+	return true
+}
+
+func (c *Client) readPartitionIndex(partition string) (*PartitionIndex, error) {
+	filename := c.partitionFilename(partition, partitionIndexSuffix)
+	item, err := c.readFile(partitionIndexes, filename)
+	if err == types.ErrDNE {
+		return &PartitionIndex{
+			Partition: partition,
+			Actions:   make([]string, 0),
+		}, nil
+	} else if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	index, ok := item.(*PartitionIndex)
+	if !ok {
+		return nil, errors.New(types.ErrAssertion, nil)
+	}
+
+	return index, nil
+}
+
+func (c *Client) writePartitionIndex(index *PartitionIndex) error {
+	data, err := encode(index)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	return writeFile(c.partitionFilename(index.Partition, partitionIndexSuffix), data)
+}
+
+// Partitions will return the indexes of the partitions of columnar blocks
+func (c *Client) Partitions() ([]*PartitionIndex, error) {
+	err := c.flushBlocks()
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	dir := fmt.Sprintf("%s/%s", c.basepath, db.Interactions)
+	names, err := c.readDir(dir)
+	if err != nil {
+		return nil, errors.New(err, map[string]interface{}{
+			"dir": dir,
+		})
+	}
+
+	indexes := make([]*PartitionIndex, 0)
+	for _, name := range names {
+		if !strings.HasSuffix(name, blockSuffix) {
+			continue
+		}
+
+		index, err := c.readPartitionIndex(strings.TrimSuffix(name, blockSuffix))
+		if err != nil {
+			return nil, errors.New(err, nil)
+		}
+		indexes = append(indexes, index)
+	}
+
+	sort.Slice(indexes, func(i, j int) bool {
+		return indexes[i].MinTime.Before(indexes[j].MinTime)
+	})
+
+	return indexes, nil
+}
+
+// scanBlocks will call the function for every (valid) block of the partition file
+func scanBlocks(filename string, fn func(offset, length int64, b *block) error) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+	defer f.Close()
+
+	var offset int64
+	for {
+		data, err := readFrame(f, offset)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.New(err, map[string]interface{}{
+				"filename": filename,
+				"offset":   offset,
+			})
+		}
+
+		b, err := unframe(data)
+		if err != nil {
+			return errors.New(err, map[string]interface{}{
+				"filename": filename,
+				"offset":   offset,
+			})
+		}
+
+		err = fn(offset, int64(len(data)), b)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+
+		offset += int64(len(data))
+	}
+}
+
+// readFrame will read the framed block at the offset
+// (io.EOF at the end of the file, io.ErrUnexpectedEOF for a partial block).
+func readFrame(f *os.File, offset int64) ([]byte, error) {
+	header := make([]byte, blockHeader)
+	n, err := f.ReadAt(header, offset)
+	if err == io.EOF && n == 0 {
+		return nil, io.EOF
+	} else if n < blockHeader {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	length := int64(binary.BigEndian.Uint32(header[0:4]))
+	data := make([]byte, blockHeader+length)
+	n, err = f.ReadAt(data, offset)
+	if int64(n) < blockHeader+length {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// repairBlockFile will truncate the partition after its last complete block,
+// quarantine the partial block and rebuild the partition index if it is stale.
+func (c *Client) repairBlockFile(filename string, size int64) error {
+	end, err := c.truncateFrames(filename, size)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	partition := strings.TrimSuffix(filepath.Base(filename), blockSuffix)
+	index, err := c.readPartitionIndex(partition)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+	if index.Size == end {
+		return nil
+	}
+
+	return c.rebuildPartitionIndex(partition)
+}
+
+// truncateFrames will truncate the file of frames after its last complete
+// frame and quarantine the partial frame. It returns the size of the file.
+func (c *Client) truncateFrames(filename string, size int64) (int64, error) {
+	f, err := os.OpenFile(filename, os.O_RDWR, 0644)
+	if err != nil {
+		return 0, errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+	defer f.Close()
+
+	var end int64
+	for end < size {
+		data, err := readFrame(f, end)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return 0, errors.New(err, map[string]interface{}{
+				"filename": filename,
+			})
+		}
+		end += int64(len(data))
+	}
+
+	if end == size {
+		return end, nil
+	}
+
+	partial := make([]byte, size-end)
+	_, err = f.ReadAt(partial, end)
+	if err != nil && err != io.EOF {
+		return 0, errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+
+	dest := c.quarantineName(filename)
+	err = replaceFile(dest, partial)
+	if err != nil {
+		return 0, errors.New(err, nil)
+	}
+
+	err = f.Truncate(end)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		return 0, errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+	log.Printf("truncated the partial frame of %s (quarantined as %s)", filename, dest)
+
+	return end, nil
+}
+
+// rebuildPartitionIndex will index every block of the partition again
+func (c *Client) rebuildPartitionIndex(partition string) error {
+	index := &PartitionIndex{
+		Partition: partition,
+		Actions:   make([]string, 0),
+	}
+
+	err := scanBlocks(c.partitionFilename(partition, blockSuffix), func(offset, length int64, b *block) error {
+		index.add(b)
+		index.Size = offset + length
+		return nil
+	})
+	if err != nil {
+		return errors.New(err, nil)
+	}
+	log.Printf("rebuilt the index of the partition %s", partition)
+
+	return c.writePartitionIndex(index)
+}
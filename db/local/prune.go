@@ -0,0 +1,262 @@
+package local
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+// archiveDir holds the expired partitions (at their path relative to the basepath)
+// when they are archived instead of deleted
+const archiveDir = "archive"
+
+// Prune will remove (or archive) the expired partitions of interactions and of the sandboxes,
+// rewrite the expired partitions that hold kept interactions with only those interactions,
+// and remove the expired periods of the history and the expired quarantined files.
+func (c *Client) Prune(prune *db.Prune) (*db.PruneResult, error) {
+	result := &db.PruneResult{}
+
+	c.snapshotMutex.RLock()
+	defer c.snapshotMutex.RUnlock()
+
+	// no blocks are appended while the partitions are rewritten
+	c.blocksMutex.Lock()
+	defer c.blocksMutex.Unlock()
+
+	err := c.writeBlocks()
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	dir := fmt.Sprintf("%s/%s", c.basepath, db.Interactions)
+	names, err := c.readDir(dir)
+	if err != nil {
+		return nil, errors.New(err, map[string]interface{}{
+			"dir": dir,
+		})
+	}
+
+	remove := func(user types.User, action string, createdAt time.Time) bool {
+		return !prune.Keeps(action, createdAt)
+	}
+
+	rewritten := make(map[string]struct{})    // partition files
+	rebuilt := make(map[string][]*indexEntry) // index filename -> entries of rewritten partitions
+	for _, name := range names {
+		filename := fmt.Sprintf("%s/%s", dir, name)
+
+		var partition string
+		var maxTime time.Time
+		switch {
+		case strings.HasSuffix(name, blockSuffix):
+			partition = strings.TrimSuffix(name, blockSuffix)
+			index, err := c.readPartitionIndex(partition)
+			if err != nil {
+				return nil, errors.New(err, nil)
+			}
+			maxTime = index.MaxTime
+
+			// every interaction of the partition was removed
+			if index.Rows == 0 && !prune.Before.IsZero() {
+				err := c.removePartition(filename, false)
+				if err != nil {
+					return nil, errors.New(err, nil)
+				}
+				result.Partitions++
+				rewritten[name] = struct{}{}
+				continue
+			}
+		case strings.HasSuffix(name, ".csv"):
+			partition = strings.TrimSuffix(name, ".csv")
+			end, ok := db.PartitionEnd(partition)
+			if !ok {
+				continue
+			}
+			maxTime = end
+		default:
+			continue
+		}
+
+		if prune.Expired(maxTime) {
+			err := c.removePartition(filename, prune.Archive)
+			if err != nil {
+				return nil, errors.New(err, nil)
+			}
+			result.Partitions++
+			rewritten[name] = struct{}{}
+			continue
+		}
+
+		if prune.Before.IsZero() || !maxTime.Before(prune.Before) {
+			continue
+		}
+
+		var count int64
+		var entries map[string][]*indexEntry
+		if strings.HasSuffix(name, blockSuffix) {
+			count, entries, err = c.rewriteBlocks(partition, remove, prune.Archive)
+		} else {
+			count, entries, err = c.rewritePartition(partition, remove, prune.Archive)
+		}
+		if err != nil {
+			return nil, errors.New(err, nil)
+		}
+
+		if count == 0 {
+			continue
+		}
+
+		result.Rewritten++
+		result.Interactions += count
+		rewritten[name] = struct{}{}
+		for filename, e := range entries {
+			rebuilt[filename] = append(rebuilt[filename], e...)
+		}
+	}
+
+	if len(rewritten) > 0 {
+		err = c.rebuildIndex(rewritten, rebuilt)
+		if err != nil {
+			return nil, errors.New(err, nil)
+		}
+	}
+
+	// the sandboxed interactions are not kept
+	sandboxes := &db.Prune{
+		Before:  prune.Before,
+		Archive: prune.Archive,
+	}
+	dir = fmt.Sprintf("%s/%s", c.basepath, db.Sandbox)
+	names, err = c.readDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.New(err, map[string]interface{}{
+			"dir": dir,
+		})
+	}
+
+	for _, name := range names {
+		sandbox := fmt.Sprintf("%s/%s", dir, name)
+		partitions, err := c.readDir(sandbox)
+		if err != nil {
+			return nil, errors.New(err, map[string]interface{}{
+				"dir": sandbox,
+			})
+		}
+
+		for _, partition := range partitions {
+			end, ok := db.PartitionEnd(strings.TrimSuffix(partition, ".csv"))
+			if !ok || !sandboxes.Expired(end) {
+				continue
+			}
+
+			err := c.removePartition(fmt.Sprintf("%s/%s", sandbox, partition), prune.Archive)
+			if err != nil {
+				return nil, errors.New(err, nil)
+			}
+			result.Partitions++
+		}
+	}
+
+	if prune.HistoryPeriods > 0 {
+		result.History, err = c.pruneHistory(prune.HistoryPeriods, prune.Now)
+		if err != nil {
+			return nil, errors.New(err, nil)
+		}
+	}
+
+	if prune.DeadLettersBefore.IsZero() {
+		return result, nil
+	}
+
+	dir = fmt.Sprintf("%s/%s", c.basepath, quarantineDir)
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.New(err, map[string]interface{}{
+			"dir": dir,
+		})
+	}
+
+	for _, info := range infos {
+		if info.IsDir() || !info.ModTime().Before(prune.DeadLettersBefore) {
+			continue
+		}
+
+		filename := fmt.Sprintf("%s/%s", dir, info.Name())
+		err := os.Remove(filename)
+		if err != nil {
+			return nil, errors.New(err, map[string]interface{}{
+				"filename": filename,
+			})
+		}
+		result.DeadLetters++
+	}
+
+	return result, nil
+}
+
+// removePartition will remove (or archive) the partition file, and the index of a partition of blocks
+func (c *Client) removePartition(filename string, archive bool) error {
+	var err error
+	if archive {
+		err = c.archiveFile(filename, true)
+	} else {
+		err = removeFile(filename)
+	}
+	if err != nil && err != types.ErrDNE {
+		return errors.New(err, nil)
+	}
+
+	if strings.HasSuffix(filename, blockSuffix) {
+		err := removeFile(strings.TrimSuffix(filename, blockSuffix) + partitionIndexSuffix)
+		if err != nil && err != types.ErrDNE {
+			return errors.New(err, nil)
+		}
+	}
+	log.Printf("pruned the partition %s", c.relative(filename))
+
+	return syncDir(filepath.Dir(filename))
+}
+
+// archiveFile will copy (or move) the file to the archive directory
+func (c *Client) archiveFile(filename string, move bool) error {
+	dest := filepath.Join(c.basepath, archiveDir, c.relative(filename))
+	if _, err := os.Stat(dest); err == nil {
+		dest = fmt.Sprintf("%s.%d", dest, time.Now().UnixNano())
+	}
+
+	err := os.MkdirAll(filepath.Dir(dest), 0644)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"filename": dest,
+		})
+	}
+
+	if move {
+		err = os.Rename(filename, dest)
+		if err != nil {
+			return errors.New(err, map[string]interface{}{
+				"filename": filename,
+			})
+		}
+
+		return syncDir(filepath.Dir(dest))
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+
+	return replaceFile(dest, data)
+}
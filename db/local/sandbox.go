@@ -0,0 +1,166 @@
+package local
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+// appendSandbox will append the interaction to the (daily) CSV partition of the
+// sandbox found in the where clause. Every sandbox has its own data directory
+// (`<basepath>/sandbox/<name>`) and sandboxed interactions are not indexed.
+func (c *Client) appendSandbox(interaction *types.Interaction, where db.Where) error {
+	wm, ok := where.(db.WhereMap)
+	if !ok {
+		return errors.New(types.ErrAssertion, nil)
+	}
+
+	name, ok := wm["item.sandbox"].(string)
+	if !ok || name == "" || strings.ContainsAny(name, `/\.`) {
+		return errors.New(types.ErrFilterRule, map[string]interface{}{
+			"sandbox": name,
+		})
+	}
+
+	dir := fmt.Sprintf("%s/%s/%s", c.basepath, db.Sandbox, name)
+	err := os.MkdirAll(dir, 0644)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"dir": dir,
+		})
+	}
+
+	c.sandboxMutex.Lock()
+	defer c.sandboxMutex.Unlock()
+
+	filename := fmt.Sprintf("%s/%s.csv", dir, interaction.Date())
+	_, _, err = c.appendCSV(filename, interaction.CSV())
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	return nil
+}
+
+// sandboxInteractions will return the sandboxed interactions of the users (found in the
+// where clause) by sandbox. The sandboxes are not indexed, so every partition is scanned.
+func (c *Client) sandboxInteractions(where db.Where) (map[string][]*types.Interaction, error) {
+	users, err := sandboxUsers(where)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	c.sandboxMutex.Lock()
+	defer c.sandboxMutex.Unlock()
+
+	list := make(map[string][]*types.Interaction)
+	err = c.walkSandboxes(func(name, dir string) error {
+		return c.scanPartitions(dir, name, func(sandbox string, interaction *types.Interaction) error {
+			if _, ok := users[interaction.User().String()]; ok {
+				list[sandbox] = append(list[sandbox], interaction)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	return list, nil
+}
+
+// eraseSandbox will rewrite every sandbox partition that holds interactions of the users
+// (found in the where clause) without those interactions. It returns the number of removed
+// interactions per partition (`sandbox/<name>/<partition>`).
+func (c *Client) eraseSandbox(where db.Where) (map[string]int64, error) {
+	users, err := sandboxUsers(where)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+	remove := func(user types.User, action string, createdAt time.Time) bool {
+		_, ok := users[user.String()]
+		return ok
+	}
+
+	c.sandboxMutex.Lock()
+	defer c.sandboxMutex.Unlock()
+
+	removed := make(map[string]int64)
+	err = c.walkSandboxes(func(name, dir string) error {
+		partitions, err := c.readDir(dir)
+		if err != nil {
+			return errors.New(err, map[string]interface{}{
+				"dir": dir,
+			})
+		}
+
+		for _, partition := range partitions {
+			if !strings.HasSuffix(partition, ".csv") {
+				continue
+			}
+
+			count, _, err := c.rewriteCSV(fmt.Sprintf("%s/%s", dir, partition), "", remove, false)
+			if err != nil {
+				return errors.New(err, nil)
+			}
+			if count > 0 {
+				removed[fmt.Sprintf("%s/%s/%s", db.Sandbox, name, strings.TrimSuffix(partition, ".csv"))] = count
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	return removed, nil
+}
+
+// walkSandboxes will call the function with the name and the directory of every sandbox
+func (c *Client) walkSandboxes(fn func(name, dir string) error) error {
+	root := fmt.Sprintf("%s/%s", c.basepath, db.Sandbox)
+	names, err := c.readDir(root)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"dir": root,
+		})
+	}
+
+	for _, name := range names {
+		err := fn(name, fmt.Sprintf("%s/%s", root, name))
+		if err != nil {
+			return errors.New(err, nil)
+		}
+	}
+
+	return nil
+}
+
+// sandboxUsers will return the set of the users found in the where clause
+func sandboxUsers(where db.Where) (map[string]struct{}, error) {
+	wm, ok := where.(db.WhereMap)
+	if !ok {
+		return nil, errors.New(types.ErrAssertion, nil)
+	}
+
+	users, err := whereUsers(wm)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	set := make(map[string]struct{})
+	for _, user := range users {
+		set[user.String()] = struct{}{}
+	}
+
+	return set, nil
+}
@@ -0,0 +1,217 @@
+package local
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+// ScanInteractions will call the function for every stored interaction, partition by
+// partition, and then for every sandboxed interaction (with the name of its sandbox).
+// It is used to migrate and export the interactions.
+func (c *Client) ScanInteractions(fn func(sandbox string, interaction *types.Interaction) error) error {
+	err := c.flushBlocks()
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	dir := fmt.Sprintf("%s/%s", c.basepath, db.Interactions)
+	err = c.scanPartitions(dir, "", fn)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	dir = fmt.Sprintf("%s/%s", c.basepath, db.Sandbox)
+	names, err := c.readDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"dir": dir,
+		})
+	}
+
+	for _, name := range names {
+		err := c.scanPartitions(fmt.Sprintf("%s/%s", dir, name), name, fn)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) scanPartitions(dir, sandbox string, fn func(sandbox string, interaction *types.Interaction) error) error {
+	names, err := c.readDir(dir)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"dir": dir,
+		})
+	}
+
+	for _, name := range names {
+		filename := fmt.Sprintf("%s/%s", dir, name)
+
+		var err error
+		switch {
+		case strings.HasSuffix(name, ".csv"):
+			err = scanPartition(filename, sandbox, fn)
+		case strings.HasSuffix(name, blockSuffix):
+			err = scanBlocks(filename, func(offset, length int64, b *block) error {
+				for row := 0; row < b.Len(); row++ {
+					interaction, err := b.interaction(row)
+					if err != nil {
+						return errors.New(err, nil)
+					}
+
+					err = fn(sandbox, interaction)
+					if err != nil {
+						return errors.New(err, nil)
+					}
+				}
+				return nil
+			})
+		default:
+			continue
+		}
+		if err != nil {
+			return errors.New(err, map[string]interface{}{
+				"filename": filename,
+			})
+		}
+	}
+
+	return nil
+}
+
+func scanPartition(filename, sandbox string, fn func(sandbox string, interaction *types.Interaction) error) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.New(err, nil)
+		}
+
+		interaction, err := types.InteractionFromCSV(record)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+
+		err = fn(sandbox, interaction)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+	}
+}
+
+// Scan will stream the stored interactions that match the scan. The partitions of blocks
+// are pruned with their index, and the partitions of records with their date.
+func (c *Client) Scan(scan *db.Scan, fn func(interaction *types.Interaction) error) error {
+	err := c.flushBlocks()
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	dir := fmt.Sprintf("%s/%s", c.basepath, db.Interactions)
+	names, err := c.readDir(dir)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"dir": dir,
+		})
+	}
+
+	for _, name := range names {
+		err := scan.Err()
+		if err != nil {
+			return errors.New(err, nil)
+		}
+
+		filename := fmt.Sprintf("%s/%s", dir, name)
+		switch {
+		case strings.HasSuffix(name, blockSuffix):
+			index, err := c.readPartitionIndex(strings.TrimSuffix(name, blockSuffix))
+			if err != nil {
+				return errors.New(err, nil)
+			}
+			if !index.Overlaps(scan.From, scan.To) || !scan.MatchActions(index.HasAction) {
+				scan.Pruned++
+				continue
+			}
+			scan.Partitions++
+
+			err = scanBlocks(filename, func(offset, length int64, b *block) error {
+				err := scan.Err()
+				if err != nil {
+					return errors.New(err, nil)
+				}
+
+				for row := 0; row < b.Len(); row++ {
+					if !scan.Match(b.createdAt(row), b.action(row)) {
+						continue
+					}
+
+					interaction, err := b.interaction(row)
+					if err != nil {
+						return errors.New(err, nil)
+					}
+
+					err = fn(interaction)
+					if err != nil {
+						return errors.New(err, nil)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return errors.New(err, map[string]interface{}{
+					"filename": filename,
+				})
+			}
+		case strings.HasSuffix(name, ".csv"):
+			if !scan.MatchPartition(strings.TrimSuffix(name, ".csv")) {
+				scan.Pruned++
+				continue
+			}
+			scan.Partitions++
+
+			err := scanPartition(filename, "", func(sandbox string, interaction *types.Interaction) error {
+				err := scan.Err()
+				if err != nil {
+					return errors.New(err, nil)
+				}
+
+				var action string
+				if interaction.Action != nil {
+					action = *interaction.Action
+				}
+				if interaction.CreatedAt == nil || !scan.Match(*interaction.CreatedAt, action) {
+					return nil
+				}
+
+				return fn(interaction)
+			})
+			if err != nil {
+				return errors.New(err, map[string]interface{}{
+					"filename": filename,
+				})
+			}
+		}
+	}
+
+	return nil
+}
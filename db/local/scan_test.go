@@ -0,0 +1,121 @@
+package local
+
+import (
+	"testing"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+)
+
+func TestPartitionIndexOverlaps(t *testing.T) {
+	min := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	max := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	index := &PartitionIndex{Rows: 2, MinTime: min, MaxTime: max}
+
+	tests := []struct {
+		name     string
+		index    *PartitionIndex
+		from, to time.Time
+		want     bool
+	}{
+		{"unbounded", index, time.Time{}, time.Time{}, true},
+		{"within", index, min.Add(time.Hour), max.Add(-time.Minute), true},
+		{"from at the last row", index, max, time.Time{}, true},
+		{"from after the last row", index, max.Add(time.Nanosecond), time.Time{}, false},
+		{"to after the first row", index, time.Time{}, min.Add(time.Nanosecond), true},
+		{"to at the first row", index, time.Time{}, min, false},
+		{"empty", &PartitionIndex{}, time.Time{}, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.index.Overlaps(tt.from, tt.to); got != tt.want {
+				t.Errorf("Overlaps(%v, %v) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScan(t *testing.T) {
+	day := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	c := newTestClient(t, t.TempDir())
+
+	// one partition per day, with a view on every day and a signup on the last one
+	interactions := []struct {
+		action string
+		days   int
+	}{
+		{"view", 0},
+		{"view", 1},
+		{"view", 2},
+		{"signup", 2},
+	}
+	for _, i := range interactions {
+		createdAt := day.AddDate(0, 0, i.days)
+		interaction := &types.Interaction{Action: strp(i.action), CreatedAt: &createdAt}
+		if result := c.Do(&db.Op{Resource: db.Interactions, Type: db.Create, Item: interaction}); result.Error != nil {
+			t.Fatal(result.Error)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		scan           db.Scan
+		wantScanned    int
+		wantPartitions int
+		wantPruned     int
+	}{
+		{
+			name:           "every partition",
+			scan:           db.Scan{},
+			wantScanned:    4,
+			wantPartitions: 3,
+		},
+		{
+			name:           "one day",
+			scan:           db.Scan{From: day.AddDate(0, 0, 1), To: day.AddDate(0, 0, 2)},
+			wantScanned:    1,
+			wantPartitions: 1,
+			wantPruned:     2,
+		},
+		{
+			name:           "from",
+			scan:           db.Scan{From: day.AddDate(0, 0, 1)},
+			wantScanned:    3,
+			wantPartitions: 2,
+			wantPruned:     1,
+		},
+		{
+			name:           "action",
+			scan:           db.Scan{Actions: []string{"signup"}},
+			wantScanned:    1,
+			wantPartitions: 1,
+			wantPruned:     2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scan := tt.scan
+			scanned := 0
+			err := c.Scan(&scan, func(interaction *types.Interaction) error {
+				scanned++
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Scan() error = %v", err)
+			}
+
+			if scanned != tt.wantScanned {
+				t.Errorf("scanned = %d, want %d", scanned, tt.wantScanned)
+			}
+			if scan.Partitions != tt.wantPartitions {
+				t.Errorf("Partitions = %d, want %d", scan.Partitions, tt.wantPartitions)
+			}
+			if scan.Pruned != tt.wantPruned {
+				t.Errorf("Pruned = %d, want %d", scan.Pruned, tt.wantPruned)
+			}
+		})
+	}
+}
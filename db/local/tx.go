@@ -0,0 +1,246 @@
+package local
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+// journalFile holds the documents of the transaction that is being committed.
+// Once it is written the transaction is committed: the documents are then
+// written to their files, and a crash in the middle is completed on startup.
+const journalFile = "journal"
+
+type journalEntry struct {
+	Type     string // db.Update or db.Delete
+	Filename string // relative to the basepath
+	Data     []byte
+}
+
+// Tx is a transaction of the local store. Interactions and the history are
+// append-only, so they are written (and erased) directly instead of being journaled.
+// The created interactions are only appended once the transaction is committed.
+type Tx struct {
+	client       *Client
+	entries      []*journalEntry
+	interactions []*db.Op // created interactions
+	done         bool
+}
+
+// Begin --
+func (c *Client) Begin() (db.Tx, error) {
+	return &Tx{
+		client:  c,
+		entries: make([]*journalEntry, 0),
+	}, nil
+}
+
+// Do --
+func (t *Tx) Do(op *db.Op) db.Result {
+	var result db.Result
+	if t.done {
+		result.Error = types.ErrTxDone
+		return result
+	}
+
+	if op.Resource == db.Interactions && (op.Type == db.Create || op.Type == db.Update) {
+		t.interactions = append(t.interactions, op)
+		return result
+	}
+
+	if op.Resource == db.Interactions || op.Resource == db.Sandbox || op.Resource == db.History {
+		return t.client.Do(op)
+	}
+
+	switch op.Type {
+	case db.Create, db.Update:
+		filename := t.client.filename(op.Resource, op.Item)
+		data, err := encode(op.Item)
+		if err != nil {
+			result.Error = errors.New(err, map[string]interface{}{
+				"op":       op.Type,
+				"resource": op.Resource,
+				"file":     filename,
+			})
+			return result
+		}
+
+		t.entries = append(t.entries, &journalEntry{
+			Type:     db.Update,
+			Filename: t.client.relative(filename),
+			Data:     data,
+		})
+	case db.Delete:
+		filename := t.client.filenameFromWhere(op.Resource, op.Where)
+		if filename == "" || !t.exists(filename) {
+			result.Error = types.ErrDNE
+			return result
+		}
+
+		t.entries = append(t.entries, &journalEntry{
+			Type:     db.Delete,
+			Filename: t.client.relative(filename),
+		})
+	default:
+		return t.client.Do(op)
+	}
+
+	return result
+}
+
+// exists will return whether or not the document exists at the end of the transaction (so far)
+func (t *Tx) exists(filename string) bool {
+	relative := t.client.relative(filename)
+	for i := len(t.entries) - 1; i >= 0; i-- {
+		if t.entries[i].Filename == relative {
+			return t.entries[i].Type != db.Delete
+		}
+	}
+
+	_, err := os.Stat(filename)
+	return err == nil
+}
+
+// Commit --
+func (t *Tx) Commit() error {
+	if t.done {
+		return types.ErrTxDone
+	}
+	t.done = true
+
+	if len(t.entries) > 0 {
+		err := t.client.commit(t.entries)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+	}
+
+	// the transaction is committed, so an interaction that can not be appended is skipped
+	for _, op := range t.interactions {
+		result := t.client.Do(op)
+		if result.Error != nil {
+			log.Println(errors.NewTrace(result.Error).Error())
+		}
+	}
+
+	return nil
+}
+
+// Rollback will discard the documents of the transaction (a no-op after a commit)
+func (t *Tx) Rollback() error {
+	t.done = true
+	t.entries = nil
+	t.interactions = nil
+
+	return nil
+}
+
+// commit will write the journal and then apply its entries.
+// The journal is removed once every document has been written.
+func (c *Client) commit(entries []*journalEntry) error {
+	c.snapshotMutex.RLock()
+	defer c.snapshotMutex.RUnlock()
+	c.journalMutex.Lock()
+	defer c.journalMutex.Unlock()
+
+	// a previous commit that could not be applied
+	err := c.replayJournal()
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	data, err := encode(entries)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	journal := filepath.Join(c.basepath, journalFile)
+	err = replaceFile(journal, data)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	err = c.apply(entries)
+	if err != nil {
+		// the journal is replayed on startup
+		return errors.New(err, nil)
+	}
+
+	return removeJournal(journal)
+}
+
+func (c *Client) apply(entries []*journalEntry) error {
+	for _, entry := range entries {
+		filename := filepath.Join(c.basepath, entry.Filename)
+
+		switch entry.Type {
+		case db.Update:
+			err := writeFile(filename, entry.Data)
+			if err != nil {
+				return errors.New(err, nil)
+			}
+		case db.Delete:
+			err := removeFile(filename)
+			if err != nil && err != types.ErrDNE {
+				return errors.New(err, nil)
+			}
+		}
+	}
+
+	return nil
+}
+
+// replayJournal will complete the transaction that was being committed
+// when the service stopped (writing the documents again is idempotent).
+func (c *Client) replayJournal() error {
+	journal := filepath.Join(c.basepath, journalFile)
+	data, err := ioutil.ReadFile(journal)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"filename": journal,
+		})
+	}
+
+	// the journal is written atomically, so this is not an interrupted commit
+	entries := make([]*journalEntry, 0)
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&entries)
+	if err != nil {
+		log.Printf("corrupt journal %s: %v", journal, err)
+		return c.quarantine(journal)
+	}
+
+	log.Printf("replaying the journal (%d documents)", len(entries))
+	err = c.apply(entries)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	return removeJournal(journal)
+}
+
+func removeJournal(journal string) error {
+	err := os.Remove(journal)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"filename": journal,
+		})
+	}
+
+	return syncDir(filepath.Dir(journal))
+}
+
+// relative will return the filename relative to the basepath
+func (c *Client) relative(filename string) string {
+	return strings.TrimPrefix(filename, c.basepath+"/")
+}
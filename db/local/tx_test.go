@@ -0,0 +1,316 @@
+package local
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+)
+
+func newTestClient(t *testing.T, basepath string) *Client {
+	t.Helper()
+
+	c, err := NewClient(basepath)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	return c
+}
+
+func readProperty(c *Client, name string) (*types.Property, error) {
+	result := c.Do(&db.Op{
+		Resource: db.Properties,
+		Type:     db.Read,
+		Where: db.WhereMap{
+			"item.name": name,
+		},
+	})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return result.Item.(*types.Property), nil
+}
+
+func TestTx(t *testing.T) {
+	tests := []struct {
+		name    string
+		ops     []*db.Op
+		commit  bool
+		want    map[string]string // property name -> type ("" does not exist)
+		wantErr error
+	}{
+		{
+			name: "commit",
+			ops: []*db.Op{
+				{Resource: db.Properties, Type: db.Create, Item: &types.Property{Name: "plan", Type: "string"}},
+				{Resource: db.Properties, Type: db.Create, Item: &types.Property{Name: "seats", Type: "number"}},
+			},
+			commit: true,
+			want:   map[string]string{"plan": "string", "seats": "number"},
+		},
+		{
+			name: "rollback",
+			ops: []*db.Op{
+				{Resource: db.Properties, Type: db.Create, Item: &types.Property{Name: "plan", Type: "string"}},
+			},
+			want: map[string]string{"plan": ""},
+		},
+		{
+			name: "the last write wins",
+			ops: []*db.Op{
+				{Resource: db.Properties, Type: db.Create, Item: &types.Property{Name: "plan", Type: "string"}},
+				{Resource: db.Properties, Type: db.Update, Item: &types.Property{Name: "plan", Type: "number"}},
+			},
+			commit: true,
+			want:   map[string]string{"plan": "number"},
+		},
+		{
+			name: "delete a document of the transaction",
+			ops: []*db.Op{
+				{Resource: db.Properties, Type: db.Create, Item: &types.Property{Name: "plan", Type: "string"}},
+				{Resource: db.Properties, Type: db.Delete, Where: db.WhereMap{"item.name": "plan"}},
+			},
+			commit: true,
+			want:   map[string]string{"plan": ""},
+		},
+		{
+			name: "delete a missing document",
+			ops: []*db.Op{
+				{Resource: db.Properties, Type: db.Delete, Where: db.WhereMap{"item.name": "plan"}},
+			},
+			wantErr: types.ErrDNE,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, t.TempDir())
+
+			tx, err := c.Begin()
+			if err != nil {
+				t.Fatalf("Begin() error = %v", err)
+			}
+
+			for _, op := range tt.ops {
+				result := tx.Do(op)
+				if result.Error != nil {
+					if tt.wantErr != nil && errors.Is(result.Error, tt.wantErr) {
+						return
+					}
+					t.Fatalf("Do(%s %s) error = %v", op.Type, op.Resource, result.Error)
+				}
+			}
+			if tt.wantErr != nil {
+				t.Fatalf("Do() error = nil, want %v", tt.wantErr)
+			}
+
+			// nothing is written before the commit
+			for name := range tt.want {
+				_, err := readProperty(c, name)
+				if err != types.ErrDNE {
+					t.Errorf("read %s before the commit: error = %v, want %v", name, err, types.ErrDNE)
+				}
+			}
+
+			if tt.commit {
+				err = tx.Commit()
+			} else {
+				err = tx.Rollback()
+			}
+			if err != nil {
+				t.Fatalf("commit = %v: error = %v", tt.commit, err)
+			}
+
+			for name, want := range tt.want {
+				p, err := readProperty(c, name)
+				if want == "" {
+					if err != types.ErrDNE {
+						t.Errorf("read %s: error = %v, want %v", name, err, types.ErrDNE)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("read %s: error = %v", name, err)
+				}
+				if p.Type != want {
+					t.Errorf("read %s: type = %q, want %q", name, p.Type, want)
+				}
+			}
+
+			// a finished transaction can not be used again
+			if result := tx.Do(tt.ops[0]); result.Error != types.ErrTxDone {
+				t.Errorf("Do() after the end error = %v, want %v", result.Error, types.ErrTxDone)
+			}
+			if err := tx.Commit(); err != types.ErrTxDone {
+				t.Errorf("Commit() after the end error = %v, want %v", err, types.ErrTxDone)
+			}
+		})
+	}
+}
+
+func TestTxInteractions(t *testing.T) {
+	tests := []struct {
+		name   string
+		commit bool
+		want   int
+	}{
+		{
+			name:   "commit",
+			commit: true,
+			want:   1,
+		},
+		{
+			name: "rollback",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, t.TempDir())
+			interaction := testInteractions()[0]
+			where := db.WhereMap{"item.user": []types.User{interaction.User()}}
+
+			count := func() int {
+				t.Helper()
+
+				// the appended interactions are written by a sync
+				if result := c.Do(&db.Op{Resource: db.Interactions, Type: db.Sync}); result.Error != nil {
+					t.Fatalf("sync the interactions: error = %v", result.Error)
+				}
+
+				result := c.Do(&db.Op{Resource: db.Interactions, Type: db.List, Where: where})
+				if result.Error != nil {
+					t.Fatalf("list the interactions: error = %v", result.Error)
+				}
+				return len(result.Item.([]*types.Interaction))
+			}
+
+			tx, err := c.Begin()
+			if err != nil {
+				t.Fatalf("Begin() error = %v", err)
+			}
+			if result := tx.Do(&db.Op{Resource: db.Interactions, Type: db.Create, Item: interaction}); result.Error != nil {
+				t.Fatalf("Do() error = %v", result.Error)
+			}
+
+			// the interaction is not appended before the commit
+			if n := count(); n != 0 {
+				t.Errorf("interactions before the commit = %d, want 0", n)
+			}
+
+			if tt.commit {
+				err = tx.Commit()
+			} else {
+				err = tx.Rollback()
+			}
+			if err != nil {
+				t.Fatalf("commit = %v: error = %v", tt.commit, err)
+			}
+
+			if n := count(); n != tt.want {
+				t.Errorf("interactions = %d, want %d", n, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayJournal(t *testing.T) {
+	encodeProperty := func(t *testing.T, p *types.Property) []byte {
+		data, err := encode(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name           string
+		journal        func(t *testing.T) []byte
+		want           map[string]string // property name -> type ("" does not exist)
+		wantQuarantine bool
+	}{
+		{
+			name: "interrupted commit",
+			journal: func(t *testing.T) []byte {
+				data, err := encode([]*journalEntry{
+					{Type: db.Update, Filename: "properties/plan", Data: encodeProperty(t, &types.Property{Name: "plan", Type: "string"})},
+					{Type: db.Update, Filename: "properties/seats", Data: encodeProperty(t, &types.Property{Name: "seats", Type: "number"})},
+					{Type: db.Delete, Filename: "properties/legacy"},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return data
+			},
+			want: map[string]string{"plan": "string", "seats": "number", "legacy": ""},
+		},
+		{
+			name: "corrupt journal",
+			journal: func(t *testing.T) []byte {
+				return []byte("not a journal")
+			},
+			want:           map[string]string{"plan": "", "legacy": "string"},
+			wantQuarantine: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			basepath := t.TempDir()
+			c := newTestClient(t, basepath)
+
+			// a document that was written before the interrupted commit
+			result := c.Do(&db.Op{
+				Resource: db.Properties,
+				Type:     db.Create,
+				Item:     &types.Property{Name: "legacy", Type: "string"},
+			})
+			if result.Error != nil {
+				t.Fatal(result.Error)
+			}
+
+			journal := filepath.Join(basepath, journalFile)
+			err := ioutil.WriteFile(journal, tt.journal(t), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the journal is replayed on startup
+			c = newTestClient(t, basepath)
+
+			if _, err := os.Stat(journal); !os.IsNotExist(err) {
+				t.Errorf("the journal was not removed: %v", err)
+			}
+
+			quarantined, err := ioutil.ReadDir(filepath.Join(basepath, quarantineDir))
+			if err != nil && !os.IsNotExist(err) {
+				t.Fatal(err)
+			}
+			if (len(quarantined) > 0) != tt.wantQuarantine {
+				t.Errorf("quarantined files = %d, want quarantine %v", len(quarantined), tt.wantQuarantine)
+			}
+
+			for name, want := range tt.want {
+				p, err := readProperty(c, name)
+				if want == "" {
+					if err != types.ErrDNE {
+						t.Errorf("read %s: error = %v, want %v", name, err, types.ErrDNE)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("read %s: error = %v", name, err)
+				}
+				if p.Type != want {
+					t.Errorf("read %s: type = %q, want %q", name, p.Type, want)
+				}
+			}
+		})
+	}
+}
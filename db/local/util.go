@@ -5,7 +5,7 @@ import (
 	"encoding/csv"
 	"encoding/gob"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
 
 	"github.com/EngaugeAI/engauge/db"
@@ -14,25 +14,44 @@ import (
 	"github.com/JKhawaja/errors"
 )
 
-func appendCSV(filename string, line []string) error {
+// appendCSV will append the line to the file and return
+// the offset and length of the written record.
+// The file is fsynced by the next sync of the client.
+func (c *Client) appendCSV(filename string, line []string) (int64, int64, error) {
 	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
 	if err != nil {
-		return errors.New(err, map[string]interface{}{
+		return 0, 0, errors.New(err, map[string]interface{}{
 			"filename": filename,
 		})
 	}
+	defer f.Close()
 
-	w := csv.NewWriter(f)
-	err = w.Write(line)
+	offset, err := f.Seek(0, io.SeekEnd)
 	if err != nil {
-		return errors.New(err, map[string]interface{}{
+		return 0, 0, errors.New(err, map[string]interface{}{
 			"filename": filename,
 		})
 	}
 
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	err = w.Write(line)
+	if err != nil {
+		return 0, 0, errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
 	w.Flush()
 
-	return f.Close()
+	n, err := f.Write(buf.Bytes())
+	if err != nil {
+		return 0, 0, errors.New(err, map[string]interface{}{
+			"filename": filename,
+		})
+	}
+	c.markAppended(filename)
+
+	return offset, int64(n), nil
 }
 
 func (c *Client) decodeList(resource string, filenames []string) (interface{}, error) {
@@ -41,16 +60,11 @@ func (c *Client) decodeList(resource string, filenames []string) (interface{}, e
 		list := make([]*types.Endpoint, 0)
 		for _, filename := range filenames {
 			fullName := fmt.Sprintf("%s/%s/%s", c.basepath, resource, filename)
-			data, err := ioutil.ReadFile(fullName)
-			if err != nil {
-				return nil, errors.New(err, map[string]interface{}{
-					"resource": resource,
-					"file":     filename,
-				})
-			}
-
-			item, err := decodeFile(resource, data)
-			if err != nil {
+			item, err := c.readFile(resource, fullName)
+			if err == types.ErrDNE {
+				// quarantined
+				continue
+			} else if err != nil {
 				return nil, errors.New(err, map[string]interface{}{
 					"resource": resource,
 					"file":     filename,
@@ -64,16 +78,11 @@ func (c *Client) decodeList(resource string, filenames []string) (interface{}, e
 		list := make([]*types.Origin, 0)
 		for _, filename := range filenames {
 			fullName := fmt.Sprintf("%s/%s/%s", c.basepath, resource, filename)
-			data, err := ioutil.ReadFile(fullName)
-			if err != nil {
-				return nil, errors.New(err, map[string]interface{}{
-					"resource": resource,
-					"file":     filename,
-				})
-			}
-
-			item, err := decodeFile(resource, data)
-			if err != nil {
+			item, err := c.readFile(resource, fullName)
+			if err == types.ErrDNE {
+				// quarantined
+				continue
+			} else if err != nil {
 				return nil, errors.New(err, map[string]interface{}{
 					"resource": resource,
 					"file":     filename,
@@ -87,16 +96,11 @@ func (c *Client) decodeList(resource string, filenames []string) (interface{}, e
 		list := make([]*types.IntervalStats, 0)
 		for _, filename := range filenames {
 			fullName := fmt.Sprintf("%s/%s/%s", c.basepath, resource, filename)
-			data, err := ioutil.ReadFile(fullName)
-			if err != nil {
-				return nil, errors.New(err, map[string]interface{}{
-					"resource": resource,
-					"file":     filename,
-				})
-			}
-
-			item, err := decodeFile(resource, data)
-			if err != nil {
+			item, err := c.readFile(resource, fullName)
+			if err == types.ErrDNE {
+				// quarantined
+				continue
+			} else if err != nil {
 				return nil, errors.New(err, map[string]interface{}{
 					"resource": resource,
 					"file":     filename,
@@ -110,92 +114,144 @@ func (c *Client) decodeList(resource string, filenames []string) (interface{}, e
 		list := make([]*types.Entity, 0)
 		for _, filename := range filenames {
 			fullName := fmt.Sprintf("%s/%s/%s", c.basepath, resource, filename)
-			data, err := ioutil.ReadFile(fullName)
-			if err != nil {
+			item, err := c.readFile(resource, fullName)
+			if err == types.ErrDNE {
+				// quarantined
+				continue
+			} else if err != nil {
 				return nil, errors.New(err, map[string]interface{}{
 					"resource": resource,
 					"file":     filename,
 				})
 			}
 
-			item, err := decodeFile(resource, data)
-			if err != nil {
+			list = append(list, item.(*types.Entity))
+		}
+		return list, nil
+	case db.Properties:
+		list := make([]*types.Property, 0)
+		for _, filename := range filenames {
+			fullName := fmt.Sprintf("%s/%s/%s", c.basepath, resource, filename)
+			item, err := c.readFile(resource, fullName)
+			if err == types.ErrDNE {
+				// quarantined
+				continue
+			} else if err != nil {
 				return nil, errors.New(err, map[string]interface{}{
 					"resource": resource,
 					"file":     filename,
 				})
 			}
 
-			list = append(list, item.(*types.Entity))
+			list = append(list, item.(*types.Property))
 		}
 		return list, nil
-	case db.Properties:
-		list := make([]*types.Property, 0)
+	case db.PropertyStats:
+		list := make([]*types.PropertyStats, 0)
 		for _, filename := range filenames {
 			fullName := fmt.Sprintf("%s/%s/%s", c.basepath, resource, filename)
-			data, err := ioutil.ReadFile(fullName)
-			if err != nil {
+			item, err := c.readFile(resource, fullName)
+			if err == types.ErrDNE {
+				// quarantined
+				continue
+			} else if err != nil {
 				return nil, errors.New(err, map[string]interface{}{
 					"resource": resource,
 					"file":     filename,
 				})
 			}
 
-			item, err := decodeFile(resource, data)
-			if err != nil {
+			list = append(list, item.(*types.PropertyStats))
+		}
+		return list, nil
+	case db.Summaries:
+		list := make([]*types.Summary, 0)
+		for _, filename := range filenames {
+			fullName := fmt.Sprintf("%s/%s/%s", c.basepath, resource, filename)
+			item, err := c.readFile(resource, fullName)
+			if err == types.ErrDNE {
+				// quarantined
+				continue
+			} else if err != nil {
 				return nil, errors.New(err, map[string]interface{}{
 					"resource": resource,
 					"file":     filename,
 				})
 			}
 
-			list = append(list, item.(*types.Property))
+			list = append(list, item.(*types.Summary))
 		}
 		return list, nil
-	case db.PropertyStats:
-		list := make([]*types.PropertyStats, 0)
+	case db.Identities:
+		list := make([]*types.Identity, 0)
 		for _, filename := range filenames {
 			fullName := fmt.Sprintf("%s/%s/%s", c.basepath, resource, filename)
-			data, err := ioutil.ReadFile(fullName)
-			if err != nil {
+			item, err := c.readFile(resource, fullName)
+			if err == types.ErrDNE {
+				// quarantined
+				continue
+			} else if err != nil {
 				return nil, errors.New(err, map[string]interface{}{
 					"resource": resource,
 					"file":     filename,
 				})
 			}
 
-			item, err := decodeFile(resource, data)
-			if err != nil {
+			list = append(list, item.(*types.Identity))
+		}
+		return list, nil
+	case db.Users:
+		list := make([]*types.UserProfile, 0)
+		for _, filename := range filenames {
+			fullName := fmt.Sprintf("%s/%s/%s", c.basepath, resource, filename)
+			item, err := c.readFile(resource, fullName)
+			if err == types.ErrDNE {
+				// quarantined
+				continue
+			} else if err != nil {
 				return nil, errors.New(err, map[string]interface{}{
 					"resource": resource,
 					"file":     filename,
 				})
 			}
 
-			list = append(list, item.(*types.PropertyStats))
+			list = append(list, item.(*types.UserProfile))
 		}
 		return list, nil
-	case db.Summaries:
-		list := make([]*types.Summary, 0)
+	case db.Tombstones:
+		list := make([]*types.Tombstone, 0)
 		for _, filename := range filenames {
 			fullName := fmt.Sprintf("%s/%s/%s", c.basepath, resource, filename)
-			data, err := ioutil.ReadFile(fullName)
-			if err != nil {
+			item, err := c.readFile(resource, fullName)
+			if err == types.ErrDNE {
+				// quarantined
+				continue
+			} else if err != nil {
 				return nil, errors.New(err, map[string]interface{}{
 					"resource": resource,
 					"file":     filename,
 				})
 			}
 
-			item, err := decodeFile(resource, data)
-			if err != nil {
+			list = append(list, item.(*types.Tombstone))
+		}
+		return list, nil
+	case db.DeletionReports:
+		list := make([]*types.DeletionReport, 0)
+		for _, filename := range filenames {
+			fullName := fmt.Sprintf("%s/%s/%s", c.basepath, resource, filename)
+			item, err := c.readFile(resource, fullName)
+			if err == types.ErrDNE {
+				// quarantined
+				continue
+			} else if err != nil {
 				return nil, errors.New(err, map[string]interface{}{
 					"resource": resource,
 					"file":     filename,
 				})
 			}
 
-			list = append(list, item.(*types.Summary))
+			list = append(list, item.(*types.DeletionReport))
 		}
 		return list, nil
 	}
@@ -224,6 +280,20 @@ func decodeFile(resource string, b []byte) (interface{}, error) {
 		item = &types.Summary{}
 	case db.Settings:
 		item = &types.Settings{}
+	case db.Consent:
+		item = &types.ConsentStats{}
+	case db.FilterHits:
+		item = &map[string]*types.FilterHit{}
+	case db.Identities:
+		item = &types.Identity{}
+	case db.Users:
+		item = &types.UserProfile{}
+	case db.Tombstones:
+		item = &types.Tombstone{}
+	case db.DeletionReports:
+		item = &types.DeletionReport{}
+	case partitionIndexes:
+		item = &PartitionIndex{}
 	}
 
 	// decode
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"time"
+)
+
+// Pruner is implemented by the clients that can remove the expired interactions
+type Pruner interface {
+	Prune(prune *Prune) (*PruneResult, error)
+}
+
+// Prune removes (or archives) the partitions of interactions (and of the sandboxes)
+// that only hold interactions created before a time. The interactions of the kept
+// actions are kept in the expired partitions until they expire as well.
+type Prune struct {
+	Before            time.Time // optional
+	Keep              []string  // optional, actions
+	KeepBefore        time.Time // optional, expiry of the kept actions
+	DeadLettersBefore time.Time // optional, expiry of the quarantined files
+	HistoryPeriods    int       // optional, periods of the history that are kept after they ended
+	Now               time.Time // the time the history periods expire at
+	Archive           bool
+}
+
+// PruneResult --
+type PruneResult struct {
+	Partitions    int   `json:"partitions"`    // removed (or archived) partitions
+	Rewritten     int   `json:"rewritten"`     // partitions rewritten with only the kept actions
+	Interactions  int64 `json:"interactions"`  // removed interactions of the rewritten partitions
+	DeadLetters   int   `json:"deadLetters"`   // removed quarantined files
+	IntervalStats int   `json:"intervalStats"` // removed interval stats (by the retention job)
+	History       int   `json:"history"`       // removed periods of the history
+}
+
+// Keeps will return whether or not the interaction of the action created at the time is kept
+func (p *Prune) Keeps(action string, createdAt time.Time) bool {
+	if !p.KeepBefore.IsZero() && createdAt.Before(p.KeepBefore) {
+		return false
+	}
+
+	for _, a := range p.Keep {
+		if a == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Expired will return whether or not a partition with interactions created until
+// the time expired entirely (none of its interactions are kept).
+func (p *Prune) Expired(maxTime time.Time) bool {
+	if p.Before.IsZero() || !maxTime.Before(p.Before) {
+		return false
+	}
+
+	return len(p.Keep) == 0 || (!p.KeepBefore.IsZero() && maxTime.Before(p.KeepBefore))
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/EngaugeAI/engauge/types"
+)
+
+// partitionSlack is the widest offset of a time zone. The partitions are
+// named after the date of their interactions in the zone of the interaction.
+const partitionSlack = 14 * time.Hour
+
+// Scanner is implemented by the clients that can stream the stored interactions
+type Scanner interface {
+	Scan(scan *Scan, fn func(interaction *types.Interaction) error) error
+}
+
+// Scan is a streamed read of the stored interactions (sandboxed interactions are
+// not included). The partitions that can not hold a matching interaction are skipped.
+type Scan struct {
+	Context  context.Context // optional, the scan stops once it is done
+	From, To time.Time       // optional, to is exclusive
+	Actions  []string        // optional
+
+	// set by the scanner
+	Partitions int // scanned partitions
+	Pruned     int // skipped partitions
+}
+
+// Err will return the error of the context of the scan (nil while it can go on)
+func (s *Scan) Err() error {
+	if s.Context == nil {
+		return nil
+	}
+
+	return s.Context.Err()
+}
+
+// Match will return whether or not an interaction created at the time with the action is scanned
+func (s *Scan) Match(createdAt time.Time, action string) bool {
+	if !s.From.IsZero() && createdAt.Before(s.From) {
+		return false
+	}
+	if !s.To.IsZero() && !createdAt.Before(s.To) {
+		return false
+	}
+	if len(s.Actions) == 0 {
+		return true
+	}
+
+	for _, a := range s.Actions {
+		if a == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatchActions will return whether or not any of the actions of the scan is held
+// by a partition (has reports whether or not the partition holds an action).
+func (s *Scan) MatchActions(has func(action string) bool) bool {
+	if len(s.Actions) == 0 {
+		return true
+	}
+
+	for _, a := range s.Actions {
+		if has(a) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatchPartition will return whether or not the partition named after a date
+// (`Y-M-D`, or `Y-M-D-H`) can hold interactions within the time range of the scan.
+// Partitions with other names are always scanned.
+func (s *Scan) MatchPartition(name string) bool {
+	start, length := partitionSpan(name)
+	if start.IsZero() {
+		return true
+	}
+
+	from := start.Add(-partitionSlack)
+	to := start.Add(length + partitionSlack)
+	if !s.From.IsZero() && !to.After(s.From) {
+		return false
+	}
+	if !s.To.IsZero() && !from.Before(s.To) {
+		return false
+	}
+
+	return true
+}
+
+func partitionSpan(name string) (time.Time, time.Duration) {
+	t, err := time.Parse("2006-1-2-15", name)
+	if err == nil {
+		return t, time.Hour
+	}
+
+	t, err = time.Parse("2006-1-2", name)
+	if err == nil {
+		return t, 24 * time.Hour
+	}
+
+	return time.Time{}, 0
+}
+
+// PartitionEnd will return the time until which the interactions of the partition named
+// after a date (`Y-M-D`, or `Y-M-D-H`) can be created (false for other names).
+func PartitionEnd(name string) (time.Time, bool) {
+	start, length := partitionSpan(name)
+	if start.IsZero() {
+		return time.Time{}, false
+	}
+
+	return start.Add(length + partitionSlack), true
+}
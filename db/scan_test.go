@@ -0,0 +1,68 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScanMatchPartition(t *testing.T) {
+	from := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+
+	tests := []struct {
+		name      string
+		scan      Scan
+		partition string
+		want      bool
+	}{
+		{"within", Scan{From: from, To: to}, "2026-10-18", true},
+		{"unbounded", Scan{}, "2020-1-1", true},
+		{"other name", Scan{From: from, To: to}, "sandbox", true},
+		// the interactions of the day before in a zone ahead of UTC can be created on the 18th
+		{"day before within the slack", Scan{From: from, To: to}, "2026-10-17", true},
+		{"day after within the slack", Scan{From: from, To: to}, "2026-10-19", true},
+		{"two days before", Scan{From: from, To: to}, "2026-10-16", false},
+		{"two days after", Scan{From: from, To: to}, "2026-10-20", false},
+		{"ends at from", Scan{From: from.Add(partitionSlack)}, "2026-10-17", false},
+		{"ends after from", Scan{From: from.Add(partitionSlack - time.Nanosecond)}, "2026-10-17", true},
+		{"starts at to", Scan{To: from.Add(-partitionSlack)}, "2026-10-18", false},
+		{"starts before to", Scan{To: from.Add(-partitionSlack + time.Nanosecond)}, "2026-10-18", true},
+		{"hour", Scan{From: from.Add(10 * time.Hour), To: from.Add(11 * time.Hour)}, "2026-10-18-10", true},
+		{"hour outside the slack", Scan{From: from.Add(partitionSlack + 11*time.Hour)}, "2026-10-18-10", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.scan.MatchPartition(tt.partition); got != tt.want {
+				t.Errorf("MatchPartition(%s) = %v, want %v", tt.partition, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanMatch(t *testing.T) {
+	from := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+
+	tests := []struct {
+		name      string
+		scan      Scan
+		createdAt time.Time
+		action    string
+		want      bool
+	}{
+		{"within", Scan{From: from, To: to}, from, "view", true},
+		{"before from", Scan{From: from, To: to}, from.Add(-time.Nanosecond), "view", false},
+		{"at to", Scan{From: from, To: to}, to, "view", false},
+		{"action", Scan{Actions: []string{"click", "view"}}, from, "view", true},
+		{"other action", Scan{Actions: []string{"click"}}, from, "view", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.scan.Match(tt.createdAt, tt.action); got != tt.want {
+				t.Errorf("Match(%v, %s) = %v, want %v", tt.createdAt, tt.action, got, tt.want)
+			}
+		})
+	}
+}
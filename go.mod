@@ -13,6 +13,8 @@ require (
 	github.com/humilityai/temporal v0.0.1
 	github.com/kelseyhightower/envconfig v1.4.0
 	github.com/labstack/echo/v4 v4.2.0
+	github.com/oschwald/maxminddb-golang v1.8.0
+	go.etcd.io/bbolt v1.3.6
 	golang.org/x/crypto v0.0.0-20210220033148-5ea612d1eb83
 	gonum.org/v1/gonum v0.8.2
 )
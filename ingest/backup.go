@@ -0,0 +1,32 @@
+package ingest
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/EngaugeAI/engauge/db"
+
+	"github.com/JKhawaja/errors"
+)
+
+// Backup will write a backup archive of the store (see db.WriteBackup). The buffered
+// interactions are processed first, and then no interaction is processed (and no period
+// is closed) until the snapshot is written, so the caches and the stored documents agree.
+func Backup(client db.Client, w io.Writer, base *db.Manifest) (*db.Manifest, error) {
+	s, ok := client.(db.Snapshotter)
+	if !ok {
+		return nil, fmt.Errorf("the store can not take a snapshot")
+	}
+
+	bufferMutex.Lock()
+	defer bufferMutex.Unlock()
+
+	processBuffer(client)
+
+	m, err := db.WriteBackup(w, s, base)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	return m, nil
+}
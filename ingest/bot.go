@@ -0,0 +1,30 @@
+package ingest
+
+import (
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+)
+
+var bots = types.NewBotDetector()
+
+// BotStats will return the number of detected bot interactions per reason
+func BotStats() *types.BotStats {
+	return bots.Stats()
+}
+
+// detectBot will drop or tag the interaction if it is bot traffic.
+// It returns whether or not the interaction should be dropped.
+func detectBot(interaction *types.Interaction) bool {
+	settings := db.GlobalSettings.Bots
+	reason := bots.Detect(settings, interaction)
+	if reason == "" {
+		return false
+	}
+
+	if settings.Action == types.BotDrop {
+		return true
+	}
+
+	interaction.TagBot(reason)
+	return false
+}
@@ -13,6 +13,11 @@ var (
 	// or its' received timestamp (if no created timestamp). This is used to attempt to preserve ordering of interactions
 	// based on the actual time of the interaction on the client-side.
 	InteractionsCache *cache.Cache
+	// InteractionDelay is how long an interaction is held in the cache after its creation time
+	InteractionDelay = 3 * time.Second
+
+	// cleanInterval is the interval at which the cache releases the interactions it held
+	cleanInterval = 1 * time.Second
 )
 
 func initCache() {
@@ -22,7 +27,7 @@ func initCache() {
 	}
 	config := &cache.CacheConfig{
 		OnExpires:     onExpires,
-		CleanDuration: 1 * time.Second,
+		CleanDuration: cleanInterval,
 	}
 	InteractionsCache = cache.NewCache(config)
 }
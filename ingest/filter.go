@@ -0,0 +1,29 @@
+package ingest
+
+import (
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+// Sandbox will store the interaction in the sandbox (see the sandbox filter action).
+// Sandboxed interactions are stored as they are received, they do not update
+// any stats, sessions, users or identities.
+func Sandbox(client db.Client, interaction *types.Interaction, sandbox string) error {
+	sandboxResult := client.Do(&db.Op{
+		Resource: db.Sandbox,
+		Type:     db.Create,
+		Where: db.WhereMap{
+			"item.sandbox": sandbox,
+		},
+		Item: interaction,
+	})
+	if sandboxResult.Error != nil {
+		return errors.New(sandboxResult.Error, map[string]interface{}{
+			"sandbox": sandbox,
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,340 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+var (
+	// GeoReloadInterval is how often the geo database file is checked for changes
+	GeoReloadInterval = 10 * time.Second
+
+	geo = &geoDB{
+		RWMutex: &sync.RWMutex{},
+	}
+)
+
+// geoReader resolves IP addresses to their location
+type geoReader interface {
+	Lookup(ip net.IP) (*types.Geo, error)
+	Close() error
+}
+
+// geoDB holds the reader of the current geo database file
+type geoDB struct {
+	reader  geoReader
+	file    string
+	modTime time.Time
+	*sync.RWMutex
+}
+
+// ReloadGeo will (re)load the geo database file of the settings
+// if it has changed since it was last loaded.
+func ReloadGeo() error {
+	return geo.reload(db.GlobalSettings.Geo)
+}
+
+// CheckGeo will return an error if the geo database file of the settings can not be loaded
+func CheckGeo(settings *types.GeoSettings) error {
+	if !settings.Enabled {
+		return nil
+	}
+
+	reader, err := openGeo(settings)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"file": settings.File,
+		})
+	}
+
+	return reader.Close()
+}
+
+// watchGeo will reload the geo database file whenever it changes
+func watchGeo() {
+	go func() {
+		var lastErr string
+		for {
+			err := ReloadGeo()
+			if err != nil && err.Error() != lastErr {
+				fmt.Println(errors.NewTrace(err).Error())
+			}
+			if err != nil {
+				lastErr = err.Error()
+			} else {
+				lastErr = ""
+			}
+
+			time.Sleep(GeoReloadInterval)
+		}
+	}()
+}
+
+func (g *geoDB) reload(settings *types.GeoSettings) error {
+	if !settings.Enabled || settings.File == "" {
+		g.swap(nil, "", time.Time{})
+		return nil
+	}
+
+	info, err := os.Stat(settings.File)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"file": settings.File,
+		})
+	}
+
+	g.RLock()
+	unchanged := g.reader != nil && g.file == settings.File && g.modTime.Equal(info.ModTime())
+	g.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	reader, err := openGeo(settings)
+	if err != nil {
+		return errors.New(err, map[string]interface{}{
+			"file": settings.File,
+		})
+	}
+
+	g.swap(reader, settings.File, info.ModTime())
+	return nil
+}
+
+func openGeo(settings *types.GeoSettings) (geoReader, error) {
+	if settings.CSV() {
+		return openGeoCSV(settings.File)
+	}
+
+	return openGeoMMDB(settings.File)
+}
+
+// swap will replace the reader (the previous reader is closed
+// once every ongoing lookup has finished)
+func (g *geoDB) swap(reader geoReader, file string, modTime time.Time) {
+	g.Lock()
+	previous := g.reader
+	g.reader = reader
+	g.file = file
+	g.modTime = modTime
+	g.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+}
+
+func (g *geoDB) lookup(ip net.IP) (*types.Geo, error) {
+	g.RLock()
+	defer g.RUnlock()
+
+	if g.reader == nil {
+		return nil, nil
+	}
+
+	return g.reader.Lookup(ip)
+}
+
+// enrichGeo will add the location of the client IP to the properties of the
+// interaction. The location properties sent by the client are never kept.
+func enrichGeo(interaction *types.Interaction) error {
+	settings := db.GlobalSettings.Geo
+	if !settings.Enabled {
+		return nil
+	}
+
+	for _, key := range types.GeoProperties {
+		delete(interaction.Properties, key)
+	}
+
+	ip := net.ParseIP(interaction.IP)
+	if ip == nil {
+		return nil
+	}
+
+	if settings.Truncate {
+		ip = types.TruncateIP(ip)
+	}
+
+	location, err := geo.lookup(ip)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	if location == nil {
+		return nil
+	}
+
+	if interaction.Properties == nil {
+		interaction.Properties = make(map[string]interface{})
+	}
+	for key, value := range location.Properties() {
+		interaction.Properties[key] = value
+	}
+
+	return nil
+}
+
+/* MaxMind database */
+
+type geoMMDB struct {
+	*maxminddb.Reader
+}
+
+type mmdbRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+func openGeoMMDB(file string) (*geoMMDB, error) {
+	reader, err := maxminddb.Open(file)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	return &geoMMDB{
+		Reader: reader,
+	}, nil
+}
+
+// Lookup --
+func (g *geoMMDB) Lookup(ip net.IP) (*types.Geo, error) {
+	var record mmdbRecord
+	err := g.Reader.Lookup(ip, &record)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	location := &types.Geo{
+		Country: record.Country.ISOCode,
+		City:    record.City.Names["en"],
+	}
+	if len(record.Subdivisions) > 0 {
+		location.Region = record.Subdivisions[0].Names["en"]
+		if location.Region == "" {
+			location.Region = record.Subdivisions[0].ISOCode
+		}
+	}
+
+	if location.Country == "" && location.Region == "" && location.City == "" {
+		return nil, nil
+	}
+
+	return location, nil
+}
+
+/* CSV ranges */
+
+type geoCSV struct {
+	ranges []*geoRange // sorted by start
+}
+
+type geoRange struct {
+	start, end net.IP
+	location   *types.Geo
+}
+
+// openGeoCSV will load a CSV file of IP ranges (start,end,country,region,city).
+// A header row is skipped.
+func openGeoCSV(file string) (*geoCSV, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	ranges := make([]*geoRange, 0)
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.New(err, nil)
+		}
+
+		start := net.ParseIP(strings.TrimSpace(record[0]))
+		if start == nil && line == 1 {
+			continue
+		}
+
+		if len(record) < 3 || start == nil {
+			return nil, errors.New(types.ErrGeoFile, map[string]interface{}{
+				"line": line,
+			})
+		}
+
+		end := net.ParseIP(strings.TrimSpace(record[1]))
+		if end == nil {
+			return nil, errors.New(types.ErrGeoFile, map[string]interface{}{
+				"line": line,
+			})
+		}
+
+		location := &types.Geo{
+			Country: strings.TrimSpace(record[2]),
+		}
+		if len(record) > 3 {
+			location.Region = strings.TrimSpace(record[3])
+		}
+		if len(record) > 4 {
+			location.City = strings.TrimSpace(record[4])
+		}
+
+		ranges = append(ranges, &geoRange{
+			start:    start.To16(),
+			end:      end.To16(),
+			location: location,
+		})
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return bytes.Compare(ranges[i].start, ranges[j].start) < 0
+	})
+
+	return &geoCSV{
+		ranges: ranges,
+	}, nil
+}
+
+// Lookup --
+func (g *geoCSV) Lookup(ip net.IP) (*types.Geo, error) {
+	ip = ip.To16()
+	idx := sort.Search(len(g.ranges), func(i int) bool {
+		return bytes.Compare(g.ranges[i].start, ip) > 0
+	}) - 1
+
+	if idx < 0 || bytes.Compare(ip, g.ranges[idx].end) > 0 {
+		return nil, nil
+	}
+
+	return g.ranges[idx].location, nil
+}
+
+// Close --
+func (g *geoCSV) Close() error {
+	return nil
+}
@@ -0,0 +1,89 @@
+package ingest
+
+import (
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+// the summaries that were replaced by the summary of their next period,
+// not archived yet (guarded by the buffer mutex)
+var closedSummaries = make([]*types.Summary, 0)
+
+// closeSummary will archive the summary with the next flush. The sessions that ended
+// within its period are still counted in it when they expire (see ClosedSummaryCache).
+func closeSummary(summary *types.Summary) {
+	archiveSummary(summary)
+	db.ClosedSummaryCache.Store(summary.Interval, summary)
+}
+
+// archiveSummary will add the closed summary to the summaries that are archived by the next flush (once)
+func archiveSummary(summary *types.Summary) {
+	for _, closed := range closedSummaries {
+		if closed == summary {
+			return
+		}
+	}
+	closedSummaries = append(closedSummaries, summary)
+}
+
+// archiveDB will append the closed periods of the stats and summaries to their history.
+// It stops at the first period that can not be archived.
+func archiveDB(client db.Tx) error {
+	caches := []struct {
+		resource string
+		closed   func(archiveFunc func(object interface{}) error) error
+	}{
+		{db.EndpointStats, db.EndpointsStatsCache.Closed},
+		{db.OriginStats, db.OriginsStatsCache.Closed},
+		{db.EntityStats, db.EntityStatsCache.Closed},
+		{db.PropertyStats, db.PropertyStatsCache.Closed},
+	}
+	for _, cache := range caches {
+		resource := cache.resource
+		err := cache.closed(func(object interface{}) error {
+			return archive(client, resource, object)
+		})
+		if err != nil {
+			return errors.New(err, nil)
+		}
+	}
+
+	for _, summary := range closedSummaries {
+		err := archive(client, db.Summaries, summary)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+	}
+
+	return nil
+}
+
+// archived will forget the closed periods once their flush is committed
+func archived() {
+	db.EndpointsStatsCache.Archived()
+	db.OriginsStatsCache.Archived()
+	db.EntityStatsCache.Archived()
+	db.PropertyStatsCache.Archived()
+	closedSummaries = make([]*types.Summary, 0)
+}
+
+// archive will append the closed period of a document of the resource to its history
+func archive(client db.Tx, resource string, period interface{}) error {
+	historyCreate := client.Do(&db.Op{
+		Resource: db.History,
+		Type:     db.Create,
+		Where: db.WhereMap{
+			"item.resource": resource,
+		},
+		Item: period,
+	})
+	if historyCreate.Error != nil {
+		return errors.New(historyCreate.Error, map[string]interface{}{
+			"resource": resource,
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package ingest
+
+import (
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+// Identify will link the anonymous identifiers of the request to the known user.
+// Any user that is merged into the known user has its active session moved onto
+// the known user's session, its profile merged into the known user's profile,
+// and is replaced by the known user in the unique users of the current summaries.
+// Traits of the request are set on the known user's profile.
+// The identity graph and profiles are persisted immediately.
+// The buffer is locked so that the merge can not interleave with a batch being processed.
+// Requests that involve an erased user are ignored.
+func Identify(client db.Client, request *types.Identify) error {
+	bufferMutex.Lock()
+	defer bufferMutex.Unlock()
+
+	if db.TombstonesCache.Has(types.UserKey(request.User())) {
+		return nil
+	}
+	for _, key := range request.Keys() {
+		if db.TombstonesCache.Has(key) {
+			return nil
+		}
+	}
+
+	canonical, err := link(request.User(), request.Keys()...)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	if len(request.Traits) > 0 {
+		db.UsersCache.Identify(canonical, request.Traits)
+	}
+
+	err = flush(client)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	return nil
+}
+
+// link will link the identifier keys to the canonical user of the user and merge
+// the sessions, profiles and summary users of every merged user into the canonical user.
+// It returns the canonical user. The buffer must be locked by the caller.
+func link(user types.User, keys ...string) (types.User, error) {
+	canonical := db.IdentitiesCache.Canonical(user)
+	merged := db.IdentitiesCache.Link(canonical, keys...)
+
+	for _, user := range merged {
+		err := db.SessionsCache.Merge(user, canonical)
+		if err != nil {
+			return canonical, errors.New(err, map[string]interface{}{
+				"from": user.String(),
+				"to":   canonical.String(),
+			})
+		}
+
+		db.UsersCache.Merge(user, canonical)
+
+		var mergeErr error
+		db.SummaryCache.Range(func(key, value interface{}) bool {
+			summary := value.(*types.Summary)
+			mergeErr = summary.MergeUsers(user, canonical)
+			return mergeErr == nil
+		})
+		if mergeErr != nil {
+			return canonical, errors.New(mergeErr, nil)
+		}
+	}
+
+	return canonical, nil
+}
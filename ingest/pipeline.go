@@ -0,0 +1,379 @@
+package ingest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+// Enricher adds information (e.g. derived properties) to an interaction
+type Enricher interface {
+	Enrich(interaction *types.Interaction) error
+}
+
+// Transformer modifies an interaction
+type Transformer interface {
+	Transform(interaction *types.Interaction) error
+}
+
+// EnricherFunc --
+type EnricherFunc func(interaction *types.Interaction) error
+
+// Enrich --
+func (f EnricherFunc) Enrich(interaction *types.Interaction) error {
+	return f(interaction)
+}
+
+// TransformerFunc --
+type TransformerFunc func(interaction *types.Interaction) error
+
+// Transform --
+func (f TransformerFunc) Transform(interaction *types.Interaction) error {
+	return f(interaction)
+}
+
+// EnricherBuilder builds an enricher from the config of its stage
+type EnricherBuilder func(config *types.StageConfig) (Enricher, error)
+
+// TransformerBuilder builds a transformer from the config of its stage
+type TransformerBuilder func(config *types.StageConfig) (Transformer, error)
+
+var (
+	enrichers    = make(map[string]EnricherBuilder)
+	transformers = make(map[string]TransformerBuilder)
+
+	pipeline      = make([]*stage, 0)
+	pipelineMutex = &sync.RWMutex{}
+)
+
+// stage is a built stage of the pipeline along with its counters
+type stage struct {
+	run   func(interaction *types.Interaction) error
+	stats *types.StageStats
+	*sync.Mutex
+}
+
+func init() {
+	RegisterEnricher(types.StageUserAgent, func(config *types.StageConfig) (Enricher, error) {
+		return EnricherFunc(func(interaction *types.Interaction) error {
+			db.GlobalSettings.UserAgents.Enrich(interaction)
+			return nil
+		}), nil
+	})
+	RegisterEnricher(types.StageGeo, func(config *types.StageConfig) (Enricher, error) {
+		return EnricherFunc(enrichGeo), nil
+	})
+	RegisterEnricher(types.StageCampaign, func(config *types.StageConfig) (Enricher, error) {
+		return EnricherFunc(func(interaction *types.Interaction) error {
+			db.GlobalSettings.Campaigns.Enrich(interaction)
+			return nil
+		}), nil
+	})
+	RegisterEnricher(types.StageDerive, derive)
+	RegisterTransformer(types.StageRegex, regexRewrite)
+	RegisterTransformer(types.StageNormalize, normalize)
+	RegisterTransformer(types.StageRename, rename)
+	RegisterTransformer(types.StageDrop, drop)
+	RegisterTransformer(types.StageDefault, setIfMissing)
+}
+
+// RegisterEnricher will make an enricher available as a pipeline stage type
+func RegisterEnricher(stageType string, builder EnricherBuilder) {
+	enrichers[stageType] = builder
+}
+
+// RegisterTransformer will make a transformer available as a pipeline stage type
+func RegisterTransformer(stageType string, builder TransformerBuilder) {
+	transformers[stageType] = builder
+}
+
+// SetPipeline will build the stages and replace the current pipeline.
+// The counters of the stages that are kept (same id and type) are preserved.
+func SetPipeline(configs []*types.StageConfig) error {
+	stages, err := buildPipeline(configs)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	pipelineMutex.Lock()
+	defer pipelineMutex.Unlock()
+
+	previous := make(map[string]*stage, len(pipeline))
+	for _, s := range pipeline {
+		previous[s.stats.ID] = s
+	}
+	for _, s := range stages {
+		if p, ok := previous[s.stats.ID]; ok && p.stats.Type == s.stats.Type {
+			s.stats = p.Stats()
+		}
+	}
+	pipeline = stages
+
+	return nil
+}
+
+// CheckPipeline will return an error if any stage can not be built
+func CheckPipeline(configs []*types.StageConfig) error {
+	_, err := buildPipeline(configs)
+	return err
+}
+
+// PipelineStats will return the counters of every stage (in order)
+func PipelineStats() []*types.StageStats {
+	pipelineMutex.RLock()
+	defer pipelineMutex.RUnlock()
+
+	stats := make([]*types.StageStats, 0, len(pipeline))
+	for _, s := range pipeline {
+		stats = append(stats, s.Stats())
+	}
+
+	return stats
+}
+
+func buildPipeline(configs []*types.StageConfig) ([]*stage, error) {
+	err := types.ValidatePipeline(configs)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	stages := make([]*stage, 0, len(configs))
+	for _, config := range configs {
+		var run func(interaction *types.Interaction) error
+		if builder, ok := enrichers[config.Type]; ok {
+			enricher, err := builder(config)
+			if err != nil {
+				return nil, errors.New(err, map[string]interface{}{
+					"stage": config.ID,
+				})
+			}
+			run = enricher.Enrich
+		} else if builder, ok := transformers[config.Type]; ok {
+			transformer, err := builder(config)
+			if err != nil {
+				return nil, errors.New(err, map[string]interface{}{
+					"stage": config.ID,
+				})
+			}
+			run = transformer.Transform
+		} else {
+			return nil, errors.New(types.ErrStage, map[string]interface{}{
+				"stage": config.ID,
+				"type":  config.Type,
+			})
+		}
+
+		stages = append(stages, &stage{
+			run: run,
+			stats: &types.StageStats{
+				ID:   config.ID,
+				Type: config.Type,
+			},
+			Mutex: &sync.Mutex{},
+		})
+	}
+
+	return stages, nil
+}
+
+// runPipeline will run every stage on the interaction (in order).
+// A stage that fails is counted and skipped, the interaction is still processed.
+func runPipeline(interaction *types.Interaction) {
+	pipelineMutex.RLock()
+	defer pipelineMutex.RUnlock()
+
+	for _, s := range pipeline {
+		start := time.Now()
+		err := s.run(interaction)
+		s.count(time.Since(start), err)
+		if err != nil {
+			fmt.Println(errors.NewTrace(err).Error())
+		}
+	}
+}
+
+func (s *stage) count(latency time.Duration, err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.stats.Calls++
+	s.stats.Latency += latency
+	if latency > s.stats.MaxLatency {
+		s.stats.MaxLatency = latency
+	}
+
+	if err != nil {
+		s.stats.Errors++
+		s.stats.LastError = err.Error()
+	}
+}
+
+// Stats will return a copy of the counters of the stage
+func (s *stage) Stats() *types.StageStats {
+	s.Lock()
+	defer s.Unlock()
+
+	stats := *s.stats
+	return &stats
+}
+
+/* built-in stages */
+
+func checkField(config *types.StageConfig, field string) error {
+	if !types.ValidField(field) {
+		return errors.New(types.ErrField, map[string]interface{}{
+			"stage": config.ID,
+			"field": field,
+		})
+	}
+
+	return nil
+}
+
+// regexRewrite will replace the matches of the pattern in a (string) field
+func regexRewrite(config *types.StageConfig) (Transformer, error) {
+	err := checkField(config, config.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern, err := regexp.Compile(config.Pattern)
+	if err != nil {
+		return nil, errors.New(types.ErrStage, map[string]interface{}{
+			"stage":  config.ID,
+			"reason": err.Error(),
+		})
+	}
+
+	return TransformerFunc(func(interaction *types.Interaction) error {
+		value, ok := interaction.Field(config.Field)
+		if !ok {
+			return nil
+		}
+
+		s, ok := value.(string)
+		if !ok {
+			return nil
+		}
+
+		return interaction.SetField(config.Field, pattern.ReplaceAllString(s, config.Replace))
+	}), nil
+}
+
+// normalize will lowercase and/or trim (string) fields
+func normalize(config *types.StageConfig) (Transformer, error) {
+	for _, field := range config.Fields {
+		err := checkField(config, field)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return TransformerFunc(func(interaction *types.Interaction) error {
+		for _, field := range config.Fields {
+			value, ok := interaction.Field(field)
+			if !ok {
+				continue
+			}
+
+			s, ok := value.(string)
+			if !ok {
+				continue
+			}
+
+			if config.Trim {
+				s = strings.TrimSpace(s)
+			}
+			if config.Lowercase {
+				s = strings.ToLower(s)
+			}
+
+			err := interaction.SetField(field, s)
+			if err != nil {
+				return errors.New(err, nil)
+			}
+		}
+
+		return nil
+	}), nil
+}
+
+// rename will move a property to another key
+func rename(config *types.StageConfig) (Transformer, error) {
+	if config.From == "" || config.To == "" {
+		return nil, errors.New(types.ErrStage, map[string]interface{}{
+			"stage":  config.ID,
+			"reason": "missing from or to",
+		})
+	}
+
+	return TransformerFunc(func(interaction *types.Interaction) error {
+		value, ok := interaction.Properties[config.From]
+		if !ok {
+			return nil
+		}
+
+		delete(interaction.Properties, config.From)
+		interaction.Properties[config.To] = value
+		return nil
+	}), nil
+}
+
+// drop will remove properties
+func drop(config *types.StageConfig) (Transformer, error) {
+	return TransformerFunc(func(interaction *types.Interaction) error {
+		for _, key := range config.Properties {
+			delete(interaction.Properties, key)
+		}
+
+		return nil
+	}), nil
+}
+
+// derive will set a field to the result of an expression
+func derive(config *types.StageConfig) (Enricher, error) {
+	err := checkField(config, config.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	expression, err := types.ParseExpression(config.Expression)
+	if err != nil {
+		return nil, errors.New(err, map[string]interface{}{
+			"stage": config.ID,
+		})
+	}
+
+	return EnricherFunc(func(interaction *types.Interaction) error {
+		value, err := expression.Eval(interaction)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+
+		return interaction.SetField(config.Field, value)
+	}), nil
+}
+
+// setIfMissing will set a field to a value if the field is not set
+func setIfMissing(config *types.StageConfig) (Transformer, error) {
+	err := checkField(config, config.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	return TransformerFunc(func(interaction *types.Interaction) error {
+		value, ok := interaction.Field(config.Field)
+		if ok && value != "" {
+			return nil
+		}
+
+		return interaction.SetField(config.Field, config.Value)
+	}), nil
+}
@@ -0,0 +1,243 @@
+package ingest
+
+import (
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+// Export will collect all of the stored data about the subject users (see Subjects)
+// and every identifier that has been linked to them.
+func Export(client db.Client, subjects []types.User) (*types.DataExport, error) {
+	bufferMutex.Lock()
+	defer bufferMutex.Unlock()
+
+	users := linkedUsers(subjects)
+	export := &types.DataExport{
+		Subject:      types.UserProfileID(users[0]),
+		Users:        users,
+		Identities:   make([]*types.Identity, 0),
+		Profiles:     make([]*types.UserProfile, 0),
+		Sessions:     make([]*types.UserSession, 0),
+		Interactions: make([]*types.Interaction, 0),
+		Sandboxes:    make(map[string][]*types.Interaction),
+		Archived:     make([]*types.Interaction, 0),
+		ExportedAt:   time.Now().UTC(),
+	}
+
+	seen := make(map[string]struct{})
+	for _, u := range users {
+		for _, identity := range db.IdentitiesCache.Identities(u) {
+			if _, ok := seen[identity.Key]; !ok {
+				seen[identity.Key] = struct{}{}
+				export.Identities = append(export.Identities, identity)
+			}
+		}
+
+		if profile, ok := db.UsersCache.Profile(u); ok {
+			export.Profiles = append(export.Profiles, profile)
+		}
+
+		if session, ok := db.SessionsCache.Session(u); ok {
+			export.Sessions = append(export.Sessions, session)
+		}
+	}
+
+	interactionsResult := client.Do(&db.Op{
+		Resource: db.Interactions,
+		Type:     db.List,
+		Where: db.WhereMap{
+			"item.user": users,
+		},
+	})
+	if interactionsResult.Error != nil {
+		return nil, errors.New(interactionsResult.Error, nil)
+	}
+	export.Interactions = interactionsResult.Item.([]*types.Interaction)
+
+	sandboxResult := client.Do(&db.Op{
+		Resource: db.Sandbox,
+		Type:     db.List,
+		Where: db.WhereMap{
+			"item.user": users,
+		},
+	})
+	if sandboxResult.Error != nil {
+		return nil, errors.New(sandboxResult.Error, nil)
+	}
+	export.Sandboxes = sandboxResult.Item.(map[string][]*types.Interaction)
+
+	archiveResult := client.Do(&db.Op{
+		Resource: db.Archive,
+		Type:     db.List,
+		Where: db.WhereMap{
+			"item.user": users,
+		},
+	})
+	if archiveResult.Error != nil {
+		return nil, errors.New(archiveResult.Error, nil)
+	}
+	export.Archived = archiveResult.Item.([]*types.Interaction)
+
+	return export, nil
+}
+
+// Erase will remove all of the stored data about the subject users (see Subjects) and every
+// identifier that has been linked to them: the stored, sandboxed and archived interactions, the identity
+// links, the profiles, the active sessions, and the user hashes of the current summaries.
+// A tombstone is recorded for every erased user so that its interactions are
+// dropped if they are ever received again. The deletion report is stored and returned.
+func Erase(client db.Client, subjects []types.User, requestedBy string) (*types.DeletionReport, error) {
+	bufferMutex.Lock()
+	defer bufferMutex.Unlock()
+
+	users := linkedUsers(subjects)
+	report := types.NewDeletionReport(users[0], requestedBy)
+
+	// stored interactions (regardless of the current storage setting)
+	interactionsResult := client.Do(&db.Op{
+		Resource: db.Interactions,
+		Type:     db.Delete,
+		Where: db.WhereMap{
+			"item.user": users,
+		},
+	})
+	if interactionsResult.Error != nil {
+		return nil, errors.New(interactionsResult.Error, nil)
+	}
+
+	for partition, count := range interactionsResult.Item.(map[string]int64) {
+		report.Partitions[partition] = count
+		report.Interactions += count
+	}
+
+	// sandboxed interactions
+	sandboxResult := client.Do(&db.Op{
+		Resource: db.Sandbox,
+		Type:     db.Delete,
+		Where: db.WhereMap{
+			"item.user": users,
+		},
+	})
+	if sandboxResult.Error != nil {
+		return nil, errors.New(sandboxResult.Error, nil)
+	}
+
+	for partition, count := range sandboxResult.Item.(map[string]int64) {
+		report.Partitions[partition] = count
+		report.Sandboxed += count
+	}
+
+	// archived interactions (of expired partitions)
+	archiveResult := client.Do(&db.Op{
+		Resource: db.Archive,
+		Type:     db.Delete,
+		Where: db.WhereMap{
+			"item.user": users,
+		},
+	})
+	if archiveResult.Error != nil {
+		return nil, errors.New(archiveResult.Error, nil)
+	}
+
+	for partition, count := range archiveResult.Item.(map[string]int64) {
+		report.Partitions[partition] = count
+		report.Archived += count
+	}
+
+	keys := make([]string, 0, len(users))
+	for _, u := range users {
+		keys = append(keys, types.UserKey(u))
+
+		// identity links
+		report.Links += len(db.IdentitiesCache.Remove(u))
+
+		// sessions
+		removed, err := db.SessionsCache.Remove(u)
+		if err != nil {
+			return nil, errors.New(err, map[string]interface{}{
+				"report": report.ID.String(),
+			})
+		}
+		if removed {
+			report.Sessions++
+		}
+
+		// profiles
+		if db.UsersCache.Remove(u) {
+			report.Profiles++
+		}
+	}
+
+	// summaries
+	var summaryErr error
+	db.SummaryCache.Range(func(key, value interface{}) bool {
+		summary := value.(*types.Summary)
+
+		var found bool
+		for _, u := range users {
+			removed, err := summary.RemoveUser(u)
+			if err != nil {
+				summaryErr = err
+				return false
+			}
+			found = found || removed
+		}
+
+		if found {
+			report.Summaries++
+		}
+
+		return true
+	})
+	if summaryErr != nil {
+		return nil, errors.New(summaryErr, map[string]interface{}{
+			"report": report.ID.String(),
+		})
+	}
+
+	db.TombstonesCache.Add(report.ID.String(), keys...)
+	report.Identifiers = len(keys)
+
+	err := flush(client)
+	if err != nil {
+		return nil, errors.New(err, map[string]interface{}{
+			"report": report.ID.String(),
+		})
+	}
+
+	report.CompletedAt = time.Now().UTC()
+	reportResult := client.Do(&db.Op{
+		Resource: db.DeletionReports,
+		Type:     db.Create,
+		Item:     report,
+	})
+	if reportResult.Error != nil {
+		return nil, errors.New(reportResult.Error, map[string]interface{}{
+			"report": report.ID.String(),
+		})
+	}
+
+	return report, nil
+}
+
+// linkedUsers will return the canonical users of the subjects along with
+// every user that has been linked to them.
+func linkedUsers(subjects []types.User) []types.User {
+	users := make([]types.User, 0, len(subjects))
+	seen := make(map[types.User]struct{})
+	for _, subject := range subjects {
+		for _, user := range db.IdentitiesCache.Users(subject) {
+			if _, ok := seen[user]; ok {
+				continue
+			}
+			seen[user] = struct{}{}
+			users = append(users, user)
+		}
+	}
+
+	return users
+}
@@ -0,0 +1,165 @@
+package ingest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+var (
+	pseudonymMutex = &sync.Mutex{}
+)
+
+// Pseudonymize will replace the user id, device id and chosen properties of the
+// interaction with their pseudonyms if pseudonymization is enabled. It returns
+// false if the user of the interaction was erased (under any salt, see ErasedUser).
+func Pseudonymize(client db.Client, interaction *types.Interaction) (bool, error) {
+	pseudonymMutex.Lock()
+	defer pseudonymMutex.Unlock()
+
+	now := time.Now().UTC()
+	pseudonyms, err := rotate(client, now)
+	if err != nil || pseudonyms == nil {
+		return true, err
+	}
+
+	if interaction.UserID != nil && db.TombstonesCache.ErasedUser(interaction.User(), pseudonyms) {
+		return false, nil
+	}
+
+	pseudonyms.Interaction(interaction, now)
+	return true, nil
+}
+
+// PseudonymizeIdentify will replace the identifiers and chosen traits of the
+// identify request with their pseudonyms if pseudonymization is enabled. It
+// returns false if a user of the request was erased (under any salt).
+func PseudonymizeIdentify(client db.Client, request *types.Identify) (bool, error) {
+	pseudonymMutex.Lock()
+	defer pseudonymMutex.Unlock()
+
+	pseudonyms, err := rotate(client, time.Now().UTC())
+	if err != nil || pseudonyms == nil {
+		return true, err
+	}
+
+	if db.TombstonesCache.ErasedUser(request.User(), pseudonyms) {
+		return false, nil
+	}
+	if request.AnonymousUserID != "" && db.TombstonesCache.ErasedUser(types.User{Type: request.AnonymousUserType, ID: request.AnonymousUserID}, pseudonyms) {
+		return false, nil
+	}
+
+	pseudonyms.Identify(request)
+	return true, nil
+}
+
+// SetPseudonyms will replace the pseudonym settings. The salts are never exposed, so they can
+// not be set from the dashboard: the current salts are kept (under the lock of the rotation).
+func SetPseudonyms(settings *types.PseudonymSettings) {
+	pseudonymMutex.Lock()
+	defer pseudonymMutex.Unlock()
+
+	if current := db.GlobalSettings.Pseudonyms; current != nil {
+		settings.Salts = current.Salts
+	} else {
+		settings.Salts = nil
+	}
+	db.GlobalSettings.Pseudonyms = settings
+}
+
+// Subjects will return every user that the data of a user (as identified by
+// the customer) may be stored as: the pseudonyms of the user under every retained
+// salt, and the user itself if it is known (e.g. it is already a pseudonym, or
+// it was stored before pseudonymization was enabled).
+func Subjects(user types.User) []types.User {
+	pseudonymMutex.Lock()
+	defer pseudonymMutex.Unlock()
+
+	pseudonyms := db.GlobalSettings.Pseudonyms
+	if pseudonyms == nil || len(pseudonyms.Salts) == 0 {
+		return []types.User{user}
+	}
+
+	subjects := make([]types.User, 0, len(pseudonyms.Salts)+1)
+	for _, id := range pseudonyms.Digests(user.ID) {
+		subjects = append(subjects, types.User{Type: user.Type, ID: id})
+	}
+
+	if _, ok := db.UsersCache.Profile(user); ok || db.IdentitiesCache.Has(types.UserKey(user)) {
+		subjects = append(subjects, user)
+	}
+
+	return subjects
+}
+
+// rotate will return the pseudonym settings (or nil if pseudonymization is disabled)
+// after rotating the salt if it is due. The settings are stored if the salt changed.
+func rotate(client db.Client, now time.Time) (*types.PseudonymSettings, error) {
+	pseudonyms := db.GlobalSettings.Pseudonyms
+	if pseudonyms == nil || !pseudonyms.Enabled {
+		return nil, nil
+	}
+
+	rotated, err := pseudonyms.Rotate(now)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	if rotated {
+		settingsUpdate := client.Do(&db.Op{
+			Resource: db.Settings,
+			Type:     db.Update,
+			Item:     db.GlobalSettings,
+		})
+		if settingsUpdate.Error != nil {
+			return nil, errors.New(settingsUpdate.Error, nil)
+		}
+	}
+
+	return pseudonyms, nil
+}
+
+// linkPrevious will link the pseudonyms of the interaction under the previous salt
+// (set during the grace period after a salt rotation) to the pseudonyms under the
+// current salt, so that a known user continues as the same user after a rotation.
+// The buffer must be locked by the caller.
+func linkPrevious(interaction *types.Interaction) error {
+	keys := make([]string, 0, 2)
+
+	if interaction.PreviousUserID != nil {
+		previous := types.User{
+			Type: interaction.User().Type,
+			ID:   *interaction.PreviousUserID,
+		}
+		previousKey := types.UserKey(previous)
+		if _, ok := db.UsersCache.Profile(previous); ok || db.IdentitiesCache.Has(previousKey) {
+			keys = append(keys, previousKey)
+		}
+	}
+
+	if interaction.PreviousDeviceID != nil {
+		previousKey := types.DeviceKey(types.Device{
+			Type: interaction.Device().Type,
+			ID:   *interaction.PreviousDeviceID,
+		})
+		if db.IdentitiesCache.Has(previousKey) {
+			keys = append(keys, previousKey)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	_, err := link(interaction.User(), keys...)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	return nil
+}
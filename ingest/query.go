@@ -0,0 +1,62 @@
+package ingest
+
+import (
+	"context"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+// Query will run the (validated) query over the stored interactions. The scan stops
+// at the row limit or the timeout of the query, and the result then holds the groups
+// of the interactions that were scanned so far.
+func Query(client db.Client, q *types.Query) (*types.QueryResult, error) {
+	scanner, ok := client.(db.Scanner)
+	if !ok {
+		return nil, errors.New(types.ErrResourceType, map[string]interface{}{
+			"reason": "the store can not scan interactions",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(q.Timeout)*time.Second)
+	defer cancel()
+
+	scan := &db.Scan{
+		Context: ctx,
+		From:    *q.From,
+		To:      *q.To,
+		Actions: q.Actions(),
+	}
+
+	aggregation := types.NewQueryAggregation(q)
+	var scanned int64
+	var truncated bool
+	err := scanner.Scan(scan, func(interaction *types.Interaction) error {
+		if scanned >= int64(q.MaxRows) {
+			truncated = true
+			cancel()
+			return ctx.Err()
+		}
+		scanned++
+
+		aggregation.Add(interaction)
+		return nil
+	})
+
+	timedOut := ctx.Err() == context.DeadlineExceeded
+	if err != nil && !truncated && !timedOut {
+		return nil, errors.New(err, nil)
+	}
+
+	result := aggregation.Result()
+	result.Scanned = scanned
+	result.Partitions = scan.Partitions
+	result.PrunedPartitions = scan.Pruned
+	result.Truncated = result.Truncated || truncated
+	result.TimedOut = timedOut
+
+	return result, nil
+}
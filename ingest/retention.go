@@ -0,0 +1,129 @@
+package ingest
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+// RetentionInterval is the time between two runs of the retention job
+var RetentionInterval = time.Hour
+
+// retention will remove the expired data (see Prune) on startup and then periodically
+func retention(client db.Client) {
+	go func(client db.Client) {
+		for {
+			result, err := Prune(client, time.Now().UTC())
+			if err != nil {
+				fmt.Println(errors.NewTrace(err).Error())
+			} else if result.Partitions > 0 || result.Rewritten > 0 || result.DeadLetters > 0 || result.IntervalStats > 0 || result.History > 0 {
+				log.Printf("retention: pruned %d partitions, %d interactions of %d partitions, %d quarantined files, %d interval stats and %d archived periods",
+					result.Partitions, result.Interactions, result.Rewritten, result.DeadLetters, result.IntervalStats, result.History)
+			}
+
+			time.Sleep(RetentionInterval)
+		}
+	}(client)
+}
+
+// Prune will remove the data that expired at the time according to the retention settings:
+// the partitions of raw interactions (only keeping the goal interactions in the conversions-only
+// mode), the quarantined files, and the interval stats and archived periods that ended too
+// many periods ago. The expired interval stats are archived to their history before they are removed.
+func Prune(client db.Client, now time.Time) (*db.PruneResult, error) {
+	settings := db.GlobalSettings.Retention
+	if settings == nil {
+		return &db.PruneResult{}, nil
+	}
+
+	prune := &db.Prune{
+		Before:            settings.InteractionsBefore(now),
+		DeadLettersBefore: settings.DeadLettersBefore(now),
+		HistoryPeriods:    settings.History,
+		Now:               now,
+		Archive:           settings.Archive,
+	}
+	if settings.ConversionsOnly {
+		for _, goal := range db.GlobalSettings.Goals {
+			prune.Keep = append(prune.Keep, goal.Action)
+		}
+		prune.KeepBefore = settings.ConversionsBefore(now)
+	}
+
+	result := &db.PruneResult{}
+	if pruner, ok := client.(db.Pruner); ok && (!prune.Before.IsZero() || !prune.DeadLettersBefore.IsZero() || prune.HistoryPeriods > 0) {
+		var err error
+		result, err = pruner.Prune(prune)
+		if err != nil {
+			return nil, errors.New(err, nil)
+		}
+	}
+
+	if settings.IntervalStats == 0 {
+		return result, nil
+	}
+
+	bufferMutex.Lock()
+	defer bufferMutex.Unlock()
+
+	expired := func(stats *types.IntervalStats) bool {
+		return stats.Expired(settings.IntervalStats, now)
+	}
+
+	tx, err := client.Begin()
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	caches := map[string]*types.IntervalStatsList{
+		db.EndpointStats: db.EndpointsStatsCache,
+		db.OriginStats:   db.OriginsStatsCache,
+		db.EntityStats:   db.EntityStatsCache,
+	}
+	removed := make(map[*types.IntervalStatsList][]*types.IntervalStats)
+	for resource, cache := range caches {
+		removed[cache] = cache.Expired(expired)
+		for _, stats := range removed[cache] {
+			// closed stats were archived when they were closed
+			if !stats.Closed {
+				err := archive(tx, resource, stats)
+				if err != nil {
+					tx.Rollback()
+					return nil, errors.New(err, nil)
+				}
+			}
+
+			statsDelete := tx.Do(&db.Op{
+				Resource: resource,
+				Type:     db.Delete,
+				Where: db.WhereMap{
+					"item.id":       stats.ID,
+					"item.interval": stats.Interval,
+				},
+			})
+			if statsDelete.Error != nil && statsDelete.Error != types.ErrDNE {
+				tx.Rollback()
+				return nil, errors.New(statsDelete.Error, nil)
+			}
+			result.IntervalStats++
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	// the stats are only removed from the caches once their removal is committed,
+	// so that a failed commit leaves them in the caches (as they are in the store) for the next run
+	for cache, list := range removed {
+		cache.Expire(list)
+	}
+
+	return result, nil
+}
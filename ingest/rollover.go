@@ -0,0 +1,81 @@
+package ingest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/JKhawaja/errors"
+)
+
+// RolloverDelay is how long a period stays open after its end: the interactions
+// of its last seconds are still held in the cache (see InteractionDelay) until then
+var RolloverDelay = InteractionDelay + cleanInterval
+
+// rollover will close the expired periods at every minute (every period, in every
+// time zone, ends at a whole minute), without waiting for the next interaction
+func rollover(client db.Client) {
+	go func(client db.Client) {
+		for {
+			now := time.Now().UTC()
+			time.Sleep(now.Truncate(time.Minute).Add(time.Minute + RolloverDelay).Sub(now))
+
+			err := Rollover(client, time.Now().UTC())
+			if err != nil {
+				fmt.Println(errors.NewTrace(err).Error())
+			}
+		}
+	}(client)
+}
+
+// Rollover will close the summaries and the interval and property stats that ended
+// more than the RolloverDelay before the time: the buffered interactions are processed
+// first (they may belong to the closed periods), then the closed periods are archived
+// and the summaries are replaced by the empty summaries of their current period.
+// The interactions that arrive later are counted in the closed periods (see Apply).
+func Rollover(client db.Client, now time.Time) error {
+	bufferMutex.Lock()
+	defer bufferMutex.Unlock()
+
+	// the interactions the cache released, that the worker did not buffer yet
+	for drained := false; !drained; {
+		select {
+		case interaction := <-bufferChan:
+			buffer = append(buffer, interaction)
+		default:
+			drained = true
+		}
+	}
+	processBuffer(client)
+
+	ended := now.Add(-RolloverDelay)
+
+	var closed int
+	db.SummaryCache.Range(func(key, value interface{}) bool {
+		interval := key.(string)
+		summary := value.(*types.Summary)
+		if interval == types.AllTime || !ended.After(summary.End) {
+			return true
+		}
+
+		start, end := types.PeriodSpan(interval, now.In(summary.Start.Location()))
+		closeSummary(summary)
+		db.SummaryCache.Store(interval, types.NewEmptySummary(interval, start, end))
+		closed++
+
+		return true
+	})
+
+	closed += db.EndpointsStatsCache.Rollover(ended)
+	closed += db.OriginsStatsCache.Rollover(ended)
+	closed += db.EntityStatsCache.Rollover(ended)
+	closed += db.PropertyStatsCache.Rollover(ended)
+
+	if closed == 0 {
+		return nil
+	}
+
+	return flush(client)
+}
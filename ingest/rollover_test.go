@@ -0,0 +1,112 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/db/local"
+	"github.com/EngaugeAI/engauge/types"
+
+	"github.com/gofrs/uuid"
+)
+
+func TestRollover(t *testing.T) {
+	// the end of the hourly period of the interaction
+	boundary := time.Date(2026, 10, 18, 11, 0, 0, 0, time.UTC)
+	createdAt := boundary.Add(-2 * time.Second)
+
+	tests := []struct {
+		name      string
+		now       []time.Time // the times of the rollovers
+		wantStart time.Time   // the start of the current hourly summary
+		// the archived hourly periods of the summary and of the endpoint stats
+		wantArchived int
+	}{
+		{
+			name:      "at the end of the period",
+			now:       []time.Time{boundary},
+			wantStart: boundary.Add(-time.Hour),
+		},
+		{
+			name:      "within the rollover delay",
+			now:       []time.Time{boundary.Add(RolloverDelay - time.Nanosecond)},
+			wantStart: boundary.Add(-time.Hour),
+		},
+		{
+			name:         "after the rollover delay",
+			now:          []time.Time{boundary.Add(RolloverDelay)},
+			wantStart:    boundary,
+			wantArchived: 1,
+		},
+		{
+			name:         "twice",
+			now:          []time.Time{boundary.Add(RolloverDelay), boundary.Add(RolloverDelay + time.Minute)},
+			wantStart:    boundary,
+			wantArchived: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := local.NewClient(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			db.InitCache(client)
+			closedSummaries = make([]*types.Summary, 0)
+
+			start, end := types.PeriodSpan(types.Hourly, createdAt)
+			db.SummaryCache.Store(types.Hourly, types.NewEmptySummary(types.Hourly, start, end))
+
+			action, user, userType := "view", "u-1", "visitor"
+			err = db.EndpointsStatsCache.Apply(&types.Event{
+				Interaction: &types.Interaction{
+					Action:    &action,
+					UserType:  &userType,
+					UserID:    &user,
+					CreatedAt: &createdAt,
+				},
+				Endpoint: uuid.Must(uuid.NewV4()),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, now := range tt.now {
+				err := Rollover(client, now)
+				if err != nil {
+					t.Fatalf("Rollover(%v) error = %v", now, err)
+				}
+			}
+
+			current, _ := db.SummaryCache.Load(types.Hourly)
+			if summary := current.(*types.Summary); !summary.Start.Equal(tt.wantStart) {
+				t.Errorf("current summary start = %v, want %v", summary.Start, tt.wantStart)
+			}
+			closed, ok := db.ClosedSummaryCache.Load(types.Hourly)
+			if ok != (tt.wantArchived > 0) {
+				t.Errorf("closed summary = %v, want %v", ok, tt.wantArchived > 0)
+			}
+			if ok && !closed.(*types.Summary).Start.Equal(start) {
+				t.Errorf("closed summary start = %v, want %v", closed.(*types.Summary).Start, start)
+			}
+
+			archived := make(map[string]int)
+			err = client.ScanHistory(func(resource string, period types.Period) error {
+				if interval, periodStart, _ := period.Period(); interval == types.Hourly && periodStart.Equal(start) {
+					archived[resource]++
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, resource := range []string{db.Summaries, db.EndpointStats} {
+				if archived[resource] != tt.wantArchived {
+					t.Errorf("archived %s = %d, want %d", resource, archived[resource], tt.wantArchived)
+				}
+			}
+		})
+	}
+}
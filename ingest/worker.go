@@ -26,6 +26,9 @@ var (
 	buffer          = make([]*types.Interaction, 0, MinBatchSize)
 	bufferMutex     = &sync.Mutex{}
 	bufferUpdatedAt time.Time
+
+	// the processed interactions that are stored by the next flush (in its transaction)
+	stored = make([]*types.Interaction, 0, MinBatchSize)
 )
 
 // Init will intialize the worker that moves interactions
@@ -33,24 +36,31 @@ var (
 // entities that are currently in-progress (started).
 func Init(client db.Client) {
 	initCache()
+	watchGeo()
+	types.SetGoals(db.GlobalSettings.Goals)
+
+	err := SetPipeline(db.GlobalSettings.Pipeline)
+	if err != nil {
+		fmt.Println(errors.NewTrace(err).Error())
+	}
+
 	clock(client)
 	worker(client)
+	rollover(client)
+	retention(client)
 }
 
 func clock(client db.Client) {
 	bufferUpdatedAt = time.Now().UTC()
 	go func(client db.Client) {
 		for {
+			bufferMutex.Lock()
 			tsu := time.Since(bufferUpdatedAt)
 			if tsu >= MaxProcWait && len(buffer) > 0 {
-				bufferMutex.Lock()
-				copyBuf := make([]*types.Interaction, len(buffer))
-				copy(copyBuf, buffer)
-				processInteractions(client, copyBuf)
-				buffer = make([]*types.Interaction, 0, MinBatchSize)
-				bufferUpdatedAt = time.Now().UTC()
+				processBuffer(client)
 				bufferMutex.Unlock()
 			} else {
+				bufferMutex.Unlock()
 				if tsu < MaxProcWait {
 					time.Sleep(MaxProcWait - tsu)
 				} else {
@@ -64,32 +74,69 @@ func clock(client db.Client) {
 func worker(client db.Client) {
 	go func(client db.Client) {
 		for v := range bufferChan {
+			// the buffer is processed by the clock, the rollover and the backups as well
+			bufferMutex.Lock()
 			buffer = append(buffer, v)
 
 			// if min batch size requirement met & no interactions left in chan
 			if len(buffer) >= MinBatchSize && len(bufferChan) == 0 {
-				bufferMutex.Lock()
-				copyBuf := make([]*types.Interaction, len(buffer))
-				copy(copyBuf, buffer)
-				processInteractions(client, copyBuf)
-				buffer = make([]*types.Interaction, 0, MinBatchSize)
-				bufferUpdatedAt = time.Now().UTC()
-				bufferMutex.Unlock()
+				processBuffer(client)
 			}
+			bufferMutex.Unlock()
 		}
 	}(client)
 }
 
+// processBuffer will process (and then empty) the buffered interactions.
+// The buffer mutex must be held.
+func processBuffer(client db.Client) {
+	if len(buffer) == 0 {
+		return
+	}
+
+	copyBuf := make([]*types.Interaction, len(buffer))
+	copy(copyBuf, buffer)
+	processInteractions(client, copyBuf)
+	buffer = make([]*types.Interaction, 0, MinBatchSize)
+	bufferUpdatedAt = time.Now().UTC()
+}
+
 func processInteractions(client db.Client, interactions []*types.Interaction) {
 	// process each interaction
 	for _, interaction := range interactions {
-		// event
-		session, err := db.SessionsCache.GetSession(interaction)
-		if err != nil {
-			fmt.Println(errors.NewTrace(err).Error())
+		// enrichment & transformation
+		runPipeline(interaction)
+		var err error
+
+		// identity (anonymous interactions have none)
+		if !interaction.Anonymous() {
+			err = linkPrevious(interaction)
+			if err != nil {
+				fmt.Println(errors.NewTrace(err).Error())
+			}
+			db.IdentitiesCache.Resolve(interaction)
+			if db.TombstonesCache.Erased(interaction) {
+				continue
+			}
+		}
+
+		// bots
+		if detectBot(interaction) {
 			continue
 		}
-		interaction.SessionID = &session.ID
+
+		// session (anonymous interactions and bots have none)
+		var session *types.UserSession
+		if !interaction.Anonymous() && !interaction.Bot() {
+			session, err = db.SessionsCache.GetSession(interaction)
+			if err != nil {
+				fmt.Println(errors.NewTrace(err).Error())
+				continue
+			}
+			interaction.SessionID = &session.ID
+		}
+
+		// event
 		event := &types.Event{
 			Interaction: interaction,
 			Session:     session,
@@ -98,6 +145,13 @@ func processInteractions(client db.Client, interactions []*types.Interaction) {
 			Endpoint:    db.EndpointsCache.ID(interaction.Endpoint()),
 		}
 
+		// bots are excluded from the aggregates (but can still be inspected)
+		if interaction.Bot() {
+			db.UsersCache.Apply(event)
+			storeInteraction(interaction)
+			continue
+		}
+
 		// endpoints
 		err = db.EndpointsCache.Apply(event)
 		if err != nil {
@@ -179,7 +233,22 @@ func processInteractions(client db.Client, interactions []*types.Interaction) {
 					continue
 				}
 
+				closeSummary(summary)
 				db.SummaryCache.Store(interval, newSummary)
+			} else if interaction.CreatedAt.Before(summary.Start) {
+				// an interaction that arrives after its period was closed is counted in the closed
+				// summary of its period, which is archived again (never in the summary of the next
+				// period). The interactions of the periods before the last closed period are only stored.
+				c, ok := db.ClosedSummaryCache.Load(interval)
+				if !ok || !c.(*types.Summary).Contains(*interaction.CreatedAt) {
+					continue
+				}
+
+				err = c.(*types.Summary).Apply(event)
+				if err != nil {
+					fmt.Println(errors.NewTrace(err).Error())
+				}
+				archiveSummary(c.(*types.Summary))
 			} else {
 				err = summary.Apply(event)
 				if err != nil {
@@ -188,28 +257,302 @@ func processInteractions(client db.Client, interactions []*types.Interaction) {
 			}
 		}
 
+		// anonymous interactions only count towards the aggregates
+		if interaction.Anonymous() {
+			continue
+		}
+
+		// users
+		db.UsersCache.Apply(event)
+
 		// session
 		session.Update(interaction)
+		bots.Session(db.GlobalSettings.Bots, session)
 
 		// store interaction
-		if db.GlobalSettings.InteractionsStorage {
-			interactionResult := client.Do(&db.Op{
-				Resource: db.Interactions,
-				Type:     db.Create,
-				Item:     interaction,
-			})
-			if interactionResult.Error != nil {
-				fmt.Println(errors.NewTrace(err).Error())
-			}
-		}
+		storeInteraction(interaction)
 	} // end process interactions loop
 
 	/* update in db */
-	updateDB(client)
+	err := flush(client)
+	if err != nil {
+		fmt.Println(errors.NewTrace(err).Error())
+	}
+
+	/* flush the stored interactions to disk */
+	syncResult := client.Do(&db.Op{
+		Resource: db.Interactions,
+		Type:     db.Sync,
+	})
+	if syncResult.Error != nil {
+		fmt.Println(errors.NewTrace(syncResult.Error).Error())
+	}
 }
 
-func updateDB(client db.Client) {
-	err := db.EndpointsCache.Update(func(object interface{}) error {
+// flush will write the updates of the caches and the processed interactions in a single
+// transaction, so either all of the documents of the batch are written or none of them are.
+// The caches keep the documents (and the deletes) of the flush until it is committed: the
+// documents of a failed flush are marked as updated again and written by the next flush,
+// and its closed periods and interactions are archived and stored by the next flush.
+func flush(client db.Client) error {
+	tx, err := client.Begin()
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	err = archiveDB(tx)
+	if err != nil {
+		tx.Rollback()
+		return errors.New(err, nil)
+	}
+
+	err = updateDB(tx)
+	if err != nil {
+		tx.Rollback()
+		rollback()
+		return errors.New(err, nil)
+	}
+
+	storeDB(tx)
+
+	err = tx.Commit()
+	if err != nil {
+		rollback()
+		return errors.New(err, nil)
+	}
+	committed()
+	archived()
+	stored = make([]*types.Interaction, 0, MinBatchSize)
+
+	return nil
+}
+
+// flushedCache is a cache that keeps the documents of a flush until it is committed
+type flushedCache interface {
+	Committed()
+	Rollback()
+}
+
+func flushedCaches() []flushedCache {
+	return []flushedCache{
+		db.IdentitiesCache,
+		db.TombstonesCache,
+		db.ConsentCache,
+		db.FilterHitsCache,
+		db.UsersCache,
+		db.EndpointsCache,
+		db.EndpointsStatsCache,
+		db.OriginsCache,
+		db.OriginsStatsCache,
+		db.EntitiesCache,
+		db.EntityStatsCache,
+		db.PropertiesCache,
+		db.PropertyStatsCache,
+	}
+}
+
+// committed will forget the documents of the caches once their flush is committed
+func committed() {
+	for _, cache := range flushedCaches() {
+		cache.Committed()
+	}
+}
+
+// rollback will mark the documents of a flush that was not committed as updated again
+func rollback() {
+	for _, cache := range flushedCaches() {
+		cache.Rollback()
+	}
+}
+
+// storeInteraction will add the processed interaction to the interactions that are stored by the next flush
+func storeInteraction(interaction *types.Interaction) {
+	if !db.GlobalSettings.InteractionsStorage {
+		return
+	}
+
+	stored = append(stored, interaction)
+}
+
+// storeDB will write the interactions of the batch in the transaction of its flush.
+// An interaction that can not be written is skipped (it does not fail the flush).
+func storeDB(tx db.Tx) {
+	for _, interaction := range stored {
+		interactionResult := tx.Do(&db.Op{
+			Resource: db.Interactions,
+			Type:     db.Create,
+			Item:     interaction,
+		})
+		if interactionResult.Error != nil {
+			fmt.Println(errors.NewTrace(interactionResult.Error).Error())
+		}
+	}
+}
+
+// updateDB will write the updated (and delete the removed) documents of the caches.
+// It stops at the first document that can not be written.
+func updateDB(client db.Tx) error {
+	err := db.IdentitiesCache.Update(func(object interface{}) error {
+		identity, ok := object.(*types.Identity)
+		if !ok {
+			return errors.New(types.ErrAssertion, nil)
+		}
+
+		identityUpdate := client.Do(&db.Op{
+			Resource: db.Identities,
+			Type:     db.Update,
+			Where: db.WhereMap{
+				"item.id": identity.ID,
+			},
+			Item:   identity,
+			Upsert: true,
+		})
+
+		if identityUpdate.Error != nil {
+			return errors.New(identityUpdate.Error, nil)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	err = db.IdentitiesCache.Deleted(func(object interface{}) error {
+		identity, ok := object.(*types.Identity)
+		if !ok {
+			return errors.New(types.ErrAssertion, nil)
+		}
+
+		identityDelete := client.Do(&db.Op{
+			Resource: db.Identities,
+			Type:     db.Delete,
+			Where: db.WhereMap{
+				"item.id": identity.ID,
+			},
+		})
+
+		if identityDelete.Error != nil && identityDelete.Error != types.ErrDNE {
+			return errors.New(identityDelete.Error, nil)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	err = db.TombstonesCache.Update(func(object interface{}) error {
+		tombstone, ok := object.(*types.Tombstone)
+		if !ok {
+			return errors.New(types.ErrAssertion, nil)
+		}
+
+		tombstoneUpdate := client.Do(&db.Op{
+			Resource: db.Tombstones,
+			Type:     db.Update,
+			Where: db.WhereMap{
+				"item.id": tombstone.ID,
+			},
+			Item:   tombstone,
+			Upsert: true,
+		})
+
+		if tombstoneUpdate.Error != nil {
+			return errors.New(tombstoneUpdate.Error, nil)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	err = db.ConsentCache.Update(func(object interface{}) error {
+		consentUpdate := client.Do(&db.Op{
+			Resource: db.Consent,
+			Type:     db.Update,
+			Item:     object,
+		})
+
+		if consentUpdate.Error != nil {
+			return errors.New(consentUpdate.Error, nil)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	err = db.FilterHitsCache.Update(func(object interface{}) error {
+		filterHitsUpdate := client.Do(&db.Op{
+			Resource: db.FilterHits,
+			Type:     db.Update,
+			Item:     object,
+		})
+
+		if filterHitsUpdate.Error != nil {
+			return errors.New(filterHitsUpdate.Error, nil)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	err = db.UsersCache.Update(func(object interface{}) error {
+		profile, ok := object.(*types.UserProfile)
+		if !ok {
+			return errors.New(types.ErrAssertion, nil)
+		}
+
+		profileUpdate := client.Do(&db.Op{
+			Resource: db.Users,
+			Type:     db.Update,
+			Where: db.WhereMap{
+				"item.id": profile.ID,
+			},
+			Item:   profile,
+			Upsert: true,
+		})
+
+		if profileUpdate.Error != nil {
+			return errors.New(profileUpdate.Error, nil)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	err = db.UsersCache.Deleted(func(object interface{}) error {
+		profile, ok := object.(*types.UserProfile)
+		if !ok {
+			return errors.New(types.ErrAssertion, nil)
+		}
+
+		profileDelete := client.Do(&db.Op{
+			Resource: db.Users,
+			Type:     db.Delete,
+			Where: db.WhereMap{
+				"item.id": profile.ID,
+			},
+		})
+
+		if profileDelete.Error != nil && profileDelete.Error != types.ErrDNE {
+			return errors.New(profileDelete.Error, nil)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	err = db.EndpointsCache.Update(func(object interface{}) error {
 		endpoint, ok := object.(*types.Endpoint)
 		if !ok {
 			return errors.New(types.ErrAssertion, nil)
@@ -232,7 +575,7 @@ func updateDB(client db.Client) {
 		return nil
 	})
 	if err != nil {
-		fmt.Println(errors.NewTrace(err).Error())
+		return errors.New(err, nil)
 	}
 
 	err = db.EndpointsStatsCache.Update(func(object interface{}) error {
@@ -259,7 +602,7 @@ func updateDB(client db.Client) {
 		return nil
 	})
 	if err != nil {
-		fmt.Println(errors.NewTrace(err).Error())
+		return errors.New(err, nil)
 	}
 
 	err = db.OriginsCache.Update(func(object interface{}) error {
@@ -285,7 +628,7 @@ func updateDB(client db.Client) {
 		return nil
 	})
 	if err != nil {
-		fmt.Println(errors.NewTrace(err).Error())
+		return errors.New(err, nil)
 	}
 
 	err = db.OriginsStatsCache.Update(func(object interface{}) error {
@@ -312,7 +655,7 @@ func updateDB(client db.Client) {
 		return nil
 	})
 	if err != nil {
-		fmt.Println(errors.NewTrace(err).Error())
+		return errors.New(err, nil)
 	}
 
 	err = db.EntitiesCache.Update(func(object interface{}) error {
@@ -338,7 +681,7 @@ func updateDB(client db.Client) {
 		return nil
 	})
 	if err != nil {
-		fmt.Println(errors.NewTrace(err).Error())
+		return errors.New(err, nil)
 	}
 
 	err = db.EntityStatsCache.Update(func(object interface{}) error {
@@ -365,7 +708,7 @@ func updateDB(client db.Client) {
 		return nil
 	})
 	if err != nil {
-		fmt.Println(errors.NewTrace(err).Error())
+		return errors.New(err, nil)
 	}
 
 	err = db.PropertiesCache.Update(func(object interface{}) error {
@@ -390,7 +733,7 @@ func updateDB(client db.Client) {
 		return nil
 	})
 	if err != nil {
-		fmt.Println(errors.NewTrace(err).Error())
+		return errors.New(err, nil)
 	}
 
 	err = db.PropertyStatsCache.Update(func(object interface{}) error {
@@ -416,9 +759,10 @@ func updateDB(client db.Client) {
 		return nil
 	})
 	if err != nil {
-		fmt.Println(errors.NewTrace(err).Error())
+		return errors.New(err, nil)
 	}
 
+	var summaryErr error
 	db.SummaryCache.Range(func(key, value interface{}) bool {
 		interval := key.(string)
 		summary := value.(*types.Summary)
@@ -434,9 +778,15 @@ func updateDB(client db.Client) {
 		})
 
 		if summaryUpdate.Error != nil {
-			fmt.Println(errors.New(summaryUpdate.Error, nil))
+			summaryErr = errors.New(summaryUpdate.Error, nil)
+			return false
 		}
 
 		return true
 	})
+	if summaryErr != nil {
+		return errors.New(summaryErr, nil)
+	}
+
+	return nil
 }
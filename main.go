@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/EngaugeAI/engauge/api"
 	"github.com/EngaugeAI/engauge/db"
+	"github.com/EngaugeAI/engauge/db/kv"
 	"github.com/EngaugeAI/engauge/db/local"
 	"github.com/EngaugeAI/engauge/ingest"
 	"github.com/EngaugeAI/engauge/types"
@@ -25,6 +27,8 @@ type EnvVars struct {
 	Env          string
 	Https        bool
 	Basepath     string
+	Store        string // local (default) or kv
+	Partitions   string // day (default) or hour, the partitions of the interactions of the local store
 	Timezone     string
 	Sessiondelay int
 	User         string
@@ -42,10 +46,39 @@ func main() {
 		dev = true
 	}
 
-	client, err := local.NewClient(env.Basepath)
+	// the migration opens both stores itself
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := migrateCommand(env.Basepath, os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// the restore writes the basepath before the store is opened
+	if len(os.Args) > 1 && os.Args[1] == "restore" {
+		err := restoreCommand(env.Store, env.Basepath, os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	local.HourlyPartitions = env.Partitions == "hour"
+
+	client, err := newClient(env.Store, env.Basepath)
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	// maintenance subcommands
+	if len(os.Args) > 1 {
+		err := command(client, os.Args[1], os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	ingest.Init(client)
 	api.Init(client, env.Timezone)
 
@@ -96,7 +129,7 @@ func main() {
 		}
 	}()
 
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
 	<-quit
 
@@ -108,6 +141,18 @@ func main() {
 	}
 }
 
+// newClient will open the store that is selected by the ENGAUGE_STORE variable
+func newClient(store, basepath string) (db.Client, error) {
+	switch store {
+	case "", "local":
+		return local.NewClient(basepath)
+	case "kv":
+		return kv.NewClient(basepath)
+	}
+
+	return nil, fmt.Errorf("unknown store %q (expected local or kv)", store)
+}
+
 func healthCheck(c echo.Context) error {
 	return c.String(http.StatusOK, "alive")
 }
@@ -0,0 +1,354 @@
+package types
+
+import (
+	_ "embed"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JKhawaja/errors"
+)
+
+const (
+	// UserTypeBot is the user type of interactions that have been tagged as bot traffic
+	UserTypeBot = "bot"
+
+	/* bot actions */
+
+	// BotDrop is a bot action (bot interactions are discarded)
+	BotDrop = "drop"
+	// BotTag is a bot action (bot interactions are stored with the bot user type,
+	// but are excluded from the aggregates)
+	BotTag = "tag"
+
+	/* bot detection reasons */
+
+	// BotSignature is a bot detection reason (user agent signature)
+	BotSignature = "signature"
+	// BotIP is a bot detection reason (excluded IP address or range)
+	BotIP = "ip"
+	// BotRate is a bot detection reason (interaction rate of the user)
+	BotRate = "rate"
+	// BotSession is a bot detection reason (zero-duration session of the user)
+	BotSession = "session"
+
+	// BotReasonProperty is the property that holds the detection reason of a tagged interaction
+	BotReasonProperty = "botReason"
+)
+
+var (
+	//go:embed bots.txt
+	botSignaturesFile string
+
+	// BotSignatures is the embedded list of user agent signatures (lowercase)
+	BotSignatures = parseBotSignatures(botSignaturesFile)
+)
+
+// BotSettings configures the detection of bot and crawler traffic at ingest.
+// User agents are matched against the embedded signatures and the signatures
+// of the settings, client IPs against the exclusion list. Users that exceed
+// the interaction rate, or that have a session of many interactions without
+// any duration, are flagged as bots for FlagHours.
+type BotSettings struct {
+	Enabled      bool     `json:"enabled"`
+	Action       string   `json:"action"`               // drop or tag
+	Signatures   []string `json:"signatures,omitempty"` // user agent substrings (in addition to the embedded list)
+	Exclude      []string `json:"exclude,omitempty"`    // IP addresses and CIDR ranges
+	MaxPerMinute int      `json:"maxPerMinute"`         // interactions per minute of a user (0 disables)
+	ZeroDuration int      `json:"zeroDuration"`         // interactions of a zero-duration session (0 disables)
+	FlagHours    int      `json:"flagHours"`            // how long a flagged user is treated as a bot
+}
+
+// BotStats holds the number of detected bot interactions per reason
+type BotStats struct {
+	Since   time.Time        `json:"since"`
+	Total   int64            `json:"total"`
+	Reasons map[string]int64 `json:"reasons"`
+	Flagged int              `json:"flagged"` // users currently flagged by a heuristic
+}
+
+// BotDetector keeps the state of the bot detection heuristics (in memory)
+type BotDetector struct {
+	rates   map[string]*botRate
+	flagged map[string]*botFlag
+	stats   *BotStats
+	pruned  time.Time
+	*sync.Mutex
+}
+
+type botRate struct {
+	start time.Time
+	count int
+}
+
+type botFlag struct {
+	reason string
+	until  time.Time
+}
+
+// NewBotSettings --
+func NewBotSettings() *BotSettings {
+	return &BotSettings{
+		Action:    BotTag,
+		FlagHours: 24,
+	}
+}
+
+// NewBotDetector --
+func NewBotDetector() *BotDetector {
+	now := time.Now().UTC()
+	return &BotDetector{
+		rates:   make(map[string]*botRate),
+		flagged: make(map[string]*botFlag),
+		stats: &BotStats{
+			Since:   now,
+			Reasons: make(map[string]int64),
+		},
+		pruned: now,
+		Mutex:  &sync.Mutex{},
+	}
+}
+
+func parseBotSignatures(file string) []string {
+	signatures := make([]string, 0)
+	for _, line := range strings.Split(file, "\n") {
+		line = strings.ToLower(strings.TrimSpace(line))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		signatures = append(signatures, line)
+	}
+
+	return signatures
+}
+
+// Validate --
+func (b *BotSettings) Validate() error {
+	if b.Action != BotDrop && b.Action != BotTag {
+		return errors.New(ErrBotAction, map[string]interface{}{
+			"action": b.Action,
+		})
+	}
+
+	for _, exclude := range b.Exclude {
+		if _, ok := parseNetwork(exclude); !ok {
+			return errors.New(ErrIPRange, map[string]interface{}{
+				"exclude": exclude,
+			})
+		}
+	}
+
+	return nil
+}
+
+// BotUserAgent will return whether or not the user agent matches an embedded bot signature
+func BotUserAgent(userAgent string) bool {
+	if userAgent == "" {
+		return false
+	}
+
+	ua := strings.ToLower(userAgent)
+	for _, signature := range BotSignatures {
+		if strings.Contains(ua, signature) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Signature will return whether or not the user agent matches a bot signature
+// (embedded or of the settings)
+func (b *BotSettings) Signature(userAgent string) bool {
+	if BotUserAgent(userAgent) {
+		return true
+	}
+
+	if userAgent == "" {
+		return false
+	}
+
+	ua := strings.ToLower(userAgent)
+	for _, signature := range b.Signatures {
+		if signature != "" && strings.Contains(ua, strings.ToLower(signature)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Excluded will return whether or not the IP is in the exclusion list
+func (b *BotSettings) Excluded(ip string) bool {
+	return inNetworks(ip, b.Exclude)
+}
+
+// inNetworks will return whether or not the IP is one of the IP addresses or CIDR ranges
+func inNetworks(ip string, networks []string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+
+	for _, value := range networks {
+		network, ok := parseNetwork(value)
+		if ok && network.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseNetwork will parse an IP address or a CIDR range
+func parseNetwork(value string) (*net.IPNet, bool) {
+	if strings.Contains(value, "/") {
+		_, network, err := net.ParseCIDR(value)
+		return network, err == nil
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, false
+	}
+
+	bits := 128
+	if ip.To4() != nil {
+		ip = ip.To4()
+		bits = 32
+	}
+
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, true
+}
+
+// Detect will return the reason for the interaction to be considered bot traffic
+// (an empty string if the interaction is not considered bot traffic).
+// The interaction rate of the user is counted by its received time.
+func (d *BotDetector) Detect(settings *BotSettings, i *Interaction) string {
+	if !settings.Enabled {
+		return ""
+	}
+
+	now := time.Now().UTC()
+	if i.ReceivedAt != nil {
+		now = *i.ReceivedAt
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	d.prune(now)
+
+	var reason string
+	user := i.User()
+	key := user.String()
+	flag, flagged := d.flagged[key]
+	switch {
+	case user.ID != "" && flagged && now.Before(flag.until):
+		reason = flag.reason
+	case settings.Signature(i.UserAgent):
+		reason = BotSignature
+	case settings.Excluded(i.IP):
+		reason = BotIP
+	case user.ID != "" && settings.MaxPerMinute > 0:
+		rate, ok := d.rates[key]
+		if !ok || now.Sub(rate.start) >= time.Minute {
+			rate = &botRate{start: now}
+			d.rates[key] = rate
+		}
+		rate.count++
+
+		if rate.count > settings.MaxPerMinute {
+			reason = BotRate
+			d.flag(settings, key, reason, now)
+		}
+	}
+
+	if reason != "" {
+		d.stats.Total++
+		d.stats.Reasons[reason]++
+	}
+
+	return reason
+}
+
+// Session will flag the user of the session if the session has at least
+// ZeroDuration interactions and no duration.
+func (d *BotDetector) Session(settings *BotSettings, s *UserSession) {
+	if !settings.Enabled || settings.ZeroDuration <= 0 || s == nil {
+		return
+	}
+
+	if s.Total < int64(settings.ZeroDuration) || !s.UpdatedAt.Equal(s.CreatedAt) {
+		return
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	user := User{Type: s.UserType, ID: s.UserID}
+	d.flag(settings, user.String(), BotSession, time.Now().UTC())
+}
+
+func (d *BotDetector) flag(settings *BotSettings, key, reason string, now time.Time) {
+	d.flagged[key] = &botFlag{
+		reason: reason,
+		until:  now.Add(time.Duration(settings.FlagHours) * time.Hour),
+	}
+}
+
+// prune will remove the expired rate windows and flags (at most once per minute)
+func (d *BotDetector) prune(now time.Time) {
+	if now.Sub(d.pruned) < time.Minute {
+		return
+	}
+
+	for key, rate := range d.rates {
+		if now.Sub(rate.start) >= time.Minute {
+			delete(d.rates, key)
+		}
+	}
+
+	for key, flag := range d.flagged {
+		if !now.Before(flag.until) {
+			delete(d.flagged, key)
+		}
+	}
+
+	d.pruned = now
+}
+
+// Stats will return a copy of the bot detection stats
+func (d *BotDetector) Stats() *BotStats {
+	d.Lock()
+	defer d.Unlock()
+
+	stats := &BotStats{
+		Since:   d.stats.Since,
+		Total:   d.stats.Total,
+		Reasons: make(map[string]int64, len(d.stats.Reasons)),
+		Flagged: len(d.flagged),
+	}
+	for reason, count := range d.stats.Reasons {
+		stats.Reasons[reason] = count
+	}
+
+	return stats
+}
+
+// TagBot will tag the interaction as bot traffic
+func (i *Interaction) TagBot(reason string) {
+	userType := UserTypeBot
+	i.UserType = &userType
+
+	if i.Properties == nil {
+		i.Properties = make(map[string]interface{})
+	}
+	i.Properties[BotReasonProperty] = reason
+}
+
+// Bot will return whether or not the interaction is bot traffic
+func (i *Interaction) Bot() bool {
+	return i.UserType != nil && *i.UserType == UserTypeBot
+}
@@ -0,0 +1,362 @@
+package types
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+const (
+	/* channels */
+
+	// ChannelSearch is a channel (search engines, organic or paid)
+	ChannelSearch = "search"
+	// ChannelSocial is a channel (social networks)
+	ChannelSocial = "social"
+	// ChannelEmail is a channel (newsletters and webmail)
+	ChannelEmail = "email"
+	// ChannelDirect is a channel (no referrer)
+	ChannelDirect = "direct"
+	// ChannelInternal is a channel (referred by one of the internal domains)
+	ChannelInternal = "internal"
+	// ChannelReferral is a channel (any other referrer or campaign)
+	ChannelReferral = "referral"
+
+	// DirectSource is the source of sessions without a campaign or referrer
+	DirectSource = "(direct)"
+	// InternalSource is the source of sessions referred by an internal domain
+	InternalSource = "(internal)"
+	// NoneMedium is the medium of sessions without a campaign or an external referrer
+	NoneMedium = "(none)"
+	// NotSet is the source or medium of a tagged campaign that does not have one
+	NotSet = "(not set)"
+)
+
+var (
+	// CampaignProperties are the properties that are set by the campaign enrichment
+	CampaignProperties = []string{"campaignSource", "campaignMedium", "campaignName", "campaignTerm", "campaignContent", "campaignClickID", "channel", "referrerHost"}
+
+	// clickIDs maps the click id parameters of ad networks to their source and medium
+	clickIDs = []struct {
+		param, source, medium string
+	}{
+		{"gclid", "google", "cpc"},
+		{"gbraid", "google", "cpc"},
+		{"wbraid", "google", "cpc"},
+		{"dclid", "google", "display"},
+		{"msclkid", "bing", "cpc"},
+		{"fbclid", "facebook", "paid-social"},
+		{"ttclid", "tiktok", "paid-social"},
+		{"li_fat_id", "linkedin", "paid-social"},
+		{"twclid", "twitter", "paid-social"},
+	}
+
+	// referrer host labels (or hosts) -> source
+	searchReferrers = map[string]string{
+		"google":     "google",
+		"bing":       "bing",
+		"duckduckgo": "duckduckgo",
+		"ddg":        "duckduckgo",
+		"yahoo":      "yahoo",
+		"baidu":      "baidu",
+		"yandex":     "yandex",
+		"ecosia":     "ecosia",
+		"qwant":      "qwant",
+		"startpage":  "startpage",
+		"naver":      "naver",
+		"seznam":     "seznam",
+		"brave":      "brave",
+	}
+	socialReferrers = map[string]string{
+		"facebook":  "facebook",
+		"fb":        "facebook",
+		"instagram": "instagram",
+		"twitter":   "twitter",
+		"t.co":      "twitter",
+		"x.com":     "twitter",
+		"linkedin":  "linkedin",
+		"lnkd":      "linkedin",
+		"reddit":    "reddit",
+		"pinterest": "pinterest",
+		"tiktok":    "tiktok",
+		"youtube":   "youtube",
+		"youtu.be":  "youtube",
+		"snapchat":  "snapchat",
+		"whatsapp":  "whatsapp",
+		"telegram":  "telegram",
+		"mastodon":  "mastodon",
+		"threads":   "threads",
+	}
+	emailReferrers = map[string]string{
+		"mail.google.com":    "gmail",
+		"outlook.live.com":   "outlook",
+		"outlook.office.com": "outlook",
+		"mail.yahoo.com":     "yahoo",
+		"mail.proton.me":     "proton",
+	}
+
+	// utm_medium values -> channel
+	mediumChannels = map[string]string{
+		"email":       ChannelEmail,
+		"e-mail":      ChannelEmail,
+		"newsletter":  ChannelEmail,
+		"social":      ChannelSocial,
+		"paid-social": ChannelSocial,
+		"paidsocial":  ChannelSocial,
+		"sm":          ChannelSocial,
+		"organic":     ChannelSearch,
+		"cpc":         ChannelSearch,
+		"ppc":         ChannelSearch,
+		"paidsearch":  ChannelSearch,
+	}
+)
+
+// CampaignSettings configures the parsing of the landing URL (utm_* and click id
+// parameters) and the referrer of an interaction into the campaign properties.
+type CampaignSettings struct {
+	Enabled          bool     `json:"enabled"`
+	URLProperty      string   `json:"urlProperty"`               // property that holds the landing URL
+	ReferrerProperty string   `json:"referrerProperty"`          // property that holds the referrer (URL or name)
+	InternalDomains  []string `json:"internalDomains,omitempty"` // referrers of these domains (and subdomains) are internal
+}
+
+// Campaign is the (normalized) campaign and channel of an interaction or session
+type Campaign struct {
+	Source       string `json:"source"`
+	Medium       string `json:"medium"`
+	Name         string `json:"campaign,omitempty"`
+	Term         string `json:"term,omitempty"`
+	Content      string `json:"content,omitempty"`
+	ClickID      string `json:"clickID,omitempty"`
+	Channel      string `json:"channel"`
+	ReferrerHost string `json:"referrerHost,omitempty"`
+}
+
+// NewCampaignSettings --
+func NewCampaignSettings() *CampaignSettings {
+	return &CampaignSettings{
+		URLProperty:      "url",
+		ReferrerProperty: "ref",
+		InternalDomains:  make([]string, 0),
+	}
+}
+
+// Enrich will replace the campaign properties of the interaction with the
+// campaign parsed from its landing URL and referrer properties.
+// Interactions that have neither property are left untouched.
+func (c *CampaignSettings) Enrich(i *Interaction) {
+	if !c.Enabled || i.Properties == nil {
+		return
+	}
+
+	landing, _ := i.Properties[c.URLProperty].(string)
+	referrer, _ := i.Properties[c.ReferrerProperty].(string)
+	if landing == "" && referrer == "" {
+		return
+	}
+
+	for _, key := range CampaignProperties {
+		delete(i.Properties, key)
+	}
+
+	campaign := c.Parse(landing, referrer)
+	for key, value := range campaign.Properties() {
+		i.Properties[key] = value
+	}
+}
+
+// Parse will return the campaign of a landing URL and a referrer.
+// The utm_* parameters take precedence over click ids, which take
+// precedence over the classification of the referrer.
+func (c *CampaignSettings) Parse(landing, referrer string) *Campaign {
+	campaign := &Campaign{}
+
+	var landingHost string
+	if u, err := url.Parse(strings.TrimSpace(landing)); err == nil {
+		landingHost = normalizeHost(u.Host)
+		query := u.Query()
+
+		campaign.Source = normalizeParam(query.Get("utm_source"))
+		campaign.Medium = normalizeParam(query.Get("utm_medium"))
+		campaign.Name = normalizeParam(query.Get("utm_campaign"))
+		campaign.Term = normalizeParam(query.Get("utm_term"))
+		campaign.Content = normalizeParam(query.Get("utm_content"))
+
+		for _, clickID := range clickIDs {
+			value := strings.TrimSpace(query.Get(clickID.param))
+			if value == "" {
+				continue
+			}
+
+			campaign.ClickID = value
+			if campaign.Source == "" {
+				campaign.Source = clickID.source
+			}
+			if campaign.Medium == "" {
+				campaign.Medium = clickID.medium
+			}
+			break
+		}
+	}
+
+	// referrer
+	campaign.ReferrerHost = referrerHost(referrer)
+	channel, source, medium := c.classify(campaign.ReferrerHost, landingHost)
+	if campaign.Source == "" && campaign.Medium == "" {
+		campaign.Source = source
+		campaign.Medium = medium
+		campaign.Channel = channel
+		return campaign
+	}
+
+	// tagged campaign (the channel is determined by the medium)
+	if campaign.Source == "" {
+		campaign.Source = NotSet
+	}
+	if campaign.Medium == "" {
+		campaign.Medium = NotSet
+	}
+	campaign.Channel = ChannelReferral
+	if ch, ok := mediumChannels[campaign.Medium]; ok {
+		campaign.Channel = ch
+	}
+
+	return campaign
+}
+
+// classify will return the channel, source and medium of a referrer host
+func (c *CampaignSettings) classify(host, landingHost string) (string, string, string) {
+	if host == "" {
+		return ChannelDirect, DirectSource, NoneMedium
+	}
+
+	if host == landingHost || c.internal(host) {
+		return ChannelInternal, InternalSource, NoneMedium
+	}
+
+	if source, ok := emailReferrers[host]; ok {
+		return ChannelEmail, source, ChannelEmail
+	}
+
+	if source, ok := lookupReferrer(socialReferrers, host); ok {
+		return ChannelSocial, source, ChannelSocial
+	}
+
+	if source, ok := lookupReferrer(searchReferrers, host); ok {
+		return ChannelSearch, source, "organic"
+	}
+
+	return ChannelReferral, host, ChannelReferral
+}
+
+func (c *CampaignSettings) internal(host string) bool {
+	for _, domain := range c.InternalDomains {
+		domain = normalizeHost(domain)
+		if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lookupReferrer will match the host, or any of its labels, against the referrers
+func lookupReferrer(referrers map[string]string, host string) (string, bool) {
+	if source, ok := referrers[host]; ok {
+		return source, true
+	}
+
+	for _, label := range strings.Split(host, ".") {
+		if source, ok := referrers[label]; ok {
+			return source, true
+		}
+	}
+
+	return "", false
+}
+
+// referrerHost will return the host of a referrer URL,
+// or the referrer itself if it is a name (e.g. `ddg`)
+func referrerHost(referrer string) string {
+	referrer = strings.TrimSpace(referrer)
+	if referrer == "" {
+		return ""
+	}
+
+	if strings.Contains(referrer, "://") {
+		u, err := url.Parse(referrer)
+		if err != nil {
+			return ""
+		}
+		return normalizeHost(u.Host)
+	}
+
+	host := strings.SplitN(referrer, "/", 2)[0]
+	return normalizeHost(host)
+}
+
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.TrimPrefix(host, "www.")
+}
+
+func normalizeParam(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// Properties will return the campaign as interaction properties
+func (c *Campaign) Properties() map[string]interface{} {
+	properties := map[string]interface{}{
+		"channel": c.Channel,
+	}
+
+	values := map[string]string{
+		"campaignSource":  c.Source,
+		"campaignMedium":  c.Medium,
+		"campaignName":    c.Name,
+		"campaignTerm":    c.Term,
+		"campaignContent": c.Content,
+		"campaignClickID": c.ClickID,
+		"referrerHost":    c.ReferrerHost,
+	}
+	for key, value := range values {
+		if value != "" {
+			properties[key] = value
+		}
+	}
+
+	return properties
+}
+
+// Campaign will return the campaign of the interaction
+// (nil if the interaction has not been enriched with a campaign)
+func (i *Interaction) Campaign() *Campaign {
+	if i.Properties == nil {
+		return nil
+	}
+
+	channel, ok := i.Properties["channel"].(string)
+	if !ok || channel == "" {
+		return nil
+	}
+
+	property := func(key string) string {
+		value, _ := i.Properties[key].(string)
+		return value
+	}
+
+	return &Campaign{
+		Source:       property("campaignSource"),
+		Medium:       property("campaignMedium"),
+		Name:         property("campaignName"),
+		Term:         property("campaignTerm"),
+		Content:      property("campaignContent"),
+		ClickID:      property("campaignClickID"),
+		Channel:      channel,
+		ReferrerHost: property("referrerHost"),
+	}
+}
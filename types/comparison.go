@@ -0,0 +1,261 @@
+package types
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// SignificanceLevel is the p-value under which a change is flagged as significant
+	SignificanceLevel = 0.05
+	// minSignificanceCount is the smallest count (of each period) that a change is tested with
+	minSignificanceCount = 10
+)
+
+// Delta is the change of a metric from an earlier period to the current period. The p-value
+// is only set when the counts of both periods allow a test (and the stats are not estimated).
+type Delta struct {
+	Current     float64  `json:"current"`
+	Previous    float64  `json:"previous"`
+	Change      float64  `json:"change"`
+	Percentage  *float64 `json:"percentage,omitempty"` // not set when the previous value is 0
+	PValue      *float64 `json:"pValue,omitempty"`
+	Significant bool     `json:"significant"`
+}
+
+// SessionDelta is the change of the sessions of a user, device and session type
+type SessionDelta struct {
+	UserType       string `json:"userType"`
+	DeviceType     string `json:"deviceType"`
+	SessionType    string `json:"sessionType"`
+	Count          *Delta `json:"count"`
+	Duration       *Delta `json:"duration"` // mean
+	BounceRate     *Delta `json:"bounceRate"`
+	ConversionRate *Delta `json:"conversionRate"`
+}
+
+// UnitMetricsDelta is the change of the unit metrics
+type UnitMetricsDelta struct {
+	TotalConversions      *Delta `json:"totalConversions"`
+	TotalRevenue          *Delta `json:"totalRevenue"`
+	AverageRevenuePerUser *Delta `json:"averageRevenuePerUser"`
+}
+
+// SummaryComparison is the comparison of a summary with the summary of an earlier period
+type SummaryComparison struct {
+	Start       time.Time         `json:"start"` // of the earlier period
+	End         time.Time         `json:"end"`
+	Total       *Delta            `json:"total"`
+	Users       *Delta            `json:"users"`
+	Actions     map[string]*Delta `json:"actions"`
+	Sessions    []*SessionDelta   `json:"sessions"`
+	UnitMetrics *UnitMetricsDelta `json:"unitMetrics"`
+	Estimated   bool              `json:"estimated"`
+}
+
+// NewDelta will return the change from the previous to the current value
+func NewDelta(current, previous float64) *Delta {
+	d := &Delta{
+		Current:  current,
+		Previous: previous,
+		Change:   current - previous,
+	}
+	if previous != 0 {
+		p := 100 * d.Change / previous
+		d.Percentage = &p
+	}
+
+	return d
+}
+
+// countDelta will return the change of a count, tested as the counts of two poisson processes
+func countDelta(current, previous int64, test bool) *Delta {
+	d := NewDelta(float64(current), float64(previous))
+	if !test || current < minSignificanceCount || previous < minSignificanceCount {
+		return d
+	}
+
+	z := float64(current-previous) / math.Sqrt(float64(current+previous))
+	d.test(z)
+
+	return d
+}
+
+// rateDelta will return the change of the rate of successes out of trials, tested as two proportions
+func rateDelta(current, currentTrials, previous, previousTrials int64, test bool) *Delta {
+	d := NewDelta(ratio(current, currentTrials), ratio(previous, previousTrials))
+	if !test || currentTrials < minSignificanceCount || previousTrials < minSignificanceCount {
+		return d
+	}
+
+	pooled := ratio(current+previous, currentTrials+previousTrials)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(currentTrials) + 1/float64(previousTrials)))
+	if se == 0 {
+		return d
+	}
+
+	d.test((d.Current - d.Previous) / se)
+
+	return d
+}
+
+// meanDelta will return the change of the mean of the stats, tested with the variances of the means
+func meanDelta(current, previous *SimpleStats, test bool) *Delta {
+	currentMean, currentVariance, n := numberStats(current)
+	previousMean, previousVariance, m := numberStats(previous)
+
+	d := NewDelta(currentMean, previousMean)
+	if !test || n < minSignificanceCount || m < minSignificanceCount {
+		return d
+	}
+
+	se := math.Sqrt(currentVariance/float64(n) + previousVariance/float64(m))
+	if se == 0 {
+		return d
+	}
+
+	d.test((currentMean - previousMean) / se)
+
+	return d
+}
+
+// test will set the (two-sided) p-value of the standard score of the change
+func (d *Delta) test(z float64) {
+	p := math.Erfc(math.Abs(z) / math.Sqrt2)
+	d.PValue = &p
+	d.Significant = p < SignificanceLevel
+}
+
+func ratio(n, d int64) float64 {
+	if d == 0 {
+		return 0
+	}
+
+	return float64(n) / float64(d)
+}
+
+// numberStats will return the mean, variance and count of number stats
+func numberStats(s *SimpleStats) (mean, variance float64, n int64) {
+	if s == nil || s.Type != Number {
+		return 0, 0, 0
+	}
+
+	mean, _ = s.Mean.(float64)
+	if len(s.Variance) > 0 {
+		variance = s.Variance[0]
+	}
+
+	return mean, variance, s.Total
+}
+
+// valueCounts will return the count of every value of the stats
+func valueCounts(s *SimpleStats) map[string]int64 {
+	counts := make(map[string]int64)
+	if s == nil {
+		return counts
+	}
+
+	for _, v := range s.Values {
+		if value, ok := v.Value.(string); ok {
+			counts[value] += v.Count
+		}
+	}
+
+	return counts
+}
+
+// Compare will compare the summary with the summary of an earlier period
+func (s *Summary) Compare(previous *Summary) *SummaryComparison {
+	test := !s.Estimated && !previous.Estimated
+
+	c := &SummaryComparison{
+		Start:     previous.Start,
+		End:       previous.End,
+		Total:     countDelta(s.Total, previous.Total, test),
+		Users:     countDelta(int64(len(s.Users)), int64(len(previous.Users)), test),
+		Actions:   make(map[string]*Delta),
+		Sessions:  make([]*SessionDelta, 0),
+		Estimated: !test,
+	}
+
+	current, earlier := valueCounts(s.ActionStats), valueCounts(previous.ActionStats)
+	for action, count := range current {
+		c.Actions[action] = countDelta(count, earlier[action], test)
+	}
+	for action, count := range earlier {
+		if _, ok := current[action]; !ok {
+			c.Actions[action] = countDelta(0, count, test)
+		}
+	}
+
+	c.Sessions = compareSessions(s.SessionStats, previous.SessionStats, test)
+	c.UnitMetrics = compareUnitMetrics(s, previous, test)
+
+	return c
+}
+
+func compareSessions(current, previous *SessionStatsList, test bool) []*SessionDelta {
+	empty := &SessionStats{}
+	find := func(list *SessionStatsList, stats *SessionStats) *SessionStats {
+		if list == nil {
+			return empty
+		}
+		for _, s := range list.List {
+			if s.UserType == stats.UserType && s.DeviceType == stats.DeviceType && s.SessionType == stats.SessionType {
+				return s
+			}
+		}
+		return empty
+	}
+
+	deltas := make([]*SessionDelta, 0)
+	compare := func(a, b *SessionStats, key *SessionStats) {
+		deltas = append(deltas, &SessionDelta{
+			UserType:       key.UserType,
+			DeviceType:     key.DeviceType,
+			SessionType:    key.SessionType,
+			Count:          countDelta(a.Count, b.Count, test),
+			Duration:       meanDelta(a.Duration, b.Duration, test),
+			BounceRate:     rateDelta(a.BouncedSessions, a.Count, b.BouncedSessions, b.Count, test),
+			ConversionRate: NewDelta(a.ConversionRate, b.ConversionRate),
+		})
+	}
+
+	if current != nil {
+		for _, stats := range current.List {
+			compare(stats, find(previous, stats), stats)
+		}
+	}
+	if previous != nil {
+		for _, stats := range previous.List {
+			if find(current, stats) == empty {
+				compare(empty, stats, stats)
+			}
+		}
+	}
+
+	return deltas
+}
+
+func compareUnitMetrics(s, previous *Summary, test bool) *UnitMetricsDelta {
+	a, b := s.UnitMetrics, previous.UnitMetrics
+	if a == nil {
+		a = &UnitMetrics{}
+	}
+	if b == nil {
+		b = &UnitMetrics{}
+	}
+
+	arpu := func(m *UnitMetrics) float64 {
+		if m.AverageRevenuePerUser == nil {
+			return 0
+		}
+		return *m.AverageRevenuePerUser
+	}
+
+	return &UnitMetricsDelta{
+		TotalConversions:      countDelta(a.TotalConversions, b.TotalConversions, test),
+		TotalRevenue:          NewDelta(a.TotalRevenue, b.TotalRevenue),
+		AverageRevenuePerUser: NewDelta(arpu(a), arpu(b)),
+	}
+}
@@ -0,0 +1,276 @@
+package types
+
+import (
+	"sync"
+	"time"
+
+	"github.com/JKhawaja/errors"
+)
+
+const (
+	/* consent modes */
+
+	// ConsentFull is a consent mode (interactions are fully tracked)
+	ConsentFull = "full"
+	// ConsentAnonymous is a consent mode (interactions only count towards aggregates)
+	ConsentAnonymous = "anonymous"
+	// ConsentDrop is a consent mode (interactions are discarded)
+	ConsentDrop = "drop"
+)
+
+// ConsentSettings configures how the consent of a user is determined
+// from an interaction and the headers of the request.
+// When several sources apply, the most restrictive mode wins.
+type ConsentSettings struct {
+	Property string            `json:"property,omitempty"` // property that holds the consent value
+	Values   map[string]string `json:"values,omitempty"`   // consent value -> mode
+	Default  string            `json:"default"`            // mode when the property is not set
+	DNT      string            `json:"dnt,omitempty"`      // mode for `DNT: 1` requests (empty ignores the header)
+	GPC      string            `json:"gpc,omitempty"`      // mode for `Sec-GPC: 1` requests (empty ignores the header)
+}
+
+// ConsentCount holds the number of interactions per consent mode
+type ConsentCount struct {
+	Full      int64 `json:"full"`
+	Anonymous int64 `json:"anonymous"`
+	Dropped   int64 `json:"dropped"`
+	DNT       int64 `json:"dnt"` // interactions received with `DNT: 1`
+	GPC       int64 `json:"gpc"` // interactions received with `Sec-GPC: 1`
+}
+
+// ConsentStats holds the consent counts in total and per day
+type ConsentStats struct {
+	Since time.Time                `json:"since"`
+	Total *ConsentCount            `json:"total"`
+	Days  map[string]*ConsentCount `json:"days"`
+}
+
+// ConsentCounter counts the consent mode of every received interaction
+type ConsentCounter struct {
+	stats   *ConsentStats
+	updated bool
+	flushed bool // written by a flush that is not committed yet
+	*sync.Mutex
+}
+
+// NewConsentSettings --
+func NewConsentSettings() *ConsentSettings {
+	return &ConsentSettings{
+		Property: "consent",
+		Values:   make(map[string]string),
+		Default:  ConsentFull,
+	}
+}
+
+// NewConsentStats --
+func NewConsentStats() *ConsentStats {
+	return &ConsentStats{
+		Since: time.Now().UTC(),
+		Total: &ConsentCount{},
+		Days:  make(map[string]*ConsentCount),
+	}
+}
+
+// NewConsentCounter --
+func NewConsentCounter(stats *ConsentStats) *ConsentCounter {
+	if stats == nil {
+		stats = NewConsentStats()
+	}
+
+	return &ConsentCounter{
+		stats: stats,
+		Mutex: &sync.Mutex{},
+	}
+}
+
+// ValidConsentMode --
+func ValidConsentMode(mode string) bool {
+	switch mode {
+	case ConsentFull, ConsentAnonymous, ConsentDrop:
+		return true
+	}
+
+	return false
+}
+
+// Validate --
+func (c *ConsentSettings) Validate() error {
+	modes := []string{c.Default}
+	if c.DNT != "" {
+		modes = append(modes, c.DNT)
+	}
+	if c.GPC != "" {
+		modes = append(modes, c.GPC)
+	}
+	for _, mode := range c.Values {
+		modes = append(modes, mode)
+	}
+
+	for _, mode := range modes {
+		if !ValidConsentMode(mode) {
+			return errors.New(ErrConsentMode, map[string]interface{}{
+				"mode": mode,
+			})
+		}
+	}
+
+	return nil
+}
+
+// Mode will return the consent mode for the properties (of an interaction
+// or the traits of an identify request) and the privacy headers of the request.
+func (c *ConsentSettings) Mode(properties map[string]interface{}, dnt, gpc bool) string {
+	mode := c.Default
+	if c.Property != "" && properties != nil {
+		if value, ok := properties[c.Property].(string); ok {
+			if m, ok := c.Values[value]; ok {
+				mode = m
+			} else if ValidConsentMode(value) {
+				mode = value
+			}
+		}
+	}
+
+	if dnt && c.DNT != "" {
+		mode = restrictive(mode, c.DNT)
+	}
+
+	if gpc && c.GPC != "" {
+		mode = restrictive(mode, c.GPC)
+	}
+
+	if !ValidConsentMode(mode) {
+		return ConsentFull
+	}
+
+	return mode
+}
+
+func restrictive(a, b string) string {
+	rank := map[string]int{
+		ConsentFull:      0,
+		ConsentAnonymous: 1,
+		ConsentDrop:      2,
+	}
+
+	if rank[b] > rank[a] {
+		return b
+	}
+
+	return a
+}
+
+// Anonymize will remove the user, device and session identifiers of the interaction
+func (i *Interaction) Anonymize() {
+	i.UserID = nil
+	i.DeviceID = nil
+	i.SessionID = nil
+	i.PreviousUserID = nil
+	i.PreviousDeviceID = nil
+	i.Consent = ConsentAnonymous
+}
+
+// Anonymous will return whether or not the interaction has been anonymized
+func (i *Interaction) Anonymous() bool {
+	return i.Consent == ConsentAnonymous
+}
+
+// Add will count an interaction of the consent mode
+func (c *ConsentCounter) Add(mode string, dnt, gpc bool, receivedAt time.Time) {
+	c.Lock()
+	defer c.Unlock()
+
+	day := receivedAt.Format("2006-01-02")
+	count, ok := c.stats.Days[day]
+	if !ok {
+		count = &ConsentCount{}
+		c.stats.Days[day] = count
+	}
+
+	for _, cc := range []*ConsentCount{c.stats.Total, count} {
+		switch mode {
+		case ConsentFull:
+			cc.Full++
+		case ConsentAnonymous:
+			cc.Anonymous++
+		case ConsentDrop:
+			cc.Dropped++
+		}
+
+		if dnt {
+			cc.DNT++
+		}
+
+		if gpc {
+			cc.GPC++
+		}
+	}
+
+	c.updated = true
+}
+
+// Stats will return a copy of the consent stats
+func (c *ConsentCounter) Stats() *ConsentStats {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.copy()
+}
+
+func (c *ConsentCounter) copy() *ConsentStats {
+	total := *c.stats.Total
+	stats := &ConsentStats{
+		Since: c.stats.Since,
+		Total: &total,
+		Days:  make(map[string]*ConsentCount, len(c.stats.Days)),
+	}
+	for day, count := range c.stats.Days {
+		cc := *count
+		stats.Days[day] = &cc
+	}
+
+	return stats
+}
+
+// Update --
+func (c *ConsentCounter) Update(updateFunc func(object interface{}) error) error {
+	c.Lock()
+	if !c.updated {
+		c.Unlock()
+		return nil
+	}
+	stats := c.copy()
+	c.updated = false
+	c.flushed = true
+	c.Unlock()
+
+	err := updateFunc(stats)
+	if err != nil {
+		c.Lock()
+		c.updated = true
+		c.Unlock()
+		return errors.New(err, nil)
+	}
+
+	return nil
+}
+
+// Committed will forget the flushed counts once their flush is committed
+func (c *ConsentCounter) Committed() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.flushed = false
+}
+
+// Rollback will mark the counts of a flush that was not committed
+// as updated again, so that they are written by the next flush
+func (c *ConsentCounter) Rollback() {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.flushed {
+		c.updated = true
+		c.flushed = false
+	}
+}
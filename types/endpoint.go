@@ -69,6 +69,7 @@ type Endpoints struct {
 	List    map[uuid.UUID]*Endpoint `json:"list"`
 	index   map[string]uuid.UUID
 	updated map[uuid.UUID]*Endpoint
+	flushed map[uuid.UUID]*Endpoint // written by a flush that is not committed yet
 	*sync.Mutex
 }
 
@@ -114,6 +115,7 @@ func NewEndpoints() *Endpoints {
 		List:    make(map[uuid.UUID]*Endpoint),
 		index:   make(map[string]uuid.UUID),
 		updated: make(map[uuid.UUID]*Endpoint),
+		flushed: make(map[uuid.UUID]*Endpoint),
 		Mutex:   &sync.Mutex{},
 	}
 }
@@ -138,18 +140,19 @@ func NewEndpointProfile(i *Interaction) (*EndpointProfile, error) {
 	device := i.Device()
 	user := i.User()
 	session := i.Session()
+	w := i.Weight()
 
-	userTypeStats, err := NewSimpleStats(user.Type)
+	userTypeStats, err := NewWeightedSimpleStats(user.Type, w)
 	if err != nil {
 		return nil, errors.New(err, nil)
 	}
 
-	deviceTypeStats, err := NewSimpleStats(device.Type)
+	deviceTypeStats, err := NewWeightedSimpleStats(device.Type, w)
 	if err != nil {
 		return nil, errors.New(err, nil)
 	}
 
-	sessionTypeStats, err := NewSimpleStats(session.Type)
+	sessionTypeStats, err := NewWeightedSimpleStats(session.Type, w)
 	if err != nil {
 		return nil, errors.New(err, nil)
 	}
@@ -157,7 +160,7 @@ func NewEndpointProfile(i *Interaction) (*EndpointProfile, error) {
 	propertyStats := NewNamedSimpleStatsList()
 	if i.Properties != nil {
 		for name, value := range i.Properties {
-			err := propertyStats.Update(name, value)
+			err := propertyStats.UpdateWeighted(name, value, w)
 			if err != nil {
 				return nil, errors.New(err, nil)
 			}
@@ -165,7 +168,7 @@ func NewEndpointProfile(i *Interaction) (*EndpointProfile, error) {
 	}
 
 	return &EndpointProfile{
-		Total:            1,
+		Total:            w,
 		UserTypeStats:    userTypeStats,
 		DeviceTypeStats:  deviceTypeStats,
 		SessionTypeStats: sessionTypeStats,
@@ -279,12 +282,33 @@ func (i *Endpoints) Update(updateFunc func(object interface{}) error) error {
 		if err != nil {
 			return errors.New(err, nil)
 		}
+		i.flushed[id] = endpoint
 		delete(i.updated, id)
 	}
 
 	return nil
 }
 
+// Committed will forget the documents of the flush once it is committed
+func (i *Endpoints) Committed() {
+	i.Lock()
+	defer i.Unlock()
+
+	i.flushed = make(map[uuid.UUID]*Endpoint)
+}
+
+// Rollback will mark the documents of a flush that was not committed
+// as updated again, so that they are written by the next flush
+func (i *Endpoints) Rollback() {
+	i.Lock()
+	defer i.Unlock()
+
+	for id, endpoint := range i.flushed {
+		i.updated[id] = endpoint
+	}
+	i.flushed = make(map[uuid.UUID]*Endpoint)
+}
+
 // Remove --
 func (i *Endpoints) Remove(key interface{}) error {
 	i.Lock()
@@ -460,18 +484,19 @@ func (i *Endpoint) Eq(input interface{}) bool {
 // Apply --
 func (i *EndpointProfile) Update(event *Event) error {
 	interaction := event.Interaction
+	w := interaction.Weight()
 	sess := event.Session
 
-	i.Total++
+	i.Total += w
 
 	if interaction.UserType != nil {
 		if i.UserTypeStats != nil {
-			err := i.UserTypeStats.Update(*interaction.UserType)
+			err := i.UserTypeStats.UpdateWeighted(*interaction.UserType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
 		} else {
-			ets, err := NewSimpleStats(*interaction.UserType)
+			ets, err := NewWeightedSimpleStats(*interaction.UserType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
@@ -481,12 +506,12 @@ func (i *EndpointProfile) Update(event *Event) error {
 
 	if interaction.DeviceType != nil {
 		if i.DeviceTypeStats != nil {
-			err := i.DeviceTypeStats.Update(*interaction.DeviceType)
+			err := i.DeviceTypeStats.UpdateWeighted(*interaction.DeviceType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
 		} else {
-			ets, err := NewSimpleStats(*interaction.DeviceType)
+			ets, err := NewWeightedSimpleStats(*interaction.DeviceType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
@@ -496,12 +521,12 @@ func (i *EndpointProfile) Update(event *Event) error {
 
 	if interaction.SessionType != nil {
 		if i.SessionTypeStats != nil {
-			err := i.SessionTypeStats.Update(*interaction.SessionType)
+			err := i.SessionTypeStats.UpdateWeighted(*interaction.SessionType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
 		} else {
-			ets, err := NewSimpleStats(*interaction.SessionType)
+			ets, err := NewWeightedSimpleStats(*interaction.SessionType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
@@ -511,7 +536,7 @@ func (i *EndpointProfile) Update(event *Event) error {
 
 	if interaction.Properties != nil {
 		for name, value := range interaction.Properties {
-			err := i.PropertyStats.Update(name, value)
+			err := i.PropertyStats.UpdateWeighted(name, value, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
@@ -20,6 +20,7 @@ type Entities struct {
 	List    map[uuid.UUID]*Entity
 	index   map[string]uuid.UUID
 	updated map[uuid.UUID]*Entity
+	flushed map[uuid.UUID]*Entity // written by a flush that is not committed yet
 	*sync.Mutex
 }
 
@@ -47,6 +48,7 @@ func NewEntities() *Entities {
 		List:    make(map[uuid.UUID]*Entity),
 		index:   make(map[string]uuid.UUID),
 		updated: make(map[uuid.UUID]*Entity),
+		flushed: make(map[uuid.UUID]*Entity),
 		Mutex:   &sync.Mutex{},
 	}
 }
@@ -61,14 +63,15 @@ func NewEntity(i *Interaction) *Entity {
 // NewEntityProfile --
 func NewEntityProfile(i *Interaction) (*EntityProfile, error) {
 	var userTypeStats, deviceTypeStats, sessionTypeStats *SimpleStats
+	w := i.Weight()
 
-	actionStats, err := NewSimpleStats(*i.Action)
+	actionStats, err := NewWeightedSimpleStats(*i.Action, w)
 	if err != nil {
 		return nil, errors.New(err, nil)
 	}
 
 	if i.UserType != nil {
-		uts, err := NewSimpleStats(*i.UserType)
+		uts, err := NewWeightedSimpleStats(*i.UserType, w)
 		if err != nil {
 			return nil, errors.New(err, nil)
 		}
@@ -76,7 +79,7 @@ func NewEntityProfile(i *Interaction) (*EntityProfile, error) {
 	}
 
 	if i.DeviceType != nil {
-		dts, err := NewSimpleStats(*i.DeviceType)
+		dts, err := NewWeightedSimpleStats(*i.DeviceType, w)
 		if err != nil {
 			return nil, errors.New(err, nil)
 		}
@@ -84,7 +87,7 @@ func NewEntityProfile(i *Interaction) (*EntityProfile, error) {
 	}
 
 	if i.SessionType != nil {
-		sts, err := NewSimpleStats(*i.SessionType)
+		sts, err := NewWeightedSimpleStats(*i.SessionType, w)
 		if err != nil {
 			return nil, errors.New(err, nil)
 		}
@@ -95,7 +98,7 @@ func NewEntityProfile(i *Interaction) (*EntityProfile, error) {
 	propertyStats := NewNamedSimpleStatsList()
 	if i.Properties != nil {
 		for key, value := range i.Properties {
-			err := propertyStats.Update(key, value)
+			err := propertyStats.UpdateWeighted(key, value, w)
 			if err != nil {
 				return nil, errors.New(err, nil)
 			}
@@ -103,7 +106,7 @@ func NewEntityProfile(i *Interaction) (*EntityProfile, error) {
 	}
 
 	return &EntityProfile{
-		Total:            1,
+		Total:            w,
 		UserTypeStats:    userTypeStats,
 		ActionStats:      actionStats,
 		DeviceTypeStats:  deviceTypeStats,
@@ -140,12 +143,33 @@ func (e *Entities) Update(updateFunc func(object interface{}) error) error {
 		if err != nil {
 			return errors.New(err, nil)
 		}
+		e.flushed[id] = entity
 		delete(e.updated, id)
 	}
 
 	return nil
 }
 
+// Committed will forget the documents of the flush once it is committed
+func (e *Entities) Committed() {
+	e.Lock()
+	defer e.Unlock()
+
+	e.flushed = make(map[uuid.UUID]*Entity)
+}
+
+// Rollback will mark the documents of a flush that was not committed
+// as updated again, so that they are written by the next flush
+func (e *Entities) Rollback() {
+	e.Lock()
+	defer e.Unlock()
+
+	for id, entity := range e.flushed {
+		e.updated[id] = entity
+	}
+	e.flushed = make(map[uuid.UUID]*Entity)
+}
+
 // ID --
 func (e *Entities) ID(object interface{}) uuid.UUID {
 	e.Lock()
@@ -191,22 +215,23 @@ func (e *Entity) String() string {
 // Update --
 func (e *EntityProfile) Update(event *Event) error {
 	i := event.Interaction
+	w := i.Weight()
 
-	e.Total++
+	e.Total += w
 
-	err := e.ActionStats.Update(*i.Action)
+	err := e.ActionStats.UpdateWeighted(*i.Action, w)
 	if err != nil {
 		return errors.New(err, nil)
 	}
 
 	if i.UserType != nil {
 		if e.UserTypeStats != nil {
-			err := e.UserTypeStats.Update(*i.UserType)
+			err := e.UserTypeStats.UpdateWeighted(*i.UserType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
 		} else {
-			ets, err := NewSimpleStats(*i.UserType)
+			ets, err := NewWeightedSimpleStats(*i.UserType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
@@ -216,12 +241,12 @@ func (e *EntityProfile) Update(event *Event) error {
 
 	if i.DeviceType != nil {
 		if e.DeviceTypeStats != nil {
-			err := e.DeviceTypeStats.Update(*i.DeviceType)
+			err := e.DeviceTypeStats.UpdateWeighted(*i.DeviceType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
 		} else {
-			ets, err := NewSimpleStats(*i.DeviceType)
+			ets, err := NewWeightedSimpleStats(*i.DeviceType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
@@ -231,12 +256,12 @@ func (e *EntityProfile) Update(event *Event) error {
 
 	if i.SessionType != nil {
 		if e.SessionTypeStats != nil {
-			err := e.SessionTypeStats.Update(*i.SessionType)
+			err := e.SessionTypeStats.UpdateWeighted(*i.SessionType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
 		} else {
-			ets, err := NewSimpleStats(*i.SessionType)
+			ets, err := NewWeightedSimpleStats(*i.SessionType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
@@ -246,7 +271,7 @@ func (e *EntityProfile) Update(event *Event) error {
 
 	if i.Properties != nil {
 		for name, value := range i.Properties {
-			err := e.PropertyStats.Update(name, value)
+			err := e.PropertyStats.UpdateWeighted(name, value, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
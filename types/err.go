@@ -15,6 +15,10 @@ var (
 	ErrCollision = errors.New("key collision")
 	// ErrDNE --
 	ErrDNE = errors.New("does not exist")
+	// ErrChecksum --
+	ErrChecksum = errors.New("checksum mismatch")
+	// ErrTxDone --
+	ErrTxDone = errors.New("transaction has already been committed or rolled back")
 	// ErrUser --
 	ErrUser = errors.New("missing user id")
 	// ErrDataType --
@@ -45,10 +49,48 @@ var (
 	ErrLength = errors.New("incorrect length")
 	// ErrTimestamp --
 	ErrTimestamp = errors.New("invalid timestamp format")
+	// ErrTimestampRange --
+	ErrTimestampRange = errors.New("timestamp out of accepted range")
+	// ErrTimezone --
+	ErrTimezone = errors.New("invalid timezone")
 	// ErrBounds --
 	ErrBounds = errors.New("out of bounds")
 	// ErrAssertion --
 	ErrAssertion = errors.New("type assertion error")
+	// ErrIdentifier --
+	ErrIdentifier = errors.New("missing anonymous user id, device id, or traits")
+	// ErrSortKey --
+	ErrSortKey = errors.New("invalid sort key")
+	// ErrConsentMode --
+	ErrConsentMode = errors.New("invalid consent mode")
+	// ErrBotAction --
+	ErrBotAction = errors.New("invalid bot action")
+	// ErrIPRange --
+	ErrIPRange = errors.New("invalid IP address or CIDR range")
+	// ErrFilterRule --
+	ErrFilterRule = errors.New("invalid filter rule")
+	// ErrGeoFile --
+	ErrGeoFile = errors.New("missing or invalid geo database file")
+	// ErrStage --
+	ErrStage = errors.New("invalid pipeline stage")
+	// ErrField --
+	ErrField = errors.New("invalid or missing field")
+	// ErrExpression --
+	ErrExpression = errors.New("invalid expression")
+	// ErrSampleRule --
+	ErrSampleRule = errors.New("invalid sample rule")
+	// ErrGoal --
+	ErrGoal = errors.New("invalid goal")
 	// ErrResourceType --
 	ErrResourceType = errors.New("invalid resource type")
+	// ErrQuery --
+	ErrQuery = errors.New("invalid query")
+	// ErrRetention --
+	ErrRetention = errors.New("invalid retention settings")
+	// ErrInterval --
+	ErrInterval = errors.New("invalid or missing interval")
+	// ErrBackup --
+	ErrBackup = errors.New("invalid or incompatible backup")
+	// ErrTimestampSettings --
+	ErrTimestampSettings = errors.New("invalid timestamp settings")
 )
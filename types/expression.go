@@ -0,0 +1,301 @@
+package types
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/JKhawaja/errors"
+)
+
+// Expression is a simple arithmetic expression over the fields of an interaction,
+// used for derived properties. It supports numbers, 'strings', fields (e.g. `action`
+// or `properties.price`), parentheses, and the + - * / operators (+ concatenates
+// when either side is a string).
+type Expression struct {
+	source string
+	root   exprNode
+}
+
+type exprNode interface {
+	eval(i *Interaction) (interface{}, error)
+}
+
+type exprValue struct {
+	value interface{}
+}
+
+type exprField struct {
+	name string
+}
+
+type exprNegate struct {
+	operand exprNode
+}
+
+type exprBinary struct {
+	op          byte
+	left, right exprNode
+}
+
+type exprParser struct {
+	source string
+	pos    int
+}
+
+// ParseExpression --
+func ParseExpression(source string) (*Expression, error) {
+	p := &exprParser{source: source}
+	root, err := p.expression()
+	if err != nil {
+		return nil, errors.New(err, map[string]interface{}{
+			"expression": source,
+		})
+	}
+
+	p.skipSpace()
+	if p.pos < len(p.source) {
+		return nil, errors.New(ErrExpression, map[string]interface{}{
+			"expression": source,
+			"position":   p.pos,
+		})
+	}
+
+	return &Expression{
+		source: source,
+		root:   root,
+	}, nil
+}
+
+// String --
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Eval will evaluate the expression on the interaction.
+// The result is either a float64 or a string.
+func (e *Expression) Eval(i *Interaction) (interface{}, error) {
+	return e.root.eval(i)
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.source) && unicode.IsSpace(rune(p.source[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *exprParser) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.source) {
+		return 0
+	}
+
+	return p.source[p.pos]
+}
+
+// expression := term (('+' | '-') term)*
+func (p *exprParser) expression() (exprNode, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return left, nil
+		}
+		p.pos++
+
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: op, left: left, right: right}
+	}
+}
+
+// term := factor (('*' | '/') factor)*
+func (p *exprParser) term() (exprNode, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.peek()
+		if op != '*' && op != '/' {
+			return left, nil
+		}
+		p.pos++
+
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: op, left: left, right: right}
+	}
+}
+
+// factor := number | 'string' | field | '(' expression ')' | '-' factor
+func (p *exprParser) factor() (exprNode, error) {
+	c := p.peek()
+	switch {
+	case c == '(':
+		p.pos++
+		node, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, errors.New(ErrExpression, map[string]interface{}{
+				"position": p.pos,
+			})
+		}
+		p.pos++
+		return node, nil
+	case c == '-':
+		p.pos++
+		operand, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return &exprNegate{operand: operand}, nil
+	case c == '\'':
+		end := strings.IndexByte(p.source[p.pos+1:], '\'')
+		if end < 0 {
+			return nil, errors.New(ErrExpression, map[string]interface{}{
+				"position": p.pos,
+			})
+		}
+		value := p.source[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return &exprValue{value: value}, nil
+	case c == '.' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.source) && (p.source[p.pos] == '.' || (p.source[p.pos] >= '0' && p.source[p.pos] <= '9')) {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(p.source[start:p.pos], 64)
+		if err != nil {
+			return nil, errors.New(ErrExpression, map[string]interface{}{
+				"position": start,
+			})
+		}
+		return &exprValue{value: n}, nil
+	case c == '_' || unicode.IsLetter(rune(c)):
+		start := p.pos
+		for p.pos < len(p.source) {
+			r := rune(p.source[p.pos])
+			if r != '_' && r != '.' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				break
+			}
+			p.pos++
+		}
+		return &exprField{name: p.source[start:p.pos]}, nil
+	}
+
+	return nil, errors.New(ErrExpression, map[string]interface{}{
+		"position": p.pos,
+	})
+}
+
+func (e *exprValue) eval(i *Interaction) (interface{}, error) {
+	return e.value, nil
+}
+
+func (e *exprField) eval(i *Interaction) (interface{}, error) {
+	value, ok := i.Field(e.name)
+	if !ok {
+		return nil, errors.New(ErrField, map[string]interface{}{
+			"field": e.name,
+		})
+	}
+
+	return value, nil
+}
+
+func (e *exprNegate) eval(i *Interaction) (interface{}, error) {
+	value, err := e.operand.eval(i)
+	if err != nil {
+		return nil, err
+	}
+
+	n, ok := exprNumber(value)
+	if !ok {
+		return nil, errors.New(ErrExpression, map[string]interface{}{
+			"value": value,
+		})
+	}
+
+	return -n, nil
+}
+
+func (e *exprBinary) eval(i *Interaction) (interface{}, error) {
+	left, err := e.left.eval(i)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := e.right.eval(i)
+	if err != nil {
+		return nil, err
+	}
+
+	// concatenation
+	_, ls := left.(string)
+	_, rs := right.(string)
+	if e.op == '+' && (ls || rs) {
+		return exprString(left) + exprString(right), nil
+	}
+
+	l, lok := exprNumber(left)
+	r, rok := exprNumber(right)
+	if !lok || !rok {
+		return nil, errors.New(ErrExpression, map[string]interface{}{
+			"left":  left,
+			"right": right,
+		})
+	}
+
+	switch e.op {
+	case '+':
+		return l + r, nil
+	case '-':
+		return l - r, nil
+	case '*':
+		return l * r, nil
+	}
+
+	if r == 0 {
+		return nil, errors.New(ErrExpression, map[string]interface{}{
+			"reason": "division by zero",
+		})
+	}
+
+	return l / r, nil
+}
+
+// exprNumber will return the value as a number (numeric strings are parsed)
+func exprNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil
+	}
+
+	return 0, false
+}
+
+func exprString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	return ""
+}
@@ -0,0 +1,295 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/JKhawaja/errors"
+)
+
+const (
+	/* filter actions */
+
+	// FilterExclude is a filter action (matching interactions are discarded)
+	FilterExclude = "exclude"
+	// FilterSandbox is a filter action (matching interactions are stored in a sandbox
+	// and do not update any stats)
+	FilterSandbox = "sandbox"
+)
+
+var sandboxName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// FilterRule matches internal and test traffic at ingest.
+// Every condition that is set must match. The rules are matched
+// in order and the first matching rule applies.
+type FilterRule struct {
+	ID       string `json:"id"`
+	Action   string `json:"action"`            // exclude or sandbox
+	Sandbox  string `json:"sandbox,omitempty"` // sandbox name (for the sandbox action)
+	UserType string `json:"userType,omitempty"`
+	UserID   string `json:"userID,omitempty"` // regular expression
+	DeviceID string `json:"deviceID,omitempty"`
+	Property string `json:"property,omitempty"`
+	Value    string `json:"value,omitempty"` // value of the property (any value if empty)
+	APIKey   string `json:"apiKey,omitempty"`
+
+	userID *regexp.Regexp
+}
+
+// FilterRules is the ordered list of filter rules
+type FilterRules []*FilterRule
+
+// FilterHit holds the number of interactions matched by a filter rule
+type FilterHit struct {
+	Hits      int64      `json:"hits"`
+	LastHitAt *time.Time `json:"lastHitAt,omitempty"`
+}
+
+// FilterRuleView is a filter rule along with its hits
+type FilterRuleView struct {
+	*FilterRule
+	*FilterHit
+}
+
+// FilterHits counts the hits of every filter rule (by rule id)
+type FilterHits struct {
+	List    map[string]*FilterHit
+	updated bool
+	flushed bool // written by a flush that is not committed yet
+	*sync.Mutex
+}
+
+// NewFilterRules --
+func NewFilterRules() FilterRules {
+	return make(FilterRules, 0)
+}
+
+// NewFilterHits --
+func NewFilterHits(list map[string]*FilterHit) *FilterHits {
+	if list == nil {
+		list = make(map[string]*FilterHit)
+	}
+
+	return &FilterHits{
+		List:  list,
+		Mutex: &sync.Mutex{},
+	}
+}
+
+// Validate will check every rule (and compile the user id patterns)
+func (f FilterRules) Validate() error {
+	ids := make(map[string]struct{})
+	for _, rule := range f {
+		if rule.ID == "" {
+			return errors.New(ErrFilterRule, map[string]interface{}{
+				"reason": "missing id",
+			})
+		}
+
+		if _, ok := ids[rule.ID]; ok {
+			return errors.New(ErrFilterRule, map[string]interface{}{
+				"id":     rule.ID,
+				"reason": "duplicate id",
+			})
+		}
+		ids[rule.ID] = struct{}{}
+
+		err := rule.Validate()
+		if err != nil {
+			return errors.New(err, nil)
+		}
+	}
+
+	return nil
+}
+
+// Validate --
+func (r *FilterRule) Validate() error {
+	switch r.Action {
+	case FilterExclude:
+	case FilterSandbox:
+		if !sandboxName.MatchString(r.Sandbox) {
+			return errors.New(ErrFilterRule, map[string]interface{}{
+				"id":     r.ID,
+				"reason": "invalid sandbox name",
+			})
+		}
+	default:
+		return errors.New(ErrFilterRule, map[string]interface{}{
+			"id":     r.ID,
+			"reason": "invalid action",
+		})
+	}
+
+	if r.UserType == "" && r.UserID == "" && r.DeviceID == "" && r.Property == "" && r.APIKey == "" {
+		return errors.New(ErrFilterRule, map[string]interface{}{
+			"id":     r.ID,
+			"reason": "no conditions",
+		})
+	}
+
+	if r.UserID != "" {
+		pattern, err := regexp.Compile(r.UserID)
+		if err != nil {
+			return errors.New(ErrFilterRule, map[string]interface{}{
+				"id":     r.ID,
+				"reason": err.Error(),
+			})
+		}
+		r.userID = pattern
+	}
+
+	return nil
+}
+
+// Match will return the first rule that matches the interaction (nil if none match)
+func (f FilterRules) Match(i *Interaction, apiKey string) *FilterRule {
+	for _, rule := range f {
+		if rule.Match(i, apiKey) {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+// Match will return whether or not the interaction matches every condition of the rule
+func (r *FilterRule) Match(i *Interaction, apiKey string) bool {
+	if r.APIKey != "" && r.APIKey != apiKey {
+		return false
+	}
+
+	if r.UserType != "" && (i.UserType == nil || *i.UserType != r.UserType) {
+		return false
+	}
+
+	if r.UserID != "" {
+		// the pattern is compiled by Validate
+		if r.userID == nil || i.UserID == nil || !r.userID.MatchString(*i.UserID) {
+			return false
+		}
+	}
+
+	if r.DeviceID != "" && (i.DeviceID == nil || *i.DeviceID != r.DeviceID) {
+		return false
+	}
+
+	if r.Property != "" {
+		value, ok := i.Properties[r.Property]
+		if !ok || !matchValue(value, r.Value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchValue will match a property value (or any element of an array value)
+func matchValue(value interface{}, expected string) bool {
+	if expected == "" {
+		return true
+	}
+
+	switch v := value.(type) {
+	case []string:
+		for _, s := range v {
+			if s == expected {
+				return true
+			}
+		}
+		return false
+	case []float64:
+		for _, n := range v {
+			if fmt.Sprint(n) == expected {
+				return true
+			}
+		}
+		return false
+	}
+
+	return fmt.Sprint(value) == expected
+}
+
+// Hit will count a hit of the rule
+func (f *FilterHits) Hit(id string, at time.Time) {
+	f.Lock()
+	defer f.Unlock()
+
+	hit, ok := f.List[id]
+	if !ok {
+		hit = &FilterHit{}
+		f.List[id] = hit
+	}
+	hit.Hits++
+	hit.LastHitAt = &at
+	f.updated = true
+}
+
+// Views will return the rules along with their hits
+func (f *FilterHits) Views(rules FilterRules) []*FilterRuleView {
+	f.Lock()
+	defer f.Unlock()
+
+	views := make([]*FilterRuleView, 0, len(rules))
+	for _, rule := range rules {
+		hit := &FilterHit{}
+		if h, ok := f.List[rule.ID]; ok {
+			*hit = *h
+		}
+		views = append(views, &FilterRuleView{
+			FilterRule: rule,
+			FilterHit:  hit,
+		})
+	}
+
+	return views
+}
+
+// Update --
+func (f *FilterHits) Update(updateFunc func(object interface{}) error) error {
+	f.Lock()
+	if !f.updated {
+		f.Unlock()
+		return nil
+	}
+	list := make(map[string]*FilterHit, len(f.List))
+	for id, hit := range f.List {
+		h := *hit
+		list[id] = &h
+	}
+	f.updated = false
+	f.flushed = true
+	f.Unlock()
+
+	err := updateFunc(list)
+	if err != nil {
+		f.Lock()
+		f.updated = true
+		f.Unlock()
+		return errors.New(err, nil)
+	}
+
+	return nil
+}
+
+// Committed will forget the flushed counts once their flush is committed
+func (f *FilterHits) Committed() {
+	f.Lock()
+	defer f.Unlock()
+
+	f.flushed = false
+}
+
+// Rollback will mark the counts of a flush that was not committed
+// as updated again, so that they are written by the next flush
+func (f *FilterHits) Rollback() {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.flushed {
+		f.updated = true
+		f.flushed = false
+	}
+}
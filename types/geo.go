@@ -0,0 +1,107 @@
+package types
+
+import (
+	"net"
+	"strings"
+
+	"github.com/JKhawaja/errors"
+)
+
+// GeoProperties are the interaction properties of a location
+var GeoProperties = []string{"country", "region", "city"}
+
+// GeoSettings configures the resolution of the client IP of an interaction
+// into its country, region and city with a local database file, which
+// is either a MaxMind (.mmdb) database or a CSV file of IP ranges
+// (start,end,country,region,city). The file is reloaded when it changes.
+type GeoSettings struct {
+	Enabled  bool   `json:"enabled"`
+	File     string `json:"file"`
+	Truncate bool   `json:"truncate"` // truncate the IP before the lookup (IPv4 to /24, IPv6 to /48)
+}
+
+// Geo is the location of an IP address
+type Geo struct {
+	Country string // ISO 3166-1 code
+	Region  string
+	City    string
+}
+
+// NewGeoSettings --
+func NewGeoSettings() *GeoSettings {
+	return &GeoSettings{}
+}
+
+// Validate --
+func (g *GeoSettings) Validate() error {
+	if g.Enabled && g.File == "" {
+		return errors.New(ErrGeoFile, nil)
+	}
+
+	return nil
+}
+
+// CSV will return whether or not the database file is a CSV file
+func (g *GeoSettings) CSV() bool {
+	return strings.HasSuffix(strings.ToLower(g.File), ".csv")
+}
+
+// Properties will return the location as interaction properties
+func (g *Geo) Properties() map[string]interface{} {
+	properties := make(map[string]interface{})
+	for idx, value := range []string{g.Country, g.Region, g.City} {
+		if value != "" {
+			properties[GeoProperties[idx]] = value
+		}
+	}
+
+	return properties
+}
+
+// TruncateIP will zero the host part of the IP (the last octet of an IPv4
+// address and the last 80 bits of an IPv6 address).
+func TruncateIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32))
+	}
+
+	return ip.Mask(net.CIDRMask(48, 128))
+}
+
+// ValidateProxies will check that every trusted proxy is an IP address or a CIDR range
+func ValidateProxies(proxies []string) error {
+	for _, proxy := range proxies {
+		if _, ok := parseNetwork(proxy); !ok {
+			return errors.New(ErrIPRange, map[string]interface{}{
+				"proxy": proxy,
+			})
+		}
+	}
+
+	return nil
+}
+
+// ClientIP will return the IP of the client of a request. The `X-Forwarded-For`
+// chain is only followed (from right to left) while the connecting address
+// is a trusted proxy, so that clients can not spoof their IP.
+func ClientIP(remoteAddr, forwardedFor string, proxies []string) string {
+	ip := remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		ip = host
+	}
+
+	var chain []string
+	if forwardedFor != "" {
+		chain = strings.Split(forwardedFor, ",")
+	}
+
+	for idx := len(chain) - 1; idx >= 0 && inNetworks(ip, proxies); idx-- {
+		next := strings.TrimSpace(chain[idx])
+		if net.ParseIP(next) == nil {
+			break
+		}
+		ip = next
+	}
+
+	return ip
+}
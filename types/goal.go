@@ -0,0 +1,279 @@
+package types
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/JKhawaja/errors"
+)
+
+var (
+	goals      = NewGoals()
+	goalsMutex = &sync.RWMutex{}
+)
+
+// Goal is an action that is counted as a conversion (an outcome such as a
+// signup, a purchase or a refund). The value of a goal interaction is read
+// from its ValueProperty and multiplied by the Sign (-1 for e.g. refunds).
+type Goal struct {
+	Action        string `json:"action"`
+	ValueProperty string `json:"valueProperty,omitempty"` // property that holds the value (empty: the goal has no value)
+	Currency      string `json:"currency,omitempty"`      // ISO 4217 code of the value
+	Sign          int    `json:"sign"`                    // 1, or -1 for goals that reduce the value
+}
+
+// Goals is the registry of goal actions
+type Goals []*Goal
+
+// GoalCount is the number of conversions and the (signed) value of a goal
+type GoalCount struct {
+	Goal        string  `json:"goal"`
+	Conversions int64   `json:"conversions"`
+	Value       float64 `json:"value"`
+}
+
+// GoalStats are the conversion stats and unit metrics of a goal
+type GoalStats struct {
+	Goal           string              `json:"goal"`
+	Currency       string              `json:"currency,omitempty"`
+	ConvertedUsers int64               `json:"convertedUsers"`
+	ConversionRate float64             `json:"conversionRate"` // converted users / users
+	UnitMetrics    *UnitMetrics        `json:"unitMetrics"`
+	Users          map[uint32]struct{} `json:"-"`
+}
+
+// GoalStatsList --
+type GoalStatsList struct {
+	List []*GoalStats
+}
+
+// NewGoals will return the default goals (the `conversion` action with an `amount` value)
+func NewGoals() Goals {
+	return Goals{
+		{
+			Action:        Conversion,
+			ValueProperty: "amount",
+			Sign:          1,
+		},
+	}
+}
+
+// Validate will check the goals, and normalize their sign and currency
+func (g Goals) Validate() error {
+	actions := make(map[string]struct{})
+	for _, goal := range g {
+		if goal == nil || goal.Action == "" {
+			return errors.New(ErrGoal, map[string]interface{}{
+				"reason": "missing action",
+			})
+		}
+
+		if _, ok := actions[goal.Action]; ok {
+			return errors.New(ErrGoal, map[string]interface{}{
+				"goal":   goal.Action,
+				"reason": "duplicate action",
+			})
+		}
+		actions[goal.Action] = struct{}{}
+
+		switch goal.Sign {
+		case 0:
+			goal.Sign = 1
+		case 1, -1:
+		default:
+			return errors.New(ErrGoal, map[string]interface{}{
+				"goal":   goal.Action,
+				"reason": "sign must be 1 or -1",
+			})
+		}
+
+		goal.Currency = strings.ToUpper(strings.TrimSpace(goal.Currency))
+		if goal.Currency != "" && !currencyCode(goal.Currency) {
+			return errors.New(ErrGoal, map[string]interface{}{
+				"goal":     goal.Action,
+				"currency": goal.Currency,
+				"reason":   "currency must be an ISO 4217 code",
+			})
+		}
+	}
+
+	return nil
+}
+
+func currencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Get will return the goal of an action (nil if the action is not a goal)
+func (g Goals) Get(action string) *Goal {
+	for _, goal := range g {
+		if goal.Action == action {
+			return goal
+		}
+	}
+
+	return nil
+}
+
+// SetGoals will replace the goals that the stats are computed for
+func SetGoals(g Goals) {
+	goalsMutex.Lock()
+	defer goalsMutex.Unlock()
+
+	if g == nil {
+		g = NewGoals()
+	}
+	goals = g
+}
+
+// GoalOf will return the goal of an action (nil if the action is not a goal)
+func GoalOf(action string) *Goal {
+	goalsMutex.RLock()
+	defer goalsMutex.RUnlock()
+
+	return goals.Get(action)
+}
+
+// Goal will return the goal of the interaction (nil if its action is not a goal)
+func (i *Interaction) Goal() *Goal {
+	if i.Action == nil {
+		return nil
+	}
+
+	return GoalOf(*i.Action)
+}
+
+// Positive will return whether or not the goal counts towards the total conversions
+func (g *Goal) Positive() bool {
+	return g.Sign >= 0
+}
+
+// Value will return the signed value of a goal interaction,
+// and whether or not the interaction has a value.
+func (g *Goal) Value(i *Interaction) (float64, bool) {
+	if g.ValueProperty == "" || i.Properties == nil {
+		return 0, false
+	}
+
+	value, ok := i.Properties[g.ValueProperty].(float64)
+	if !ok {
+		return 0, false
+	}
+
+	if g.Sign < 0 {
+		return -value, true
+	}
+
+	return value, true
+}
+
+// addGoal will add the conversions and the value of a goal to the counts
+func addGoal(counts []*GoalCount, goal string, conversions int64, value float64) []*GoalCount {
+	for _, count := range counts {
+		if count.Goal == goal {
+			count.Conversions += conversions
+			count.Value += value
+			return counts
+		}
+	}
+
+	return append(counts, &GoalCount{
+		Goal:        goal,
+		Conversions: conversions,
+		Value:       value,
+	})
+}
+
+// NewGoalStatsList --
+func NewGoalStatsList() *GoalStatsList {
+	return &GoalStatsList{
+		List: make([]*GoalStats, 0),
+	}
+}
+
+// get will return the stats of a goal (created if they do not exist)
+func (g *GoalStatsList) get(goal *Goal) *GoalStats {
+	for _, stats := range g.List {
+		if stats.Goal == goal.Action {
+			stats.Currency = goal.Currency
+			return stats
+		}
+	}
+
+	stats := &GoalStats{
+		Goal:        goal.Action,
+		Currency:    goal.Currency,
+		UnitMetrics: &UnitMetrics{},
+		Users:       make(map[uint32]struct{}),
+	}
+	g.List = append(g.List, stats)
+
+	return stats
+}
+
+// Update will update the stats of the goal of the interaction,
+// users is the number of unique users of the summary.
+func (g *GoalStatsList) Update(i *Interaction, users int64) error {
+	goal := i.Goal()
+	if goal == nil {
+		return nil
+	}
+
+	stats := g.get(goal)
+	if stats.Users == nil {
+		stats.Users = make(map[uint32]struct{})
+	}
+	if !i.Anonymous() {
+		hashedKey, err := userHash(i.User())
+		if err != nil {
+			return errors.New(err, nil)
+		}
+		stats.Users[hashedKey] = struct{}{}
+	}
+
+	value, ok := goal.Value(i)
+	err := stats.UnitMetrics.update(value, ok, i.Weight(), users)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	return nil
+}
+
+// SimpleUpdate will update the conversions and values of the goal of the interaction
+func (g *GoalStatsList) SimpleUpdate(i *Interaction) {
+	goal := i.Goal()
+	if goal == nil {
+		return
+	}
+
+	value, ok := goal.Value(i)
+	g.get(goal).UnitMetrics.update(value, ok, i.Weight(), 0)
+}
+
+// Rates will return the goal stats with their conversion rates
+// and revenue per user computed for the number of unique users.
+func (g *GoalStatsList) Rates(users int64) []*GoalStats {
+	for _, stats := range g.List {
+		if users <= 0 {
+			continue
+		}
+
+		stats.ConvertedUsers = int64(len(stats.Users))
+		stats.ConversionRate = float64(stats.ConvertedUsers) / float64(users)
+		arpu := stats.UnitMetrics.TotalRevenue / float64(users)
+		stats.UnitMetrics.AverageRevenuePerUser = &arpu
+	}
+
+	return g.List
+}
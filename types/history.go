@@ -0,0 +1,107 @@
+package types
+
+import (
+	"time"
+
+	"github.com/humilityai/temporal"
+)
+
+// Period is implemented by the stats of a period of an interval. Once the stats
+// of the next period replace them, the stats are archived to their history.
+type Period interface {
+	Period() (interval string, start, end time.Time)
+}
+
+// Period --
+func (i *IntervalStats) Period() (string, time.Time, time.Time) {
+	return i.Interval, i.Start, i.End
+}
+
+// Period --
+func (p *PropertyStats) Period() (string, time.Time, time.Time) {
+	return p.SpanType, p.Start, p.End
+}
+
+// Period --
+func (s *Summary) Period() (string, time.Time, time.Time) {
+	return s.Interval, s.Start, s.End
+}
+
+// PeriodExpired will return whether or not the period ended more than the number of periods (of its interval) ago
+func PeriodExpired(p Period, periods int, now time.Time) bool {
+	if periods == 0 {
+		return false
+	}
+
+	interval, _, end := p.Period()
+
+	var expiry time.Time
+	switch interval {
+	case Hourly:
+		expiry = end.Add(time.Duration(periods) * time.Hour)
+	case Daily:
+		expiry = end.AddDate(0, 0, periods)
+	case Weekly:
+		expiry = end.AddDate(0, 0, 7*periods)
+	case Monthly:
+		expiry = end.AddDate(0, periods, 0)
+	case Quarterly:
+		expiry = end.AddDate(0, 3*periods, 0)
+	case Yearly:
+		expiry = end.AddDate(periods, 0, 0)
+	default:
+		return false
+	}
+
+	return now.After(expiry)
+}
+
+// InPeriods will return whether or not the period starts within [from, to) (both optional, zero)
+func InPeriods(p Period, from, to time.Time) bool {
+	_, start, _ := p.Period()
+	if !from.IsZero() && start.Before(from) {
+		return false
+	}
+	if !to.IsZero() && !start.Before(to) {
+		return false
+	}
+
+	return true
+}
+
+// PeriodContains will return whether or not the time lies within the period
+func PeriodContains(p Period, t time.Time) bool {
+	_, start, end := p.Period()
+	return !t.Before(start) && !t.After(end)
+}
+
+// PeriodSpan will return the start and end of the period of the interval that holds the time
+func PeriodSpan(interval string, t time.Time) (start, end time.Time) {
+	switch interval {
+	case Hourly:
+		return temporal.HourStart(t), temporal.HourFinish(t)
+	case Daily:
+		return temporal.DayStart(t), temporal.DayFinish(t)
+	case Weekly:
+		return weekStart(t), weekFinish(t)
+	case Monthly:
+		return temporal.MonthStart(t), temporal.MonthFinish(t)
+	case Quarterly:
+		return temporal.QuarterStart(t), temporal.QuarterFinish(t)
+	case Yearly:
+		return temporal.YearStart(t), temporal.YearFinish(t)
+	}
+
+	return time.Time{}, time.Unix(1<<63-1, 0)
+}
+
+// weekStart will return the start (sunday, 00:00) of the week of the time. The week
+// start of temporal keeps the time of the day, so its weeks would not line up.
+func weekStart(t time.Time) time.Time {
+	return temporal.DayStart(temporal.WeekStart(t))
+}
+
+// weekFinish will return the final time of the week of the time
+func weekFinish(t time.Time) time.Time {
+	return weekStart(t).AddDate(0, 0, 7).Add(-time.Nanosecond)
+}
@@ -0,0 +1,190 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeriodSpan(t *testing.T) {
+	// a sunday afternoon
+	at := time.Date(2026, 10, 18, 15, 30, 45, 0, time.UTC)
+	last := time.Second - time.Nanosecond
+
+	tests := []struct {
+		interval  string
+		t         time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{
+			interval:  Hourly,
+			t:         at,
+			wantStart: time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 10, 18, 15, 59, 59, 0, time.UTC).Add(last),
+		},
+		{
+			interval:  Daily,
+			t:         at,
+			wantStart: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 10, 18, 23, 59, 59, 0, time.UTC).Add(last),
+		},
+		{
+			interval:  Weekly,
+			t:         at,
+			wantStart: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 10, 24, 23, 59, 59, 0, time.UTC).Add(last),
+		},
+		{
+			interval:  Weekly,
+			t:         time.Date(2026, 10, 24, 23, 59, 59, 0, time.UTC),
+			wantStart: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 10, 24, 23, 59, 59, 0, time.UTC).Add(last),
+		},
+		{
+			interval:  Weekly,
+			t:         time.Date(2026, 12, 30, 8, 0, 0, 0, time.UTC),
+			wantStart: time.Date(2026, 12, 27, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2027, 1, 2, 23, 59, 59, 0, time.UTC).Add(last),
+		},
+		{
+			interval:  Monthly,
+			t:         at,
+			wantStart: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 10, 31, 23, 59, 59, 0, time.UTC).Add(last),
+		},
+		{
+			interval:  Quarterly,
+			t:         at,
+			wantStart: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 12, 31, 23, 59, 59, 0, time.UTC).Add(last),
+		},
+		{
+			interval:  Yearly,
+			t:         at,
+			wantStart: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 12, 31, 23, 59, 59, 0, time.UTC).Add(last),
+		},
+		{
+			interval:  AllTime,
+			t:         at,
+			wantStart: time.Time{},
+			wantEnd:   time.Unix(1<<63-1, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.interval+" "+tt.t.Format(time.RFC3339), func(t *testing.T) {
+			start, end := PeriodSpan(tt.interval, tt.t)
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("PeriodSpan(%s, %v) = (%v, %v), want (%v, %v)",
+					tt.interval, tt.t, start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestPeriodSpanContiguous(t *testing.T) {
+	at := time.Date(2026, 10, 18, 15, 30, 45, 0, time.UTC)
+
+	for _, interval := range []string{Hourly, Daily, Weekly, Monthly, Quarterly, Yearly} {
+		t.Run(interval, func(t *testing.T) {
+			_, end := PeriodSpan(interval, at)
+			next, _ := PeriodSpan(interval, end.Add(time.Nanosecond))
+			if !next.Equal(end.Add(time.Nanosecond)) {
+				t.Errorf("the next period starts at %v, want %v", next, end.Add(time.Nanosecond))
+			}
+		})
+	}
+}
+
+func TestPeriodExpired(t *testing.T) {
+	end := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond)
+
+	tests := []struct {
+		name     string
+		interval string
+		periods  int
+		now      time.Time
+		want     bool
+	}{
+		{
+			name:     "no retention",
+			interval: Daily,
+			now:      end.AddDate(10, 0, 0),
+		},
+		{
+			name:     "within the retention",
+			interval: Daily,
+			periods:  7,
+			now:      end.AddDate(0, 0, 7),
+		},
+		{
+			name:     "past the retention",
+			interval: Daily,
+			periods:  7,
+			now:      end.AddDate(0, 0, 7).Add(time.Nanosecond),
+			want:     true,
+		},
+		{
+			name:     "hourly",
+			interval: Hourly,
+			periods:  24,
+			now:      end.Add(25 * time.Hour),
+			want:     true,
+		},
+		{
+			name:     "weekly",
+			interval: Weekly,
+			periods:  2,
+			now:      end.AddDate(0, 0, 13),
+		},
+		{
+			name:     "quarterly",
+			interval: Quarterly,
+			periods:  1,
+			now:      end.AddDate(0, 4, 0),
+			want:     true,
+		},
+		{
+			name:     "all time never expires",
+			interval: AllTime,
+			periods:  1,
+			now:      end.AddDate(10, 0, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &IntervalStats{Interval: tt.interval, End: end}
+			if got := PeriodExpired(p, tt.periods, tt.now); got != tt.want {
+				t.Errorf("PeriodExpired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInPeriods(t *testing.T) {
+	start := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	p := &IntervalStats{Interval: Daily, Start: start}
+
+	tests := []struct {
+		name string
+		from time.Time
+		to   time.Time
+		want bool
+	}{
+		{name: "unbounded", want: true},
+		{name: "from the start", from: start, want: true},
+		{name: "from after the start", from: start.Add(time.Hour)},
+		{name: "to after the start", to: start.Add(time.Hour), want: true},
+		{name: "to the start (exclusive)", to: start},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InPeriods(p, tt.from, tt.to); got != tt.want {
+				t.Errorf("InPeriods(%v, %v) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,433 @@
+package types
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/JKhawaja/errors"
+)
+
+// Identity links an identifier (a user or a device) to the
+// canonical user that the identifier belongs to.
+type Identity struct {
+	ID        string    `json:"id"`  // hash of the identifier key (used for storage)
+	Key       string    `json:"key"` // identifier key, see UserKey and DeviceKey
+	Canonical User      `json:"canonical"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Identify is the request format for linking anonymous identifiers
+// (a previous user and/or a device) to a known user.
+type Identify struct {
+	UserType          string                 `json:"userType"`
+	UserID            string                 `json:"userID"`
+	AnonymousUserType string                 `json:"anonymousUserType,omitempty"`
+	AnonymousUserID   string                 `json:"anonymousUserID,omitempty"`
+	DeviceType        string                 `json:"deviceType,omitempty"`
+	DeviceID          string                 `json:"deviceID,omitempty"`
+	Traits            map[string]interface{} `json:"traits,omitempty"`
+}
+
+// Identities is the identity graph. It maps every known identifier
+// onto its canonical user.
+type Identities struct {
+	List    map[string]*Identity // identifier key -> identity
+	members map[string][]string  // canonical user -> identifier keys
+	updated map[string]*Identity
+	flushed map[string]*Identity // written by a flush that is not committed yet
+	deleted map[string]*Identity
+	removed map[string]*Identity // deleted by a flush that is not committed yet
+	*sync.Mutex
+}
+
+// NewIdentities --
+func NewIdentities() *Identities {
+	return &Identities{
+		List:    make(map[string]*Identity),
+		members: make(map[string][]string),
+		updated: make(map[string]*Identity),
+		flushed: make(map[string]*Identity),
+		deleted: make(map[string]*Identity),
+		removed: make(map[string]*Identity),
+		Mutex:   &sync.Mutex{},
+	}
+}
+
+// NewIdentity --
+func NewIdentity(key string, canonical User) *Identity {
+	now := time.Now().UTC()
+	return &Identity{
+		ID:        IdentityID(key),
+		Key:       key,
+		Canonical: canonical,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// IdentityID will return the storage id for an identifier key.
+func IdentityID(key string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(key)))
+}
+
+// UserKey will return the identifier key of a user
+func UserKey(u User) string {
+	return "user:" + u.String()
+}
+
+// DeviceKey will return the identifier key of a device
+func DeviceKey(d Device) string {
+	return "device:" + d.String()
+}
+
+// String will return a unique string which
+// represents the Device object.
+func (d Device) String() string {
+	return d.Type + "," + d.ID
+}
+
+// Validate --
+func (i *Identify) Validate() error {
+	if i.UserID == "" {
+		return errors.New(ErrUser, nil)
+	}
+
+	if i.AnonymousUserID == "" && i.DeviceID == "" && len(i.Traits) == 0 {
+		return errors.New(ErrIdentifier, nil)
+	}
+
+	// all traits must have numerical, text, or boolean values
+	for key, value := range i.Traits {
+		switch value.(type) {
+		case float64, string, bool:
+			continue
+		default:
+			delete(i.Traits, key)
+		}
+	}
+
+	return nil
+}
+
+// User will return the known user of the request
+func (i *Identify) User() User {
+	return User{
+		Type: i.UserType,
+		ID:   i.UserID,
+	}
+}
+
+// Keys will return the identifier keys that should be linked to the known user
+func (i *Identify) Keys() []string {
+	keys := make([]string, 0, 2)
+	if i.AnonymousUserID != "" {
+		keys = append(keys, UserKey(User{Type: i.AnonymousUserType, ID: i.AnonymousUserID}))
+	}
+
+	if i.DeviceID != "" {
+		keys = append(keys, DeviceKey(Device{Type: i.DeviceType, ID: i.DeviceID}))
+	}
+
+	return keys
+}
+
+// Set will load an identity into the graph
+func (g *Identities) Set(identity *Identity) {
+	g.Lock()
+	defer g.Unlock()
+
+	g.set(identity)
+}
+
+func (g *Identities) set(identity *Identity) {
+	if existing, ok := g.List[identity.Key]; ok {
+		g.removeMember(existing.Canonical.String(), identity.Key)
+	}
+
+	g.add(identity)
+}
+
+func (g *Identities) add(identity *Identity) {
+	g.List[identity.Key] = identity
+	canonical := identity.Canonical.String()
+	g.members[canonical] = append(g.members[canonical], identity.Key)
+}
+
+func (g *Identities) removeMember(canonical, key string) {
+	members := g.members[canonical]
+	for idx, member := range members {
+		if member == key {
+			g.members[canonical] = append(members[:idx], members[idx+1:]...)
+			break
+		}
+	}
+
+	if len(g.members[canonical]) == 0 {
+		delete(g.members, canonical)
+	}
+}
+
+// Has will return whether or not the identifier key is part of the graph
+func (g *Identities) Has(key string) bool {
+	g.Lock()
+	defer g.Unlock()
+
+	_, ok := g.List[key]
+	return ok
+}
+
+// Canonical will return the canonical user for a user.
+// The user itself is returned if it is not linked to another user.
+func (g *Identities) Canonical(u User) User {
+	g.Lock()
+	defer g.Unlock()
+
+	return g.canonical(u)
+}
+
+func (g *Identities) canonical(u User) User {
+	identity, ok := g.List[UserKey(u)]
+	if !ok {
+		return u
+	}
+
+	return identity.Canonical
+}
+
+// Users will return the canonical user of the user along with
+// every other user that has been linked to it.
+func (g *Identities) Users(u User) []User {
+	g.Lock()
+	defer g.Unlock()
+
+	canonical := g.canonical(u)
+	users := []User{canonical}
+	for _, key := range g.members[canonical.String()] {
+		if user, ok := userFromKey(key); ok && user != canonical {
+			users = append(users, user)
+		}
+	}
+
+	return users
+}
+
+// Identities will return every identity that is linked to the canonical user of the user
+func (g *Identities) Identities(u User) []*Identity {
+	g.Lock()
+	defer g.Unlock()
+
+	canonical := g.canonical(u)
+	identities := make([]*Identity, 0)
+	for _, key := range g.members[canonical.String()] {
+		if identity, ok := g.List[key]; ok {
+			identities = append(identities, identity)
+		}
+	}
+
+	return identities
+}
+
+// Remove will remove every identity that is linked to the canonical user of the user
+// from the graph. The removed identities are returned.
+func (g *Identities) Remove(u User) []*Identity {
+	g.Lock()
+	defer g.Unlock()
+
+	canonical := g.canonical(u)
+	removed := make([]*Identity, 0)
+	for _, key := range g.members[canonical.String()] {
+		identity, ok := g.List[key]
+		if !ok {
+			continue
+		}
+
+		delete(g.List, key)
+		delete(g.updated, key)
+		g.deleted[key] = identity
+		removed = append(removed, identity)
+	}
+	delete(g.members, canonical.String())
+
+	return removed
+}
+
+// Resolve will rewrite the user of the interaction to its canonical user.
+// The user key is looked up first, and the device key is only used if the
+// interaction user is not a known (canonical) user itself.
+// It returns whether or not the interaction user was rewritten.
+func (g *Identities) Resolve(i *Interaction) bool {
+	g.Lock()
+	defer g.Unlock()
+
+	user := i.User()
+	canonical := user
+
+	if identity, ok := g.List[UserKey(user)]; ok {
+		canonical = identity.Canonical
+	} else if _, known := g.members[user.String()]; !known && i.DeviceID != nil {
+		if identity, ok := g.List[DeviceKey(i.Device())]; ok {
+			canonical = identity.Canonical
+		}
+	}
+
+	if canonical == user {
+		return false
+	}
+
+	userType, userID := canonical.Type, canonical.ID
+	i.UserType = &userType
+	i.UserID = &userID
+
+	return true
+}
+
+// Link will link each identifier key to the canonical user. If an identifier
+// was already linked to another user, then every identifier of that user is
+// moved to the canonical user as well.
+// It returns the list of users that have been merged into the canonical user.
+func (g *Identities) Link(canonical User, keys ...string) []User {
+	g.Lock()
+	defer g.Unlock()
+
+	canonical = g.canonical(canonical)
+	canonicalKey := UserKey(canonical)
+
+	merged := make([]User, 0)
+	for _, key := range keys {
+		if key == canonicalKey {
+			continue
+		}
+
+		var previous *User
+		if identity, ok := g.List[key]; ok {
+			if identity.Canonical == canonical {
+				continue
+			}
+			p := identity.Canonical
+			previous = &p
+		} else if user, ok := userFromKey(key); ok {
+			previous = &user
+		}
+
+		g.link(key, canonical)
+
+		if previous == nil || *previous == canonical {
+			continue
+		}
+
+		// move all identifiers of the previous user to the canonical user
+		for _, member := range append([]string{}, g.members[previous.String()]...) {
+			g.link(member, canonical)
+		}
+
+		if UserKey(*previous) != key {
+			g.link(UserKey(*previous), canonical)
+		}
+
+		merged = append(merged, *previous)
+	}
+
+	return merged
+}
+
+func (g *Identities) link(key string, canonical User) {
+	identity, ok := g.List[key]
+	if ok {
+		g.removeMember(identity.Canonical.String(), key)
+		identity.Canonical = canonical
+		identity.UpdatedAt = time.Now().UTC()
+	} else {
+		identity = NewIdentity(key, canonical)
+	}
+
+	g.add(identity)
+	g.updated[key] = identity
+}
+
+func userFromKey(key string) (User, bool) {
+	const prefix = "user:"
+	if len(key) <= len(prefix) || key[:len(prefix)] != prefix {
+		return User{}, false
+	}
+
+	rest := key[len(prefix):]
+	for idx := 0; idx < len(rest); idx++ {
+		if rest[idx] == ',' {
+			return User{Type: rest[:idx], ID: rest[idx+1:]}, true
+		}
+	}
+
+	return User{}, false
+}
+
+// Update --
+func (g *Identities) Update(updateFunc func(object interface{}) error) error {
+	g.Lock()
+	defer g.Unlock()
+
+	for key, identity := range g.updated {
+		err := updateFunc(identity)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+		g.flushed[key] = identity
+		delete(g.updated, key)
+	}
+
+	return nil
+}
+
+// Deleted will call the delete function on every identity that
+// has been removed from the graph.
+func (g *Identities) Deleted(deleteFunc func(object interface{}) error) error {
+	g.Lock()
+	defer g.Unlock()
+
+	for key, identity := range g.deleted {
+		err := deleteFunc(identity)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+		g.removed[key] = identity
+		delete(g.deleted, key)
+	}
+
+	return nil
+}
+
+// Committed will forget the documents of the flush once it is committed
+func (g *Identities) Committed() {
+	g.Lock()
+	defer g.Unlock()
+
+	g.flushed = make(map[string]*Identity)
+	g.removed = make(map[string]*Identity)
+}
+
+// Rollback will mark the documents of a flush that was not committed
+// as updated again, so that they are written by the next flush
+func (g *Identities) Rollback() {
+	g.Lock()
+	defer g.Unlock()
+
+	for key, identity := range g.flushed {
+		g.updated[key] = identity
+	}
+	g.flushed = make(map[string]*Identity)
+
+	// the deletes, unless the documents were updated since
+	for key, identity := range g.removed {
+		if _, ok := g.updated[key]; !ok {
+			g.deleted[key] = identity
+		}
+	}
+	g.removed = make(map[string]*Identity)
+}
+
+// Len --
+func (g *Identities) Len() int {
+	return len(g.List)
+}
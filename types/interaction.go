@@ -11,7 +11,7 @@ import (
 
 var (
 	/* special keys */
-	// Conversion is a special key (used in automated analysis)
+	// Conversion is the action of the default goal (see Goal)
 	Conversion = "conversion"
 
 	/* timezone */
@@ -42,14 +42,30 @@ type Interaction struct {
 	DeviceType *string `json:"deviceType,omitempty"`
 	DeviceID   *string `json:"deviceID,omitempty"`
 
+	// pseudonyms under the previous salt (only set during a pseudonym grace period)
+	PreviousUserID   *string `json:"-"`
+	PreviousDeviceID *string `json:"-"`
+
+	// consent mode of the interaction (set at ingest)
+	Consent string `json:"-"`
+
+	// sample rate of a kept sampled interaction (set at ingest, see SampleRule)
+	SampleRate float64 `json:"-"`
+
+	// request metadata (set at ingest, never stored)
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
+
 	// why (context)
 	SessionType *string `json:"sessionType,omitempty"`
 	SessionID   *string `json:"sessionID,omitempty"`
 
 	// when
-	Timestamp  *string    `json:"timestamp,omitempty"`
-	CreatedAt  *time.Time `json:"createdAt,omitempty"`
-	ReceivedAt *time.Time `json:"receivedAt,omitempty"`
+	Timestamp       *string    `json:"timestamp,omitempty"`
+	TimestampLayout *string    `json:"timestampLayout,omitempty"` // optional declared layout for the timestamp
+	Timezone        *string    `json:"timezone,omitempty"`        // optional declared timezone for the timestamp
+	CreatedAt       *time.Time `json:"createdAt,omitempty"`
+	ReceivedAt      *time.Time `json:"receivedAt,omitempty"`
 
 	// metadata: entity-properties, origin-properties, user-properties, session-properties, etc.
 	Properties map[string]interface{} `json:"properties,omitempty"`
@@ -84,6 +100,58 @@ func (i *Interaction) Validate() error {
 	return nil
 }
 
+// UnmarshalJSON allows the timestamp to be sent either as a string
+// or as a number (epoch timestamps).
+func (i *Interaction) UnmarshalJSON(data []byte) error {
+	type interaction Interaction
+	aux := struct {
+		*interaction
+		Timestamp json.RawMessage `json:"timestamp,omitempty"`
+	}{
+		interaction: (*interaction)(i),
+	}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	raw := strings.TrimSpace(string(aux.Timestamp))
+	switch {
+	case raw == "" || raw == "null":
+		i.Timestamp = nil
+	case strings.HasPrefix(raw, `"`):
+		var ts string
+		err := json.Unmarshal(aux.Timestamp, &ts)
+		if err != nil {
+			return err
+		}
+		i.Timestamp = &ts
+	default:
+		var n json.Number
+		err := json.Unmarshal(aux.Timestamp, &n)
+		if err != nil {
+			return err
+		}
+		ts := n.String()
+		i.Timestamp = &ts
+	}
+
+	return nil
+}
+
+// TimestampFormat will return the timestamp format declared on the interaction (if any).
+func (i *Interaction) TimestampFormat() *TimestampFormat {
+	if i.TimestampLayout == nil && i.Timezone == nil {
+		return nil
+	}
+
+	return &TimestampFormat{
+		Layout:   pstr(i.TimestampLayout),
+		Timezone: pstr(i.Timezone),
+	}
+}
+
 // Date --
 func (i *Interaction) Date() string {
 	t := *i.CreatedAt
@@ -123,6 +191,11 @@ func (i *Interaction) String() string {
 	s = append(s, pstr(i.DeviceID))
 	s = append(s, t.String())
 
+	// anonymous interactions can not be told apart by their user
+	if i.Anonymous() && i.ReceivedAt != nil {
+		s = append(s, i.ReceivedAt.String())
+	}
+
 	return strings.Join(s, "-")
 }
 
@@ -183,3 +256,70 @@ func (i *Interaction) CSV() []string {
 
 	return s
 }
+
+// InteractionFromCSV will decode a CSV record (as written by the CSV method)
+// back into an interaction.
+func InteractionFromCSV(record []string) (*Interaction, error) {
+	if len(record) != 15 {
+		return nil, errors.New(ErrLength, map[string]interface{}{
+			"length": len(record),
+		})
+	}
+
+	i := &Interaction{
+		Action:      strp(record[0]),
+		EntityType:  strp(record[1]),
+		EntityID:    strp(record[2]),
+		OriginType:  strp(record[3]),
+		OriginID:    strp(record[4]),
+		UserType:    strp(record[5]),
+		UserID:      strp(record[6]),
+		DeviceType:  strp(record[7]),
+		DeviceID:    strp(record[8]),
+		SessionType: strp(record[9]),
+		SessionID:   strp(record[10]),
+		Timestamp:   strp(record[11]),
+	}
+
+	if record[12] != "" {
+		t, err := parseTimeString(record[12])
+		if err != nil {
+			return nil, errors.New(err, nil)
+		}
+		i.CreatedAt = &t
+	}
+
+	if record[13] != "" {
+		t, err := parseTimeString(record[13])
+		if err != nil {
+			return nil, errors.New(err, nil)
+		}
+		i.ReceivedAt = &t
+	}
+
+	if record[14] != "" {
+		err := json.Unmarshal([]byte(record[14]), &i.Properties)
+		if err != nil {
+			return nil, errors.New(err, nil)
+		}
+	}
+
+	return i, nil
+}
+
+// parseTimeString parses the output of time.Time.String()
+func parseTimeString(s string) (time.Time, error) {
+	// strip monotonic clock reading
+	if idx := strings.Index(s, " m="); idx >= 0 {
+		s = s[:idx]
+	}
+
+	return time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", s)
+}
+
+func strp(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -20,6 +20,7 @@ type Origins struct {
 	List    map[uuid.UUID]*Origin
 	index   map[string]uuid.UUID
 	updated map[uuid.UUID]*Origin
+	flushed map[uuid.UUID]*Origin // written by a flush that is not committed yet
 	*sync.Mutex
 }
 
@@ -79,6 +80,7 @@ func NewOrigins() *Origins {
 	return &Origins{
 		List:    make(map[uuid.UUID]*Origin),
 		updated: make(map[uuid.UUID]*Origin),
+		flushed: make(map[uuid.UUID]*Origin),
 		index:   make(map[string]uuid.UUID),
 		Mutex:   &sync.Mutex{},
 	}
@@ -101,16 +103,17 @@ func NewOriginCounts() *OriginCounts {
 // NewOriginProfile --
 func NewOriginProfile(event *Event) (*OriginProfile, error) {
 	i := event.Interaction
+	w := i.Weight()
 	sess := event.Session
 
-	actionStats, err := NewSimpleStats(*i.Action)
+	actionStats, err := NewWeightedSimpleStats(*i.Action, w)
 	if err != nil {
 		return nil, errors.New(err, nil)
 	}
 
 	var entityTypeStats, userTypeStats, deviceTypeStats, sessionTypeStats *SimpleStats
 	if i.EntityType != nil {
-		ets, err := NewSimpleStats(*i.EntityType)
+		ets, err := NewWeightedSimpleStats(*i.EntityType, w)
 		if err != nil {
 			return nil, errors.New(err, nil)
 		}
@@ -118,7 +121,7 @@ func NewOriginProfile(event *Event) (*OriginProfile, error) {
 	}
 
 	if i.UserType != nil {
-		uts, err := NewSimpleStats(*i.UserType)
+		uts, err := NewWeightedSimpleStats(*i.UserType, w)
 		if err != nil {
 			return nil, errors.New(err, nil)
 		}
@@ -126,7 +129,7 @@ func NewOriginProfile(event *Event) (*OriginProfile, error) {
 	}
 
 	if i.DeviceType != nil {
-		dts, err := NewSimpleStats(*i.DeviceType)
+		dts, err := NewWeightedSimpleStats(*i.DeviceType, w)
 		if err != nil {
 			return nil, errors.New(err, nil)
 		}
@@ -134,7 +137,7 @@ func NewOriginProfile(event *Event) (*OriginProfile, error) {
 	}
 
 	if i.SessionType != nil {
-		sts, err := NewSimpleStats(*i.SessionType)
+		sts, err := NewWeightedSimpleStats(*i.SessionType, w)
 		if err != nil {
 			return nil, errors.New(err, nil)
 		}
@@ -144,7 +147,7 @@ func NewOriginProfile(event *Event) (*OriginProfile, error) {
 	propertyStats := NewNamedSimpleStatsList()
 	if i.Properties != nil {
 		for key, value := range i.Properties {
-			err := propertyStats.Update(key, value)
+			err := propertyStats.UpdateWeighted(key, value, w)
 			if err != nil {
 				return nil, errors.New(err, nil)
 			}
@@ -164,7 +167,7 @@ func NewOriginProfile(event *Event) (*OriginProfile, error) {
 	}
 
 	return &OriginProfile{
-		Total:            1,
+		Total:            w,
 		ActionStats:      actionStats,
 		EntityTypeStats:  entityTypeStats,
 		UserTypeStats:    userTypeStats,
@@ -232,12 +235,33 @@ func (o *Origins) Update(updateFunc func(object interface{}) error) error {
 		if err != nil {
 			return errors.New(err, nil)
 		}
+		o.flushed[id] = origin
 		delete(o.updated, id)
 	}
 
 	return nil
 }
 
+// Committed will forget the documents of the flush once it is committed
+func (o *Origins) Committed() {
+	o.Lock()
+	defer o.Unlock()
+
+	o.flushed = make(map[uuid.UUID]*Origin)
+}
+
+// Rollback will mark the documents of a flush that was not committed
+// as updated again, so that they are written by the next flush
+func (o *Origins) Rollback() {
+	o.Lock()
+	defer o.Unlock()
+
+	for id, origin := range o.flushed {
+		o.updated[id] = origin
+	}
+	o.flushed = make(map[uuid.UUID]*Origin)
+}
+
 // Remove --
 func (o *Origins) Remove(key interface{}) error {
 	o.Lock()
@@ -304,11 +328,11 @@ func (o *Origin) Eq(input interface{}) bool {
 		return false
 	}
 
-	if *o.OriginType != *o2.OriginType {
+	if pstr(o.OriginType) != pstr(o2.OriginType) {
 		return false
 	}
 
-	if *o.OriginID != *o2.OriginID {
+	if pstr(o.OriginID) != pstr(o2.OriginID) {
 		return false
 	}
 
@@ -393,24 +417,25 @@ func (o *OriginsList) qualifies(id uuid.UUID) bool {
 
 // Update --
 func (o *OriginProfile) Update(event *Event) error {
-	o.Total++
-
 	i := event.Interaction
 	s := event.Session
+	w := i.Weight()
+
+	o.Total += w
 
-	err := o.ActionStats.Update(*i.Action)
+	err := o.ActionStats.UpdateWeighted(*i.Action, w)
 	if err != nil {
 		return errors.New(err, nil)
 	}
 
 	if i.EntityType != nil {
 		if o.EntityTypeStats != nil {
-			err := o.EntityTypeStats.Update(*i.EntityType)
+			err := o.EntityTypeStats.UpdateWeighted(*i.EntityType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
 		} else {
-			ets, err := NewSimpleStats(*i.EntityType)
+			ets, err := NewWeightedSimpleStats(*i.EntityType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
@@ -420,12 +445,12 @@ func (o *OriginProfile) Update(event *Event) error {
 
 	if i.UserType != nil {
 		if o.UserTypeStats != nil {
-			err := o.UserTypeStats.Update(*i.UserType)
+			err := o.UserTypeStats.UpdateWeighted(*i.UserType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
 		} else {
-			ets, err := NewSimpleStats(*i.UserType)
+			ets, err := NewWeightedSimpleStats(*i.UserType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
@@ -435,12 +460,12 @@ func (o *OriginProfile) Update(event *Event) error {
 
 	if i.DeviceType != nil {
 		if o.DeviceTypeStats != nil {
-			err := o.DeviceTypeStats.Update(*i.DeviceType)
+			err := o.DeviceTypeStats.UpdateWeighted(*i.DeviceType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
 		} else {
-			ets, err := NewSimpleStats(*i.DeviceType)
+			ets, err := NewWeightedSimpleStats(*i.DeviceType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
@@ -450,12 +475,12 @@ func (o *OriginProfile) Update(event *Event) error {
 
 	if i.SessionType != nil {
 		if o.SessionTypeStats != nil {
-			err := o.SessionTypeStats.Update(*i.SessionType)
+			err := o.SessionTypeStats.UpdateWeighted(*i.SessionType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
 		} else {
-			ets, err := NewSimpleStats(*i.SessionType)
+			ets, err := NewWeightedSimpleStats(*i.SessionType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
@@ -465,7 +490,7 @@ func (o *OriginProfile) Update(event *Event) error {
 
 	if i.Properties != nil {
 		for name, value := range i.Properties {
-			err := o.PropertyStats.Update(name, value)
+			err := o.PropertyStats.UpdateWeighted(name, value, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
@@ -0,0 +1,164 @@
+package types
+
+import (
+	"strings"
+	"time"
+
+	"github.com/JKhawaja/errors"
+)
+
+const (
+	/* pipeline stage types */
+
+	// StageUserAgent is a stage type (user agent enrichment, see UserAgentSettings)
+	StageUserAgent = "userAgent"
+	// StageGeo is a stage type (geo enrichment, see GeoSettings)
+	StageGeo = "geo"
+	// StageCampaign is a stage type (campaign and referrer enrichment, see CampaignSettings)
+	StageCampaign = "campaign"
+	// StageRegex is a stage type (regular expression rewrite of a field)
+	StageRegex = "regex"
+	// StageNormalize is a stage type (lowercase and/or trim fields)
+	StageNormalize = "normalize"
+	// StageRename is a stage type (rename a property)
+	StageRename = "rename"
+	// StageDrop is a stage type (drop properties)
+	StageDrop = "drop"
+	// StageDerive is a stage type (set a field to the result of an expression)
+	StageDerive = "derive"
+	// StageDefault is a stage type (set a field if it is missing)
+	StageDefault = "default"
+)
+
+// StageConfig configures a stage of the ingest pipeline. Fields are addressed by
+// their JSON name (e.g. `action`, `originID`) or as `properties.<key>`.
+// Only the options of the stage type are used.
+type StageConfig struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+
+	Field      string   `json:"field,omitempty"`      // regex, derive, default
+	Fields     []string `json:"fields,omitempty"`     // normalize
+	Pattern    string   `json:"pattern,omitempty"`    // regex
+	Replace    string   `json:"replace,omitempty"`    // regex (supports $1 expansions)
+	Lowercase  bool     `json:"lowercase,omitempty"`  // normalize
+	Trim       bool     `json:"trim,omitempty"`       // normalize
+	From       string   `json:"from,omitempty"`       // rename (property key)
+	To         string   `json:"to,omitempty"`         // rename (property key)
+	Properties []string `json:"properties,omitempty"` // drop (property keys)
+	Expression string   `json:"expression,omitempty"` // derive
+	Value      string   `json:"value,omitempty"`      // default
+}
+
+// StageStats holds the counters of a pipeline stage
+type StageStats struct {
+	ID         string        `json:"id"`
+	Type       string        `json:"type"`
+	Calls      int64         `json:"calls"`
+	Errors     int64         `json:"errors"`
+	Latency    time.Duration `json:"latency"`    // total
+	MaxLatency time.Duration `json:"maxLatency"` // slowest call
+	LastError  string        `json:"lastError,omitempty"`
+}
+
+// NewPipeline will return the default pipeline (user agent, geo and campaign enrichment)
+func NewPipeline() []*StageConfig {
+	return []*StageConfig{
+		{ID: StageUserAgent, Type: StageUserAgent},
+		{ID: StageGeo, Type: StageGeo},
+		{ID: StageCampaign, Type: StageCampaign},
+	}
+}
+
+// ValidatePipeline will check that every stage has a unique id and a type
+// (the options of each stage are checked when the stage is built).
+func ValidatePipeline(stages []*StageConfig) error {
+	ids := make(map[string]struct{})
+	for _, stage := range stages {
+		if stage == nil || stage.ID == "" || stage.Type == "" {
+			return errors.New(ErrStage, map[string]interface{}{
+				"reason": "missing id or type",
+			})
+		}
+
+		if _, ok := ids[stage.ID]; ok {
+			return errors.New(ErrStage, map[string]interface{}{
+				"id":     stage.ID,
+				"reason": "duplicate id",
+			})
+		}
+		ids[stage.ID] = struct{}{}
+	}
+
+	return nil
+}
+
+// fields holds the string fields of the interaction (by JSON name)
+func (i *Interaction) fields() map[string]**string {
+	return map[string]**string{
+		"action":      &i.Action,
+		"entityType":  &i.EntityType,
+		"entityID":    &i.EntityID,
+		"originType":  &i.OriginType,
+		"originID":    &i.OriginID,
+		"userType":    &i.UserType,
+		"userID":      &i.UserID,
+		"deviceType":  &i.DeviceType,
+		"deviceID":    &i.DeviceID,
+		"sessionType": &i.SessionType,
+	}
+}
+
+// Field will return the value of a field (a string, or any property value).
+func (i *Interaction) Field(name string) (interface{}, bool) {
+	if key := strings.TrimPrefix(name, "properties."); key != name {
+		value, ok := i.Properties[key]
+		return value, ok
+	}
+
+	field, ok := i.fields()[name]
+	if !ok || *field == nil {
+		return nil, false
+	}
+
+	return **field, true
+}
+
+// SetField will set the value of a field (fields other than properties only accept strings)
+func (i *Interaction) SetField(name string, value interface{}) error {
+	if key := strings.TrimPrefix(name, "properties."); key != name {
+		if i.Properties == nil {
+			i.Properties = make(map[string]interface{})
+		}
+		i.Properties[key] = value
+		return nil
+	}
+
+	field, ok := i.fields()[name]
+	if !ok {
+		return errors.New(ErrField, map[string]interface{}{
+			"field": name,
+		})
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return errors.New(ErrField, map[string]interface{}{
+			"field": name,
+			"value": value,
+		})
+	}
+	*field = &s
+
+	return nil
+}
+
+// ValidField will return whether or not the field can be addressed
+func ValidField(name string) bool {
+	if strings.HasPrefix(name, "properties.") {
+		return len(name) > len("properties.")
+	}
+
+	_, ok := (&Interaction{}).fields()[name]
+	return ok
+}
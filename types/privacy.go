@@ -0,0 +1,201 @@
+package types
+
+import (
+	"sync"
+	"time"
+
+	"github.com/JKhawaja/errors"
+)
+
+// DataSubject is the request format for exporting or erasing the data of a user
+type DataSubject struct {
+	UserType string `json:"userType"`
+	UserID   string `json:"userID"`
+}
+
+// DataExport holds all of the stored data about a user (data subject)
+// and every identifier that has been linked to the user.
+type DataExport struct {
+	Subject      string                    `json:"subject"`
+	Users        []User                    `json:"users"`
+	Identities   []*Identity               `json:"identities"`
+	Profiles     []*UserProfile            `json:"profiles"`
+	Sessions     []*UserSession            `json:"sessions"`
+	Interactions []*Interaction            `json:"interactions"`
+	Sandboxes    map[string][]*Interaction `json:"sandboxes"` // sandbox -> sandboxed interactions
+	Archived     []*Interaction            `json:"archived"`  // interactions of the archived partitions
+	ExportedAt   time.Time                 `json:"exportedAt"`
+}
+
+// DeletionReport is the audit record of an erasure. It does not contain
+// the erased identifiers, only the profile id (hash) of the subject.
+type DeletionReport struct {
+	ID           *UUID            `json:"id"`
+	Subject      string           `json:"subject"`
+	RequestedBy  string           `json:"requestedBy"`
+	Identifiers  int              `json:"identifiers"`  // identifiers that have been tombstoned
+	Links        int              `json:"links"`        // identity graph links removed
+	Profiles     int              `json:"profiles"`     // user profiles removed
+	Sessions     int              `json:"sessions"`     // active sessions removed
+	Summaries    int              `json:"summaries"`    // current summaries the user was removed from
+	Interactions int64            `json:"interactions"` // stored interactions removed
+	Sandboxed    int64            `json:"sandboxed"`    // sandboxed interactions removed
+	Archived     int64            `json:"archived"`     // archived interactions removed
+	Partitions   map[string]int64 `json:"partitions"`   // partition (or sandbox/<name>/<partition>, archive/<path>) -> interactions removed
+	StartedAt    time.Time        `json:"startedAt"`
+	CompletedAt  time.Time        `json:"completedAt"`
+}
+
+// Tombstone marks an erased identifier so that interactions (e.g. replays)
+// of the identifier are never stored again. Only the hash of the identifier is kept.
+type Tombstone struct {
+	ID        string    `json:"id"` // IdentityID of the identifier key
+	ReportID  string    `json:"reportID"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Tombstones holds the tombstones of all erased identifiers
+type Tombstones struct {
+	List    map[string]*Tombstone
+	updated map[string]*Tombstone
+	flushed map[string]*Tombstone // written by a flush that is not committed yet
+	*sync.Mutex
+}
+
+// Validate --
+func (d *DataSubject) Validate() error {
+	if d.UserID == "" {
+		return errors.New(ErrUser, nil)
+	}
+
+	return nil
+}
+
+// User --
+func (d *DataSubject) User() User {
+	return User{
+		Type: d.UserType,
+		ID:   d.UserID,
+	}
+}
+
+// NewDeletionReport --
+func NewDeletionReport(subject User, requestedBy string) *DeletionReport {
+	return &DeletionReport{
+		ID:          NewUUID(),
+		Subject:     UserProfileID(subject),
+		RequestedBy: requestedBy,
+		Partitions:  make(map[string]int64),
+		StartedAt:   time.Now().UTC(),
+	}
+}
+
+// NewTombstones --
+func NewTombstones() *Tombstones {
+	return &Tombstones{
+		List:    make(map[string]*Tombstone),
+		updated: make(map[string]*Tombstone),
+		flushed: make(map[string]*Tombstone),
+		Mutex:   &sync.Mutex{},
+	}
+}
+
+// Add will create a tombstone for each identifier key
+func (t *Tombstones) Add(reportID string, keys ...string) {
+	t.Lock()
+	defer t.Unlock()
+
+	now := time.Now().UTC()
+	for _, key := range keys {
+		id := IdentityID(key)
+		if _, ok := t.List[id]; ok {
+			continue
+		}
+
+		tombstone := &Tombstone{
+			ID:        id,
+			ReportID:  reportID,
+			CreatedAt: now,
+		}
+		t.List[id] = tombstone
+		t.updated[id] = tombstone
+	}
+}
+
+// Has will return whether or not the identifier key has been erased
+func (t *Tombstones) Has(key string) bool {
+	t.Lock()
+	defer t.Unlock()
+
+	_, ok := t.List[IdentityID(key)]
+	return ok
+}
+
+// Erased will return whether or not the user of the interaction has been erased
+func (t *Tombstones) Erased(i *Interaction) bool {
+	return t.Has(UserKey(i.User()))
+}
+
+// ErasedUser will return whether or not the user (with the identifier sent by the customer) has
+// been erased under any retained salt of the pseudonyms. The tombstones of an erasure hold the
+// pseudonyms under the salts of the time of the erasure, which differ from the pseudonyms
+// under the salts that are created later.
+func (t *Tombstones) ErasedUser(user User, pseudonyms *PseudonymSettings) bool {
+	for _, id := range pseudonyms.Digests(user.ID) {
+		if t.Has(UserKey(User{Type: user.Type, ID: id})) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Set will load a tombstone
+func (t *Tombstones) Set(tombstone *Tombstone) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.List[tombstone.ID] = tombstone
+}
+
+// Update --
+func (t *Tombstones) Update(updateFunc func(object interface{}) error) error {
+	t.Lock()
+	defer t.Unlock()
+
+	for id, tombstone := range t.updated {
+		err := updateFunc(tombstone)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+		t.flushed[id] = tombstone
+		delete(t.updated, id)
+	}
+
+	return nil
+}
+
+// Committed will forget the documents of the flush once it is committed
+func (t *Tombstones) Committed() {
+	t.Lock()
+	defer t.Unlock()
+
+	t.flushed = make(map[string]*Tombstone)
+}
+
+// Rollback will mark the documents of a flush that was not committed
+// as updated again, so that they are written by the next flush
+func (t *Tombstones) Rollback() {
+	t.Lock()
+	defer t.Unlock()
+
+	for id, tombstone := range t.flushed {
+		t.updated[id] = tombstone
+	}
+	t.flushed = make(map[string]*Tombstone)
+}
+
+// Len --
+func (t *Tombstones) Len() int {
+	return len(t.List)
+}
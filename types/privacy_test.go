@@ -0,0 +1,70 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTombstonesErasedUser(t *testing.T) {
+	erasedAt := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	erased := User{Type: "customer", ID: "c-1"}
+
+	tests := []struct {
+		name      string
+		user      User
+		rotations int // rotations of the salt after the erasure
+		want      bool
+	}{
+		{
+			name: "erased user",
+			user: erased,
+			want: true,
+		},
+		{
+			name:      "erased user after a rotation",
+			user:      erased,
+			rotations: 1,
+			want:      true,
+		},
+		{
+			name:      "erased user after two rotations",
+			user:      erased,
+			rotations: 2,
+			want:      true,
+		},
+		{
+			name:      "another user",
+			user:      User{Type: "customer", ID: "c-2"},
+			rotations: 1,
+		},
+		{
+			name:      "another user type",
+			user:      User{Type: "visitor", ID: "c-1"},
+			rotations: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pseudonyms := &PseudonymSettings{Enabled: true, RotationDays: 30}
+			if _, err := pseudonyms.Rotate(erasedAt.AddDate(0, 0, -1)); err != nil {
+				t.Fatal(err)
+			}
+
+			// the erasure tombstones the pseudonym under the current salt
+			tombstones := NewTombstones()
+			tombstones.Add("r-1", UserKey(User{Type: erased.Type, ID: pseudonyms.Digest(erased.ID)}))
+
+			for n := 1; n <= tt.rotations; n++ {
+				rotated, err := pseudonyms.Rotate(erasedAt.AddDate(0, 0, 30*n))
+				if err != nil || !rotated {
+					t.Fatalf("Rotate() = %v, %v", rotated, err)
+				}
+			}
+
+			if got := tombstones.ErasedUser(tt.user, pseudonyms); got != tt.want {
+				t.Errorf("ErasedUser(%v) = %v, want %v", tt.user, got, tt.want)
+			}
+		})
+	}
+}
@@ -70,12 +70,16 @@ type PropertyStats struct {
 	Start    time.Time    `json:"start"`
 	End      time.Time    `json:"end"`
 	Stats    *SimpleStats `json:"stats"`
+	Closed   bool         `json:"closed,omitempty"` // the period ended and the stats were archived
 }
 
 // PropertyStatsList --
 type PropertyStatsList struct {
-	index   map[uint32]*PropertyStats
-	updated map[uint32]*PropertyStats
+	index    map[uint32]*PropertyStats
+	updated  map[uint32]*PropertyStats
+	flushed  map[uint32]*PropertyStats // written by a flush that is not committed yet
+	closed   []*PropertyStats          // replaced by the stats of their next period, not archived yet
+	previous map[uint32]*PropertyStats // the last closed stats of every property (for late interactions)
 	*sync.Mutex
 }
 
@@ -83,6 +87,7 @@ type PropertyStatsList struct {
 type Properties struct {
 	List    map[string]*Property
 	updated map[string]*Property
+	flushed map[string]*Property // written by a flush that is not committed yet
 	*sync.Mutex
 }
 
@@ -106,6 +111,7 @@ func NewProperties() *Properties {
 	return &Properties{
 		List:    make(map[string]*Property),
 		updated: make(map[string]*Property),
+		flushed: make(map[string]*Property),
 		Mutex:   &sync.Mutex{},
 	}
 }
@@ -113,9 +119,12 @@ func NewProperties() *Properties {
 // NewPropertyStatsList --
 func NewPropertyStatsList() *PropertyStatsList {
 	return &PropertyStatsList{
-		index:   make(map[uint32]*PropertyStats),
-		updated: make(map[uint32]*PropertyStats),
-		Mutex:   &sync.Mutex{},
+		index:    make(map[uint32]*PropertyStats),
+		updated:  make(map[uint32]*PropertyStats),
+		flushed:  make(map[uint32]*PropertyStats),
+		closed:   make([]*PropertyStats, 0),
+		previous: make(map[uint32]*PropertyStats),
+		Mutex:    &sync.Mutex{},
 	}
 }
 
@@ -135,8 +144,8 @@ func NewPropertyStats(name string, spanType string, value interface{}, timestamp
 		start = temporal.DayStart(*timestamp)
 		end = temporal.DayFinish(*timestamp)
 	case Weekly:
-		start = temporal.WeekStart(*timestamp)
-		end = temporal.WeekFinish(*timestamp)
+		start = weekStart(*timestamp)
+		end = weekFinish(*timestamp)
 	case Monthly:
 		start = temporal.MonthStart(*timestamp)
 		end = temporal.MonthFinish(*timestamp)
@@ -146,6 +155,9 @@ func NewPropertyStats(name string, spanType string, value interface{}, timestamp
 	case Yearly:
 		start = temporal.YearStart(*timestamp)
 		end = temporal.YearFinish(*timestamp)
+	case AllTime:
+		start = time.Time{}
+		end = time.Unix(1<<63-1, 0)
 	}
 
 	return &PropertyStats{
@@ -210,12 +222,33 @@ func (p *Properties) Update(updateFunc func(object interface{}) error) error {
 		if err != nil {
 			return errors.New(err, nil)
 		}
+		p.flushed[key] = property
 		delete(p.updated, key)
 	}
 
 	return nil
 }
 
+// Committed will forget the documents of the flush once it is committed
+func (p *Properties) Committed() {
+	p.Lock()
+	defer p.Unlock()
+
+	p.flushed = make(map[string]*Property)
+}
+
+// Rollback will mark the documents of a flush that was not committed
+// as updated again, so that they are written by the next flush
+func (p *Properties) Rollback() {
+	p.Lock()
+	defer p.Unlock()
+
+	for key, property := range p.flushed {
+		p.updated[key] = property
+	}
+	p.flushed = make(map[string]*Property)
+}
+
 // Remove --
 func (p *Properties) Remove(key interface{}) error {
 	p.Lock()
@@ -311,8 +344,7 @@ func ValidPropertyType(t string) bool {
 
 // Apply --
 func (p *PropertyStats) Apply(value interface{}, timestamp *time.Time) error {
-	t := *timestamp
-	if t.After(p.End) {
+	if p.Ended(*timestamp) {
 		newStats, err := NewPropertyStats(p.Name, p.SpanType, value, timestamp)
 		if err != nil {
 			return errors.New(err, nil)
@@ -324,6 +356,11 @@ func (p *PropertyStats) Apply(value interface{}, timestamp *time.Time) error {
 	return p.Stats.Update(value)
 }
 
+// Ended will return whether or not the period of the stats ended before the time
+func (p *PropertyStats) Ended(t time.Time) bool {
+	return p.SpanType != AllTime && t.After(p.End)
+}
+
 // Apply --
 func (p *PropertyStatsList) Apply(event *Event) error {
 	p.Lock()
@@ -358,6 +395,49 @@ func (p *PropertyStatsList) Apply(event *Event) error {
 					continue
 				}
 
+				if stats.Ended(*i.CreatedAt) {
+					newStats, err := NewPropertyStats(name, spantype, value, i.CreatedAt)
+					if err != nil {
+						return errors.New(err, map[string]interface{}{
+							"spantype": spantype,
+							"name":     name,
+						})
+					}
+					if !stats.Closed {
+						stats.Closed = true
+						p.archive(stats)
+					}
+					p.previous[hashedKey] = stats
+					p.index[hashedKey] = newStats
+					p.updated[hashedKey] = newStats
+					continue
+				}
+
+				// a value that arrives after its period was closed is counted in the closed
+				// stats of its period, which are archived again (never in the stats of the next
+				// period). The values of the periods before the last closed period are dropped.
+				if stats.Closed || i.CreatedAt.Before(stats.Start) {
+					closed := p.closedPeriod(hashedKey, stats, *i.CreatedAt)
+					if closed == nil {
+						continue
+					}
+
+					err = closed.Stats.Update(value)
+					if err != nil {
+						return errors.New(err, map[string]interface{}{
+							"spantype": spantype,
+							"name":     name,
+						})
+					}
+					p.archive(closed)
+
+					// the stats of the previous period are no longer the document
+					if closed == stats {
+						p.updated[hashedKey] = stats
+					}
+					continue
+				}
+
 				err = stats.Apply(value, i.CreatedAt)
 				if err != nil {
 					return errors.New(err, map[string]interface{}{
@@ -425,8 +505,98 @@ func (p *PropertyStatsList) Update(updateFunc func(object interface{}) error) er
 		if err != nil {
 			return errors.New(err, nil)
 		}
+		p.flushed[id] = mab
 		delete(p.updated, id)
 	}
 
 	return nil
 }
+
+// Committed will forget the documents of the flush once it is committed
+func (p *PropertyStatsList) Committed() {
+	p.Lock()
+	defer p.Unlock()
+
+	p.flushed = make(map[uint32]*PropertyStats)
+}
+
+// Rollback will mark the documents of a flush that was not committed
+// as updated again, so that they are written by the next flush
+func (p *PropertyStatsList) Rollback() {
+	p.Lock()
+	defer p.Unlock()
+
+	for id, mab := range p.flushed {
+		p.updated[id] = mab
+	}
+	p.flushed = make(map[uint32]*PropertyStats)
+}
+
+// Closed will call the function for every stats that were replaced by the stats of their next period
+// since they were last archived. It stops at the first stats that can not be archived.
+func (p *PropertyStatsList) Closed(archiveFunc func(object interface{}) error) error {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, stats := range p.closed {
+		err := archiveFunc(stats)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+	}
+
+	return nil
+}
+
+// Rollover will close the stats that ended before the time without waiting for the next
+// value of their property. They are kept (as the last period) until then.
+// It returns the number of closed stats.
+func (p *PropertyStatsList) Rollover(now time.Time) int {
+	p.Lock()
+	defer p.Unlock()
+
+	var n int
+	for key, stats := range p.index {
+		if stats.Closed || !stats.Ended(now) {
+			continue
+		}
+
+		stats.Closed = true
+		p.archive(stats)
+		p.updated[key] = stats
+		n++
+	}
+
+	return n
+}
+
+// closedPeriod will return the closed stats of the property (the current stats, or the
+// stats of the previous period) whose period holds the time, or nil if there are none
+func (p *PropertyStatsList) closedPeriod(key uint32, stats *PropertyStats, t time.Time) *PropertyStats {
+	if stats.Closed && PeriodContains(stats, t) {
+		return stats
+	}
+	if previous, ok := p.previous[key]; ok && PeriodContains(previous, t) {
+		return previous
+	}
+
+	return nil
+}
+
+// archive will add the closed stats to the stats that are archived by the next flush (once)
+func (p *PropertyStatsList) archive(stats *PropertyStats) {
+	for _, closed := range p.closed {
+		if closed == stats {
+			return
+		}
+	}
+	p.closed = append(p.closed, stats)
+}
+
+// Archived will forget the closed stats once they are archived
+func (p *PropertyStatsList) Archived() {
+	p.Lock()
+	defer p.Unlock()
+
+	p.closed = make([]*PropertyStats, 0)
+}
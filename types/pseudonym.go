@@ -0,0 +1,177 @@
+package types
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/JKhawaja/errors"
+)
+
+// PseudonymSettings configures the replacement of user identifiers, device
+// identifiers and chosen properties with keyed (HMAC-SHA256) digests at ingest.
+// The key (salt) rotates every RotationDays. For GraceDays after a rotation
+// the digests of the previous salt are linked to the digests of the new salt,
+// so that ongoing users are not split into new users by the rotation.
+// The replaced salts are retained (but no longer used for new digests): the profiles and
+// identity links are kept until they are erased, and the data subject requests need the
+// digests of every salt that the data of a user may be stored under.
+type PseudonymSettings struct {
+	Enabled      bool             `json:"enabled"`
+	PropertyKeys []string         `json:"propertyKeys,omitempty"` // e.g. email, ip
+	RotationDays int              `json:"rotationDays"`           // 0 disables rotation
+	GraceDays    int              `json:"graceDays"`              // 0 disables the grace mapping
+	Salts        []*PseudonymSalt `json:"-"`                      // every salt, newest last, never exposed
+}
+
+// PseudonymSalt is a (secret) HMAC key
+type PseudonymSalt struct {
+	Value     []byte
+	CreatedAt time.Time
+}
+
+// NewPseudonymSettings --
+func NewPseudonymSettings() *PseudonymSettings {
+	return &PseudonymSettings{
+		PropertyKeys: make([]string, 0),
+		Salts:        make([]*PseudonymSalt, 0),
+	}
+}
+
+// NewPseudonymSalt will generate a new random salt
+func NewPseudonymSalt(now time.Time) (*PseudonymSalt, error) {
+	value := make([]byte, 32)
+	_, err := rand.Read(value)
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
+	return &PseudonymSalt{
+		Value:     value,
+		CreatedAt: now,
+	}, nil
+}
+
+// Rotate will add a new salt if there is no salt yet or if the current salt
+// is older than the rotation period. The replaced salts are retained for the
+// data subject requests. It returns whether or not the salts have changed.
+func (p *PseudonymSettings) Rotate(now time.Time) (bool, error) {
+	current := p.current()
+	if current != nil && (p.RotationDays <= 0 || now.Before(current.CreatedAt.AddDate(0, 0, p.RotationDays))) {
+		return false, nil
+	}
+
+	salt, err := NewPseudonymSalt(now)
+	if err != nil {
+		return false, errors.New(err, nil)
+	}
+
+	p.Salts = append(p.Salts, salt)
+
+	return true, nil
+}
+
+func (p *PseudonymSettings) current() *PseudonymSalt {
+	if len(p.Salts) == 0 {
+		return nil
+	}
+
+	return p.Salts[len(p.Salts)-1]
+}
+
+// previous will return the previous salt if the grace period is active
+func (p *PseudonymSettings) previous(now time.Time) *PseudonymSalt {
+	if p.GraceDays <= 0 || len(p.Salts) < 2 {
+		return nil
+	}
+
+	current := p.current()
+	if now.After(current.CreatedAt.AddDate(0, 0, p.GraceDays)) {
+		return nil
+	}
+
+	return p.Salts[len(p.Salts)-2]
+}
+
+// Digest will return the pseudonym of the value under the current salt
+func (p *PseudonymSettings) Digest(value string) string {
+	return digest(p.current(), value)
+}
+
+// Digests will return the pseudonyms of the value under every retained salt (newest first)
+func (p *PseudonymSettings) Digests(value string) []string {
+	digests := make([]string, 0, len(p.Salts))
+	for idx := len(p.Salts) - 1; idx >= 0; idx-- {
+		digests = append(digests, digest(p.Salts[idx], value))
+	}
+
+	return digests
+}
+
+func digest(salt *PseudonymSalt, value string) string {
+	if salt == nil {
+		return value
+	}
+
+	mac := hmac.New(sha256.New, salt.Value)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// Interaction will replace the user id, device id and chosen properties of the
+// interaction with their pseudonyms. During the grace period the pseudonyms
+// under the previous salt are set on the interaction as well.
+func (p *PseudonymSettings) Interaction(i *Interaction, now time.Time) {
+	previous := p.previous(now)
+
+	if i.UserID != nil {
+		if previous != nil {
+			prev := digest(previous, *i.UserID)
+			i.PreviousUserID = &prev
+		}
+		userID := p.Digest(*i.UserID)
+		i.UserID = &userID
+	}
+
+	if i.DeviceID != nil {
+		if previous != nil {
+			prev := digest(previous, *i.DeviceID)
+			i.PreviousDeviceID = &prev
+		}
+		deviceID := p.Digest(*i.DeviceID)
+		i.DeviceID = &deviceID
+	}
+
+	p.properties(i.Properties)
+}
+
+// Identify will replace the identifiers and chosen traits of the identify request with their pseudonyms
+func (p *PseudonymSettings) Identify(i *Identify) {
+	i.UserID = p.Digest(i.UserID)
+	if i.AnonymousUserID != "" {
+		i.AnonymousUserID = p.Digest(i.AnonymousUserID)
+	}
+	if i.DeviceID != "" {
+		i.DeviceID = p.Digest(i.DeviceID)
+	}
+
+	p.properties(i.Traits)
+}
+
+func (p *PseudonymSettings) properties(properties map[string]interface{}) {
+	if properties == nil {
+		return
+	}
+
+	for _, key := range p.PropertyKeys {
+		value, ok := properties[key]
+		if !ok {
+			continue
+		}
+
+		properties[key] = p.Digest(fmt.Sprint(value))
+	}
+}
@@ -0,0 +1,643 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JKhawaja/errors"
+)
+
+const (
+	/* query filter operators */
+
+	// QueryEq matches a field equal to the value (or an array holding it)
+	QueryEq = "eq"
+	// QueryIn matches a field equal to any of the values
+	QueryIn = "in"
+	// QueryRange matches a numeric field within [min, max)
+	QueryRange = "range"
+	// QueryRegex matches a field with the pattern of the value
+	QueryRegex = "regex"
+	// QueryExists matches a field that is set
+	QueryExists = "exists"
+
+	/* query metrics */
+
+	// QueryCount is the number of interactions
+	QueryCount = "count"
+	// QueryUniqueUsers is the number of distinct users
+	QueryUniqueUsers = "uniqueUsers"
+	// QuerySum is the sum of a numeric field
+	QuerySum = "sum"
+	// QueryAvg is the average of a numeric field
+	QueryAvg = "avg"
+	// QueryMin is the minimum of a numeric field
+	QueryMin = "min"
+	// QueryMax is the maximum of a numeric field
+	QueryMax = "max"
+	// QueryPercentile is a percentile of a numeric field
+	QueryPercentile = "percentile"
+
+	// QueryNotSet is the group of the interactions without a value for a dimension
+	QueryNotSet = "(not set)"
+
+	// query limits
+	maxQueryGroupBy     = 3
+	defaultQueryLimit   = 1000
+	maxQueryLimit       = 10000
+	defaultQueryMaxRows = 1000000
+	maxQueryMaxRows     = 10000000
+	defaultQueryTimeout = 10
+	maxQueryTimeout     = 60
+)
+
+// queryTimeBuckets are the time dimensions of a query (`time:<bucket>`)
+var queryTimeBuckets = map[string]bool{
+	"hour":  true,
+	"day":   true,
+	"week":  true,
+	"month": true,
+	"year":  true,
+}
+
+// Query is an ad-hoc query over the stored interactions. The interactions created
+// within [from, to) that match every filter are grouped by up to three dimensions
+// (fields, `properties.<key>`, or `time:hour|day|week|month|year`) and the metrics
+// are computed for every group.
+type Query struct {
+	From     *time.Time     `json:"from"`
+	To       *time.Time     `json:"to"`
+	Filters  []*QueryFilter `json:"filters"`
+	GroupBy  []string       `json:"groupBy"`
+	Metrics  []*QueryMetric `json:"metrics"`  // defaults to count
+	Timezone string         `json:"timezone"` // of the time dimensions
+	Limit    int            `json:"limit"`    // groups (defaults to 1000, at most 10000)
+	MaxRows  int            `json:"maxRows"`  // scanned interactions (defaults to 1000000, at most 10000000)
+	Timeout  int            `json:"timeout"`  // seconds (defaults to 10, at most 60)
+
+	location *time.Location
+}
+
+// QueryFilter --
+type QueryFilter struct {
+	Field  string        `json:"field"`
+	Op     string        `json:"op"`
+	Value  interface{}   `json:"value"`  // eq, regex
+	Values []interface{} `json:"values"` // in
+	Min    *float64      `json:"min"`    // range (inclusive)
+	Max    *float64      `json:"max"`    // range (exclusive)
+
+	pattern *regexp.Regexp
+}
+
+// QueryMetric --
+type QueryMetric struct {
+	Type       string  `json:"type"`
+	Field      string  `json:"field"`      // sum, avg, min, max, percentile
+	Percentile float64 `json:"percentile"` // 0-100
+}
+
+// QueryResult holds one row per group: the values of the dimensions and then the metrics
+type QueryResult struct {
+	Columns          []string        `json:"columns"`
+	Rows             [][]interface{} `json:"rows"`
+	Scanned          int64           `json:"scanned"`
+	Matched          int64           `json:"matched"`
+	Partitions       int             `json:"partitions"`
+	PrunedPartitions int             `json:"prunedPartitions"`
+	Truncated        bool            `json:"truncated"` // the row or group limit was reached
+	TimedOut         bool            `json:"timedOut"`
+}
+
+// Validate will check the query, set its defaults, and compile its patterns
+func (q *Query) Validate() error {
+	if q.From == nil || q.To == nil || !q.From.Before(*q.To) {
+		return errors.New(ErrQuery, map[string]interface{}{
+			"reason": "from must be before to",
+		})
+	}
+
+	if len(q.GroupBy) > maxQueryGroupBy {
+		return errors.New(ErrQuery, map[string]interface{}{
+			"reason": fmt.Sprintf("at most %d group by dimensions", maxQueryGroupBy),
+		})
+	}
+
+	for _, dimension := range q.GroupBy {
+		if bucket := strings.TrimPrefix(dimension, "time:"); bucket != dimension {
+			if !queryTimeBuckets[bucket] {
+				return errors.New(ErrQuery, map[string]interface{}{
+					"groupBy": dimension,
+				})
+			}
+			continue
+		}
+
+		if !ValidField(dimension) {
+			return errors.New(ErrField, map[string]interface{}{
+				"groupBy": dimension,
+			})
+		}
+	}
+
+	for _, f := range q.Filters {
+		err := f.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(q.Metrics) == 0 {
+		q.Metrics = []*QueryMetric{{Type: QueryCount}}
+	}
+	for _, m := range q.Metrics {
+		err := m.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	if q.Timezone == "" {
+		q.Timezone = DefaultTimeZone
+	}
+	location, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		return errors.New(ErrQuery, map[string]interface{}{
+			"timezone": q.Timezone,
+		})
+	}
+	q.location = location
+
+	if q.Limit <= 0 {
+		q.Limit = defaultQueryLimit
+	}
+	if q.Limit > maxQueryLimit {
+		q.Limit = maxQueryLimit
+	}
+	if q.MaxRows <= 0 {
+		q.MaxRows = defaultQueryMaxRows
+	}
+	if q.MaxRows > maxQueryMaxRows {
+		q.MaxRows = maxQueryMaxRows
+	}
+	if q.Timeout <= 0 {
+		q.Timeout = defaultQueryTimeout
+	}
+	if q.Timeout > maxQueryTimeout {
+		q.Timeout = maxQueryTimeout
+	}
+
+	return nil
+}
+
+// Validate --
+func (f *QueryFilter) Validate() error {
+	if !ValidField(f.Field) {
+		return errors.New(ErrField, map[string]interface{}{
+			"field": f.Field,
+		})
+	}
+
+	switch f.Op {
+	case QueryEq:
+		if f.Value == nil {
+			return errors.New(ErrQuery, map[string]interface{}{
+				"field": f.Field,
+				"op":    f.Op,
+			})
+		}
+	case QueryIn:
+		if len(f.Values) == 0 {
+			return errors.New(ErrQuery, map[string]interface{}{
+				"field": f.Field,
+				"op":    f.Op,
+			})
+		}
+	case QueryRange:
+		if f.Min == nil && f.Max == nil {
+			return errors.New(ErrQuery, map[string]interface{}{
+				"field": f.Field,
+				"op":    f.Op,
+			})
+		}
+	case QueryRegex:
+		source, ok := f.Value.(string)
+		if !ok {
+			return errors.New(ErrQuery, map[string]interface{}{
+				"field": f.Field,
+				"op":    f.Op,
+			})
+		}
+
+		pattern, err := regexp.Compile(source)
+		if err != nil {
+			return errors.New(ErrQuery, map[string]interface{}{
+				"field": f.Field,
+				"error": err.Error(),
+			})
+		}
+		f.pattern = pattern
+	case QueryExists:
+	default:
+		return errors.New(ErrQuery, map[string]interface{}{
+			"field": f.Field,
+			"op":    f.Op,
+		})
+	}
+
+	return nil
+}
+
+// Validate --
+func (m *QueryMetric) Validate() error {
+	switch m.Type {
+	case QueryCount, QueryUniqueUsers:
+		return nil
+	case QuerySum, QueryAvg, QueryMin, QueryMax, QueryPercentile:
+	default:
+		return errors.New(ErrQuery, map[string]interface{}{
+			"metric": m.Type,
+		})
+	}
+
+	if !ValidField(m.Field) {
+		return errors.New(ErrField, map[string]interface{}{
+			"metric": m.Type,
+			"field":  m.Field,
+		})
+	}
+
+	if m.Type == QueryPercentile && (m.Percentile < 0 || m.Percentile > 100) {
+		return errors.New(ErrQuery, map[string]interface{}{
+			"percentile": m.Percentile,
+		})
+	}
+
+	return nil
+}
+
+// Column will return the name of the metric in the result
+func (m *QueryMetric) Column() string {
+	switch m.Type {
+	case QueryCount, QueryUniqueUsers:
+		return m.Type
+	case QueryPercentile:
+		return fmt.Sprintf("p%s(%s)", queryString(m.Percentile), m.Field)
+	}
+
+	return fmt.Sprintf("%s(%s)", m.Type, m.Field)
+}
+
+// Actions will return the actions that every matching interaction has one of
+// (from the action filters), so that the scan can skip the other ones.
+func (q *Query) Actions() []string {
+	var actions []string
+	for _, f := range q.Filters {
+		if f.Field != "action" {
+			continue
+		}
+
+		var values []interface{}
+		switch f.Op {
+		case QueryEq:
+			values = []interface{}{f.Value}
+		case QueryIn:
+			values = f.Values
+		default:
+			continue
+		}
+
+		matching := make([]string, 0, len(values))
+		for _, value := range values {
+			if s, ok := value.(string); ok {
+				matching = append(matching, s)
+			}
+		}
+
+		// every filter must match
+		if actions == nil {
+			actions = matching
+			continue
+		}
+		actions = intersect(actions, matching)
+	}
+
+	return actions
+}
+
+func intersect(a, b []string) []string {
+	both := make([]string, 0)
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				both = append(both, x)
+				break
+			}
+		}
+	}
+
+	return both
+}
+
+// Match will return whether or not the interaction matches every filter of the query
+func (q *Query) Match(i *Interaction) bool {
+	if i.CreatedAt == nil || i.CreatedAt.Before(*q.From) || !i.CreatedAt.Before(*q.To) {
+		return false
+	}
+
+	for _, f := range q.Filters {
+		if !f.Match(i) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Match --
+func (f *QueryFilter) Match(i *Interaction) bool {
+	value, ok := i.Field(f.Field)
+	if !ok || value == nil {
+		return false
+	}
+
+	switch f.Op {
+	case QueryEq:
+		return queryMatch(value, func(v interface{}) bool {
+			return queryString(v) == queryString(f.Value)
+		})
+	case QueryIn:
+		return queryMatch(value, func(v interface{}) bool {
+			s := queryString(v)
+			for _, expected := range f.Values {
+				if s == queryString(expected) {
+					return true
+				}
+			}
+			return false
+		})
+	case QueryRange:
+		return queryMatch(value, func(v interface{}) bool {
+			n, ok := exprNumber(v)
+			if !ok {
+				return false
+			}
+			return (f.Min == nil || n >= *f.Min) && (f.Max == nil || n < *f.Max)
+		})
+	case QueryRegex:
+		return queryMatch(value, func(v interface{}) bool {
+			return f.pattern.MatchString(queryString(v))
+		})
+	}
+
+	// exists
+	return true
+}
+
+// queryMatch will match a value (or any element of an array value)
+func queryMatch(value interface{}, match func(v interface{}) bool) bool {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, e := range v {
+			if match(e) {
+				return true
+			}
+		}
+		return false
+	case []string:
+		for _, e := range v {
+			if match(e) {
+				return true
+			}
+		}
+		return false
+	case []float64:
+		for _, e := range v {
+			if match(e) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return match(value)
+}
+
+func queryString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return fmt.Sprint(v)
+	}
+
+	return fmt.Sprint(value)
+}
+
+// QueryAggregation holds the groups of a query while the interactions are scanned
+type QueryAggregation struct {
+	query     *Query
+	groups    map[string]*queryGroup
+	Matched   int64
+	Truncated bool
+}
+
+type queryGroup struct {
+	values  []interface{}
+	count   int64
+	users   map[string]struct{}
+	metrics []*queryMetricState
+}
+
+type queryMetricState struct {
+	sum      float64
+	n        int64
+	min, max float64
+	values   []float64 // percentiles
+}
+
+// NewQueryAggregation --
+func NewQueryAggregation(q *Query) *QueryAggregation {
+	return &QueryAggregation{
+		query:  q,
+		groups: make(map[string]*queryGroup),
+	}
+}
+
+// Add will add the interaction to its group (if it matches the query)
+func (a *QueryAggregation) Add(i *Interaction) {
+	if !a.query.Match(i) {
+		return
+	}
+	a.Matched++
+
+	values := make([]interface{}, 0, len(a.query.GroupBy))
+	keys := make([]string, 0, len(a.query.GroupBy))
+	for _, dimension := range a.query.GroupBy {
+		value := a.query.dimension(i, dimension)
+		values = append(values, value)
+		keys = append(keys, queryString(value))
+	}
+	key := strings.Join(keys, "\x00")
+
+	g, ok := a.groups[key]
+	if !ok {
+		if len(a.groups) >= a.query.Limit {
+			a.Truncated = true
+			return
+		}
+
+		g = &queryGroup{
+			values:  values,
+			users:   make(map[string]struct{}),
+			metrics: make([]*queryMetricState, len(a.query.Metrics)),
+		}
+		for n := range g.metrics {
+			g.metrics[n] = &queryMetricState{}
+		}
+		a.groups[key] = g
+	}
+
+	g.count++
+	for n, m := range a.query.Metrics {
+		switch m.Type {
+		case QueryCount:
+		case QueryUniqueUsers:
+			g.users[UserKey(i.User())] = struct{}{}
+		default:
+			value, ok := i.Field(m.Field)
+			if !ok {
+				continue
+			}
+			number, ok := exprNumber(value)
+			if !ok {
+				continue
+			}
+			g.metrics[n].add(number, m.Type == QueryPercentile)
+		}
+	}
+}
+
+// dimension will return the value of the interaction for a group by dimension
+func (q *Query) dimension(i *Interaction, dimension string) interface{} {
+	if bucket := strings.TrimPrefix(dimension, "time:"); bucket != dimension {
+		return timeBucket(i.CreatedAt.In(q.location), bucket).Format(time.RFC3339)
+	}
+
+	value, ok := i.Field(dimension)
+	if !ok || value == nil {
+		return QueryNotSet
+	}
+
+	switch v := value.(type) {
+	case string, float64, bool:
+		return v
+	}
+
+	return queryString(value)
+}
+
+// timeBucket will return the start of the bucket of the time (weeks start on monday)
+func timeBucket(t time.Time, bucket string) time.Time {
+	year, month, day := t.Date()
+	switch bucket {
+	case "hour":
+		return time.Date(year, month, day, t.Hour(), 0, 0, 0, t.Location())
+	case "week":
+		weekday := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-weekday, 0, 0, 0, 0, t.Location())
+	case "month":
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	case "year":
+		return time.Date(year, 1, 1, 0, 0, 0, 0, t.Location())
+	}
+
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+func (s *queryMetricState) add(value float64, keep bool) {
+	if s.n == 0 || value < s.min {
+		s.min = value
+	}
+	if s.n == 0 || value > s.max {
+		s.max = value
+	}
+	s.sum += value
+	s.n++
+
+	if keep {
+		s.values = append(s.values, value)
+	}
+}
+
+func (s *queryMetricState) value(m *QueryMetric) interface{} {
+	if s.n == 0 && m.Type != QuerySum {
+		return nil
+	}
+
+	switch m.Type {
+	case QuerySum:
+		return s.sum
+	case QueryAvg:
+		return s.sum / float64(s.n)
+	case QueryMin:
+		return s.min
+	case QueryMax:
+		return s.max
+	}
+
+	// percentile (nearest rank)
+	sort.Float64s(s.values)
+	rank := int(math.Ceil(m.Percentile / 100 * float64(len(s.values))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	return s.values[rank-1]
+}
+
+// Result will return the groups of the query, sorted by their dimensions
+func (a *QueryAggregation) Result() *QueryResult {
+	result := &QueryResult{
+		Columns:   make([]string, 0, len(a.query.GroupBy)+len(a.query.Metrics)),
+		Rows:      make([][]interface{}, 0, len(a.groups)),
+		Matched:   a.Matched,
+		Truncated: a.Truncated,
+	}
+
+	result.Columns = append(result.Columns, a.query.GroupBy...)
+	for _, m := range a.query.Metrics {
+		result.Columns = append(result.Columns, m.Column())
+	}
+
+	keys := make([]string, 0, len(a.groups))
+	for key := range a.groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		g := a.groups[key]
+		row := make([]interface{}, 0, len(result.Columns))
+		row = append(row, g.values...)
+
+		for n, m := range a.query.Metrics {
+			switch m.Type {
+			case QueryCount:
+				row = append(row, g.count)
+			case QueryUniqueUsers:
+				row = append(row, len(g.users))
+			default:
+				row = append(row, g.metrics[n].value(m))
+			}
+		}
+
+		result.Rows = append(result.Rows, row)
+	}
+
+	return result
+}
@@ -0,0 +1,348 @@
+package types
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func floatp(f float64) *float64 {
+	return &f
+}
+
+func TestQueryValidate(t *testing.T) {
+	from := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+
+	tests := []struct {
+		name        string
+		query       Query
+		wantErr     error
+		wantLimit   int
+		wantMaxRows int
+		wantTimeout int
+	}{
+		{
+			name:        "defaults",
+			query:       Query{From: &from, To: &to},
+			wantLimit:   defaultQueryLimit,
+			wantMaxRows: defaultQueryMaxRows,
+			wantTimeout: defaultQueryTimeout,
+		},
+		{
+			name:        "capped",
+			query:       Query{From: &from, To: &to, Limit: 1e9, MaxRows: 1e9, Timeout: 3600},
+			wantLimit:   maxQueryLimit,
+			wantMaxRows: maxQueryMaxRows,
+			wantTimeout: maxQueryTimeout,
+		},
+		{
+			name:        "within the caps",
+			query:       Query{From: &from, To: &to, Limit: 10, MaxRows: 100, Timeout: 5},
+			wantLimit:   10,
+			wantMaxRows: 100,
+			wantTimeout: 5,
+		},
+		{
+			name:    "missing from",
+			query:   Query{To: &to},
+			wantErr: ErrQuery,
+		},
+		{
+			name:    "from after to",
+			query:   Query{From: &to, To: &from},
+			wantErr: ErrQuery,
+		},
+		{
+			name:    "too many dimensions",
+			query:   Query{From: &from, To: &to, GroupBy: []string{"action", "entityType", "userType", "time:day"}},
+			wantErr: ErrQuery,
+		},
+		{
+			name:    "time bucket",
+			query:   Query{From: &from, To: &to, GroupBy: []string{"time:minute"}},
+			wantErr: ErrQuery,
+		},
+		{
+			name:    "dimension",
+			query:   Query{From: &from, To: &to, GroupBy: []string{"color"}},
+			wantErr: ErrField,
+		},
+		{
+			name:    "timezone",
+			query:   Query{From: &from, To: &to, Timezone: "Mars/Olympus"},
+			wantErr: ErrQuery,
+		},
+		{
+			name:    "filter operator",
+			query:   Query{From: &from, To: &to, Filters: []*QueryFilter{{Field: "action", Op: "like", Value: "view"}}},
+			wantErr: ErrQuery,
+		},
+		{
+			name:    "filter field",
+			query:   Query{From: &from, To: &to, Filters: []*QueryFilter{{Field: "color", Op: QueryExists}}},
+			wantErr: ErrField,
+		},
+		{
+			name:    "eq without a value",
+			query:   Query{From: &from, To: &to, Filters: []*QueryFilter{{Field: "action", Op: QueryEq}}},
+			wantErr: ErrQuery,
+		},
+		{
+			name:    "in without values",
+			query:   Query{From: &from, To: &to, Filters: []*QueryFilter{{Field: "action", Op: QueryIn}}},
+			wantErr: ErrQuery,
+		},
+		{
+			name:    "range without bounds",
+			query:   Query{From: &from, To: &to, Filters: []*QueryFilter{{Field: "properties.seats", Op: QueryRange}}},
+			wantErr: ErrQuery,
+		},
+		{
+			name:    "regex pattern",
+			query:   Query{From: &from, To: &to, Filters: []*QueryFilter{{Field: "action", Op: QueryRegex, Value: "("}}},
+			wantErr: ErrQuery,
+		},
+		{
+			name:    "metric",
+			query:   Query{From: &from, To: &to, Metrics: []*QueryMetric{{Type: "median", Field: "properties.seats"}}},
+			wantErr: ErrQuery,
+		},
+		{
+			name:    "metric field",
+			query:   Query{From: &from, To: &to, Metrics: []*QueryMetric{{Type: QuerySum}}},
+			wantErr: ErrField,
+		},
+		{
+			name:    "percentile",
+			query:   Query{From: &from, To: &to, Metrics: []*QueryMetric{{Type: QueryPercentile, Field: "properties.seats", Percentile: 101}}},
+			wantErr: ErrQuery,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query
+			err := q.Validate()
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Validate() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+
+			if q.Limit != tt.wantLimit {
+				t.Errorf("Limit = %d, want %d", q.Limit, tt.wantLimit)
+			}
+			if q.MaxRows != tt.wantMaxRows {
+				t.Errorf("MaxRows = %d, want %d", q.MaxRows, tt.wantMaxRows)
+			}
+			if q.Timeout != tt.wantTimeout {
+				t.Errorf("Timeout = %d, want %d", q.Timeout, tt.wantTimeout)
+			}
+			if len(q.Metrics) == 0 || q.Metrics[0].Type != QueryCount {
+				t.Errorf("Metrics = %v, want count", q.Metrics)
+			}
+		})
+	}
+}
+
+func TestQueryFilterMatch(t *testing.T) {
+	createdAt := time.Date(2026, 10, 18, 15, 30, 0, 0, time.UTC)
+	action, user, userType := "view", "u-1", "visitor"
+	interaction := &Interaction{
+		Action:    &action,
+		UserType:  &userType,
+		UserID:    &user,
+		CreatedAt: &createdAt,
+		Properties: map[string]interface{}{
+			"plan":  "pro",
+			"seats": float64(3),
+			"tags":  []interface{}{"a", "b"},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		filter QueryFilter
+		want   bool
+	}{
+		{"eq", QueryFilter{Field: "action", Op: QueryEq, Value: "view"}, true},
+		{"eq other value", QueryFilter{Field: "action", Op: QueryEq, Value: "click"}, false},
+		{"eq number", QueryFilter{Field: "properties.seats", Op: QueryEq, Value: float64(3)}, true},
+		{"eq array element", QueryFilter{Field: "properties.tags", Op: QueryEq, Value: "b"}, true},
+		{"eq unset", QueryFilter{Field: "entityType", Op: QueryEq, Value: "article"}, false},
+		{"in", QueryFilter{Field: "properties.plan", Op: QueryIn, Values: []interface{}{"free", "pro"}}, true},
+		{"in other values", QueryFilter{Field: "properties.plan", Op: QueryIn, Values: []interface{}{"free"}}, false},
+		{"range", QueryFilter{Field: "properties.seats", Op: QueryRange, Min: floatp(1), Max: floatp(5)}, true},
+		{"range min inclusive", QueryFilter{Field: "properties.seats", Op: QueryRange, Min: floatp(3)}, true},
+		{"range max exclusive", QueryFilter{Field: "properties.seats", Op: QueryRange, Max: floatp(3)}, false},
+		{"range not a number", QueryFilter{Field: "properties.plan", Op: QueryRange, Min: floatp(0)}, false},
+		{"regex", QueryFilter{Field: "userID", Op: QueryRegex, Value: "^u-[0-9]+$"}, true},
+		{"regex no match", QueryFilter{Field: "userID", Op: QueryRegex, Value: "^c-"}, false},
+		{"exists", QueryFilter{Field: "properties.plan", Op: QueryExists}, true},
+		{"exists unset", QueryFilter{Field: "properties.color", Op: QueryExists}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.filter
+			if err := f.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if got := f.Match(interaction); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeBucket(t *testing.T) {
+	// a thursday
+	at := time.Date(2026, 10, 22, 15, 30, 45, 0, time.UTC)
+
+	tests := []struct {
+		bucket string
+		at     time.Time
+		want   time.Time
+	}{
+		{"hour", at, time.Date(2026, 10, 22, 15, 0, 0, 0, time.UTC)},
+		{"day", at, time.Date(2026, 10, 22, 0, 0, 0, 0, time.UTC)},
+		{"week", at, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"week", time.Date(2026, 10, 25, 23, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"week", time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC), time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)},
+		{"month", at, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{"year", at, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.bucket+" "+tt.at.Format(time.RFC3339), func(t *testing.T) {
+			if got := timeBucket(tt.at, tt.bucket); !got.Equal(tt.want) {
+				t.Errorf("timeBucket(%v, %s) = %v, want %v", tt.at, tt.bucket, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryAggregation(t *testing.T) {
+	from := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 2)
+
+	tests := []struct {
+		name          string
+		query         Query
+		wantRows      [][]interface{}
+		wantMatched   int64
+		wantTruncated bool
+	}{
+		{
+			name: "day buckets in a time zone",
+			query: Query{
+				From:     &from,
+				To:       &to,
+				GroupBy:  []string{"time:day"},
+				Timezone: "Asia/Tokyo",
+			},
+			wantRows: [][]interface{}{
+				{"2026-10-18T00:00:00+09:00", int64(1)},
+				{"2026-10-19T00:00:00+09:00", int64(2)},
+				{"2026-10-20T00:00:00+09:00", int64(1)},
+			},
+			wantMatched: 4,
+		},
+		{
+			name: "metrics",
+			query: Query{
+				From:    &from,
+				To:      &to,
+				GroupBy: []string{"properties.plan"},
+				Metrics: []*QueryMetric{
+					{Type: QueryCount},
+					{Type: QueryUniqueUsers},
+					{Type: QuerySum, Field: "properties.seats"},
+					{Type: QueryMax, Field: "properties.seats"},
+				},
+			},
+			wantRows: [][]interface{}{
+				{QueryNotSet, int64(1), 1, float64(0), nil},
+				{"pro", int64(3), 2, float64(6), float64(3)},
+			},
+			wantMatched: 4,
+		},
+		{
+			name: "group limit",
+			query: Query{
+				From:    &from,
+				To:      &to,
+				GroupBy: []string{"userID"},
+				Limit:   2,
+			},
+			wantRows: [][]interface{}{
+				{"u-1", int64(2)},
+				{"u-2", int64(1)},
+			},
+			wantMatched:   4,
+			wantTruncated: true,
+		},
+	}
+
+	interactions := []struct {
+		user      string
+		createdAt time.Time
+		seats     interface{}
+	}{
+		{"u-1", from.Add(time.Hour), float64(1)},
+		{"u-2", from.Add(16 * time.Hour), float64(2)},
+		{"u-1", from.Add(20 * time.Hour), float64(3)},
+		{"u-3", from.Add(40 * time.Hour), nil},
+		{"u-1", to, float64(4)}, // after the time range
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query
+			if err := q.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+
+			a := NewQueryAggregation(&q)
+			for _, i := range interactions {
+				action, userType, user, createdAt := "view", "visitor", i.user, i.createdAt
+				interaction := &Interaction{
+					Action:     &action,
+					UserType:   &userType,
+					UserID:     &user,
+					CreatedAt:  &createdAt,
+					Properties: map[string]interface{}{},
+				}
+				if i.seats != nil {
+					interaction.Properties["plan"] = "pro"
+					interaction.Properties["seats"] = i.seats
+				}
+				a.Add(interaction)
+			}
+
+			result := a.Result()
+			if result.Matched != tt.wantMatched {
+				t.Errorf("Matched = %d, want %d", result.Matched, tt.wantMatched)
+			}
+			if result.Truncated != tt.wantTruncated {
+				t.Errorf("Truncated = %v, want %v", result.Truncated, tt.wantTruncated)
+			}
+			if len(result.Rows) != len(tt.wantRows) {
+				t.Fatalf("Rows = %v, want %v", result.Rows, tt.wantRows)
+			}
+			for n, row := range result.Rows {
+				for c := range row {
+					if row[c] != tt.wantRows[n][c] {
+						t.Errorf("Rows[%d] = %v, want %v", n, row, tt.wantRows[n])
+						break
+					}
+				}
+			}
+		})
+	}
+}
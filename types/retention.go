@@ -0,0 +1,71 @@
+package types
+
+import (
+	"time"
+
+	"github.com/JKhawaja/errors"
+)
+
+// RetentionSettings are the retention periods of the stored data (0 keeps the data forever).
+// The interactions are removed by partition: a partition expires once all of its
+// interactions are older than the retention period.
+type RetentionSettings struct {
+	Interactions    int  `json:"interactions"`    // days of raw (and sandboxed) interactions
+	ConversionsOnly bool `json:"conversionsOnly"` // the goal interactions of the expired partitions are kept
+	Conversions     int  `json:"conversions"`     // days of the kept goal interactions
+	IntervalStats   int  `json:"intervalStats"`   // periods of the interval stats after they ended
+	History         int  `json:"history"`         // periods of the archived stats and summaries after they ended
+	DeadLetters     int  `json:"deadLetters"`     // days of the quarantined files
+	Archive         bool `json:"archive"`         // expired partitions are archived instead of deleted
+}
+
+// NewRetentionSettings --
+func NewRetentionSettings() *RetentionSettings {
+	return &RetentionSettings{}
+}
+
+// Validate --
+func (r *RetentionSettings) Validate() error {
+	if r.Interactions < 0 || r.Conversions < 0 || r.IntervalStats < 0 || r.History < 0 || r.DeadLetters < 0 {
+		return errors.New(ErrRetention, map[string]interface{}{
+			"reason": "retention periods can not be negative",
+		})
+	}
+
+	if r.ConversionsOnly && r.Conversions > 0 && r.Conversions < r.Interactions {
+		return errors.New(ErrRetention, map[string]interface{}{
+			"reason": "conversions must be kept longer than interactions",
+		})
+	}
+
+	return nil
+}
+
+// retentionBefore will return the time before which the data of the retention period expired
+func retentionBefore(days int, now time.Time) time.Time {
+	if days == 0 {
+		return time.Time{}
+	}
+
+	return now.AddDate(0, 0, -days)
+}
+
+// InteractionsBefore will return the time before which interactions expired (zero if they never do)
+func (r *RetentionSettings) InteractionsBefore(now time.Time) time.Time {
+	return retentionBefore(r.Interactions, now)
+}
+
+// ConversionsBefore will return the time before which the kept goal interactions expired (zero if they never do)
+func (r *RetentionSettings) ConversionsBefore(now time.Time) time.Time {
+	return retentionBefore(r.Conversions, now)
+}
+
+// DeadLettersBefore will return the time before which quarantined files expired (zero if they never do)
+func (r *RetentionSettings) DeadLettersBefore(now time.Time) time.Time {
+	return retentionBefore(r.DeadLetters, now)
+}
+
+// Expired will return whether or not the stats ended more than the number of periods (of their interval) ago
+func (i *IntervalStats) Expired(periods int, now time.Time) bool {
+	return PeriodExpired(i, periods, now)
+}
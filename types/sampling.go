@@ -0,0 +1,151 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+
+	"github.com/JKhawaja/errors"
+)
+
+// SampleRule keeps only a fraction (Rate) of the matching interactions, e.g. for
+// very high-volume actions. Every condition that is set must match (an action,
+// or the fields of an endpoint). The rules are matched in order and the first
+// matching rule applies.
+// Interactions are sampled by user (a hash of the user), so the same users are
+// always kept and their sessions stay consistent. The rate must be 1/N
+// (e.g. 0.5, 0.1, 0.01) so that every kept interaction counts N times in the stats.
+type SampleRule struct {
+	ID         string  `json:"id"`
+	Action     string  `json:"action,omitempty"`
+	EntityType string  `json:"entityType,omitempty"`
+	EntityID   string  `json:"entityID,omitempty"`
+	OriginType string  `json:"originType,omitempty"`
+	OriginID   string  `json:"originID,omitempty"`
+	Rate       float64 `json:"rate"`
+}
+
+// SampleRules is the ordered list of sample rules
+type SampleRules []*SampleRule
+
+// NewSampleRules --
+func NewSampleRules() SampleRules {
+	return make(SampleRules, 0)
+}
+
+// Validate --
+func (s SampleRules) Validate() error {
+	ids := make(map[string]struct{})
+	for _, rule := range s {
+		if rule == nil || rule.ID == "" {
+			return errors.New(ErrSampleRule, map[string]interface{}{
+				"reason": "missing id",
+			})
+		}
+
+		if _, ok := ids[rule.ID]; ok {
+			return errors.New(ErrSampleRule, map[string]interface{}{
+				"rule":   rule.ID,
+				"reason": "duplicate id",
+			})
+		}
+		ids[rule.ID] = struct{}{}
+
+		if rule.Action == "" && rule.EntityType == "" && rule.EntityID == "" && rule.OriginType == "" && rule.OriginID == "" {
+			return errors.New(ErrSampleRule, map[string]interface{}{
+				"rule":   rule.ID,
+				"reason": "missing action or endpoint",
+			})
+		}
+
+		if rule.Rate <= 0 || rule.Rate > 1 {
+			return errors.New(ErrSampleRule, map[string]interface{}{
+				"rule":   rule.ID,
+				"reason": "rate must be in (0, 1]",
+			})
+		}
+
+		n := 1 / rule.Rate
+		if math.Abs(n-math.Round(n)) > 1e-6 {
+			return errors.New(ErrSampleRule, map[string]interface{}{
+				"rule":   rule.ID,
+				"reason": "rate must be 1/N",
+			})
+		}
+	}
+
+	return nil
+}
+
+// Match will return the first rule that matches the interaction (nil if none)
+func (s SampleRules) Match(i *Interaction) *SampleRule {
+	for _, rule := range s {
+		if rule.match(i) {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+func (r *SampleRule) match(i *Interaction) bool {
+	conditions := []struct {
+		value string
+		field *string
+	}{
+		{r.Action, i.Action},
+		{r.EntityType, i.EntityType},
+		{r.EntityID, i.EntityID},
+		{r.OriginType, i.OriginType},
+		{r.OriginID, i.OriginID},
+	}
+
+	for _, c := range conditions {
+		if c.value == "" {
+			continue
+		}
+		if c.field == nil || *c.field != c.value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Sample will return whether or not the interaction is kept by the rule.
+// The sample rate is recorded on the kept interactions.
+func (r *SampleRule) Sample(i *Interaction) bool {
+	if r.Rate >= 1 {
+		return true
+	}
+
+	// anonymous interactions have no user (and no session)
+	key := i.User().String()
+	if i.Anonymous() {
+		key = i.String()
+	}
+
+	if sampleBucket(key) >= r.Rate {
+		return false
+	}
+
+	i.SampleRate = r.Rate
+
+	return true
+}
+
+// sampleBucket will return the (deterministic) position of the key in [0, 1)
+func sampleBucket(key string) float64 {
+	sum := sha256.Sum256([]byte(key))
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / float64(1<<53)
+}
+
+// Weight will return the number of interactions that the interaction
+// stands for in the stats (1/rate for sampled interactions)
+func (i *Interaction) Weight() int64 {
+	if i.SampleRate <= 0 || i.SampleRate >= 1 {
+		return 1
+	}
+
+	return int64(math.Round(1 / i.SampleRate))
+}
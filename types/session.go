@@ -39,7 +39,8 @@ type UserSession struct {
 
 	// conversions
 	Conversions  int64         `json:"totalConversions"`
-	Value        float64       `json:"value"` // total conversion amount for session
+	Value        float64       `json:"value"` // total (signed) goal value for session
+	Goals        []*GoalCount  `json:"goals,omitempty"`
 	OriginCounts *OriginCounts `json:"originCounts"`
 	PrevEndpoint *Endpoint     `json:"prevEndpoint"`
 
@@ -48,6 +49,9 @@ type UserSession struct {
 	OriginDuration time.Duration `json:"origin_duration"`
 	CurrentOrigin  *Origin       `json:"current_origin"`
 
+	// first-touch campaign
+	Campaign *Campaign `json:"campaign,omitempty"`
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -110,6 +114,64 @@ func (u *UserSessions) GetSession(i *Interaction) (*UserSession, error) {
 	return item.(*UserSession), nil
 }
 
+// Merge will move the active session of the `from` user onto the `to` user.
+// If the `to` user also has an active session then both sessions are combined
+// into the `to` user's session.
+func (u *UserSessions) Merge(from, to User) error {
+	item, err := u.Get(from.String())
+	if err == cache.ErrDNE {
+		return nil
+	} else if err != nil {
+		return errors.New(err, nil)
+	}
+	sess := item.(*UserSession)
+
+	err = u.Delete(from.String())
+	if err != nil && err != cache.ErrDNE {
+		return errors.New(err, nil)
+	}
+
+	existing, err := u.Get(to.String())
+	if err == cache.ErrDNE {
+		sess.UserType = to.Type
+		sess.UserID = to.ID
+		err := u.Add(to.String(), sess, SessionExpiryDuration)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+		return nil
+	} else if err != nil {
+		return errors.New(err, nil)
+	}
+
+	existing.(*UserSession).Merge(sess)
+	return nil
+}
+
+// Session will return the active session of the user
+func (u *UserSessions) Session(user User) (*UserSession, bool) {
+	item, err := u.Get(user.String())
+	if err != nil {
+		return nil, false
+	}
+
+	return item.(*UserSession), true
+}
+
+// Remove will end the active session of the user without
+// applying it to the summaries.
+// It returns whether or not the user had an active session.
+func (u *UserSessions) Remove(user User) (bool, error) {
+	err := u.Delete(user.String())
+	if err == cache.ErrDNE {
+		return false, nil
+	} else if err != nil {
+		return false, errors.New(err, nil)
+	}
+
+	return true, nil
+}
+
 // String will return a unique string which
 // represents the User object.
 func (u User) String() string {
@@ -221,14 +283,61 @@ func NewSession(i *Interaction) *UserSession {
 // unless the Update() method is also called using this interaction.
 func (s *UserSession) Renew(i *Interaction) {
 	s.Total = 0
+	s.Conversions = 0
+	s.Value = 0
+	s.Goals = nil
 	s.ID = NewUUID().String()
 	s.OriginCounts = NewOriginCounts()
 	s.OriginDuration = time.Duration(0)
 	s.CurrentOrigin = i.Origin()
+	s.Campaign = nil
 	s.CreatedAt = *i.CreatedAt
 	s.UpdatedAt = *i.CreatedAt
 }
 
+// Merge will combine another session of the same (canonical) user into this session.
+func (s *UserSession) Merge(other *UserSession) {
+	s.Total += other.Total
+	s.Conversions += other.Conversions
+	s.Value += other.Value
+	for _, g := range other.Goals {
+		s.Goals = addGoal(s.Goals, g.Goal, g.Conversions, g.Value)
+	}
+
+	for _, oc := range other.OriginCounts.List {
+		existing, ok := s.OriginCounts.Get(oc.Origin)
+		if !ok {
+			s.OriginCounts.List = append(s.OriginCounts.List, oc)
+			continue
+		}
+		existing.Count += oc.Count
+		existing.Visits += oc.Visits
+	}
+
+	if other.CreatedAt.Before(s.CreatedAt) {
+		s.CreatedAt = other.CreatedAt
+		if other.Campaign != nil {
+			s.Campaign = other.Campaign
+		}
+	}
+	if s.Campaign == nil {
+		s.Campaign = other.Campaign
+	}
+
+	if other.UpdatedAt.After(s.UpdatedAt) {
+		s.UpdatedAt = other.UpdatedAt
+		s.PrevEndpoint = other.PrevEndpoint
+		s.CurrentOrigin = other.CurrentOrigin
+		s.OriginDuration = other.OriginDuration
+		s.VisitTotal = other.VisitTotal
+	}
+
+	if s.DeviceID == "" {
+		s.DeviceType = other.DeviceType
+		s.DeviceID = other.DeviceID
+	}
+}
+
 // Expired will check if the session has expired or not.
 func (s *UserSession) Expired() bool {
 	return time.Now().UTC().After(s.UpdatedAt.Add(SessionExpiryDuration))
@@ -263,14 +372,29 @@ func (s *UserSession) Origin(i *Interaction) (*Origin, time.Duration) {
 // and whether or not the origin has changed.
 // SessionUpdate should occur last when processing an interaction.
 func (s *UserSession) Update(i *Interaction) {
-	s.Total++
+	w := i.Weight()
+	s.Total += w
 	defer func(s *UserSession, i *Interaction) {
 		s.UpdatedAt = *i.CreatedAt
 	}(s, i)
 
 	// set if converted session or not
-	if *i.Action == Conversion {
-		s.Conversions++
+	if goal := i.Goal(); goal != nil {
+		if goal.Positive() {
+			s.Conversions += w
+		}
+
+		var value float64
+		if v, ok := goal.Value(i); ok {
+			value = v * float64(w)
+			s.Value += value
+		}
+		s.Goals = addGoal(s.Goals, goal.Action, w, value)
+	}
+
+	// first-touch campaign
+	if s.Campaign == nil {
+		s.Campaign = i.Campaign()
 	}
 
 	s.PrevEndpoint = i.Endpoint()
@@ -343,6 +467,7 @@ type sessionEncoded struct {
 	Total        int64          `json:"total"`
 	Conversions  int64          `json:"conversions"`
 	Value        float64        `json:"value"`
+	Goals        []*GoalCount   `json:"goals"`
 	OriginCounts []*OriginCount `json:"originCounts"`
 	PrevEndpoint *Endpoint      `json:"prevEndpoint"`
 
@@ -351,6 +476,8 @@ type sessionEncoded struct {
 	OriginDuration time.Duration `json:"origin_duration"`
 	CurrentOrigin  *Origin       `json:"current_origin"`
 
+	Campaign *Campaign `json:"campaign"`
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -364,11 +491,13 @@ func (s *UserSession) encoded() *sessionEncoded {
 		Total:          s.Total,
 		Conversions:    s.Conversions,
 		Value:          s.Value,
+		Goals:          s.Goals,
 		OriginCounts:   s.OriginCounts.List,
 		PrevEndpoint:   s.PrevEndpoint,
 		VisitTotal:     s.VisitTotal,
 		OriginDuration: s.OriginDuration,
 		CurrentOrigin:  s.CurrentOrigin,
+		Campaign:       s.Campaign,
 		CreatedAt:      s.CreatedAt,
 		UpdatedAt:      s.UpdatedAt,
 	}
@@ -385,11 +514,13 @@ func (s *UserSession) decoded(encoded *sessionEncoded) {
 	s.Total = encoded.Total
 	s.Conversions = encoded.Conversions
 	s.Value = encoded.Value
+	s.Goals = encoded.Goals
 	s.OriginCounts = originCounts
 	s.PrevEndpoint = encoded.PrevEndpoint
 	s.VisitTotal = encoded.VisitTotal
 	s.OriginDuration = encoded.OriginDuration
 	s.CurrentOrigin = encoded.CurrentOrigin
+	s.Campaign = encoded.Campaign
 	s.CreatedAt = encoded.CreatedAt
 	s.UpdatedAt = encoded.UpdatedAt
 }
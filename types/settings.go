@@ -31,6 +31,10 @@ const (
 	Quarterly = "quarterly"
 	// Yearly is an interval type
 	Yearly = "yearly"
+
+	// settingsVersion is the version of the encoded settings
+	// (1: the timestamp settings are always present)
+	settingsVersion = 1
 )
 
 var (
@@ -40,13 +44,26 @@ var (
 
 // Settings is the settings for the entire system
 type Settings struct {
-	ID                  string        `json:"id"`
-	StatsToggles        *StatsToggles `json:"statsToggles"`
-	InteractionsStorage bool          `json:"interactions"`
-	User                string        `json:"-"`
-	Password            string        `json:"-"`
-	APIKey              string        `json:"-"`
-	JWTSecret           string        `json:"-"`
+	ID                  string             `json:"id"`
+	StatsToggles        *StatsToggles      `json:"statsToggles"`
+	InteractionsStorage bool               `json:"interactions"`
+	Timestamps          *TimestampSettings `json:"timestamps"`
+	Pseudonyms          *PseudonymSettings `json:"pseudonyms"`
+	Consent             *ConsentSettings   `json:"consent"`
+	Bots                *BotSettings       `json:"bots"`
+	Filters             FilterRules        `json:"filters"`
+	Sampling            SampleRules        `json:"sampling"`
+	Goals               Goals              `json:"goals"`
+	UserAgents          *UserAgentSettings `json:"userAgents"`
+	Geo                 *GeoSettings       `json:"geo"`
+	Campaigns           *CampaignSettings  `json:"campaigns"`
+	TrustedProxies      []string           `json:"trustedProxies"`
+	Pipeline            []*StageConfig     `json:"pipeline"`
+	Retention           *RetentionSettings `json:"retention"`
+	User                string             `json:"-"`
+	Password            string             `json:"-"`
+	APIKey              string             `json:"-"`
+	JWTSecret           string             `json:"-"`
 }
 
 // StatsToggles is the set of all summary toggles
@@ -64,6 +81,19 @@ func NewSettings() *Settings {
 	return &Settings{
 		StatsToggles:        NewStatsToggles(),
 		InteractionsStorage: true,
+		Timestamps:          NewTimestampSettings(),
+		Pseudonyms:          NewPseudonymSettings(),
+		Consent:             NewConsentSettings(),
+		Bots:                NewBotSettings(),
+		Filters:             NewFilterRules(),
+		Sampling:            NewSampleRules(),
+		Goals:               NewGoals(),
+		UserAgents:          NewUserAgentSettings(),
+		Geo:                 NewGeoSettings(),
+		Campaigns:           NewCampaignSettings(),
+		TrustedProxies:      make([]string, 0),
+		Pipeline:            NewPipeline(),
+		Retention:           NewRetentionSettings(),
 	}
 }
 
@@ -108,11 +138,39 @@ func toggleBool(value bool) bool {
 // GobEncode --
 func (s *Settings) GobEncode() ([]byte, error) {
 	sCopy := struct {
+		Version             int
 		StatsToggles        *StatsToggles
 		InteractionsStorage bool
+		Timestamps          *TimestampSettings
+		Pseudonyms          *PseudonymSettings
+		Consent             *ConsentSettings
+		Bots                *BotSettings
+		Filters             FilterRules
+		Sampling            SampleRules
+		Goals               Goals
+		UserAgents          *UserAgentSettings
+		Geo                 *GeoSettings
+		Campaigns           *CampaignSettings
+		TrustedProxies      []string
+		Pipeline            []*StageConfig
+		Retention           *RetentionSettings
 	}{
+		Version:             settingsVersion,
 		StatsToggles:        s.StatsToggles,
 		InteractionsStorage: s.InteractionsStorage,
+		Timestamps:          s.Timestamps,
+		Pseudonyms:          s.Pseudonyms,
+		Consent:             s.Consent,
+		Bots:                s.Bots,
+		Filters:             s.Filters,
+		Sampling:            s.Sampling,
+		Goals:               s.Goals,
+		UserAgents:          s.UserAgents,
+		Geo:                 s.Geo,
+		Campaigns:           s.Campaigns,
+		TrustedProxies:      s.TrustedProxies,
+		Pipeline:            s.Pipeline,
+		Retention:           s.Retention,
 	}
 
 	var buf bytes.Buffer
@@ -128,12 +186,26 @@ func (s *Settings) GobEncode() ([]byte, error) {
 // GobDecode --
 func (s *Settings) GobDecode(data []byte) error {
 	type settings struct {
+		Version                int
 		StatsToggles           *StatsToggles
 		InteractionsStorage    bool
 		ConversionsStorageOnly bool
 		InteractionsRetention  int
+		Timestamps             *TimestampSettings
+		Pseudonyms             *PseudonymSettings
+		Consent                *ConsentSettings
+		Bots                   *BotSettings
+		Filters                FilterRules
+		Sampling               SampleRules
+		Goals                  Goals
+		UserAgents             *UserAgentSettings
+		Geo                    *GeoSettings
+		Campaigns              *CampaignSettings
+		TrustedProxies         []string
+		Pipeline               []*StageConfig
+		Retention              *RetentionSettings
 	}
-	var sCopy *settings
+	sCopy := &settings{}
 	dec := gob.NewDecoder(bytes.NewBuffer(data))
 	err := dec.Decode(sCopy)
 	if err != nil {
@@ -142,5 +214,83 @@ func (s *Settings) GobDecode(data []byte) error {
 
 	s.StatsToggles = sCopy.StatsToggles
 	s.InteractionsStorage = sCopy.InteractionsStorage
+
+	// the settings of older versions get the defaults, while gob
+	// omits the timestamp settings when every check is disabled
+	s.Timestamps = sCopy.Timestamps
+	if s.Timestamps == nil && sCopy.Version < 1 {
+		s.Timestamps = NewTimestampSettings()
+	} else if s.Timestamps == nil {
+		s.Timestamps = &TimestampSettings{}
+	}
+	if s.Timestamps.Keys == nil {
+		s.Timestamps.Keys = make(map[string]*TimestampFormat)
+	}
+
+	s.Pseudonyms = sCopy.Pseudonyms
+	if s.Pseudonyms == nil {
+		s.Pseudonyms = NewPseudonymSettings()
+	}
+
+	s.Consent = sCopy.Consent
+	if s.Consent == nil {
+		s.Consent = NewConsentSettings()
+	}
+
+	s.Bots = sCopy.Bots
+	if s.Bots == nil {
+		s.Bots = NewBotSettings()
+	}
+
+	// compiles the patterns of the (already validated) rules
+	s.Filters = sCopy.Filters
+	if s.Filters == nil {
+		s.Filters = NewFilterRules()
+	}
+	s.Filters.Validate()
+
+	s.Sampling = sCopy.Sampling
+	if s.Sampling == nil {
+		s.Sampling = NewSampleRules()
+	}
+
+	s.Goals = sCopy.Goals
+	if s.Goals == nil {
+		s.Goals = NewGoals()
+	}
+
+	s.UserAgents = sCopy.UserAgents
+	if s.UserAgents == nil {
+		s.UserAgents = NewUserAgentSettings()
+	}
+
+	s.Geo = sCopy.Geo
+	if s.Geo == nil {
+		s.Geo = NewGeoSettings()
+	}
+
+	s.Campaigns = sCopy.Campaigns
+	if s.Campaigns == nil {
+		s.Campaigns = NewCampaignSettings()
+	}
+
+	s.TrustedProxies = sCopy.TrustedProxies
+	if s.TrustedProxies == nil {
+		s.TrustedProxies = make([]string, 0)
+	}
+
+	s.Pipeline = sCopy.Pipeline
+	if s.Pipeline == nil {
+		s.Pipeline = NewPipeline()
+	}
+
+	// the retention of older versions
+	s.Retention = sCopy.Retention
+	if s.Retention == nil {
+		s.Retention = NewRetentionSettings()
+		s.Retention.Interactions = sCopy.InteractionsRetention
+		s.Retention.ConversionsOnly = sCopy.ConversionsStorageOnly
+	}
+
 	return nil
 }
@@ -76,7 +76,11 @@ func NewNamedStats(name string, value interface{}, timestamp *time.Time) (*Named
 
 // NewNamedSimpleStats --
 func NewNamedSimpleStats(name string, value interface{}) (*NamedSimpleStats, error) {
-	stats, err := NewSimpleStats(value)
+	return newNamedSimpleStats(name, value, 1)
+}
+
+func newNamedSimpleStats(name string, value interface{}, weight int64) (*NamedSimpleStats, error) {
+	stats, err := NewWeightedSimpleStats(value, weight)
 	if err != nil {
 		return nil, errors.New(err, nil)
 	}
@@ -89,17 +93,22 @@ func NewNamedSimpleStats(name string, value interface{}) (*NamedSimpleStats, err
 
 // Update --
 func (n *NamedSimpleStatsList) Update(name string, value interface{}) error {
+	return n.UpdateWeighted(name, value, 1)
+}
+
+// UpdateWeighted will update the named stats with a value that counts `weight` times
+func (n *NamedSimpleStatsList) UpdateWeighted(name string, value interface{}, weight int64) error {
 	var exists bool
 	for _, ns := range n.List {
 		if ns.Name == name {
-			ns.Stats.Update(value)
+			ns.Stats.UpdateWeighted(value, weight)
 			exists = true
 			break
 		}
 	}
 
 	if !exists {
-		newStats, err := NewNamedSimpleStats(name, value)
+		newStats, err := newNamedSimpleStats(name, value, weight)
 		if err != nil {
 			return errors.New(err, nil)
 		}
@@ -0,0 +1,75 @@
+package types
+
+import "sort"
+
+// CampaignStats holds the sessions, conversions and revenue
+// of the sessions first touched by a source/medium/campaign
+type CampaignStats struct {
+	Source         string  `json:"source"`
+	Medium         string  `json:"medium"`
+	Campaign       string  `json:"campaign"`
+	Channel        string  `json:"channel"`
+	Sessions       int64   `json:"sessions"`
+	Conversions    int64   `json:"conversions"`
+	ConversionRate float64 `json:"conversionRate"` // conversions per session
+	Revenue        float64 `json:"revenue"`
+}
+
+// CampaignStatsList --
+type CampaignStatsList struct {
+	List []*CampaignStats
+}
+
+// NewCampaignStatsList --
+func NewCampaignStatsList() *CampaignStatsList {
+	return &CampaignStatsList{
+		List: make([]*CampaignStats, 0),
+	}
+}
+
+// Update will add the (expired) session to the stats of its first-touch campaign.
+// Sessions without a campaign are counted as direct sessions.
+func (c *CampaignStatsList) Update(sess *UserSession) {
+	campaign := sess.Campaign
+	if campaign == nil {
+		campaign = &Campaign{
+			Source:  DirectSource,
+			Medium:  NoneMedium,
+			Channel: ChannelDirect,
+		}
+	}
+
+	var stats *CampaignStats
+	for _, cs := range c.List {
+		if cs.Source == campaign.Source && cs.Medium == campaign.Medium && cs.Campaign == campaign.Name {
+			stats = cs
+			break
+		}
+	}
+
+	if stats == nil {
+		stats = &CampaignStats{
+			Source:   campaign.Source,
+			Medium:   campaign.Medium,
+			Campaign: campaign.Name,
+			Channel:  campaign.Channel,
+		}
+		c.List = append(c.List, stats)
+	}
+
+	stats.Sessions++
+	stats.Conversions += sess.Conversions
+	stats.Revenue += sess.Value
+	stats.ConversionRate = float64(stats.Conversions) / float64(stats.Sessions)
+}
+
+// Sorted will return the stats ordered by sessions (descending)
+func (c *CampaignStatsList) Sorted() []*CampaignStats {
+	list := make([]*CampaignStats, len(c.List))
+	copy(list, c.List)
+	sort.SliceStable(list, func(a, b int) bool {
+		return list[a].Sessions > list[b].Sessions
+	})
+
+	return list
+}
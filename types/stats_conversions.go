@@ -5,10 +5,13 @@ import (
 	"github.com/gofrs/uuid"
 )
 
-// UnitMetrics is calculated from conversion event types
-// and revenue is calculated from amount property on conversion event types.
-// If there are no conversion event types then UnitMetrics will be zeroed.
-// If there are no amount property keys then revenue results will be zeroed.
+// UnitMetrics is calculated from the goal actions (see Goal)
+// and revenue is calculated from the (signed) values of the goals.
+// Goals with a negative sign (e.g. refunds) reduce the revenue but
+// are not counted as conversions.
+// Sampled conversions count 1/rate times (see SampleRule).
+// If there are no goal actions then UnitMetrics will be zeroed.
+// If there are no value properties then revenue results will be zeroed.
 type UnitMetrics struct {
 	TotalConversions      int64        `json:"totalConversions"`
 	TotalRevenue          float64      `json:"totalRevenue"`
@@ -19,20 +22,26 @@ type UnitMetrics struct {
 // ConversionStats --
 type ConversionStats struct {
 	Endpoint    uuid.UUID    `json:"endpoint"`
+	Goal        string       `json:"goal,omitempty"`
+	Currency    string       `json:"currency,omitempty"`
 	TotalValue  float64      `json:"value"`
 	AmountStats *SimpleStats `json:"amountStats"`
 }
 
 // NewConversionStats --
 func NewConversionStats(endpoint uuid.UUID, amount float64) (*ConversionStats, error) {
-	stats, err := NewSimpleStats(amount)
+	return newConversionStats(endpoint, amount, 1)
+}
+
+func newConversionStats(endpoint uuid.UUID, amount float64, weight int64) (*ConversionStats, error) {
+	stats, err := NewWeightedSimpleStats(amount, weight)
 	if err != nil {
 		return nil, errors.New(err, nil)
 	}
 
 	return &ConversionStats{
 		Endpoint:    endpoint,
-		TotalValue:  amount,
+		TotalValue:  amount * float64(weight),
 		AmountStats: stats,
 	}, nil
 }
@@ -51,41 +60,31 @@ func NewConversionStatsList() *ConversionStatsList {
 
 // NewUnitMetrics --
 func NewUnitMetrics(i *Interaction) (*UnitMetrics, error) {
-	if *i.Action != Conversion {
+	goal := i.Goal()
+	if goal == nil {
 		return nil, nil
 	}
 
-	var amount float64
-	if i.Properties != nil {
-		a, ok := i.Properties["amount"]
-		if ok {
-			f, ok := a.(float64)
-			if ok {
-				amount = f
-			}
-		}
-	}
-
-	amountStats, err := NewSimpleStats(amount)
+	u := &UnitMetrics{}
+	err := u.Update(i, 1)
 	if err != nil {
 		return nil, errors.New(err, map[string]interface{}{
-			"amount":    amount,
+			"goal":      goal.Action,
 			"timestamp": i.CreatedAt,
 		})
 	}
 
-	return &UnitMetrics{
-		TotalConversions:      1,
-		TotalRevenue:          amount,
-		AverageRevenuePerUser: &amount,
-		AmountStats:           amountStats,
-	}, nil
+	return u, nil
 }
 
 // Update --
 func (c *ConversionStats) Update(amount float64) error {
-	c.TotalValue += amount
-	err := c.AmountStats.Update(amount)
+	return c.update(amount, 1)
+}
+
+func (c *ConversionStats) update(amount float64, weight int64) error {
+	c.TotalValue += amount * float64(weight)
+	err := c.AmountStats.UpdateWeighted(amount, weight)
 	if err != nil {
 		return errors.New(err, nil)
 	}
@@ -96,49 +95,52 @@ func (c *ConversionStats) Update(amount float64) error {
 // Update --
 func (c *ConversionStatsList) Update(event *Event) error {
 	i := event.Interaction
-	if *i.Action == Conversion {
-		if i.Properties != nil {
-			a, ok := i.Properties["amount"]
-			if ok {
-				amount, ok := a.(float64)
-				if ok {
-					for _, stats := range c.List {
-						if stats.Endpoint == event.Endpoint {
-							err := stats.Update(amount)
-							if err != nil {
-								return errors.New(err, nil)
-							}
-							return nil
-						}
-					}
-
-					newConversionStats, err := NewConversionStats(event.Endpoint, amount)
-					if err != nil {
-						return errors.New(err, nil)
-					}
-					c.List = append(c.List, newConversionStats)
-				}
+	goal := i.Goal()
+	if goal == nil {
+		return nil
+	}
+
+	amount, ok := goal.Value(i)
+	if !ok {
+		return nil
+	}
+
+	for _, stats := range c.List {
+		if stats.Endpoint == event.Endpoint {
+			stats.Goal = goal.Action
+			stats.Currency = goal.Currency
+			err := stats.update(amount, i.Weight())
+			if err != nil {
+				return errors.New(err, nil)
 			}
+			return nil
 		}
 	}
 
+	newConversionStats, err := newConversionStats(event.Endpoint, amount, i.Weight())
+	if err != nil {
+		return errors.New(err, nil)
+	}
+	newConversionStats.Goal = goal.Action
+	newConversionStats.Currency = goal.Currency
+	c.List = append(c.List, newConversionStats)
+
 	return nil
 }
 
 // SimpleUpdate --
 func (u *UnitMetrics) SimpleUpdate(i *Interaction) {
-	if *i.Action == Conversion {
-		u.TotalConversions++
-
-		a, ok := i.Properties["amount"]
-		if ok {
-			amount, ok := a.(float64)
-			if ok {
-				u.TotalRevenue += amount
-				u.AmountStats.Update(amount)
-			}
-		}
+	goal := i.Goal()
+	if goal == nil {
+		return
 	}
+
+	value, ok := goal.Value(i)
+	var conversions int64
+	if goal.Positive() {
+		conversions = i.Weight()
+	}
+	u.add(value, ok, conversions, i.Weight(), 0)
 }
 
 // SessionUpdate --
@@ -150,30 +152,46 @@ func (u *UnitMetrics) SessionUpdate(s *UserSession) error {
 
 // Update --
 func (u *UnitMetrics) Update(i *Interaction, users int64) error {
-	if *i.Action == Conversion {
-		u.TotalConversions++
-
-		if i.Properties != nil {
-			a, ok := i.Properties["amount"]
-			if ok {
-				amount, ok := a.(float64)
-				if ok {
-					u.TotalRevenue += amount
-					arpu := u.TotalRevenue / float64(users)
-					u.AverageRevenuePerUser = &arpu
-					if u.AmountStats == nil {
-						amountStats, err := NewSimpleStats(amount)
-						if err != nil {
-							return errors.New(err, nil)
-						}
-						u.AmountStats = amountStats
-					} else {
-						u.AmountStats.Update(amount)
-					}
-				}
-			}
+	goal := i.Goal()
+	if goal == nil {
+		return nil
+	}
+
+	value, ok := goal.Value(i)
+	var conversions int64
+	if goal.Positive() {
+		conversions = i.Weight()
+	}
+	return u.add(value, ok, conversions, i.Weight(), users)
+}
+
+// update will add a conversion of a single goal (every conversion is counted)
+func (u *UnitMetrics) update(value float64, ok bool, weight, users int64) error {
+	return u.add(value, ok, weight, weight, users)
+}
+
+// add will add the conversions and, if the interaction has one, the value
+// of a goal interaction. The revenue per user is only computed if there are users.
+func (u *UnitMetrics) add(value float64, ok bool, conversions, weight, users int64) error {
+	u.TotalConversions += conversions
+	if !ok {
+		return nil
+	}
+
+	u.TotalRevenue += value * float64(weight)
+	if users > 0 {
+		arpu := u.TotalRevenue / float64(users)
+		u.AverageRevenuePerUser = &arpu
+	}
+
+	if u.AmountStats == nil {
+		amountStats, err := NewWeightedSimpleStats(value, weight)
+		if err != nil {
+			return errors.New(err, nil)
 		}
+		u.AmountStats = amountStats
+		return nil
 	}
 
-	return nil
+	return u.AmountStats.UpdateWeighted(value, weight)
 }
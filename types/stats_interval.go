@@ -25,18 +25,23 @@ type Updater interface {
 
 // IntervalStats --
 type IntervalStats struct {
-	ID       *UUID     `json:"id"`
-	Interval string    `json:"interval"`
-	Start    time.Time `json:"start"`
-	End      time.Time `json:"end"`
-	Stats    Updater   `json:"stats"`
+	ID        *UUID     `json:"id"`
+	Interval  string    `json:"interval"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Stats     Updater   `json:"stats"`
+	Estimated bool      `json:"estimated"`        // some stats are scaled from sampled interactions
+	Closed    bool      `json:"closed,omitempty"` // the period ended and the stats were archived
 }
 
 // IntervalStatsList --
 type IntervalStatsList struct {
-	Type    string
-	index   map[uint32]*IntervalStats
-	updated map[uint32]*IntervalStats
+	Type     string
+	index    map[uint32]*IntervalStats
+	updated  map[uint32]*IntervalStats
+	flushed  map[uint32]*IntervalStats // written by a flush that is not committed yet
+	closed   []*IntervalStats          // replaced by the stats of their next period, not archived yet
+	previous map[uint32]*IntervalStats // the last closed stats of every document (for late interactions)
 	*sync.Mutex
 }
 
@@ -54,10 +59,13 @@ type AllIntervalStats struct {
 // NewIntervalStatsList --
 func NewIntervalStatsList(objectType string) *IntervalStatsList {
 	return &IntervalStatsList{
-		Type:    objectType,
-		index:   make(map[uint32]*IntervalStats),
-		updated: make(map[uint32]*IntervalStats),
-		Mutex:   &sync.Mutex{},
+		Type:     objectType,
+		index:    make(map[uint32]*IntervalStats),
+		updated:  make(map[uint32]*IntervalStats),
+		flushed:  make(map[uint32]*IntervalStats),
+		closed:   make([]*IntervalStats, 0),
+		previous: make(map[uint32]*IntervalStats),
+		Mutex:    &sync.Mutex{},
 	}
 }
 
@@ -100,8 +108,8 @@ func NewIntervalStats(event *Event, interval string, objectType string) (*Interv
 		start = temporal.DayStart(*i.CreatedAt)
 		end = temporal.DayFinish(*i.CreatedAt)
 	case Weekly:
-		start = temporal.WeekStart(*i.CreatedAt)
-		end = temporal.WeekFinish(*i.CreatedAt)
+		start = weekStart(*i.CreatedAt)
+		end = weekFinish(*i.CreatedAt)
 	case Monthly:
 		start = temporal.MonthStart(*i.CreatedAt)
 		end = temporal.MonthFinish(*i.CreatedAt)
@@ -117,11 +125,12 @@ func NewIntervalStats(event *Event, interval string, objectType string) (*Interv
 	}
 
 	return &IntervalStats{
-		ID:       &UUID{id},
-		Interval: interval,
-		Start:    start,
-		End:      end,
-		Stats:    updater,
+		ID:        &UUID{id},
+		Interval:  interval,
+		Start:     start,
+		End:       end,
+		Stats:     updater,
+		Estimated: i.Weight() > 1,
 	}, nil
 }
 
@@ -193,7 +202,38 @@ func (i *IntervalStatsList) Apply(event *Event) error {
 			if err != nil {
 				return errors.New(err, nil)
 			}
+			if !stats.Closed {
+				stats.Closed = true
+				i.archive(stats)
+			}
+			i.previous[hashedKey] = stats
+			i.index[hashedKey] = newStats
 			stats = newStats
+		} else if stats.Closed || cat.Before(stats.Start) {
+			// an interaction that arrives after its period was closed is counted in the closed
+			// stats of its period, which are archived again (never in the stats of the next period).
+			// The interactions of the periods before the last closed period are only stored.
+			closed := i.closedPeriod(hashedKey, stats, cat)
+			if closed == nil {
+				continue
+			}
+
+			err = closed.Stats.Update(event)
+			if err != nil {
+				return errors.New(err, map[string]interface{}{
+					"interval": interval,
+					"id":       id,
+				})
+			}
+			if event.Interaction.Weight() > 1 {
+				closed.Estimated = true
+			}
+			i.archive(closed)
+
+			// the stats of the previous period are no longer the document
+			if closed != stats {
+				continue
+			}
 		} else {
 			err = stats.Stats.Update(event)
 			if err != nil {
@@ -202,6 +242,9 @@ func (i *IntervalStatsList) Apply(event *Event) error {
 					"id":       id,
 				})
 			}
+			if event.Interaction.Weight() > 1 {
+				stats.Estimated = true
+			}
 		}
 		i.updated[hashedKey] = stats
 	}
@@ -240,12 +283,138 @@ func (i *IntervalStatsList) Update(updateFunc func(object interface{}) error) er
 		if err != nil {
 			return errors.New(err, nil)
 		}
+		i.flushed[id] = es
 		delete(i.updated, id)
 	}
 
 	return nil
 }
 
+// Committed will forget the documents of the flush once it is committed
+func (i *IntervalStatsList) Committed() {
+	i.Lock()
+	defer i.Unlock()
+
+	i.flushed = make(map[uint32]*IntervalStats)
+}
+
+// Rollback will mark the documents of a flush that was not committed
+// as updated again, so that they are written by the next flush
+func (i *IntervalStatsList) Rollback() {
+	i.Lock()
+	defer i.Unlock()
+
+	for id, es := range i.flushed {
+		i.updated[id] = es
+	}
+	i.flushed = make(map[uint32]*IntervalStats)
+}
+
+// Closed will call the function for every stats that were replaced by the stats of their next period
+// since they were last archived. It stops at the first stats that can not be archived.
+func (i *IntervalStatsList) Closed(archiveFunc func(object interface{}) error) error {
+	i.Lock()
+	defer i.Unlock()
+
+	for _, stats := range i.closed {
+		err := archiveFunc(stats)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+	}
+
+	return nil
+}
+
+// Rollover will close the stats that ended before the time without waiting for the next
+// interaction of their object. They are kept (as the last period) until then.
+// It returns the number of closed stats.
+func (i *IntervalStatsList) Rollover(now time.Time) int {
+	i.Lock()
+	defer i.Unlock()
+
+	var n int
+	for key, stats := range i.index {
+		if stats.Interval == AllTime || stats.Closed || !now.After(stats.End) {
+			continue
+		}
+
+		stats.Closed = true
+		i.archive(stats)
+		i.updated[key] = stats
+		n++
+	}
+
+	return n
+}
+
+// closedPeriod will return the closed stats of the document (the current stats, or the
+// stats of the previous period) whose period holds the time, or nil if there are none
+func (i *IntervalStatsList) closedPeriod(key uint32, stats *IntervalStats, t time.Time) *IntervalStats {
+	if stats.Closed && PeriodContains(stats, t) {
+		return stats
+	}
+	if previous, ok := i.previous[key]; ok && PeriodContains(previous, t) {
+		return previous
+	}
+
+	return nil
+}
+
+// archive will add the closed stats to the stats that are archived by the next flush (once)
+func (i *IntervalStatsList) archive(stats *IntervalStats) {
+	for _, closed := range i.closed {
+		if closed == stats {
+			return
+		}
+	}
+	i.closed = append(i.closed, stats)
+}
+
+// Archived will forget the closed stats once they are archived
+func (i *IntervalStatsList) Archived() {
+	i.Lock()
+	defer i.Unlock()
+
+	i.closed = make([]*IntervalStats, 0)
+}
+
+// Expired will return the stats of the list that expired (see Expire)
+func (i *IntervalStatsList) Expired(expired func(stats *IntervalStats) bool) []*IntervalStats {
+	i.Lock()
+	defer i.Unlock()
+
+	list := make([]*IntervalStats, 0)
+	for _, stats := range i.index {
+		if expired(stats) {
+			list = append(list, stats)
+		}
+	}
+
+	return list
+}
+
+// Expire will remove the expired stats from the list (once their removal is committed)
+func (i *IntervalStatsList) Expire(list []*IntervalStats) {
+	i.Lock()
+	defer i.Unlock()
+
+	removed := make(map[*IntervalStats]struct{})
+	for _, stats := range list {
+		removed[stats] = struct{}{}
+	}
+
+	for key, stats := range i.index {
+		if _, ok := removed[stats]; !ok {
+			continue
+		}
+
+		delete(i.index, key)
+		delete(i.updated, key)
+		delete(i.previous, key)
+	}
+}
+
 // AllIntervalStats --
 func (i *IntervalStatsList) AllIntervalStats(id string) (*AllIntervalStats, error) {
 	ais := &AllIntervalStats{}
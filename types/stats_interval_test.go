@@ -0,0 +1,258 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+func testEvent(id uuid.UUID, createdAt time.Time) *Event {
+	action, user, userType := "view", "u-1", "visitor"
+	return &Event{
+		Interaction: &Interaction{
+			Action:    &action,
+			UserType:  &userType,
+			UserID:    &user,
+			CreatedAt: &createdAt,
+			Properties: map[string]interface{}{
+				"plan": "pro",
+			},
+		},
+		Endpoint: id,
+	}
+}
+
+// lateStep is an interaction (or a rollover) of the late interaction tests
+type lateStep struct {
+	at       time.Time
+	rollover bool
+}
+
+func TestIntervalStatsListLateInteraction(t *testing.T) {
+	boundary := time.Date(2026, 10, 18, 11, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		steps        []lateStep
+		wantCurrent  int64     // the total of the current hourly stats
+		wantStart    time.Time // the start of the current hourly stats
+		wantArchived []int64   // the totals of the hourly stats to archive
+	}{
+		{
+			name: "after the rollover",
+			steps: []lateStep{
+				{at: boundary.Add(-2 * time.Second)},
+				{at: boundary.Add(5 * time.Second), rollover: true},
+				{at: boundary.Add(-time.Second)},
+			},
+			wantCurrent:  2,
+			wantStart:    boundary.Add(-time.Hour),
+			wantArchived: []int64{2},
+		},
+		{
+			name: "after the next period started",
+			steps: []lateStep{
+				{at: boundary.Add(-2 * time.Second)},
+				{at: boundary.Add(5 * time.Second), rollover: true},
+				{at: boundary.Add(10 * time.Second)},
+				{at: boundary.Add(-time.Second)},
+			},
+			wantCurrent:  1,
+			wantStart:    boundary,
+			wantArchived: []int64{2},
+		},
+		{
+			name: "before the last closed period",
+			steps: []lateStep{
+				{at: boundary.Add(-2 * time.Second)},
+				{at: boundary.Add(10 * time.Second)},
+				{at: boundary.Add(-2 * time.Hour)},
+			},
+			wantCurrent:  1,
+			wantStart:    boundary,
+			wantArchived: []int64{1},
+		},
+		{
+			name: "without a rollover",
+			steps: []lateStep{
+				{at: boundary.Add(-2 * time.Second)},
+				{at: boundary.Add(-time.Second)},
+			},
+			wantCurrent: 2,
+			wantStart:   boundary.Add(-time.Hour),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := uuid.Must(uuid.NewV4())
+			list := NewIntervalStatsList(EndpointObjectType)
+
+			for _, step := range tt.steps {
+				if step.rollover {
+					list.Rollover(step.at)
+					continue
+				}
+
+				err := list.Apply(testEvent(id, step.at))
+				if err != nil {
+					t.Fatalf("Apply(%v) error = %v", step.at, err)
+				}
+			}
+
+			current, err := list.Get(id.String(), Hourly)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total := current.Stats.(*EndpointProfile).Total; total != tt.wantCurrent {
+				t.Errorf("current total = %d, want %d", total, tt.wantCurrent)
+			}
+			if !current.Start.Equal(tt.wantStart) {
+				t.Errorf("current start = %v, want %v", current.Start, tt.wantStart)
+			}
+
+			var archived []int64
+			err = list.Closed(func(object interface{}) error {
+				if stats := object.(*IntervalStats); stats.Interval == Hourly {
+					archived = append(archived, stats.Stats.(*EndpointProfile).Total)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(archived) != len(tt.wantArchived) {
+				t.Fatalf("archived totals = %v, want %v", archived, tt.wantArchived)
+			}
+			for idx := range archived {
+				if archived[idx] != tt.wantArchived[idx] {
+					t.Errorf("archived totals = %v, want %v", archived, tt.wantArchived)
+				}
+			}
+		})
+	}
+}
+
+func TestIntervalStatsListRearchive(t *testing.T) {
+	boundary := time.Date(2026, 10, 18, 11, 0, 0, 0, time.UTC)
+	id := uuid.Must(uuid.NewV4())
+	list := NewIntervalStatsList(EndpointObjectType)
+
+	hourly := func() []*IntervalStats {
+		closed := make([]*IntervalStats, 0)
+		list.Closed(func(object interface{}) error {
+			if stats := object.(*IntervalStats); stats.Interval == Hourly {
+				closed = append(closed, stats)
+			}
+			return nil
+		})
+		return closed
+	}
+
+	list.Apply(testEvent(id, boundary.Add(-2*time.Second)))
+	list.Apply(testEvent(id, boundary.Add(10*time.Second)))
+	if n := len(hourly()); n != 1 {
+		t.Fatalf("closed = %d, want 1", n)
+	}
+
+	// the closed period was archived
+	list.Archived()
+	if n := len(hourly()); n != 0 {
+		t.Fatalf("closed after archiving = %d, want 0", n)
+	}
+
+	// a late interaction archives it again
+	list.Apply(testEvent(id, boundary.Add(-time.Second)))
+	closed := hourly()
+	if len(closed) != 1 {
+		t.Fatalf("closed after a late interaction = %d, want 1", len(closed))
+	}
+	if total := closed[0].Stats.(*EndpointProfile).Total; total != 2 {
+		t.Errorf("closed total = %d, want 2", total)
+	}
+}
+
+func TestPropertyStatsListLateInteraction(t *testing.T) {
+	boundary := time.Date(2026, 10, 18, 11, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		steps        []lateStep
+		wantCurrent  int64
+		wantArchived []int64
+	}{
+		{
+			name: "after the rollover",
+			steps: []lateStep{
+				{at: boundary.Add(-2 * time.Second)},
+				{at: boundary.Add(5 * time.Second), rollover: true},
+				{at: boundary.Add(-time.Second)},
+			},
+			wantCurrent:  2,
+			wantArchived: []int64{2},
+		},
+		{
+			name: "after the next period started",
+			steps: []lateStep{
+				{at: boundary.Add(-2 * time.Second)},
+				{at: boundary.Add(10 * time.Second)},
+				{at: boundary.Add(-time.Second)},
+			},
+			wantCurrent:  1,
+			wantArchived: []int64{2},
+		},
+		{
+			name: "before the last closed period",
+			steps: []lateStep{
+				{at: boundary.Add(-2 * time.Second)},
+				{at: boundary.Add(10 * time.Second)},
+				{at: boundary.Add(-2 * time.Hour)},
+			},
+			wantCurrent:  1,
+			wantArchived: []int64{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := NewPropertyStatsList()
+
+			for _, step := range tt.steps {
+				if step.rollover {
+					list.Rollover(step.at)
+					continue
+				}
+
+				err := list.Apply(testEvent(uuid.Nil, step.at))
+				if err != nil {
+					t.Fatalf("Apply(%v) error = %v", step.at, err)
+				}
+			}
+
+			current, err := list.Get("plan", Hourly)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if current.Stats.Total != tt.wantCurrent {
+				t.Errorf("current total = %d, want %d", current.Stats.Total, tt.wantCurrent)
+			}
+
+			var archived []int64
+			list.Closed(func(object interface{}) error {
+				if stats := object.(*PropertyStats); stats.SpanType == Hourly {
+					archived = append(archived, stats.Stats.Total)
+				}
+				return nil
+			})
+			if len(archived) != len(tt.wantArchived) {
+				t.Fatalf("archived totals = %v, want %v", archived, tt.wantArchived)
+			}
+			for idx := range archived {
+				if archived[idx] != tt.wantArchived[idx] {
+					t.Errorf("archived totals = %v, want %v", archived, tt.wantArchived)
+				}
+			}
+		})
+	}
+}
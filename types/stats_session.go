@@ -4,17 +4,27 @@ import "github.com/JKhawaja/errors"
 
 // SessionStats --
 type SessionStats struct {
-	UserType        string       `json:"userType"`
-	DeviceType      string       `json:"deviceType"`
-	SessionType     string       `json:"sessionType"`
-	Count           int64        `json:"count"`
-	Percentage      float64      `json:"percentage"`
-	Duration        *SimpleStats `json:"durationStats"`
-	Interactions    *SimpleStats `json:"interactionStats"`
-	Conversions     int64        `json:"conversions"`
-	ConversionRate  float64      `json:"conversionRate"`
-	BouncedSessions int64        `json:"bouncedSessions"`
-	BounceRate      float64      `json:"bounceRate"`
+	UserType        string              `json:"userType"`
+	DeviceType      string              `json:"deviceType"`
+	SessionType     string              `json:"sessionType"`
+	Count           int64               `json:"count"`
+	Percentage      float64             `json:"percentage"`
+	Duration        *SimpleStats        `json:"durationStats"`
+	Interactions    *SimpleStats        `json:"interactionStats"`
+	Conversions     int64               `json:"conversions"`
+	ConversionRate  float64             `json:"conversionRate"` // conversions per session
+	GoalStats       []*SessionGoalStats `json:"goalStats,omitempty"`
+	BouncedSessions int64               `json:"bouncedSessions"`
+	BounceRate      float64             `json:"bounceRate"`
+}
+
+// SessionGoalStats are the stats of the sessions that converted on a goal
+type SessionGoalStats struct {
+	Goal           string  `json:"goal"`
+	Sessions       int64   `json:"sessions"` // converted sessions
+	Conversions    int64   `json:"conversions"`
+	Value          float64 `json:"value"`
+	ConversionRate float64 `json:"conversionRate"` // converted sessions / sessions
 }
 
 // SessionStatsList --
@@ -46,16 +56,47 @@ func NewSessionStats(sess *UserSession) (*SessionStats, error) {
 		bouncedSessions++
 	}
 
-	return &SessionStats{
+	stats := &SessionStats{
 		UserType:        sess.UserType,
 		DeviceType:      sess.DeviceType,
 		SessionType:     sess.Type,
 		Count:           1,
 		Duration:        durationStats,
 		Interactions:    interactionStats,
+		Conversions:     sess.Conversions,
+		ConversionRate:  float64(sess.Conversions),
 		BouncedSessions: bouncedSessions,
 		BounceRate:      float64(bouncedSessions) / 1.0,
-	}, nil
+	}
+	stats.updateGoals(sess)
+
+	return stats, nil
+}
+
+// updateGoals will add the goals of the session, and update the
+// goal conversion rates for the current number of sessions.
+func (u *SessionStats) updateGoals(sess *UserSession) {
+	for _, g := range sess.Goals {
+		var stats *SessionGoalStats
+		for _, gs := range u.GoalStats {
+			if gs.Goal == g.Goal {
+				stats = gs
+				break
+			}
+		}
+		if stats == nil {
+			stats = &SessionGoalStats{Goal: g.Goal}
+			u.GoalStats = append(u.GoalStats, stats)
+		}
+
+		stats.Sessions++
+		stats.Conversions += g.Conversions
+		stats.Value += g.Value
+	}
+
+	for _, stats := range u.GoalStats {
+		stats.ConversionRate = float64(stats.Sessions) / float64(u.Count)
+	}
 }
 
 // Update --
@@ -68,7 +109,8 @@ func (u *SessionStats) Update(sess *UserSession) {
 		u.BouncedSessions++
 	}
 	u.BounceRate = float64(u.BouncedSessions) / float64(u.Count)
-	u.ConversionRate = float64(u.Count) / float64(u.Conversions)
+	u.ConversionRate = float64(u.Conversions) / float64(u.Count)
+	u.updateGoals(sess)
 }
 
 // SimpleUpdate --
@@ -79,11 +121,17 @@ func (u *SessionStats) SimpleUpdate(sess *UserSession) {
 		u.BouncedSessions++
 	}
 	u.BounceRate = float64(u.BouncedSessions) / float64(u.Count)
-	u.ConversionRate = float64(u.Count) / float64(u.Conversions)
+	u.ConversionRate = float64(u.Conversions) / float64(u.Count)
+	u.updateGoals(sess)
 }
 
 // SimpleUpdate --
 func (s *SessionStatsList) SimpleUpdate(sess *UserSession) error {
+	// anonymous interactions have no session
+	if sess == nil {
+		return nil
+	}
+
 	// update stats if exists
 	var exists bool
 	var total int64
@@ -116,6 +164,11 @@ func (s *SessionStatsList) SimpleUpdate(sess *UserSession) error {
 
 // Update --
 func (u *SessionStatsList) Update(sess *UserSession) error {
+	// anonymous interactions have no session
+	if sess == nil {
+		return nil
+	}
+
 	// update stats if exists
 	var exists bool
 	var total int64
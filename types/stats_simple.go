@@ -12,13 +12,14 @@ import (
 
 // SimpleStats --
 type SimpleStats struct {
-	Type     string              `json:"type"`
-	Total    int64               `json:"total"`
-	Mean     interface{}         `json:"mean"`
-	Mode     interface{}         `json:"mode"`
-	Values   []*SimpleValueStats `json:"values"`
-	Variance sam.SliceFloat64    `json:"variance"`
-	StdDev   sam.SliceFloat64    `json:"std_dev"`
+	Type      string              `json:"type"`
+	Total     int64               `json:"total"`
+	Mean      interface{}         `json:"mean"`
+	Mode      interface{}         `json:"mode"`
+	Values    []*SimpleValueStats `json:"values"`
+	Variance  sam.SliceFloat64    `json:"variance"`
+	StdDev    sam.SliceFloat64    `json:"std_dev"`
+	Estimated bool                `json:"estimated,omitempty"` // counts include sampled (scaled) values
 }
 
 // SimpleValueStats --
@@ -117,6 +118,25 @@ func NewSimpleStats(value interface{}) (*SimpleStats, error) {
 	return s, nil
 }
 
+// NewWeightedSimpleStats will create the stats of a value that counts
+// `weight` times (e.g. a sampled interaction, see SampleRule)
+func NewWeightedSimpleStats(value interface{}, weight int64) (*SimpleStats, error) {
+	s, err := NewSimpleStats(value)
+	if err != nil {
+		return s, err
+	}
+
+	if weight > 1 {
+		s.Total = weight
+		for _, stats := range s.Values {
+			stats.Count = weight
+		}
+		s.Estimated = true
+	}
+
+	return s, nil
+}
+
 // NewSimpleValueStats --
 func NewSimpleValueStats(value interface{}) (*SimpleValueStats, error) {
 	return &SimpleValueStats{
@@ -132,11 +152,15 @@ func (s *SimpleValueStats) Update() {
 
 // Update --
 func (s *SimpleStats) UpdateValue(value interface{}) error {
+	return s.updateValue(value, 1)
+}
+
+func (s *SimpleStats) updateValue(value interface{}, weight int64) error {
 	// update value stats (if exists)
 	var exists bool
 	for _, stats := range s.Values {
 		if stats.Value == value {
-			stats.Update()
+			stats.Count += weight
 			exists = true
 			break
 		}
@@ -148,6 +172,7 @@ func (s *SimpleStats) UpdateValue(value interface{}) error {
 		if err != nil {
 			return errors.New(err, nil)
 		}
+		stats.Count = weight
 		s.Values = append(s.Values, stats)
 	}
 
@@ -219,6 +244,16 @@ func (s *SimpleStats) contains(value interface{}) bool {
 // Update will update the total and mean for the stream stats
 // with the new value.
 func (s *SimpleStats) Update(value interface{}) error {
+	return s.UpdateWeighted(value, 1)
+}
+
+// UpdateWeighted will update the stats with a value that counts `weight` times
+// (e.g. a sampled interaction, see SampleRule). The stats are then flagged as estimated.
+func (s *SimpleStats) UpdateWeighted(value interface{}, weight int64) error {
+	if weight < 1 {
+		weight = 1
+	}
+
 	switch value.(type) {
 	case string:
 		if s.Type != String {
@@ -233,10 +268,10 @@ func (s *SimpleStats) Update(value interface{}) error {
 			s.StdDev = append(s.StdDev, 0)
 		}
 
-		s.UpdateValue(v)
+		s.updateValue(v, weight)
 
 		// update stats
-		s.Total++
+		s.Total += weight
 		s.Mean = s.get(s.meanIndex()).Value
 		s.Mode = s.max()
 		sum := s.sum()
@@ -261,18 +296,34 @@ func (s *SimpleStats) Update(value interface{}) error {
 			v = float64(iv)
 		}
 
-		oldMean := s.Mean.(float64)
-		s.Mean = hMath.IsNum((float64(s.Total)*oldMean + v) / (float64(s.Total) + 1))
+		// a weighted value is the same value seen `weight` times (West's weighted
+		// incremental update of the mean and of the sum of squared differences)
+		var m2 float64
+		if s.Total > 1 {
+			m2 = s.Variance[0] * float64(s.Total-1)
+		}
+		mean := s.Mean.(float64)
+		delta := v - mean
+
+		s.Total += weight
+		mean = hMath.IsNum(mean + float64(weight)*delta/float64(s.Total))
+		m2 += float64(weight) * delta * (v - mean)
 
-		s.Total++
-		s.Variance[0] = hMath.IsNum(((float64(s.Total)-2)*s.Variance[0] + (v-s.Mean.(float64))*(v-oldMean)) / (float64(s.Total) - 1))
+		s.Mean = mean
+		if s.Total > 1 {
+			s.Variance[0] = hMath.IsNum(m2 / float64(s.Total-1))
+		}
 		s.StdDev[0] = math.Sqrt(s.Variance[0])
 
-		s.UpdateValue(value)
+		s.updateValue(value, weight)
 	default:
 		return errors.New(ErrDataType, nil)
 	}
 
+	if weight > 1 {
+		s.Estimated = true
+	}
+
 	return nil
 }
 
@@ -0,0 +1,102 @@
+package types
+
+import (
+	"math"
+	"testing"
+)
+
+// weightedValue is a value of the weighted stats tests that counts `weight` times
+type weightedValue struct {
+	value  float64
+	weight int64
+}
+
+// referenceStats will return the mean and the sample variance of the values, with
+// every value repeated `weight` times
+func referenceStats(values []weightedValue) (mean, variance float64) {
+	var n, sum float64
+	for _, v := range values {
+		n += float64(v.weight)
+		sum += float64(v.weight) * v.value
+	}
+	mean = sum / n
+
+	if n < 2 {
+		return mean, 0
+	}
+	for _, v := range values {
+		variance += float64(v.weight) * (v.value - mean) * (v.value - mean)
+	}
+
+	return mean, variance / (n - 1)
+}
+
+func TestSimpleStatsUpdateWeighted(t *testing.T) {
+	tests := []struct {
+		name          string
+		values        []weightedValue
+		wantEstimated bool
+	}{
+		{
+			name:   "unweighted",
+			values: []weightedValue{{2, 1}, {4, 1}, {4, 1}, {5, 1}, {9, 1}},
+		},
+		{
+			name:          "weighted",
+			values:        []weightedValue{{2, 1}, {4, 3}, {10, 2}},
+			wantEstimated: true,
+		},
+		{
+			name:          "weighted first value",
+			values:        []weightedValue{{3, 5}, {7, 1}, {1, 4}},
+			wantEstimated: true,
+		},
+		{
+			name:          "sample rate of 0.001",
+			values:        []weightedValue{{120, 1000}, {80, 1000}, {95.5, 1000}},
+			wantEstimated: true,
+		},
+		{
+			name:          "same value",
+			values:        []weightedValue{{6, 10}, {6, 100}},
+			wantEstimated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewWeightedSimpleStats(tt.values[0].value, tt.values[0].weight)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range tt.values[1:] {
+				err := s.UpdateWeighted(v.value, v.weight)
+				if err != nil {
+					t.Fatalf("UpdateWeighted(%v, %d) error = %v", v.value, v.weight, err)
+				}
+			}
+
+			var total int64
+			for _, v := range tt.values {
+				total += v.weight
+			}
+			if s.Total != total {
+				t.Errorf("Total = %d, want %d", s.Total, total)
+			}
+
+			wantMean, wantVariance := referenceStats(tt.values)
+			if mean := s.Mean.(float64); math.Abs(mean-wantMean) > 1e-9 {
+				t.Errorf("Mean = %v, want %v", mean, wantMean)
+			}
+			if math.Abs(s.Variance[0]-wantVariance) > 1e-6 {
+				t.Errorf("Variance = %v, want %v", s.Variance[0], wantVariance)
+			}
+			if math.Abs(s.StdDev[0]-math.Sqrt(wantVariance)) > 1e-6 {
+				t.Errorf("StdDev = %v, want %v", s.StdDev[0], math.Sqrt(wantVariance))
+			}
+			if s.Estimated != tt.wantEstimated {
+				t.Errorf("Estimated = %v, want %v", s.Estimated, tt.wantEstimated)
+			}
+		})
+	}
+}
@@ -23,7 +23,10 @@ type Summary struct {
 	Users            map[uint32]struct{}
 	SessionStats     *SessionStatsList
 	ConversionStats  *ConversionStatsList
+	CampaignStats    *CampaignStatsList
+	GoalStats        *GoalStatsList
 	UnitMetrics      *UnitMetrics
+	Estimated        bool // some stats are scaled from sampled interactions
 }
 
 // SummaryListView --
@@ -47,13 +50,19 @@ type SummaryResponse struct {
 	SessionTypeStats *SimpleStats       `json:"sessionTypeStats,omitempty"`
 	SessionStats     []*SessionStats    `json:"sessionStats,omitempty"` // seu
 	ConversionStats  []*ConversionStats `json:"conversionStats,omitempty"`
+	CampaignStats    []*CampaignStats   `json:"campaignStats,omitempty"`
+	GoalStats        []*GoalStats       `json:"goalStats,omitempty"`
 	UnitMetrics      *UnitMetrics       `json:"unitMetrics,omitempty"`
+	Estimated        bool               `json:"estimated"`
+	Previous         *SummaryComparison `json:"previous,omitempty"` // with the previous period
+	LastYear         *SummaryComparison `json:"lastYear,omitempty"` // with the same period last year
 }
 
 // NewSummary will generate a new summary for an interval type
 func NewSummary(interval string, event *Event) (*Summary, error) {
 	i := event.Interaction
 	sess := event.Session
+	w := i.Weight()
 
 	// span
 	var start, end time.Time
@@ -65,8 +74,8 @@ func NewSummary(interval string, event *Event) (*Summary, error) {
 		start = temporal.DayStart(*i.CreatedAt)
 		end = temporal.DayFinish(*i.CreatedAt)
 	case Weekly:
-		start = temporal.WeekStart(*i.CreatedAt)
-		end = temporal.WeekFinish(*i.CreatedAt)
+		start = weekStart(*i.CreatedAt)
+		end = weekFinish(*i.CreatedAt)
 	case Monthly:
 		start = temporal.MonthStart(*i.CreatedAt)
 		end = temporal.MonthFinish(*i.CreatedAt)
@@ -84,26 +93,32 @@ func NewSummary(interval string, event *Event) (*Summary, error) {
 		unitMetrics := &UnitMetrics{}
 		unitMetrics.SimpleUpdate(i)
 
+		goalStats := NewGoalStatsList()
+		goalStats.SimpleUpdate(i)
+
 		sessionStats := NewSessionStatsList()
 
 		return &Summary{
-			Start:        start,
-			End:          end,
-			Interval:     interval,
-			Total:        1,
-			UnitMetrics:  unitMetrics,
-			SessionStats: sessionStats,
+			Start:         start,
+			End:           end,
+			Interval:      interval,
+			Total:         w,
+			Estimated:     w > 1,
+			UnitMetrics:   unitMetrics,
+			SessionStats:  sessionStats,
+			CampaignStats: NewCampaignStatsList(),
+			GoalStats:     goalStats,
 		}, nil
 	}
 
-	actionStats, err := NewSimpleStats(*i.Action)
+	actionStats, err := NewWeightedSimpleStats(*i.Action, w)
 	if err != nil {
 		return nil, errors.New(err, nil)
 	}
 
 	var originTypeStats, entityTypeStats, userTypeStats, deviceTypeStats, sessionTypeStats *SimpleStats
 	if i.OriginType != nil {
-		ets, err := NewSimpleStats(*i.OriginType)
+		ets, err := NewWeightedSimpleStats(*i.OriginType, w)
 		if err != nil {
 			return nil, errors.New(err, nil)
 		}
@@ -111,7 +126,7 @@ func NewSummary(interval string, event *Event) (*Summary, error) {
 	}
 
 	if i.EntityType != nil {
-		ets, err := NewSimpleStats(*i.EntityType)
+		ets, err := NewWeightedSimpleStats(*i.EntityType, w)
 		if err != nil {
 			return nil, errors.New(err, nil)
 		}
@@ -119,7 +134,7 @@ func NewSummary(interval string, event *Event) (*Summary, error) {
 	}
 
 	if i.UserType != nil {
-		uts, err := NewSimpleStats(*i.UserType)
+		uts, err := NewWeightedSimpleStats(*i.UserType, w)
 		if err != nil {
 			return nil, errors.New(err, nil)
 		}
@@ -127,7 +142,7 @@ func NewSummary(interval string, event *Event) (*Summary, error) {
 	}
 
 	if i.DeviceType != nil {
-		dts, err := NewSimpleStats(*i.DeviceType)
+		dts, err := NewWeightedSimpleStats(*i.DeviceType, w)
 		if err != nil {
 			return nil, errors.New(err, nil)
 		}
@@ -135,23 +150,22 @@ func NewSummary(interval string, event *Event) (*Summary, error) {
 	}
 
 	if i.SessionType != nil {
-		sts, err := NewSimpleStats(*i.SessionType)
+		sts, err := NewWeightedSimpleStats(*i.SessionType, w)
 		if err != nil {
 			return nil, errors.New(err, nil)
 		}
 		sessionTypeStats = sts
 	}
 
-	// user
+	// user (anonymous interactions are not counted as unique users)
 	users := make(map[uint32]struct{})
-
-	hasher := fnv.New32a()
-	_, err = hasher.Write([]byte(i.User().String()))
-	if err != nil {
-		return nil, errors.New(err, nil)
+	if !i.Anonymous() {
+		hashedKey, err := userHash(i.User())
+		if err != nil {
+			return nil, errors.New(err, nil)
+		}
+		users[hashedKey] = struct{}{}
 	}
-	hashedKey := hasher.Sum32()
-	users[hashedKey] = struct{}{}
 
 	sessionStats := NewSessionStatsList()
 	err = sessionStats.Update(sess)
@@ -172,11 +186,18 @@ func NewSummary(interval string, event *Event) (*Summary, error) {
 		return nil, errors.New(err, nil)
 	}
 
+	goalStats := NewGoalStatsList()
+	err = goalStats.Update(i, int64(len(users)))
+	if err != nil {
+		return nil, errors.New(err, nil)
+	}
+
 	return &Summary{
 		Start:            start,
 		End:              end,
 		Interval:         interval,
-		Total:            1,
+		Total:            w,
+		Estimated:        w > 1,
 		ActionStats:      actionStats,
 		OriginTypeStats:  originTypeStats,
 		EntityTypeStats:  entityTypeStats,
@@ -186,10 +207,27 @@ func NewSummary(interval string, event *Event) (*Summary, error) {
 		Users:            users,
 		SessionStats:     sessionStats,
 		ConversionStats:  conversionStats,
+		CampaignStats:    NewCampaignStatsList(),
+		GoalStats:        goalStats,
 		UnitMetrics:      unitMetrics,
 	}, nil
 }
 
+// NewEmptySummary will generate the summary of a period without interactions
+func NewEmptySummary(interval string, start, end time.Time) *Summary {
+	return &Summary{
+		Interval:        interval,
+		Start:           start,
+		End:             end,
+		Users:           make(map[uint32]struct{}),
+		SessionStats:    NewSessionStatsList(),
+		ConversionStats: NewConversionStatsList(),
+		CampaignStats:   NewCampaignStatsList(),
+		GoalStats:       NewGoalStatsList(),
+		UnitMetrics:     &UnitMetrics{},
+	}
+}
+
 // ListView --
 func (s *Summary) ListView() *SummaryListView {
 	return &SummaryListView{
@@ -214,12 +252,20 @@ func (s *Summary) Response() *SummaryResponse {
 		SessionTypeStats: s.SessionTypeStats,
 		SessionStats:     s.SessionStats.List,
 		ConversionStats:  s.ConversionStats.List,
+		CampaignStats:    s.Campaigns(),
+		GoalStats:        s.Goals(),
 		UnitMetrics:      s.UnitMetrics,
+		Estimated:        s.Estimated,
 	}
 }
 
 // SessionExpirationUpdate --
 func (s *Summary) SessionExpirationUpdate(session *UserSession) error {
+	if s.CampaignStats == nil {
+		s.CampaignStats = NewCampaignStatsList()
+	}
+	s.CampaignStats.Update(session)
+
 	if s.Interval == AllTime {
 		return s.SessionStats.SimpleUpdate(session)
 	}
@@ -227,6 +273,30 @@ func (s *Summary) SessionExpirationUpdate(session *UserSession) error {
 	return s.SessionStats.Update(session)
 }
 
+// Campaigns will return the campaign stats of the summary (by sessions)
+func (s *Summary) Campaigns() []*CampaignStats {
+	if s.CampaignStats == nil {
+		return make([]*CampaignStats, 0)
+	}
+
+	return s.CampaignStats.Sorted()
+}
+
+// Goals will return the stats of every goal of the summary,
+// with the conversion rates of the unique users of the summary.
+func (s *Summary) Goals() []*GoalStats {
+	if s.GoalStats == nil {
+		return make([]*GoalStats, 0)
+	}
+
+	return s.GoalStats.Rates(int64(len(s.Users)))
+}
+
+// Contains will return whether or not the time is within the period of the summary
+func (s *Summary) Contains(t time.Time) bool {
+	return !t.Before(s.Start) && !t.After(s.End)
+}
+
 // Expired will return whether or not the interaction is past the
 // end time of the summary or not.
 func (s *Summary) Expired(i *Interaction) bool {
@@ -235,23 +305,36 @@ func (s *Summary) Expired(i *Interaction) bool {
 
 // Apply --
 func (s *Summary) Apply(event *Event) error {
-	s.Total++
-
 	i := event.Interaction
+	w := i.Weight()
 
-	err := s.ActionStats.Update(*i.Action)
-	if err != nil {
-		return errors.New(err, nil)
+	s.Total += w
+	if w > 1 {
+		s.Estimated = true
+	}
+
+	// an empty summary (see NewEmptySummary) has no action stats yet
+	if s.ActionStats == nil {
+		actionStats, err := NewWeightedSimpleStats(*i.Action, w)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+		s.ActionStats = actionStats
+	} else {
+		err := s.ActionStats.UpdateWeighted(*i.Action, w)
+		if err != nil {
+			return errors.New(err, nil)
+		}
 	}
 
 	if i.OriginType != nil {
 		if s.OriginTypeStats != nil {
-			err := s.OriginTypeStats.Update(*i.OriginType)
+			err := s.OriginTypeStats.UpdateWeighted(*i.OriginType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
 		} else {
-			ets, err := NewSimpleStats(*i.OriginType)
+			ets, err := NewWeightedSimpleStats(*i.OriginType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
@@ -261,12 +344,12 @@ func (s *Summary) Apply(event *Event) error {
 
 	if i.EntityType != nil {
 		if s.EntityTypeStats != nil {
-			err := s.EntityTypeStats.Update(*i.EntityType)
+			err := s.EntityTypeStats.UpdateWeighted(*i.EntityType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
 		} else {
-			ets, err := NewSimpleStats(*i.EntityType)
+			ets, err := NewWeightedSimpleStats(*i.EntityType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
@@ -276,12 +359,12 @@ func (s *Summary) Apply(event *Event) error {
 
 	if i.UserType != nil {
 		if s.UserTypeStats != nil {
-			err := s.UserTypeStats.Update(*i.UserType)
+			err := s.UserTypeStats.UpdateWeighted(*i.UserType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
 		} else {
-			ets, err := NewSimpleStats(*i.UserType)
+			ets, err := NewWeightedSimpleStats(*i.UserType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
@@ -291,12 +374,12 @@ func (s *Summary) Apply(event *Event) error {
 
 	if i.DeviceType != nil {
 		if s.DeviceTypeStats != nil {
-			err := s.DeviceTypeStats.Update(*i.DeviceType)
+			err := s.DeviceTypeStats.UpdateWeighted(*i.DeviceType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
 		} else {
-			ets, err := NewSimpleStats(*i.DeviceType)
+			ets, err := NewWeightedSimpleStats(*i.DeviceType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
@@ -306,12 +389,12 @@ func (s *Summary) Apply(event *Event) error {
 
 	if i.SessionType != nil {
 		if s.SessionTypeStats != nil {
-			err := s.SessionTypeStats.Update(*i.SessionType)
+			err := s.SessionTypeStats.UpdateWeighted(*i.SessionType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
 		} else {
-			ets, err := NewSimpleStats(*i.SessionType)
+			ets, err := NewWeightedSimpleStats(*i.SessionType, w)
 			if err != nil {
 				return errors.New(err, nil)
 			}
@@ -319,12 +402,28 @@ func (s *Summary) Apply(event *Event) error {
 		}
 	}
 
+	if s.GoalStats == nil {
+		s.GoalStats = NewGoalStatsList()
+	}
+
 	if s.Interval == AllTime {
 		s.UnitMetrics.SimpleUpdate(i)
+		s.GoalStats.SimpleUpdate(i)
 		return nil
 	}
 
-	err = s.ConversionStats.Update(event)
+	if s.Users == nil {
+		s.Users = make(map[uint32]struct{})
+	}
+	if !i.Anonymous() {
+		hashedKey, err := userHash(i.User())
+		if err != nil {
+			return errors.New(err, nil)
+		}
+		s.Users[hashedKey] = struct{}{}
+	}
+
+	err := s.ConversionStats.Update(event)
 	if err != nil {
 		return err
 	}
@@ -342,5 +441,83 @@ func (s *Summary) Apply(event *Event) error {
 		}
 	}
 
+	err = s.GoalStats.Update(i, int64(len(s.Users)))
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
+
+// MergeUsers will replace the `from` user with the `to` user in the
+// set of unique users of the summary.
+func (s *Summary) MergeUsers(from, to User) error {
+	if s.Users == nil {
+		return nil
+	}
+
+	fromKey, err := userHash(from)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	if _, ok := s.Users[fromKey]; !ok {
+		return nil
+	}
+
+	toKey, err := userHash(to)
+	if err != nil {
+		return errors.New(err, nil)
+	}
+
+	delete(s.Users, fromKey)
+	s.Users[toKey] = struct{}{}
+
+	if s.GoalStats != nil {
+		for _, stats := range s.GoalStats.List {
+			if _, ok := stats.Users[fromKey]; ok {
+				delete(stats.Users, fromKey)
+				stats.Users[toKey] = struct{}{}
+			}
+		}
+	}
+
+	return nil
+}
+
+// RemoveUser will remove the user from the set of unique users of the summary.
+// It returns whether or not the user was part of the summary.
+func (s *Summary) RemoveUser(u User) (bool, error) {
+	if s.Users == nil {
+		return false, nil
+	}
+
+	key, err := userHash(u)
+	if err != nil {
+		return false, errors.New(err, nil)
+	}
+
+	if _, ok := s.Users[key]; !ok {
+		return false, nil
+	}
+
+	delete(s.Users, key)
+
+	if s.GoalStats != nil {
+		for _, stats := range s.GoalStats.List {
+			delete(stats.Users, key)
+		}
+	}
+
+	return true, nil
+}
+
+func userHash(u User) (uint32, error) {
+	hasher := fnv.New32a()
+	_, err := hasher.Write([]byte(u.String()))
+	if err != nil {
+		return 0, err
+	}
+
+	return hasher.Sum32(), nil
+}
@@ -0,0 +1,52 @@
+package types
+
+import "time"
+
+// Timeline is the ordered activity of a user grouped into sessions
+type Timeline struct {
+	UserType string             `json:"userType"`
+	UserID   string             `json:"userID"`
+	Sessions []*TimelineSession `json:"sessions"`
+}
+
+// TimelineSession is a detected session within a timeline
+type TimelineSession struct {
+	Type         string         `json:"type"`
+	ID           string         `json:"id"`
+	Start        time.Time      `json:"start"`
+	End          time.Time      `json:"end"`
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// NewTimeline will group the (ordered) interactions of the user into their sessions.
+// Consecutive interactions that share a session id belong to the same timeline session.
+func NewTimeline(user User, interactions []*Interaction) *Timeline {
+	timeline := &Timeline{
+		UserType: user.Type,
+		UserID:   user.ID,
+		Sessions: make([]*TimelineSession, 0),
+	}
+
+	var current *TimelineSession
+	for _, i := range interactions {
+		session := i.Session()
+		if current == nil || current.ID != session.ID || current.Type != session.Type {
+			current = &TimelineSession{
+				Type:         session.Type,
+				ID:           session.ID,
+				Interactions: make([]*Interaction, 0),
+			}
+			if i.CreatedAt != nil {
+				current.Start = *i.CreatedAt
+			}
+			timeline.Sessions = append(timeline.Sessions, current)
+		}
+
+		current.Interactions = append(current.Interactions, i)
+		if i.CreatedAt != nil {
+			current.End = *i.CreatedAt
+		}
+	}
+
+	return timeline
+}
@@ -0,0 +1,296 @@
+package types
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JKhawaja/errors"
+)
+
+const (
+	/* epoch layouts */
+
+	// UnixSeconds is a timestamp layout for epoch seconds
+	UnixSeconds = "unix"
+	// UnixMilli is a timestamp layout for epoch milliseconds
+	UnixMilli = "unix_ms"
+	// UnixNano is a timestamp layout for epoch nanoseconds
+	UnixNano = "unix_ns"
+)
+
+var (
+	// TimestampLayouts is the ordered list of layouts that are tried when no
+	// layout has been declared for a timestamp. Only layouts that cannot be
+	// confused with one another are included (e.g. no "1/2/2006"), so the
+	// result of parsing a timestamp never depends on previously seen timestamps.
+	TimestampLayouts = []string{
+		time.RFC3339Nano,
+		time.RFC3339,
+		"2006-01-02T15:04:05.999999999Z0700",
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05.999999999 -0700 MST",
+		"2006-01-02 15:04:05.999999999Z07:00",
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02",
+		time.RFC1123Z,
+		time.RFC1123,
+		time.RFC850,
+		time.RFC822Z,
+		time.RFC822,
+		time.RubyDate,
+		time.UnixDate,
+		time.ANSIC,
+		"02/Jan/2006:15:04:05 -0700",
+	}
+)
+
+// TimestampFormat declares how the timestamp of an interaction should be parsed.
+// Layout can be any Go time layout or one of the epoch layouts (unix, unix_ms, unix_ns).
+// Timezone is an IANA timezone name used for layouts that do not carry a zone offset.
+type TimestampFormat struct {
+	Layout   string `json:"layout,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// TimestampSettings holds the system-wide timestamp parsing configuration
+type TimestampSettings struct {
+	Layout           string                      `json:"layout,omitempty"`
+	Timezone         string                      `json:"timezone,omitempty"`
+	MaxFutureMinutes int                         `json:"maxFutureMinutes"` // 0 disables the check
+	MaxPastDays      int                         `json:"maxPastDays"`      // 0 disables the check
+	Keys             map[string]*TimestampFormat `json:"keys,omitempty"`   // declared formats per api-key
+}
+
+// NewTimestampSettings --
+func NewTimestampSettings() *TimestampSettings {
+	return &TimestampSettings{
+		MaxFutureMinutes: 15,
+		Keys:             make(map[string]*TimestampFormat),
+	}
+}
+
+// Validate will return an error if a timezone of the settings (system-wide or
+// of an api-key) can not be loaded, or if a limit of the timestamps is negative.
+func (t *TimestampSettings) Validate() error {
+	if t.MaxFutureMinutes < 0 || t.MaxPastDays < 0 {
+		return errors.New(ErrTimestampSettings, map[string]interface{}{
+			"reason": "the limits of the timestamps can not be negative",
+		})
+	}
+
+	timezones := []string{t.Timezone}
+	for _, format := range t.Keys {
+		if format != nil {
+			timezones = append(timezones, format.Timezone)
+		}
+	}
+
+	for _, timezone := range timezones {
+		if timezone == "" {
+			continue
+		}
+
+		_, err := time.LoadLocation(timezone)
+		if err != nil {
+			return errors.New(ErrTimezone, map[string]interface{}{
+				"timezone": timezone,
+			})
+		}
+	}
+
+	return nil
+}
+
+// Format will return the timestamp format that applies to a request.
+// A format declared on the request takes precedence over the format
+// declared for the api-key, which takes precedence over the system default.
+// Empty fields fall through to the next level.
+func (t *TimestampSettings) Format(apiKey string, declared *TimestampFormat) *TimestampFormat {
+	format := &TimestampFormat{
+		Layout:   t.Layout,
+		Timezone: t.Timezone,
+	}
+
+	if key, ok := t.Keys[apiKey]; ok && key != nil {
+		if key.Layout != "" {
+			format.Layout = key.Layout
+		}
+		if key.Timezone != "" {
+			format.Timezone = key.Timezone
+		}
+	}
+
+	if declared != nil {
+		if declared.Layout != "" {
+			format.Layout = declared.Layout
+		}
+		if declared.Timezone != "" {
+			format.Timezone = declared.Timezone
+		}
+	}
+
+	return format
+}
+
+// Check will return an error if the timestamp lies outside of the
+// accepted window relative to the provided current time.
+func (t *TimestampSettings) Check(timestamp, now time.Time) error {
+	if t.MaxFutureMinutes > 0 {
+		limit := now.Add(time.Duration(t.MaxFutureMinutes) * time.Minute)
+		if timestamp.After(limit) {
+			return errors.New(ErrTimestampRange, map[string]interface{}{
+				"timestamp": timestamp,
+				"limit":     limit,
+			})
+		}
+	}
+
+	if t.MaxPastDays > 0 {
+		limit := now.AddDate(0, 0, -t.MaxPastDays)
+		if timestamp.Before(limit) {
+			return errors.New(ErrTimestampRange, map[string]interface{}{
+				"timestamp": timestamp,
+				"limit":     limit,
+			})
+		}
+	}
+
+	return nil
+}
+
+// ParseTimestamp will parse the timestamp string using the declared format.
+// If no layout is declared, then purely numeric timestamps are treated as epoch
+// values (the precision is inferred from the magnitude) and all other timestamps
+// are matched against the unambiguous TimestampLayouts.
+// The default location is used when neither the format nor the timestamp specify a zone.
+func ParseTimestamp(ts string, format *TimestampFormat, defaultLocation *time.Location) (time.Time, error) {
+	ts = strings.TrimSpace(ts)
+
+	location := defaultLocation
+	if format != nil && format.Timezone != "" {
+		loc, err := time.LoadLocation(format.Timezone)
+		if err != nil {
+			return time.Time{}, errors.New(ErrTimezone, map[string]interface{}{
+				"timezone": format.Timezone,
+			})
+		}
+		location = loc
+	}
+	if location == nil {
+		location = time.UTC
+	}
+
+	var layout string
+	if format != nil {
+		layout = format.Layout
+	}
+
+	switch layout {
+	case UnixSeconds, UnixMilli, UnixNano:
+		return parseEpoch(ts, layout, location)
+	case "":
+		if isNumeric(ts) {
+			return parseEpoch(ts, "", location)
+		}
+
+		for _, l := range TimestampLayouts {
+			timestamp, err := time.ParseInLocation(l, ts, location)
+			if err == nil {
+				return timestamp, nil
+			}
+		}
+
+		return time.Time{}, errors.New(ErrTimestamp, map[string]interface{}{
+			"timestamp": ts,
+		})
+	}
+
+	timestamp, err := time.ParseInLocation(layout, ts, location)
+	if err != nil {
+		return time.Time{}, errors.New(ErrTimestamp, map[string]interface{}{
+			"timestamp": ts,
+			"layout":    layout,
+		})
+	}
+
+	return timestamp, nil
+}
+
+// parseEpoch will parse an epoch timestamp. If no epoch layout is provided
+// then the precision is inferred from the number of integer digits:
+// up to 11 digits are seconds, up to 14 are milliseconds, up to 17 are microseconds
+// and anything longer is nanoseconds.
+func parseEpoch(ts, layout string, location *time.Location) (time.Time, error) {
+	integer, fraction := ts, ""
+	if idx := strings.Index(ts, "."); idx >= 0 {
+		integer, fraction = ts[:idx], ts[idx+1:]
+	}
+
+	n, err := strconv.ParseInt(integer, 10, 64)
+	if err != nil {
+		return time.Time{}, errors.New(ErrTimestamp, map[string]interface{}{
+			"timestamp": ts,
+			"layout":    layout,
+		})
+	}
+
+	if layout == "" {
+		digits := len(strings.TrimLeft(integer, "-"))
+		switch {
+		case digits <= 11:
+			layout = UnixSeconds
+		case digits <= 14:
+			layout = UnixMilli
+		case digits <= 17:
+			return time.Unix(0, n*int64(time.Microsecond)).In(location), nil
+		default:
+			layout = UnixNano
+		}
+	}
+
+	switch layout {
+	case UnixSeconds:
+		var nsec int64
+		if fraction != "" {
+			f, err := strconv.ParseFloat("0."+fraction, 64)
+			if err != nil {
+				return time.Time{}, errors.New(ErrTimestamp, map[string]interface{}{
+					"timestamp": ts,
+					"layout":    layout,
+				})
+			}
+			nsec = int64(f * float64(time.Second))
+		}
+		return time.Unix(n, nsec).In(location), nil
+	case UnixMilli:
+		return time.Unix(0, n*int64(time.Millisecond)).In(location), nil
+	default:
+		return time.Unix(0, n).In(location), nil
+	}
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	var dot bool
+	for idx, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			continue
+		case r == '-' && idx == 0 && len(s) > 1:
+			continue
+		case r == '.' && !dot:
+			dot = true
+		default:
+			return false
+		}
+	}
+
+	return true
+}
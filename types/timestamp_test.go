@@ -0,0 +1,310 @@
+package types
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("the timezone database is not available")
+	}
+
+	tests := []struct {
+		name     string
+		ts       string
+		format   *TimestampFormat
+		location *time.Location
+		want     time.Time
+		wantErr  bool
+	}{
+		{
+			name: "rfc3339",
+			ts:   "2026-10-18T15:30:00Z",
+			want: time.Date(2026, 10, 18, 15, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "rfc3339 with offset",
+			ts:   "2026-10-18T15:30:00+02:00",
+			want: time.Date(2026, 10, 18, 13, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "surrounding whitespace",
+			ts:   " 2026-10-18 ",
+			want: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "default location",
+			ts:       "2026-10-18 15:30:00",
+			location: newYork,
+			want:     time.Date(2026, 10, 18, 15, 30, 0, 0, newYork),
+		},
+		{
+			name:     "declared timezone takes precedence",
+			ts:       "2026-10-18 15:30:00",
+			format:   &TimestampFormat{Timezone: "America/New_York"},
+			location: time.UTC,
+			want:     time.Date(2026, 10, 18, 15, 30, 0, 0, newYork),
+		},
+		{
+			name: "epoch seconds",
+			ts:   "1792337400",
+			want: time.Unix(1792337400, 0),
+		},
+		{
+			name: "epoch seconds with a fraction",
+			ts:   "1792337400.5",
+			want: time.Unix(1792337400, int64(500*time.Millisecond)),
+		},
+		{
+			name: "epoch milliseconds",
+			ts:   "1792337400123",
+			want: time.Unix(1792337400, int64(123*time.Millisecond)),
+		},
+		{
+			name: "epoch microseconds",
+			ts:   "1792337400123456",
+			want: time.Unix(1792337400, int64(123456*time.Microsecond)),
+		},
+		{
+			name: "epoch nanoseconds",
+			ts:   "1792337400123456789",
+			want: time.Unix(1792337400, 123456789),
+		},
+		{
+			name:   "declared epoch layout",
+			ts:     "1792337400",
+			format: &TimestampFormat{Layout: UnixMilli},
+			want:   time.Unix(1792337, int64(400*time.Millisecond)),
+		},
+		{
+			name:   "declared layout",
+			ts:     "1/2/2006",
+			format: &TimestampFormat{Layout: "2/1/2006"},
+			want:   time.Date(2006, 2, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "ambiguous layouts are never guessed",
+			ts:      "1/2/2006",
+			wantErr: true,
+		},
+		{
+			name:    "declared layout mismatch",
+			ts:      "2026-10-18",
+			format:  &TimestampFormat{Layout: time.RFC1123},
+			wantErr: true,
+		},
+		{
+			name:    "unknown timezone",
+			ts:      "2026-10-18",
+			format:  &TimestampFormat{Timezone: "Mars/Olympus_Mons"},
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			ts:      "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTimestamp(tt.ts, tt.format, tt.location)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseTimestamp(%q) = %v, want an error", tt.ts, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTimestamp(%q) error = %v", tt.ts, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseTimestamp(%q) = %v, want %v", tt.ts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimestampSettingsCheck(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		settings  *TimestampSettings
+		timestamp time.Time
+		wantErr   bool
+	}{
+		{
+			name:      "defaults accept a backfill",
+			settings:  NewTimestampSettings(),
+			timestamp: now.AddDate(-1, 0, 0),
+		},
+		{
+			name:      "defaults accept the future limit",
+			settings:  NewTimestampSettings(),
+			timestamp: now.Add(15 * time.Minute),
+		},
+		{
+			name:      "defaults reject beyond the future limit",
+			settings:  NewTimestampSettings(),
+			timestamp: now.Add(16 * time.Minute),
+			wantErr:   true,
+		},
+		{
+			name:      "past limit",
+			settings:  &TimestampSettings{MaxPastDays: 7},
+			timestamp: now.AddDate(0, 0, -8),
+			wantErr:   true,
+		},
+		{
+			name:      "within the past limit",
+			settings:  &TimestampSettings{MaxPastDays: 7},
+			timestamp: now.AddDate(0, 0, -6),
+		},
+		{
+			name:      "disabled checks",
+			settings:  &TimestampSettings{},
+			timestamp: now.AddDate(1, 0, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.settings.Check(tt.timestamp, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check(%v) error = %v, wantErr %v", tt.timestamp, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTimestampSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings *TimestampSettings
+		wantErr  error
+	}{
+		{
+			name:     "defaults",
+			settings: NewTimestampSettings(),
+		},
+		{
+			name: "timezones",
+			settings: &TimestampSettings{
+				Timezone: "Europe/Berlin",
+				Keys: map[string]*TimestampFormat{
+					"key-1": {Timezone: "America/New_York"},
+					"key-2": {Layout: UnixMilli},
+					"key-3": nil,
+				},
+			},
+		},
+		{
+			name:     "unknown timezone",
+			settings: &TimestampSettings{Timezone: "Mars/Olympus"},
+			wantErr:  ErrTimezone,
+		},
+		{
+			name: "unknown timezone of an api-key",
+			settings: &TimestampSettings{
+				Keys: map[string]*TimestampFormat{
+					"key-1": {Timezone: "Mars/Olympus"},
+				},
+			},
+			wantErr: ErrTimezone,
+		},
+		{
+			name:     "negative future limit",
+			settings: &TimestampSettings{MaxFutureMinutes: -1},
+			wantErr:  ErrTimestampSettings,
+		},
+		{
+			name:     "negative past limit",
+			settings: &TimestampSettings{MaxPastDays: -1},
+			wantErr:  ErrTimestampSettings,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.settings.Validate()
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSettingsTimestampsGob(t *testing.T) {
+	// the settings written before the timestamp settings existed
+	var legacy bytes.Buffer
+	err := gob.NewEncoder(&legacy).Encode(struct {
+		StatsToggles        *StatsToggles
+		InteractionsStorage bool
+	}{
+		StatsToggles:        NewStatsToggles(),
+		InteractionsStorage: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	disabled := NewSettings()
+	disabled.Timestamps = &TimestampSettings{}
+	disabledData, err := disabled.GobEncode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backfill := NewSettings()
+	backfill.Timestamps = &TimestampSettings{MaxPastDays: 365}
+	backfillData, err := backfill.GobEncode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		wantFuture int
+		wantPast   int
+	}{
+		{
+			name:       "older versions get the defaults",
+			data:       legacy.Bytes(),
+			wantFuture: NewTimestampSettings().MaxFutureMinutes,
+			wantPast:   NewTimestampSettings().MaxPastDays,
+		},
+		{
+			name: "disabled checks stay disabled",
+			data: disabledData,
+		},
+		{
+			name:     "limits are kept",
+			data:     backfillData,
+			wantPast: 365,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Settings{}
+			err := s.GobDecode(tt.data)
+			if err != nil {
+				t.Fatalf("GobDecode() error = %v", err)
+			}
+			if s.Timestamps.MaxFutureMinutes != tt.wantFuture || s.Timestamps.MaxPastDays != tt.wantPast {
+				t.Errorf("GobDecode() limits = (%d, %d), want (%d, %d)",
+					s.Timestamps.MaxFutureMinutes, s.Timestamps.MaxPastDays, tt.wantFuture, tt.wantPast)
+			}
+			if s.Timestamps.Keys == nil {
+				t.Error("GobDecode() keys = nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,438 @@
+package types
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/JKhawaja/errors"
+)
+
+const (
+	/* user profile sort keys */
+
+	// SortFirstSeen is a user profile sort key
+	SortFirstSeen = "firstSeen"
+	// SortLastSeen is a user profile sort key
+	SortLastSeen = "lastSeen"
+	// SortInteractions is a user profile sort key
+	SortInteractions = "interactions"
+	// SortSessions is a user profile sort key
+	SortSessions = "sessions"
+	// SortConversions is a user profile sort key
+	SortConversions = "conversions"
+	// SortRevenue is a user profile sort key
+	SortRevenue = "revenue"
+)
+
+// UserProfile holds the lifetime metrics and traits of a (canonical) user
+type UserProfile struct {
+	ID            string                 `json:"id"`
+	UserType      string                 `json:"userType"`
+	UserID        string                 `json:"userID"`
+	FirstSeen     time.Time              `json:"firstSeen"`
+	LastSeen      time.Time              `json:"lastSeen"`
+	Interactions  int64                  `json:"interactions"`
+	Sessions      int64                  `json:"sessions"`
+	Conversions   int64                  `json:"conversions"`
+	Revenue       float64                `json:"revenue"`
+	Goals         []*GoalCount           `json:"goals,omitempty"`
+	Origin        *Origin                `json:"acquisitionOrigin,omitempty"`
+	Devices       []Device               `json:"devices"`
+	Traits        map[string]interface{} `json:"traits,omitempty"`
+	LastSessionID string                 `json:"-"`
+}
+
+// UserProfileListView --
+type UserProfileListView struct {
+	ID           string    `json:"id"`
+	UserType     string    `json:"userType"`
+	UserID       string    `json:"userID"`
+	FirstSeen    time.Time `json:"firstSeen"`
+	LastSeen     time.Time `json:"lastSeen"`
+	Interactions int64     `json:"interactions"`
+	Sessions     int64     `json:"sessions"`
+	Conversions  int64     `json:"conversions"`
+	Revenue      float64   `json:"revenue"`
+}
+
+// UserProfiles holds all of the user profiles
+type UserProfiles struct {
+	List    map[string]*UserProfile // user string -> profile
+	index   map[string]string       // profile id -> user string
+	updated map[string]*UserProfile
+	flushed map[string]*UserProfile // written by a flush that is not committed yet
+	deleted map[string]*UserProfile
+	removed map[string]*UserProfile // deleted by a flush that is not committed yet
+	*sync.Mutex
+}
+
+// NewUserProfiles --
+func NewUserProfiles() *UserProfiles {
+	return &UserProfiles{
+		List:    make(map[string]*UserProfile),
+		index:   make(map[string]string),
+		updated: make(map[string]*UserProfile),
+		flushed: make(map[string]*UserProfile),
+		deleted: make(map[string]*UserProfile),
+		removed: make(map[string]*UserProfile),
+		Mutex:   &sync.Mutex{},
+	}
+}
+
+// UserProfileID will return the id of the profile for a user
+func UserProfileID(u User) string {
+	return IdentityID(UserKey(u))
+}
+
+// NewUserProfile --
+func NewUserProfile(u User, seen time.Time) *UserProfile {
+	return &UserProfile{
+		ID:        UserProfileID(u),
+		UserType:  u.Type,
+		UserID:    u.ID,
+		FirstSeen: seen,
+		LastSeen:  seen,
+		Devices:   make([]Device, 0),
+		Traits:    make(map[string]interface{}),
+	}
+}
+
+// User --
+func (p *UserProfile) User() User {
+	return User{
+		Type: p.UserType,
+		ID:   p.UserID,
+	}
+}
+
+// ListView --
+func (p *UserProfile) ListView() *UserProfileListView {
+	return &UserProfileListView{
+		ID:           p.ID,
+		UserType:     p.UserType,
+		UserID:       p.UserID,
+		FirstSeen:    p.FirstSeen,
+		LastSeen:     p.LastSeen,
+		Interactions: p.Interactions,
+		Sessions:     p.Sessions,
+		Conversions:  p.Conversions,
+		Revenue:      p.Revenue,
+	}
+}
+
+// Apply will update the profile with the interaction of the event.
+func (p *UserProfile) Apply(event *Event) {
+	i := event.Interaction
+
+	p.Interactions++
+
+	if i.CreatedAt.Before(p.FirstSeen) {
+		p.FirstSeen = *i.CreatedAt
+	}
+
+	if i.CreatedAt.After(p.LastSeen) {
+		p.LastSeen = *i.CreatedAt
+	}
+
+	if p.Origin == nil && (i.OriginType != nil || i.OriginID != nil) {
+		p.Origin = i.Origin()
+	}
+
+	if event.Session != nil && event.Session.ID != p.LastSessionID {
+		p.Sessions++
+		p.LastSessionID = event.Session.ID
+	}
+
+	if i.DeviceID != nil || i.DeviceType != nil {
+		p.addDevice(i.Device())
+	}
+
+	if goal := i.Goal(); goal != nil {
+		if goal.Positive() {
+			p.Conversions++
+		}
+
+		value, _ := goal.Value(i)
+		p.Revenue += value
+		p.Goals = addGoal(p.Goals, goal.Action, 1, value)
+	}
+}
+
+func (p *UserProfile) addDevice(device Device) {
+	for _, d := range p.Devices {
+		if d == device {
+			return
+		}
+	}
+
+	p.Devices = append(p.Devices, device)
+}
+
+// SetTraits will set (or overwrite) the traits of the profile
+func (p *UserProfile) SetTraits(traits map[string]interface{}) {
+	if p.Traits == nil {
+		p.Traits = make(map[string]interface{})
+	}
+
+	for key, value := range traits {
+		p.Traits[key] = value
+	}
+}
+
+// Merge will combine the profile of another user into this profile
+func (p *UserProfile) Merge(other *UserProfile) {
+	p.Interactions += other.Interactions
+	p.Sessions += other.Sessions
+	p.Conversions += other.Conversions
+	p.Revenue += other.Revenue
+	for _, g := range other.Goals {
+		p.Goals = addGoal(p.Goals, g.Goal, g.Conversions, g.Value)
+	}
+
+	if other.FirstSeen.Before(p.FirstSeen) {
+		p.FirstSeen = other.FirstSeen
+		if other.Origin != nil {
+			p.Origin = other.Origin
+		}
+	}
+
+	if other.LastSeen.After(p.LastSeen) {
+		p.LastSeen = other.LastSeen
+	}
+
+	if p.Origin == nil {
+		p.Origin = other.Origin
+	}
+
+	for _, device := range other.Devices {
+		p.addDevice(device)
+	}
+
+	for key, value := range other.Traits {
+		if _, ok := p.Traits[key]; !ok {
+			p.SetTraits(map[string]interface{}{key: value})
+		}
+	}
+}
+
+// Apply will create or update the profile of the interaction user
+func (u *UserProfiles) Apply(event *Event) {
+	u.Lock()
+	defer u.Unlock()
+
+	user := event.Interaction.User()
+	profile, ok := u.List[user.String()]
+	if !ok {
+		profile = NewUserProfile(user, *event.Interaction.CreatedAt)
+		u.set(profile)
+	}
+
+	profile.Apply(event)
+	u.updated[profile.ID] = profile
+}
+
+// Identify will set the traits on the profile of the user.
+// A new profile is created if the user has not been seen yet.
+func (u *UserProfiles) Identify(user User, traits map[string]interface{}) {
+	u.Lock()
+	defer u.Unlock()
+
+	profile, ok := u.List[user.String()]
+	if !ok {
+		profile = NewUserProfile(user, time.Now().UTC())
+		u.set(profile)
+	}
+
+	profile.SetTraits(traits)
+	u.updated[profile.ID] = profile
+}
+
+// Merge will merge the profile of the `from` user into the profile
+// of the `to` user. The profile of the `from` user is removed.
+func (u *UserProfiles) Merge(from, to User) {
+	u.Lock()
+	defer u.Unlock()
+
+	fromProfile, ok := u.List[from.String()]
+	if !ok {
+		return
+	}
+
+	toProfile, ok := u.List[to.String()]
+	if !ok {
+		toProfile = NewUserProfile(to, fromProfile.FirstSeen)
+		u.set(toProfile)
+	}
+
+	toProfile.Merge(fromProfile)
+	u.updated[toProfile.ID] = toProfile
+
+	delete(u.List, from.String())
+	delete(u.index, fromProfile.ID)
+	delete(u.updated, fromProfile.ID)
+	u.deleted[fromProfile.ID] = fromProfile
+}
+
+// Remove will remove the profile of the user.
+// It returns whether or not the user had a profile.
+func (u *UserProfiles) Remove(user User) bool {
+	u.Lock()
+	defer u.Unlock()
+
+	profile, ok := u.List[user.String()]
+	if !ok {
+		return false
+	}
+
+	delete(u.List, user.String())
+	delete(u.index, profile.ID)
+	delete(u.updated, profile.ID)
+	u.deleted[profile.ID] = profile
+
+	return true
+}
+
+// Profile will return the profile of the user
+func (u *UserProfiles) Profile(user User) (*UserProfile, bool) {
+	u.Lock()
+	defer u.Unlock()
+
+	profile, ok := u.List[user.String()]
+	return profile, ok
+}
+
+// Set will load a profile
+func (u *UserProfiles) Set(profile *UserProfile) {
+	u.Lock()
+	defer u.Unlock()
+
+	u.set(profile)
+}
+
+func (u *UserProfiles) set(profile *UserProfile) {
+	user := profile.User().String()
+	u.List[user] = profile
+	u.index[profile.ID] = user
+}
+
+// Get will return the profile by its id
+func (u *UserProfiles) Get(id string) (*UserProfile, error) {
+	u.Lock()
+	defer u.Unlock()
+
+	user, ok := u.index[id]
+	if !ok {
+		return nil, ErrDNE
+	}
+
+	return u.List[user], nil
+}
+
+// Sorted will return all of the profiles sorted by the sort key.
+func (u *UserProfiles) Sorted(sortKey string, descending bool) ([]*UserProfile, error) {
+	u.Lock()
+	list := make([]*UserProfile, 0, len(u.List))
+	for _, profile := range u.List {
+		list = append(list, profile)
+	}
+	u.Unlock()
+
+	var less func(a, b *UserProfile) bool
+	switch sortKey {
+	case SortFirstSeen:
+		less = func(a, b *UserProfile) bool { return a.FirstSeen.Before(b.FirstSeen) }
+	case SortLastSeen, "":
+		less = func(a, b *UserProfile) bool { return a.LastSeen.Before(b.LastSeen) }
+	case SortInteractions:
+		less = func(a, b *UserProfile) bool { return a.Interactions < b.Interactions }
+	case SortSessions:
+		less = func(a, b *UserProfile) bool { return a.Sessions < b.Sessions }
+	case SortConversions:
+		less = func(a, b *UserProfile) bool { return a.Conversions < b.Conversions }
+	case SortRevenue:
+		less = func(a, b *UserProfile) bool { return a.Revenue < b.Revenue }
+	default:
+		return nil, errors.New(ErrSortKey, map[string]interface{}{
+			"sort": sortKey,
+		})
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		if descending {
+			return less(list[j], list[i])
+		}
+		return less(list[i], list[j])
+	})
+
+	return list, nil
+}
+
+// Update --
+func (u *UserProfiles) Update(updateFunc func(object interface{}) error) error {
+	u.Lock()
+	defer u.Unlock()
+
+	for id, profile := range u.updated {
+		err := updateFunc(profile)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+		u.flushed[id] = profile
+		delete(u.updated, id)
+	}
+
+	return nil
+}
+
+// Deleted will call the delete function on every profile that
+// has been removed (merged into another profile).
+func (u *UserProfiles) Deleted(deleteFunc func(object interface{}) error) error {
+	u.Lock()
+	defer u.Unlock()
+
+	for id, profile := range u.deleted {
+		err := deleteFunc(profile)
+		if err != nil {
+			return errors.New(err, nil)
+		}
+		u.removed[id] = profile
+		delete(u.deleted, id)
+	}
+
+	return nil
+}
+
+// Committed will forget the documents of the flush once it is committed
+func (u *UserProfiles) Committed() {
+	u.Lock()
+	defer u.Unlock()
+
+	u.flushed = make(map[string]*UserProfile)
+	u.removed = make(map[string]*UserProfile)
+}
+
+// Rollback will mark the documents of a flush that was not committed
+// as updated again, so that they are written by the next flush
+func (u *UserProfiles) Rollback() {
+	u.Lock()
+	defer u.Unlock()
+
+	for id, profile := range u.flushed {
+		u.updated[id] = profile
+	}
+	u.flushed = make(map[string]*UserProfile)
+
+	// the deletes, unless the documents were updated since
+	for id, profile := range u.removed {
+		if _, ok := u.updated[id]; !ok {
+			u.deleted[id] = profile
+		}
+	}
+	u.removed = make(map[string]*UserProfile)
+}
+
+// Len --
+func (u *UserProfiles) Len() int {
+	return len(u.List)
+}
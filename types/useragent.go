@@ -0,0 +1,190 @@
+package types
+
+import (
+	_ "embed"
+	"regexp"
+	"strings"
+)
+
+const (
+	/* device classes */
+
+	// DeviceDesktop is a device class
+	DeviceDesktop = "desktop"
+	// DeviceMobile is a device class
+	DeviceMobile = "mobile"
+	// DeviceTablet is a device class
+	DeviceTablet = "tablet"
+	// DeviceTV is a device class
+	DeviceTV = "tv"
+	// DeviceConsole is a device class
+	DeviceConsole = "console"
+	// DeviceBot is a device class
+	DeviceBot = "bot"
+	// DeviceOther is a device class
+	DeviceOther = "other"
+)
+
+var (
+	//go:embed useragents.txt
+	userAgentRulesFile string
+
+	userAgentRules = parseUserAgentRules(userAgentRulesFile)
+)
+
+// UserAgentSettings configures the parsing of the request's user agent into
+// the browser, browserVersion, os, osVersion, deviceClass, mobile and bot properties.
+type UserAgentSettings struct {
+	Enabled  bool `json:"enabled"`
+	Override bool `json:"override"` // replace the properties sent by the client
+}
+
+// UserAgent is a parsed user agent
+type UserAgent struct {
+	Browser        string
+	BrowserVersion string
+	OS             string
+	OSVersion      string
+	Device         string
+	Bot            bool
+}
+
+type userAgentRule struct {
+	kind    string
+	name    string
+	pattern *regexp.Regexp
+}
+
+// NewUserAgentSettings --
+func NewUserAgentSettings() *UserAgentSettings {
+	return &UserAgentSettings{}
+}
+
+func parseUserAgentRules(file string) []*userAgentRule {
+	rules := make([]*userAgentRule, 0)
+	for _, line := range strings.Split(file, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		rules = append(rules, &userAgentRule{
+			kind:    fields[0],
+			name:    fields[1],
+			pattern: regexp.MustCompile("(?i)" + fields[2]),
+		})
+	}
+
+	return rules
+}
+
+// ParseUserAgent will parse the user agent with the embedded rules.
+// Unknown browsers and operating systems are reported as "other".
+func ParseUserAgent(userAgent string) *UserAgent {
+	ua := &UserAgent{
+		Browser: DeviceOther,
+		OS:      DeviceOther,
+	}
+
+	var hasBrowser, hasOS, hasDevice bool
+	for _, rule := range userAgentRules {
+		switch {
+		case rule.kind == "browser" && !hasBrowser,
+			rule.kind == "os" && !hasOS,
+			rule.kind == "device" && !hasDevice:
+		default:
+			continue
+		}
+
+		match := rule.pattern.FindStringSubmatch(userAgent)
+		if match == nil {
+			continue
+		}
+
+		var version string
+		if len(match) > 1 {
+			version = match[1]
+		}
+
+		switch rule.kind {
+		case "browser":
+			ua.Browser, ua.BrowserVersion, hasBrowser = rule.name, version, true
+		case "os":
+			ua.OS, ua.OSVersion, hasOS = rule.name, version, true
+		case "device":
+			ua.Device, hasDevice = rule.name, true
+		}
+	}
+
+	ua.Bot = BotUserAgent(userAgent)
+	switch {
+	case ua.Bot:
+		ua.Device = DeviceBot
+	case hasDevice:
+	case hasOS:
+		ua.Device = DeviceDesktop
+	default:
+		ua.Device = DeviceOther
+	}
+
+	return ua
+}
+
+// Properties will return the user agent as interaction properties
+func (u *UserAgent) Properties() map[string]interface{} {
+	properties := map[string]interface{}{
+		"browser":     u.Browser,
+		"os":          u.OS,
+		"deviceClass": u.Device,
+		"mobile":      boolString(u.Device == DeviceMobile),
+		"bot":         boolString(u.Bot),
+	}
+
+	if u.BrowserVersion != "" {
+		properties["browserVersion"] = u.BrowserVersion
+	}
+
+	if u.OSVersion != "" {
+		properties["osVersion"] = u.OSVersion
+	}
+
+	return properties
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+
+	return "false"
+}
+
+// Enrich will add the parsed user agent of the request to the properties
+// of the interaction, and set the device type (if it is not set) to the device class.
+func (u *UserAgentSettings) Enrich(i *Interaction) {
+	if !u.Enabled || i.UserAgent == "" {
+		return
+	}
+
+	ua := ParseUserAgent(i.UserAgent)
+
+	if i.Properties == nil {
+		i.Properties = make(map[string]interface{})
+	}
+	for key, value := range ua.Properties() {
+		if _, ok := i.Properties[key]; ok && !u.Override {
+			continue
+		}
+		i.Properties[key] = value
+	}
+
+	if i.DeviceType == nil || *i.DeviceType == "" {
+		deviceType := ua.Device
+		i.DeviceType = &deviceType
+	}
+}